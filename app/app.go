@@ -0,0 +1,132 @@
+package app
+
+import (
+	"github.com/bellaananda/go-postgresql-blog-http.git/content"
+	"github.com/bellaananda/go-postgresql-blog-http.git/mail"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+	"github.com/bellaananda/go-postgresql-blog-http.git/webhook"
+
+	"gorm.io/gorm"
+)
+
+// App is the application container: it owns the services the handlers are
+// built from, so the router never touches concrete implementations.
+type App struct {
+	UserService          service.UserService
+	PostService          service.PostService
+	CommentService       service.CommentService
+	TrashService         service.TrashService
+	MediaService         service.MediaService
+	ReactionService      service.ReactionService
+	BookmarkService      service.BookmarkService
+	FollowService        service.FollowService
+	SyndicationService   service.SyndicationService
+	SitemapService       service.SitemapService
+	ViewService          service.ViewService
+	NotificationService  service.NotificationService
+	MailService          service.MailService
+	WebhookService       service.WebhookService
+	CommentStreamService service.CommentStreamService
+}
+
+// Config holds what the services need besides the database.
+type Config struct {
+	Blobs           storage.BlobStore
+	DeletePolicies  service.DeletePolicies
+	ThumbnailWidths []int
+	Site            service.Site
+	Robots          service.Robots
+	Mailer          mail.Mailer
+	MailTemplates   *mail.Templates
+}
+
+// ConfigFromEnv builds the Config from environment variables.
+func ConfigFromEnv() (Config, error) {
+	blobs, err := storage.NewBlobStoreFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+
+	policies, err := service.DeletePoliciesFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+
+	widths, err := service.ThumbnailWidthsFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+
+	site, err := service.SiteFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+
+	robots, err := service.RobotsFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+
+	mailer, err := mail.NewMailerFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+
+	templates, err := mail.TemplatesFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return Config{
+		Blobs:           blobs,
+		DeletePolicies:  policies,
+		ThumbnailWidths: widths,
+		Site:            site,
+		Robots:          robots,
+		Mailer:          mailer,
+		MailTemplates:   templates,
+	}, nil
+}
+
+// New wires the GORM repositories and default services together.
+func New(db *gorm.DB, config Config) *App {
+	userRepository := repository.NewUserRepository(db)
+	postRepository := repository.NewPostRepository(db)
+	commentRepository := repository.NewCommentRepository(db)
+	mediaRepository := repository.NewMediaRepository(db)
+	reactionRepository := repository.NewReactionRepository(db)
+	bookmarkRepository := repository.NewBookmarkRepository(db)
+	followRepository := repository.NewFollowRepository(db)
+	viewRepository := repository.NewViewRepository(db)
+	notificationRepository := repository.NewNotificationRepository(db)
+	webhookRepository := repository.NewWebhookRepository(db)
+	commentEventRepository := repository.NewCommentEventRepository(db)
+
+	// rendered HTML is shared by posts and comments
+	html := content.NewCache(1000)
+	mediaService := service.NewMediaService(mediaRepository, postRepository, config.Blobs, config.ThumbnailWidths)
+	mailService := service.NewMailService(config.Mailer, config.MailTemplates, userRepository, postRepository, commentRepository, config.Site)
+	notificationService := service.NewNotificationService(notificationRepository, userRepository, mailService)
+	webhookService := service.NewWebhookService(webhookRepository, webhook.NewClient(service.WebhookTimeout))
+	streamService := service.NewCommentStreamService(commentEventRepository, postRepository)
+
+	return &App{
+		UserService:          service.NewUserService(userRepository, postRepository, commentRepository, config.DeletePolicies, mailService, webhookService, streamService),
+		PostService:          service.NewPostService(postRepository, userRepository, commentRepository, bookmarkRepository, followRepository, mediaService, config.Blobs, config.DeletePolicies, webhookService, streamService, html),
+		CommentService:       service.NewCommentService(commentRepository, postRepository, userRepository, notificationService, webhookService, streamService, html),
+		TrashService:         service.NewTrashService(userRepository, postRepository, commentRepository, mediaRepository, reactionRepository, config.Blobs, config.DeletePolicies, webhookService, streamService),
+		MediaService:         mediaService,
+		ReactionService:      service.NewReactionService(reactionRepository, postRepository, commentRepository, userRepository, notificationService),
+		BookmarkService:      service.NewBookmarkService(bookmarkRepository, postRepository, userRepository, mediaService),
+		FollowService:        service.NewFollowService(followRepository, userRepository, notificationService),
+		SyndicationService:   service.NewSyndicationService(postRepository, userRepository, config.Site, html),
+		SitemapService:       service.NewSitemapService(postRepository, config.Site, config.Robots),
+		ViewService:          service.NewViewService(viewRepository),
+		NotificationService:  notificationService,
+		MailService:          mailService,
+		WebhookService:       webhookService,
+		CommentStreamService: streamService,
+	}
+}
@@ -0,0 +1,77 @@
+package content
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache remembers rendered HTML per row, so a row is only rendered again
+// once it has changed. Entries carry the row version they were rendered
+// from: a lookup for any other version misses. Once full, the least recently
+// used entry makes room.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	version uint
+	html    string
+}
+
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Render returns the HTML for a row at version, rendering and caching it
+// on a miss.
+func (cache *Cache) Render(key string, version uint, format string, source string) (string, error) {
+	cache.mu.Lock()
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if entry.version == version {
+			cache.order.MoveToFront(element)
+			cache.mu.Unlock()
+			return entry.html, nil
+		}
+	}
+	cache.mu.Unlock()
+
+	rendered, err := Render(format, source)
+	if err != nil {
+		return "", err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		cache.order.Remove(element)
+	}
+	cache.entries[key] = cache.order.PushFront(&cacheEntry{key: key, version: version, html: rendered})
+	for cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).key)
+	}
+
+	return rendered, nil
+}
+
+// Invalidate drops the entry for a row that was updated or deleted.
+func (cache *Cache) Invalidate(key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+	}
+}
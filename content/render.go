@@ -0,0 +1,103 @@
+// Package content turns the text of posts and comments into HTML that is
+// safe to put on a page, whatever format it was written in.
+package content
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// Formats content can be written in.
+const (
+	Markdown = "markdown" // CommonMark
+	HTML     = "html"
+	Plain    = "plain"
+)
+
+// DefaultFormat is used when a writer does not say.
+const DefaultFormat = Markdown
+
+var ErrUnknownFormat = errors.New("unknown content format")
+
+// ValidFormat reports whether format is one of Markdown, HTML or Plain.
+func ValidFormat(format string) error {
+	switch format {
+	case Markdown, HTML, Plain:
+		return nil
+	}
+	return fmt.Errorf("%w %q, want markdown, html or plain", ErrUnknownFormat, format)
+}
+
+// markdown renders CommonMark. Raw HTML in the source is left out of the
+// output; whatever gets through is sanitized anyway.
+var markdown = goldmark.New()
+
+// sanitizer allows text formatting, lists, quotes, code, tables, links and
+// images, and nothing else: no scripts, styles, event handlers, iframes or
+// forms. Links and images must be http, https or mailto URLs, and links get
+// rel="nofollow".
+var sanitizer = newSanitizer()
+
+func newSanitizer() *bluemonday.Policy {
+	policy := bluemonday.NewPolicy()
+	policy.AllowElements(
+		"p", "br", "hr",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+		"em", "strong", "del", "blockquote", "pre", "code",
+		"ul", "ol", "li",
+		"table", "thead", "tbody", "tr", "th", "td",
+	)
+	policy.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+
+	policy.AllowAttrs("href", "title").OnElements("a")
+	policy.AllowAttrs("src", "alt", "title").OnElements("img")
+	policy.AllowURLSchemes("http", "https", "mailto")
+	policy.AllowRelativeURLs(true)
+	policy.RequireParseableURLs(true)
+	policy.RequireNoFollowOnLinks(true)
+
+	return policy
+}
+
+// Render converts source written in format to sanitized HTML.
+func Render(format string, source string) (string, error) {
+	switch format {
+	case Markdown:
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(source), &buf); err != nil {
+			return "", err
+		}
+		return sanitizer.Sanitize(buf.String()), nil
+	case HTML:
+		return sanitizer.Sanitize(source), nil
+	case Plain:
+		return renderPlain(source), nil
+	}
+	return "", ValidFormat(format)
+}
+
+// renderPlain escapes the text, turning blank lines into paragraphs and
+// single line breaks into <br>.
+func renderPlain(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+
+	var out strings.Builder
+	for _, paragraph := range strings.Split(source, "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if paragraph == "" {
+			continue
+		}
+		out.WriteString("<p>")
+		out.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		out.WriteString("</p>\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,130 @@
+package content
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestRenderSanitizes feeds hostile sources through each format and checks
+// that nothing able to run script reaches the output, while the harmless
+// parts around it survive.
+func TestRenderSanitizes(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		format  string
+		source  string
+		absent  []string
+		present []string
+	}{
+		{
+			name:    "markdown raw script",
+			format:  Markdown,
+			source:  "Hello\n\n<script>alert(1)</script>\n\nworld",
+			absent:  []string{"<script", "alert(1)"},
+			present: []string{"<p>Hello</p>", "<p>world</p>"},
+		},
+		{
+			name:    "markdown inline raw html",
+			format:  Markdown,
+			source:  "a <b onmouseover=\"alert(1)\">bold</b> <iframe src=\"https://evil.example\"></iframe> move",
+			absent:  []string{"<b", "onmouseover", "<iframe", "evil.example"},
+			present: []string{"move"},
+		},
+		{
+			name:   "markdown raw html block",
+			format: Markdown,
+			source: "<div style=\"position:fixed\"><form action=\"https://evil.example\"><input name=\"password\"></form></div>",
+			absent: []string{"<div", "style", "<form", "<input"},
+		},
+		{
+			name:    "markdown javascript link",
+			format:  Markdown,
+			source:  "[click](javascript:alert(1))",
+			absent:  []string{"javascript:", "href"},
+			present: []string{"click"},
+		},
+		{
+			name:    "markdown javascript link with entities",
+			format:  Markdown,
+			source:  "[click](&#x6a;avascript:alert(1))",
+			absent:  []string{"avascript:", "href"},
+			present: []string{"click"},
+		},
+		{
+			name:   "markdown data image",
+			format: Markdown,
+			source: "![x](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)",
+			absent: []string{"data:", "src"},
+		},
+		{
+			name:    "markdown safe link",
+			format:  Markdown,
+			source:  "[site](https://example.com \"Example\")",
+			present: []string{`<a href="https://example.com" title="Example" rel="nofollow">site</a>`},
+		},
+		{
+			name:    "markdown code keeps its language",
+			format:  Markdown,
+			source:  "```go\nfmt.Println(\"<script>\")\n```",
+			absent:  []string{"<script>"},
+			present: []string{`<code class="language-go">`, "&lt;script&gt;"},
+		},
+		{
+			name:    "html event handlers",
+			format:  HTML,
+			source:  `<p onclick="alert(1)">hi</p><img src="https://example.com/a.png" onerror="alert(1)">`,
+			absent:  []string{"onclick", "onerror", "alert"},
+			present: []string{"<p>hi</p>", `<img src="https://example.com/a.png">`},
+		},
+		{
+			name:    "html script and style",
+			format:  HTML,
+			source:  `<style>body{display:none}</style><script>alert(1)</script><p>kept</p>`,
+			absent:  []string{"<style", "display", "<script", "alert"},
+			present: []string{"<p>kept</p>"},
+		},
+		{
+			name:   "html javascript link",
+			format: HTML,
+			source: `<a href="JaVaScRiPt:alert(1)">x</a>`,
+			absent: []string{"alert", "href"},
+		},
+		{
+			name:   "html code class",
+			format: HTML,
+			source: `<code class="language-go x" style="color:red">x</code>`,
+			absent: []string{"class", "style"},
+		},
+		{
+			name:    "plain escapes everything",
+			format:  Plain,
+			source:  "<script>alert(1)</script>\n<a href=\"javascript:x\">",
+			absent:  []string{"<script", "<a "},
+			present: []string{"&lt;script&gt;", "<br>"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := Render(tt.format, tt.source)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(strings.ToLower(rendered), strings.ToLower(s)) {
+					t.Errorf("rendered %q contains %q", rendered, s)
+				}
+			}
+			for _, s := range tt.present {
+				if !strings.Contains(rendered, s) {
+					t.Errorf("rendered %q is missing %q", rendered, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	if _, err := Render("rtf", "x"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Render err = %v, want ErrUnknownFormat", err)
+	}
+}
@@ -0,0 +1,92 @@
+package content
+
+import (
+	"html"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// ExcerptLength is the most characters an excerpt taken from the content
+// runs to, not counting the ellipsis.
+const ExcerptLength = 280
+
+// WordsPerMinute is the reading speed reading times are estimated at.
+const WordsPerMinute = 200
+
+// Summary describes content for list views that leave the content out.
+type Summary struct {
+	Excerpt        string
+	WordCount      int
+	ReadingMinutes int
+}
+
+// blocksOnly drops the inline tags of rendered HTML without leaving a space,
+// so that "*word*," stays one word followed by its comma.
+var blocksOnly = bluemonday.NewPolicy().AllowElements(
+	"p", "br", "hr",
+	"h1", "h2", "h3", "h4", "h5", "h6",
+	"blockquote", "pre",
+	"ul", "ol", "li",
+	"table", "thead", "tbody", "tr", "th", "td",
+)
+
+// textOnly keeps the text of rendered HTML and drops every tag, leaving a
+// space where a tag was so that words in adjacent blocks do not run together.
+var textOnly = bluemonday.StrictPolicy().AddSpaceWhenStrippingTag(true)
+
+// Summarize counts the words of source written in format, estimates how long
+// it takes to read and takes an excerpt from its text. Markup is not counted.
+func Summarize(format string, source string) (Summary, error) {
+	text, err := Text(format, source)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	words := strings.Fields(text)
+	return Summary{
+		Excerpt:        excerpt(words, ExcerptLength),
+		WordCount:      len(words),
+		ReadingMinutes: int(math.Ceil(float64(len(words)) / WordsPerMinute)),
+	}, nil
+}
+
+// Text returns the text of source written in format without any markup.
+func Text(format string, source string) (string, error) {
+	if format == Plain {
+		return source, ValidFormat(format)
+	}
+
+	rendered, err := Render(format, source)
+	if err != nil {
+		return "", err
+	}
+	return html.UnescapeString(textOnly.Sanitize(blocksOnly.Sanitize(rendered))), nil
+}
+
+// excerpt joins words up to max characters, never cutting a word in two, and
+// marks a cut with an ellipsis. A first word longer than max is cut anyway.
+func excerpt(words []string, max int) string {
+	var out strings.Builder
+	length := 0
+	for i, word := range words {
+		wordLength := len([]rune(word))
+		if i > 0 {
+			wordLength++
+		}
+		if length+wordLength > max {
+			if i == 0 {
+				out.WriteString(string([]rune(word)[:max]))
+			}
+			return strings.TrimRightFunc(out.String(), unicode.IsPunct) + "…"
+		}
+		if i > 0 {
+			out.WriteByte(' ')
+		}
+		out.WriteString(word)
+		length += wordLength
+	}
+	return out.String()
+}
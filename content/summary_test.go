@@ -0,0 +1,119 @@
+package content
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSummarize(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		format  string
+		source  string
+		excerpt string
+		words   int
+		minutes int
+	}{
+		{"empty", Markdown, "", "", 0, 0},
+		{"only whitespace", Plain, " \n\t ", "", 0, 0},
+		{"only markup", HTML, "<p></p><script>alert(1)</script>", "", 0, 0},
+		{
+			name:    "markdown",
+			format:  Markdown,
+			source:  "# Title\n\nSome *emphasis*, a [link](https://example.com) and `code`.\n\n- one\n- two",
+			excerpt: "Title Some emphasis, a link and code. one two",
+			words:   9,
+			minutes: 1,
+		},
+		{
+			name:    "html",
+			format:  HTML,
+			source:  "<p>First</p><p>second &amp; third</p><img src=\"https://example.com/a.png\" alt=\"an image\">",
+			excerpt: "First second & third",
+			words:   4,
+			minutes: 1,
+		},
+		{
+			name:    "plain keeps angle brackets",
+			format:  Plain,
+			source:  "a <b> c",
+			excerpt: "a <b> c",
+			words:   3,
+			minutes: 1,
+		},
+		{
+			name:    "reading time rounds up",
+			format:  Plain,
+			source:  strings.Repeat("word ", WordsPerMinute+1),
+			words:   WordsPerMinute + 1,
+			minutes: 2,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := Summarize(tt.format, tt.source)
+			if err != nil {
+				t.Fatalf("Summarize: %v", err)
+			}
+			if tt.excerpt != "" && summary.Excerpt != tt.excerpt || tt.words == 0 && summary.Excerpt != "" {
+				t.Errorf("Excerpt = %q, want %q", summary.Excerpt, tt.excerpt)
+			}
+			if summary.WordCount != tt.words || summary.ReadingMinutes != tt.minutes {
+				t.Errorf("%d words, %d minutes; want %d words, %d minutes", summary.WordCount, summary.ReadingMinutes, tt.words, tt.minutes)
+			}
+		})
+	}
+
+	if _, err := Summarize("rtf", "x"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Summarize err = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		words []string
+		max   int
+		want  string
+	}{
+		{"none", nil, 10, ""},
+		{"fits exactly", []string{"ab", "cd", "ef"}, 8, "ab cd ef"},
+		{"cut between words", []string{"ab", "cd", "ef"}, 7, "ab cd…"},
+		{"cut after punctuation", []string{"ab,", "cd.", "ef"}, 7, "ab, cd…"},
+		{"long first word", []string{"abcdefghij", "k"}, 4, "abcd…"},
+		// lengths count characters, not bytes
+		{"multibyte fits", []string{"żółć", "ąę"}, 7, "żółć ąę"},
+		{"multibyte cut", []string{"żółć", "ąę"}, 6, "żółć…"},
+		{"multibyte first word", []string{"日本語のテキスト"}, 3, "日本語…"},
+		{"emoji", []string{"🙂🙂🙂🙂"}, 2, "🙂🙂…"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := excerpt(tt.words, tt.max)
+			if got != tt.want {
+				t.Errorf("excerpt = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("excerpt %q is not valid UTF-8", got)
+			}
+		})
+	}
+}
+
+func TestSummarizeExcerptLength(t *testing.T) {
+	source := strings.Repeat("zażółć gęślą jaźń ", 50)
+	summary, err := Summarize(Markdown, source)
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+
+	if !strings.HasSuffix(summary.Excerpt, "…") {
+		t.Errorf("Excerpt %q has no ellipsis", summary.Excerpt)
+	}
+	if length := utf8.RuneCountInString(strings.TrimSuffix(summary.Excerpt, "…")); length > ExcerptLength {
+		t.Errorf("Excerpt runs to %d characters, want at most %d", length, ExcerptLength)
+	}
+	if !utf8.ValidString(summary.Excerpt) || !strings.HasPrefix(source, strings.TrimSuffix(summary.Excerpt, "…")) {
+		t.Errorf("Excerpt %q is not a cut of whole words", summary.Excerpt)
+	}
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"github.com/bellaananda/go-postgresql-blog-http.git/telemetry"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -12,5 +14,10 @@ func RunDatabase() (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// emit a span for every query
+	if err := gormDB.Use(telemetry.NewGormPlugin()); err != nil {
+		return nil, err
+	}
+
 	return gormDB, nil
 }
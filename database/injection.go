@@ -3,37 +3,75 @@ package database
 import (
 	"context"
 
-	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
 
 	"gorm.io/gorm"
 )
 
-type PostgreSQLGORMRepository struct {
-	db *gorm.DB
-}
-
-func NewPostgreSQLGORMRepository(db *gorm.DB) *PostgreSQLGORMRepository {
-	return &PostgreSQLGORMRepository{
-		db: db,
-	}
-}
-
 // migrate database
-func (r *PostgreSQLGORMRepository) Migrate(ctx context.Context) error {
+func Migrate(ctx context.Context, db *gorm.DB) error {
 	// table user
-	err := r.db.WithContext(ctx).AutoMigrate(&models.GormUser{})
+	err := repository.NewUserRepository(db).MigrateUser(ctx)
 	if err != nil {
 		return err
 	}
 
 	// table post
-	err = r.db.WithContext(ctx).AutoMigrate(&models.GormPost{})
+	err = repository.NewPostRepository(db).MigratePost(ctx)
 	if err != nil {
 		return err
 	}
 
 	// table comment
-	err = r.db.WithContext(ctx).AutoMigrate(&models.GormComment{})
+	err = repository.NewCommentRepository(db).MigrateComment(ctx)
+	if err != nil {
+		return err
+	}
+
+	// table post media
+	err = repository.NewMediaRepository(db).MigrateMedia(ctx)
+	if err != nil {
+		return err
+	}
+
+	// table reaction
+	err = repository.NewReactionRepository(db).MigrateReaction(ctx)
+	if err != nil {
+		return err
+	}
+
+	// table bookmark
+	err = repository.NewBookmarkRepository(db).MigrateBookmark(ctx)
+	if err != nil {
+		return err
+	}
+
+	// tables follow and timeline entry
+	err = repository.NewFollowRepository(db).MigrateFollow(ctx)
+	if err != nil {
+		return err
+	}
+
+	// table post view
+	err = repository.NewViewRepository(db).MigrateView(ctx)
+	if err != nil {
+		return err
+	}
+
+	// tables notification and notification preference
+	err = repository.NewNotificationRepository(db).MigrateNotification(ctx)
+	if err != nil {
+		return err
+	}
+
+	// tables webhook, webhook event and webhook delivery
+	err = repository.NewWebhookRepository(db).MigrateWebhook(ctx)
+	if err != nil {
+		return err
+	}
+
+	// table comment event
+	err = repository.NewCommentEventRepository(db).MigrateCommentEvent(ctx)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+)
+
+// AdminToken guards admin-only routes. Requests must send it in the
+// X-Admin-Token header; when it is empty every admin request is refused.
+var AdminToken = os.Getenv("ADMIN_TOKEN")
+
+// RequireAdmin answers 403 unless the request carries the admin token.
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Admin-Token")
+		if AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(AdminToken)) != 1 {
+			http.Error(w, "Admin token required", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/gorilla/mux"
+)
+
+func AddBookmarkHandler(bookmarkService service.BookmarkService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Bookmarks belong to the authenticated user
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Get the post ID from the URL parameters
+		postID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		// Parse form data
+		err = r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form data", http.StatusBadRequest)
+			return
+		}
+
+		// Call the service method to save the post to the reading list
+		bookmark, created, err := bookmarkService.AddBookmark(r.Context(), userID, uint(postID), r.Form.Get("list"))
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the bookmark, 201 when it is new
+		w.Header().Set("Content-Type", "application/json")
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(bookmark)
+	}
+}
+
+func RemoveBookmarkHandler(bookmarkService service.BookmarkService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Bookmarks belong to the authenticated user
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Get the post ID from the URL parameters
+		postID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		// Call the service method to remove the post from the reading list
+		err = bookmarkService.RemoveBookmark(r.Context(), userID, uint(postID), r.URL.Query().Get("list"))
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with no content, whether or not the post was saved
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func GetBookmarksHandler(bookmarkService service.BookmarkService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Bookmarks belong to the authenticated user
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Read the page asked for
+		page, ok := readPage(w, r)
+		if !ok {
+			return
+		}
+
+		// ?list= narrows down to one reading list, the unsorted one when empty
+		var list *string
+		if values, ok := r.URL.Query()["list"]; ok {
+			list = &values[0]
+		}
+
+		// Call the service method to get the page of bookmarks
+		bookmarks, total, err := bookmarkService.GetBookmarks(r.Context(), userID, list, page)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the bookmarks
+		writePageHeaders(w, r, page, total)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bookmarks)
+	}
+}
+
+func GetBookmarkListsHandler(bookmarkService service.BookmarkService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Bookmarks belong to the authenticated user
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to get the reading lists
+		lists, err := bookmarkService.GetBookmarkLists(r.Context(), userID)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the reading lists
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lists)
+	}
+}
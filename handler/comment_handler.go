@@ -10,7 +10,7 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func CreateCommentHandler(commentService service.CommentService, postService service.PostService, userService service.UserService) http.HandlerFunc {
+func CreateCommentHandler(commentService service.CommentService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse form data
 		err := r.ParseForm()
@@ -23,6 +23,7 @@ func CreateCommentHandler(commentService service.CommentService, postService ser
 		user_id := r.Form.Get("user_id")
 		post_id := r.Form.Get("post_id")
 		content := r.Form.Get("content")
+		contentFormat := r.Form.Get("content_format")
 
 		// Validate and convert form values
 		userIDInt, err := strconv.ParseUint(user_id, 10, 64)
@@ -36,29 +37,18 @@ func CreateCommentHandler(commentService service.CommentService, postService ser
 			return
 		}
 
-		// Check if the specified user and post exist
-		_, err = userService.GetUserByID(r.Context(), uint(userIDInt))
-		if err != nil {
-			http.Error(w, "User with the specified ID does not exist", http.StatusBadRequest)
-			return
-		}
-		_, err = postService.GetPostByID(r.Context(), uint(postIDInt))
-		if err != nil {
-			http.Error(w, "Post with the specified ID does not exist", http.StatusBadRequest)
-			return
-		}
-
 		// Create a GormComment instance
 		comment := models.GormComment{
-			UserID:  uint(userIDInt),
-			PostID:  uint(postIDInt),
-			Content: content,
+			UserID:        uint(userIDInt),
+			PostID:        uint(postIDInt),
+			Content:       content,
+			ContentFormat: contentFormat,
 		}
 
 		// Call the service method to create the comment
 		createdComment, err := commentService.CreateComment(r.Context(), comment)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), serviceErrorStatus(err))
 			return
 		}
 
@@ -70,16 +60,21 @@ func CreateCommentHandler(commentService service.CommentService, postService ser
 
 func GetAllCommentsHandler(commentService service.CommentService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the fields and relations asked for
+		fieldset, ok := readFieldset(w, r, "comment")
+		if !ok {
+			return
+		}
+
 		// Call the service method to get the comments
-		comments, err := commentService.GetAllComments(r.Context())
+		comments, err := commentService.GetAllComments(r.Context(), fieldset)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// Respond with the comments
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(comments)
+		writeFieldset(w, fieldset, comments)
 	}
 }
 
@@ -101,20 +96,25 @@ func GetCommentHandler(commentService service.CommentService) http.HandlerFunc {
 			return
 		}
 
+		// Read the fields and relations asked for
+		fieldset, ok := readFieldset(w, r, "comment")
+		if !ok {
+			return
+		}
+
 		// Call the service method to get the comment by id
-		comment, err := commentService.GetCommentByID(r.Context(), uint(id))
+		comment, err := commentService.GetCommentByID(r.Context(), uint(id), fieldset)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Respond with the comment
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(comment)
+		// Respond with the comment, or 304 when the client already has it
+		writeVersioned(w, r, comment.Version, fieldset, comment)
 	}
 }
 
-func UpdateCommentHandler(commentService service.CommentService, postService service.PostService, userService service.UserService) http.HandlerFunc {
+func UpdateCommentHandler(commentService service.CommentService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get the comment ID from the URL parameters
 		vars := mux.Vars(r)
@@ -131,12 +131,23 @@ func UpdateCommentHandler(commentService service.CommentService, postService ser
 			return
 		}
 
+		// Read the version the client last saw
+		version, ok := ifMatchVersion(w, r)
+		if !ok {
+			return
+		}
+
 		// Parse the form data
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Error parsing form data", http.StatusBadRequest)
 			return
 		}
 
+		// PUT replaces the whole comment, so every field must be present
+		if !requireFormFields(w, r, "user_id", "post_id", "content") {
+			return
+		}
+
 		// Extract form values
 		userIDStr := r.Form.Get("user_id")
 		postIDStr := r.Form.Get("post_id")
@@ -154,18 +165,6 @@ func UpdateCommentHandler(commentService service.CommentService, postService ser
 			return
 		}
 
-		// Check if the specified user and post exist
-		_, err = userService.GetUserByID(r.Context(), uint(userID))
-		if err != nil {
-			http.Error(w, "User with specified ID not found", http.StatusBadRequest)
-			return
-		}
-		_, err = postService.GetPostByID(r.Context(), uint(postID))
-		if err != nil {
-			http.Error(w, "Post with specified ID not found", http.StatusBadRequest)
-			return
-		}
-
 		// Initialize an empty GormComment
 		var updatedComment models.GormComment
 
@@ -173,25 +172,62 @@ func UpdateCommentHandler(commentService service.CommentService, postService ser
 		updatedComment.UserID = uint(userID)
 		updatedComment.PostID = uint(postID)
 		updatedComment.Content = content
-
-		// Check if the form contains the "content" field
-		if content, ok := r.Form["content"]; ok {
-			updatedComment.Content = content[0]
-		}
+		updatedComment.ContentFormat = r.Form.Get("content_format")
 
 		// Set the ID of the comment to be updated
 		updatedComment.ID = uint(commentID)
+		updatedComment.Version = version
 
 		// Call the service method to update the comment
-		existingComment, err := commentService.UpdateCommentByID(r.Context(), uint(commentID), updatedComment)
+		savedComment, err := commentService.UpdateCommentByID(r.Context(), uint(commentID), updatedComment)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), serviceErrorStatus(err))
 			return
 		}
 
-		// Respond with the updated comment
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(existingComment)
+		// Respond with the comment as stored
+		writeUpdated(w, r, savedComment.Version, savedComment)
+	}
+}
+
+func PatchCommentHandler(commentService service.CommentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the comment ID from the URL parameters
+		vars := mux.Vars(r)
+		id, ok := vars["id"]
+		if !ok {
+			http.Error(w, "Comment ID is missing in URL", http.StatusBadRequest)
+			return
+		}
+
+		// Parse the ID into an integer
+		commentID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+			return
+		}
+
+		// Read the version the client last saw
+		version, ok := ifMatchVersion(w, r)
+		if !ok {
+			return
+		}
+
+		// Read the merge patch or JSON patch document
+		patchType, patch, ok := readPatch(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to patch the comment
+		patchedComment, err := commentService.PatchCommentByID(r.Context(), uint(commentID), version, patchType, patch)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the patched comment
+		writeUpdated(w, r, patchedComment.Version, patchedComment)
 	}
 }
 
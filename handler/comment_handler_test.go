@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+func TestCreateCommentHandler(t *testing.T) {
+	var created models.GormComment
+	comments := &fakeCommentService{createComment: func(ctx context.Context, comment models.GormComment) (*models.GormComment, error) {
+		created = comment
+		if comment.PostID != 9 {
+			return nil, service.ErrPostNotFound
+		}
+		comment.ID, comment.Version = 11, 1
+		return &comment, nil
+	}}
+	create := CreateCommentHandler(comments)
+
+	form := url.Values{"user_id": {"7"}, "post_id": {"9"}, "content": {"Nice"}, "content_format": {"markdown"}}
+	w := serve(create, newRequest(http.MethodPost, "/comments", nil, form))
+	checkStatus(t, w, http.StatusOK)
+	if created.UserID != 7 || created.PostID != 9 || created.Content != "Nice" || created.ContentFormat != "markdown" {
+		t.Errorf("service got %+v", created)
+	}
+	if body := decodeBody(t, w); body["ID"] != float64(11) {
+		t.Errorf("body = %v", body)
+	}
+
+	form.Set("post_id", "10")
+	w = serve(create, newRequest(http.MethodPost, "/comments", nil, form))
+	checkStatus(t, w, http.StatusBadRequest)
+
+	form.Set("post_id", "")
+	w = serve(create, newRequest(http.MethodPost, "/comments", nil, form))
+	checkStatus(t, w, http.StatusBadRequest)
+
+	comments.createComment = func(ctx context.Context, comment models.GormComment) (*models.GormComment, error) {
+		return nil, service.ErrConflict
+	}
+	form.Set("post_id", "9")
+	w = serve(create, newRequest(http.MethodPost, "/comments", nil, form))
+	checkStatus(t, w, http.StatusConflict)
+}
+
+func TestGetCommentHandler(t *testing.T) {
+	comments := &fakeCommentService{getCommentByID: func(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormComment, error) {
+		if id != 11 {
+			return nil, service.ErrNotFound
+		}
+		comment := &models.GormComment{Version: 2, UserID: 7, PostID: 9, Content: "Nice"}
+		comment.ID = id
+		return comment, nil
+	}}
+	get := GetCommentHandler(comments)
+
+	w := serve(get, newRequest(http.MethodGet, "/comments/11", map[string]string{"id": "11"}, nil))
+	checkStatus(t, w, http.StatusOK)
+	checkRepresentationTag(t, w, 2)
+	if body := decodeBody(t, w); body["Content"] != "Nice" || body["PostID"] != float64(9) {
+		t.Errorf("body = %v", body)
+	}
+
+	r := newRequest(http.MethodGet, "/comments/11", map[string]string{"id": "11"}, nil)
+	r.Header.Set("If-None-Match", "*")
+	checkStatus(t, serve(get, r), http.StatusNotModified)
+
+	w = serve(get, newRequest(http.MethodGet, "/comments/12", map[string]string{"id": "12"}, nil))
+	checkStatus(t, w, http.StatusInternalServerError)
+}
+
+func TestUpdateCommentHandler(t *testing.T) {
+	comments := &fakeCommentService{updateComment: func(ctx context.Context, id uint, comment models.GormComment) (*models.GormComment, error) {
+		if comment.Version != 2 {
+			return nil, service.ErrVersionConflict
+		}
+		comment.Version++
+		return &comment, nil
+	}}
+	update := UpdateCommentHandler(comments)
+	form := url.Values{"user_id": {"7"}, "post_id": {"9"}, "content": {"Nicer"}}
+
+	r := newRequest(http.MethodPut, "/comments/11", map[string]string{"id": "11"}, form)
+	r.Header.Set("If-Match", `"2"`)
+	r.Header.Set("Prefer", "respond-async, return=minimal")
+	w := serve(update, r)
+	checkStatus(t, w, http.StatusNoContent)
+	checkHeader(t, w, "ETag", `"3"`)
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want none", w.Body.String())
+	}
+
+	r = newRequest(http.MethodPut, "/comments/11", map[string]string{"id": "11"}, form)
+	r.Header.Set("If-Match", `"1"`)
+	checkStatus(t, serve(update, r), http.StatusPreconditionFailed)
+}
+
+func TestPatchCommentHandler(t *testing.T) {
+	var gotVersion uint
+	comments := &fakeCommentService{patchComment: func(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormComment, error) {
+		gotVersion = version
+		comment := &models.GormComment{Version: 3, Content: "Nicer"}
+		comment.ID = id
+		return comment, nil
+	}}
+
+	r := newRequest(http.MethodPatch, "/comments/11", map[string]string{"id": "11"}, `{"content":"Nicer"}`)
+	r.Header.Set("Content-Type", service.MergePatchType+"; charset=utf-8")
+	r.Header.Set("If-Match", "*")
+	w := serve(PatchCommentHandler(comments), r)
+	checkStatus(t, w, http.StatusOK)
+	checkHeader(t, w, "ETag", `"3"`)
+	if gotVersion != 0 {
+		t.Errorf("service got version %d, want 0 for If-Match: *", gotVersion)
+	}
+	if body := decodeBody(t, w); body["Content"] != "Nicer" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestDeleteCommentHandler(t *testing.T) {
+	comments := &fakeCommentService{deleteComment: func(ctx context.Context, id uint) error {
+		if id != 11 {
+			return errors.New("database is down")
+		}
+		return nil
+	}}
+	remove := DeleteCommentHandler(comments)
+
+	w := serve(remove, newRequest(http.MethodDelete, "/comments/11", map[string]string{"id": "11"}, nil))
+	checkStatus(t, w, http.StatusOK)
+	if body := decodeBody(t, w); body["message"] != "Comment deleted successfully!" {
+		t.Errorf("body = %v", body)
+	}
+
+	w = serve(remove, newRequest(http.MethodDelete, "/comments/12", map[string]string{"id": "12"}, nil))
+	checkStatus(t, w, http.StatusInternalServerError)
+}
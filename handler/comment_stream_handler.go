@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/gorilla/mux"
+)
+
+// StreamHeartbeat is how often an idle comment stream sends a comment line,
+// so proxies do not time it out and clients notice a dead connection.
+var StreamHeartbeat = 15 * time.Second
+
+func StreamCommentsHandler(streamService service.CommentStreamService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the post ID from the URL parameters
+		postID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		// Read the ID of the last event seen, which EventSource sends when
+		// it reconnects
+		var lastEventID *uint
+		if value := r.Header.Get("Last-Event-ID"); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+			last := uint(id)
+			lastEventID = &last
+		}
+
+		// Call the service method to follow the comments of the post
+		events, err := streamService.Subscribe(r.Context(), uint(postID), lastEventID)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the headers at once, then each event as it comes
+		flusher := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if err := flusher.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(StreamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			if err := flusher.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// sseFrame is one block of an event stream, up to the blank line ending it.
+type sseFrame struct {
+	id, event, data string
+	heartbeat       bool
+}
+
+// readFrame reads the next frame of an event stream.
+func readFrame(t *testing.T, stream *bufio.Reader) sseFrame {
+	t.Helper()
+	var frame sseFrame
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return frame
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "":
+			frame.heartbeat = value == "heartbeat"
+		case "id":
+			frame.id = value
+		case "event":
+			frame.event = value
+		case "data":
+			frame.data = value
+		default:
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+}
+
+// readEvent reads frames up to the next event, skipping heartbeats.
+func readEvent(t *testing.T, stream *bufio.Reader) sseFrame {
+	t.Helper()
+	for {
+		if frame := readFrame(t, stream); !frame.heartbeat {
+			return frame
+		}
+	}
+}
+
+func TestStreamCommentsHandler(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	author, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Followed"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	stream := service.NewCommentStreamService(repo, repo)
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go stream.Run(runCtx, time.Hour)
+
+	record := func(eventType string, commentID uint) {
+		t.Helper()
+		comment := &models.GormComment{Model: gorm.Model{ID: commentID}, UserID: author.ID, PostID: post.ID, Content: fmt.Sprintf("Comment %d", commentID)}
+		if err := stream.Record(ctx, eventType, comment); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	record(service.CommentEventCreated, 1)
+	record(service.CommentEventUpdated, 1)
+
+	heartbeat := StreamHeartbeat
+	StreamHeartbeat = 20 * time.Millisecond
+	defer func() { StreamHeartbeat = heartbeat }()
+
+	// returned tells when a request has been answered in full
+	returned := make(chan struct{}, 10)
+	router := mux.NewRouter()
+	router.HandleFunc("/posts/{id}/comments/stream", func(w http.ResponseWriter, r *http.Request) {
+		defer func() { returned <- struct{}{} }()
+		StreamCommentsHandler(stream)(w, r)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// open starts following the post, and returns the stream and how to
+	// hang up.
+	open := func(lastEventID string) (*bufio.Reader, context.CancelFunc) {
+		t.Helper()
+		requestCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		r, _ := http.NewRequestWithContext(requestCtx, "GET", fmt.Sprintf("%s/posts/%d/comments/stream", server.URL, post.ID), nil)
+		if lastEventID != "" {
+			r.Header.Set("Last-Event-ID", lastEventID)
+		}
+		response, err := http.DefaultClient.Do(r)
+		if err != nil {
+			cancel()
+			t.Fatalf("GET: %v", err)
+		}
+		if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+			cancel()
+			t.Fatalf("response %d %s, want 200 text/event-stream", response.StatusCode, response.Header.Get("Content-Type"))
+		}
+		return bufio.NewReader(response.Body), func() {
+			cancel()
+			response.Body.Close()
+		}
+	}
+
+	t.Run("replays from Last-Event-ID", func(t *testing.T) {
+		events, hangUp := open("0")
+		defer hangUp()
+
+		first := readEvent(t, events)
+		if first.id != "1" || first.event != service.CommentEventCreated || !strings.Contains(first.data, `"Comment 1"`) {
+			t.Errorf("first frame = %+v, want the creation", first)
+		}
+		if second := readEvent(t, events); second.id != "2" || second.event != service.CommentEventUpdated {
+			t.Errorf("second frame = %+v, want the update", second)
+		}
+
+		record(service.CommentEventCreated, 2)
+		if third := readEvent(t, events); third.id != "3" || third.event != service.CommentEventCreated || !strings.Contains(third.data, `"Comment 2"`) {
+			t.Errorf("third frame = %+v, want the new comment", third)
+		}
+	})
+
+	t.Run("replays after the event last seen", func(t *testing.T) {
+		events, hangUp := open("2")
+		defer hangUp()
+
+		if frame := readEvent(t, events); frame.id != "3" {
+			t.Errorf("frame = %+v, want event 3 first", frame)
+		}
+	})
+
+	t.Run("starts from now", func(t *testing.T) {
+		events, hangUp := open("")
+		defer hangUp()
+
+		// the stream is open once the first heartbeat is in
+		if frame := readFrame(t, events); !frame.heartbeat {
+			t.Fatalf("frame = %+v, want a heartbeat before anything happens", frame)
+		}
+		record(service.CommentEventDeleted, 2)
+		if frame := readEvent(t, events); frame.id != "4" || frame.event != service.CommentEventDeleted {
+			t.Errorf("frame = %+v, want only the deletion", frame)
+		}
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		events, hangUp := open("")
+		defer hangUp()
+
+		for i := 0; i < 3; i++ {
+			if frame := readFrame(t, events); !frame.heartbeat {
+				t.Errorf("frame = %+v, want a heartbeat", frame)
+			}
+		}
+	})
+
+	// drain what the subtests left, each of which hung up
+	for i := 0; i < 4; i++ {
+		select {
+		case <-returned:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of 4 streams still open after hanging up", 4-i)
+		}
+	}
+
+	t.Run("ends on disconnect", func(t *testing.T) {
+		events, hangUp := open("")
+		readFrame(t, events)
+		hangUp()
+
+		select {
+		case <-returned:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the handler kept streaming after the client hung up")
+		}
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		for _, tt := range []struct {
+			path        string
+			lastEventID string
+			status      int
+		}{
+			{fmt.Sprintf("/posts/%d/comments/stream", post.ID), "first", http.StatusBadRequest},
+			{"/posts/x/comments/stream", "", http.StatusBadRequest},
+			{"/posts/999/comments/stream", "", http.StatusNotFound},
+		} {
+			r := httptest.NewRequest("GET", tt.path, nil)
+			if tt.lastEventID != "" {
+				r.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			<-returned
+			checkStatus(t, w, tt.status)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+// encodeCursor turns the last post of a page into the opaque ?before=
+// value of the next page.
+func encodeCursor(post models.GormPost) string {
+	raw := fmt.Sprintf("%d.%d", post.PublishedAt.UnixNano(), post.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(value string) (*service.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var nanos int64
+	var postID uint
+	if _, err := fmt.Sscanf(string(raw), "%d.%d", &nanos, &postID); err != nil {
+		return nil, err
+	}
+	return &service.Cursor{PublishedAt: time.Unix(0, nanos).UTC(), PostID: postID}, nil
+}
+
+// readCursor reads a keyset page, ?before=<cursor>&limit=50, answering 400
+// when either is malformed. limit is capped at service.MaxPageSize.
+func readCursor(w http.ResponseWriter, r *http.Request) (*service.Cursor, int, bool) {
+	query := r.URL.Query()
+
+	limit := service.DefaultPageSize
+	if raw := query.Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return nil, 0, false
+		}
+		limit = value
+	}
+	if limit > service.MaxPageSize {
+		limit = service.MaxPageSize
+	}
+
+	var cursor *service.Cursor
+	if raw := query.Get("before"); raw != "" {
+		var err error
+		cursor, err = decodeCursor(raw)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return nil, 0, false
+		}
+	}
+
+	return cursor, limit, true
+}
+
+// writeNextLink links the page after posts in a Link header (RFC 8288),
+// unless posts came up short of limit and so were the last of them.
+func writeNextLink(w http.ResponseWriter, r *http.Request, posts []models.GormPost, limit int) {
+	if len(posts) < limit {
+		return
+	}
+
+	query := r.URL.Query()
+	query.Set("before", encodeCursor(posts[len(posts)-1]))
+	query.Set("limit", strconv.Itoa(limit))
+	w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, query.Encode()))
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+// RequireIfMatch makes PUT and PATCH answer 428 when the client sends no
+// If-Match header. Turn it on with REQUIRE_IF_MATCH=true.
+var RequireIfMatch = os.Getenv("REQUIRE_IF_MATCH") == "true"
+
+// versionETag is the strong entity tag for a row at the given version.
+func versionETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// representationETag is the strong entity tag for one representation of a
+// row at the given version: the fields and relations read, and the body.
+// The hash tells apart what the version does not count, such as reaction
+// counts or whether the reader bookmarked a post, so If-Match keeps working
+// off the version alone.
+func representationETag(version uint, fieldset service.Fieldset, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(fieldset.String() + "\n"))
+	hash.Write(body)
+	sum := hash.Sum(nil)
+	return `"` + strconv.FormatUint(uint64(version), 10) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// writeETag sets the ETag header and reports whether If-None-Match already
+// names it, in which case a 304 has been written and the caller is done.
+func writeETag(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeVersioned responds with v, a row at version, narrowed down to the
+// fieldset and tagged with its representationETag, or with 304 when the
+// client already has that representation.
+func writeVersioned(w http.ResponseWriter, r *http.Request, version uint, fieldset service.Fieldset, v interface{}) {
+	projected, err := fieldset.Project(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err := json.Marshal(projected)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	if writeETag(w, r, representationETag(version, fieldset, body)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// ifMatchVersion returns the version named by If-Match, or 0 when the client
+// sent "*" or, unless RequireIfMatch is set, no header at all. ok is false
+// when an error response has already been written.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (version uint, ok bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		if RequireIfMatch {
+			http.Error(w, "If-Match header is required", http.StatusPreconditionRequired)
+			return 0, false
+		}
+		return 0, true
+	}
+	if ifMatch == "*" {
+		return 0, true
+	}
+
+	// weak tags never match under If-Match, and neither does anything that
+	// is not one of our tags; only the version of a representation tag counts
+	tag := strings.TrimSuffix(strings.TrimPrefix(ifMatch, `"`), `"`)
+	if len(tag) != len(ifMatch)-2 {
+		http.Error(w, "If-Match does not match the current version", http.StatusPreconditionFailed)
+		return 0, false
+	}
+	tag, _, _ = strings.Cut(tag, "-")
+	parsed, err := strconv.ParseUint(tag, 10, 64)
+	if err != nil || parsed == 0 {
+		http.Error(w, "If-Match does not match the current version", http.StatusPreconditionFailed)
+		return 0, false
+	}
+
+	return uint(parsed), true
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		version uint
+		status  int
+	}{
+		{"", 0, http.StatusOK},
+		{"*", 0, http.StatusOK},
+		{`"3"`, 3, http.StatusOK},
+		{`"3-0123456789abcdef"`, 3, http.StatusOK},
+		{` "3" `, 3, http.StatusOK},
+		{`W/"3"`, 0, http.StatusPreconditionFailed},
+		{`3`, 0, http.StatusPreconditionFailed},
+		{`"3`, 0, http.StatusPreconditionFailed},
+		{`"`, 0, http.StatusPreconditionFailed},
+		{`"0"`, 0, http.StatusPreconditionFailed},
+		{`"x"`, 0, http.StatusPreconditionFailed},
+		{`"-3"`, 0, http.StatusPreconditionFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ifMatch, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			r.Header.Set("If-Match", test.ifMatch)
+			w := httptest.NewRecorder()
+
+			version, ok := ifMatchVersion(w, r)
+			if ok != (test.status == http.StatusOK) || version != test.version || w.Code != test.status {
+				t.Errorf("ifMatchVersion = %d, %v with status %d, want %d with status %d", version, ok, w.Code, test.version, test.status)
+			}
+		})
+	}
+}
+
+func TestWriteETag(t *testing.T) {
+	etag := representationETag(3, service.Fieldset{}, []byte(`{"ID":1}`))
+	if etag == representationETag(3, service.Fieldset{}, []byte(`{"ID":2}`)) {
+		t.Errorf("representations with different bodies share ETag %s", etag)
+	}
+
+	tests := []struct {
+		ifNoneMatch string
+		notModified bool
+	}{
+		{"", false},
+		{`"3"`, false},
+		{etag, true},
+		{`"2", ` + etag, true},
+		{"W/" + etag, true},
+		{"*", true},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("If-None-Match", test.ifNoneMatch)
+		w := httptest.NewRecorder()
+
+		if got := writeETag(w, r, etag); got != test.notModified {
+			t.Errorf("writeETag with If-None-Match %q = %v, want %v", test.ifNoneMatch, got, test.notModified)
+		}
+		if w.Header().Get("ETag") != etag {
+			t.Errorf("ETag = %q, want %q", w.Header().Get("ETag"), etag)
+		}
+	}
+}
+
+func TestRepresentationETagFieldset(t *testing.T) {
+	fieldset := func(fields []string, include []string) service.Fieldset {
+		t.Helper()
+		var byResource map[string][]string
+		if fields != nil {
+			byResource = map[string][]string{"post": fields}
+		}
+		fieldset, err := service.NewFieldset("post", byResource, include)
+		if err != nil {
+			t.Fatalf("NewFieldset: %v", err)
+		}
+		return fieldset
+	}
+	body := []byte(`{"ID":1}`)
+	tag := func(fieldset service.Fieldset) string {
+		return representationETag(3, fieldset, body)
+	}
+
+	if tag(fieldset([]string{"title", "tags"}, nil)) != tag(fieldset([]string{"tags", "title", "tags"}, nil)) {
+		t.Error("the same fields in another order have another ETag")
+	}
+	if tag(fieldset(nil, []string{"user", "comments"})) != tag(fieldset(nil, []string{"comments", "user"})) {
+		t.Error("the same relations in another order have another ETag")
+	}
+
+	distinct := map[string]service.Fieldset{
+		"everything":      {},
+		"title":           fieldset([]string{"title"}, nil),
+		"title and tags":  fieldset([]string{"title", "tags"}, nil),
+		"title with user": fieldset([]string{"title"}, []string{"user"}),
+		"no relations":    fieldset(nil, []string{}),
+		"user":            fieldset(nil, []string{"user"}),
+	}
+	seen := map[string]string{}
+	for name, fieldset := range distinct {
+		etag := tag(fieldset)
+		if other, ok := seen[etag]; ok {
+			t.Errorf("fieldsets %s and %s share ETag %s", name, other, etag)
+		}
+		seen[etag] = name
+	}
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/gorilla/mux"
+)
+
+// The fakes embed the service interface, so a call to a method a test has
+// not set panics rather than passing unnoticed.
+
+type fakeUserService struct {
+	service.UserService
+	createUser  func(ctx context.Context, user models.GormUser) (*models.GormUser, error)
+	getUserByID func(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormUser, error)
+	updateUser  func(ctx context.Context, id uint, user models.GormUser) (*models.GormUser, error)
+	patchUser   func(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormUser, error)
+	deleteUser  func(ctx context.Context, id uint) error
+}
+
+func (fake *fakeUserService) CreateUser(ctx context.Context, user models.GormUser) (*models.GormUser, error) {
+	return fake.createUser(ctx, user)
+}
+
+func (fake *fakeUserService) GetUserByID(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormUser, error) {
+	return fake.getUserByID(ctx, id, fieldset)
+}
+
+func (fake *fakeUserService) UpdateUserByID(ctx context.Context, id uint, user models.GormUser) (*models.GormUser, error) {
+	return fake.updateUser(ctx, id, user)
+}
+
+func (fake *fakeUserService) PatchUserByID(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormUser, error) {
+	return fake.patchUser(ctx, id, version, patchType, patch)
+}
+
+func (fake *fakeUserService) DeleteUserByID(ctx context.Context, id uint) error {
+	return fake.deleteUser(ctx, id)
+}
+
+type fakePostService struct {
+	service.PostService
+	createPost  func(ctx context.Context, post models.GormPost) (*models.GormPost, error)
+	getPostByID func(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormPost, error)
+	updatePost  func(ctx context.Context, id uint, post models.GormPost) (*models.GormPost, error)
+	patchPost   func(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormPost, error)
+	deletePost  func(ctx context.Context, id uint) error
+}
+
+func (fake *fakePostService) CreatePost(ctx context.Context, post models.GormPost) (*models.GormPost, error) {
+	return fake.createPost(ctx, post)
+}
+
+func (fake *fakePostService) GetPostByID(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormPost, error) {
+	return fake.getPostByID(ctx, id, fieldset)
+}
+
+func (fake *fakePostService) UpdatePostByID(ctx context.Context, id uint, post models.GormPost) (*models.GormPost, error) {
+	return fake.updatePost(ctx, id, post)
+}
+
+func (fake *fakePostService) PatchPostByID(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormPost, error) {
+	return fake.patchPost(ctx, id, version, patchType, patch)
+}
+
+func (fake *fakePostService) DeletePostByID(ctx context.Context, id uint) error {
+	return fake.deletePost(ctx, id)
+}
+
+type fakeCommentService struct {
+	service.CommentService
+	createComment  func(ctx context.Context, comment models.GormComment) (*models.GormComment, error)
+	getCommentByID func(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormComment, error)
+	updateComment  func(ctx context.Context, id uint, comment models.GormComment) (*models.GormComment, error)
+	patchComment   func(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormComment, error)
+	deleteComment  func(ctx context.Context, id uint) error
+}
+
+func (fake *fakeCommentService) CreateComment(ctx context.Context, comment models.GormComment) (*models.GormComment, error) {
+	return fake.createComment(ctx, comment)
+}
+
+func (fake *fakeCommentService) GetCommentByID(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormComment, error) {
+	return fake.getCommentByID(ctx, id, fieldset)
+}
+
+func (fake *fakeCommentService) UpdateCommentByID(ctx context.Context, id uint, comment models.GormComment) (*models.GormComment, error) {
+	return fake.updateComment(ctx, id, comment)
+}
+
+func (fake *fakeCommentService) PatchCommentByID(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormComment, error) {
+	return fake.patchComment(ctx, id, version, patchType, patch)
+}
+
+func (fake *fakeCommentService) DeleteCommentByID(ctx context.Context, id uint) error {
+	return fake.deleteComment(ctx, id)
+}
+
+type fakeTrashService struct {
+	service.TrashService
+	getDeletedUsers func(ctx context.Context, page service.Page) ([]models.GormUser, int64, error)
+}
+
+func (fake *fakeTrashService) GetDeletedUsers(ctx context.Context, page service.Page) ([]models.GormUser, int64, error) {
+	return fake.getDeletedUsers(ctx, page)
+}
+
+// fakeViewService keeps the visits recorded.
+type fakeViewService struct {
+	service.ViewService
+	visits []service.Visit
+}
+
+func (fake *fakeViewService) RecordView(visit service.Visit) {
+	fake.visits = append(fake.visits, visit)
+}
+
+// newRequest builds a request for a handler, as routed with vars. A
+// url.Values body is sent as a form, anything else as is.
+func newRequest(method string, target string, vars map[string]string, body interface{}) *http.Request {
+	var reader io.Reader
+	form := false
+	switch body := body.(type) {
+	case url.Values:
+		reader = strings.NewReader(body.Encode())
+		form = true
+	case string:
+		reader = strings.NewReader(body)
+	}
+
+	r := httptest.NewRequest(method, target, reader)
+	if form {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if vars != nil {
+		r = mux.SetURLVars(r, vars)
+	}
+	return r
+}
+
+// serve runs handler on r and returns what it wrote.
+func serve(handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// checkStatus fails the test unless the response has the status wanted.
+func checkStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, want, w.Body.String())
+	}
+}
+
+// checkHeader fails the test unless the response header key is want.
+func checkHeader(t *testing.T, w *httptest.ResponseRecorder, key string, want string) {
+	t.Helper()
+	if got := w.Header().Get(key); got != want {
+		t.Errorf("%s = %q, want %q", key, got, want)
+	}
+}
+
+// checkRepresentationTag fails the test unless the ETag is the tag of a
+// representation at version, and returns it.
+func checkRepresentationTag(t *testing.T, w *httptest.ResponseRecorder, version uint) string {
+	t.Helper()
+	etag := w.Header().Get("ETag")
+	if want := fmt.Sprintf(`"%d-`, version); !strings.HasPrefix(etag, want) || !strings.HasSuffix(etag, `"`) {
+		t.Errorf("ETag = %q, want a tag of version %d", etag, version)
+	}
+	return etag
+}
+
+// decodeBody decodes a JSON response body into a map.
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	checkHeader(t, w, "Content-Type", "application/json")
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding body %q: %v", w.Body.String(), err)
+	}
+	return body
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+// readFieldset reads ?fields[resource]=a,b and ?include=x,y into a
+// fieldset for a read of resource, answering 400 when they name fields or
+// relations that cannot be read. Include values listed in extra are left to
+// the caller; an include made up of only those keeps the default relations.
+func readFieldset(w http.ResponseWriter, r *http.Request, resource string, extra ...string) (service.Fieldset, bool) {
+	query := r.URL.Query()
+
+	var fields map[string][]string
+	for key, values := range query {
+		if !strings.HasPrefix(key, "fields[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		if fields == nil {
+			fields = map[string][]string{}
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "fields["), "]")
+		fields[name] = append(fields[name], splitList(values)...)
+	}
+
+	var include []string
+	if values, ok := query["include"]; ok {
+		include = []string{}
+		onlyExtra := false
+		for _, name := range splitList(values) {
+			if contains(extra, name) {
+				onlyExtra = true
+				continue
+			}
+			include = append(include, name)
+		}
+		if onlyExtra && len(include) == 0 {
+			include = nil
+		}
+	}
+
+	fieldset, err := service.NewFieldset(resource, fields, include)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return service.Fieldset{}, false
+	}
+	return fieldset, true
+}
+
+// splitList splits comma separated query values, dropping empty names.
+func splitList(values []string) []string {
+	names := []string{}
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFieldset responds with v narrowed down to the fieldset.
+func writeFieldset(w http.ResponseWriter, fieldset service.Fieldset, v interface{}) {
+	projected, err := fieldset.Project(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projected)
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/gorilla/mux"
+)
+
+func FollowHandler(followService service.FollowService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The authenticated user is the one following
+		followerID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Get the ID of the user to follow from the URL parameters
+		followeeID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		// Call the service method to follow the user
+		follow, created, err := followService.Follow(r.Context(), followerID, uint(followeeID))
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the follow, 201 when it is new
+		w.Header().Set("Content-Type", "application/json")
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(follow)
+	}
+}
+
+func UnfollowHandler(followService service.FollowService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The authenticated user is the one unfollowing
+		followerID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Get the ID of the user to unfollow from the URL parameters
+		followeeID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		// Call the service method to unfollow the user
+		err = followService.Unfollow(r.Context(), followerID, uint(followeeID))
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with no content, whether or not the user was followed
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func GetFollowersHandler(followService service.FollowService) http.HandlerFunc {
+	return followListHandler(followService.GetFollowers)
+}
+
+func GetFollowingHandler(followService service.FollowService) http.HandlerFunc {
+	return followListHandler(followService.GetFollowing)
+}
+
+// followListHandler answers with one page of the users read by list, and
+// their count in X-Total-Count.
+func followListHandler(list func(ctx context.Context, userID uint, page service.Page) ([]models.GormUser, int64, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the user ID from the URL parameters
+		userID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		// Read the page asked for
+		page, ok := readPage(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to get the page of users
+		users, total, err := list(r.Context(), uint(userID), page)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the users
+		writePageHeaders(w, r, page, total)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(users)
+	}
+}
+
+func GetFeedHandler(postService service.PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The feed is the authenticated user's
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Read where the page starts and how long it is
+		cursor, limit, ok := readCursor(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to get the page of the feed
+		posts, err := postService.GetFeed(r.Context(), userID, cursor, limit)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the posts and a link to the next page
+		writeNextLink(w, r, posts, limit)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(posts)
+	}
+}
@@ -2,12 +2,45 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/bellaananda/go-postgresql-blog-http.git/database"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
 )
 
+// serviceErrorStatus picks the status code for an error returned by a
+// service write. A missing referenced user or post is the client's fault,
+// and a stale If-Match version fails the precondition.
+func serviceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrPostNotFound),
+		errors.Is(err, service.ErrUnknownFormat), errors.Is(err, service.ErrUnknownReaction),
+		errors.Is(err, service.ErrInvalidList), errors.Is(err, service.ErrSelfFollow),
+		errors.Is(err, service.ErrInvalidTags), errors.Is(err, service.ErrInvalidAnalytics),
+		errors.Is(err, service.ErrUnknownNotification), errors.Is(err, service.ErrInvalidResetToken),
+		errors.Is(err, service.ErrInvalidWebhook):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, service.ErrConflict), errors.Is(err, service.ErrDeleteBlocked),
+		errors.Is(err, service.ErrDeliveryPending):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrUnsupportedPatch):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, service.ErrInvalidPatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrUnsupportedType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, service.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
 func FirstHandler(w http.ResponseWriter, r *http.Request) {
 	// Send a response
 	w.WriteHeader(http.StatusOK)
@@ -47,8 +80,7 @@ func MigrateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Migrate the database
-	dbIn := database.NewPostgreSQLGORMRepository(db)
-	if err := dbIn.Migrate(context.Background()); err != nil {
+	if err := database.Migrate(context.Background(), db); err != nil {
 		msg := fmt.Sprintf(`{"error": "%s"}`, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(msg))
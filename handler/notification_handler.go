@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+func GetNotificationsHandler(notificationService service.NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Notifications belong to the authenticated user
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Read the page asked for
+		page, ok := readPage(w, r)
+		if !ok {
+			return
+		}
+
+		// ?unread=true leaves out the notifications already read
+		var unread bool
+		if value := r.URL.Query().Get("unread"); value != "" {
+			var err error
+			unread, err = strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "Invalid unread", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Call the service method to get the page of notifications
+		notifications, total, err := notificationService.GetNotifications(r.Context(), userID, unread, page)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the notifications
+		writePageHeaders(w, r, page, total)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(notifications)
+	}
+}
+
+func GetUnreadCountHandler(notificationService service.NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Notifications belong to the authenticated user
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to count the unread notifications
+		unread, err := notificationService.UnreadCount(r.Context(), userID)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the count
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"unread": unread})
+	}
+}
+
+func MarkNotificationsReadHandler(notificationService service.NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Notifications belong to the authenticated user
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Parse form data
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form data", http.StatusBadRequest)
+			return
+		}
+
+		// ids=1,2 marks those notifications read, no ids marks them all
+		var ids []uint
+		for _, raw := range splitList(r.Form["ids"]) {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid notification ID "+strconv.Quote(raw), http.StatusBadRequest)
+				return
+			}
+			ids = append(ids, uint(id))
+		}
+
+		// Call the service method to mark the notifications read
+		unread, err := notificationService.MarkRead(r.Context(), userID, ids)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with how many are left unread
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"unread": unread})
+	}
+}
+
+func GetNotificationPreferencesHandler(notificationService service.NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Preferences belong to the authenticated user
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to get the preferences
+		preferences, err := notificationService.GetPreferences(r.Context(), userID)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with whether each type is on
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preferences)
+	}
+}
+
+func UpdateNotificationPreferencesHandler(notificationService service.NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Preferences belong to the authenticated user
+		userID, ok := requireReader(w, r)
+		if !ok {
+			return
+		}
+
+		// Parse form data
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form data", http.StatusBadRequest)
+			return
+		}
+
+		// Each field names a type and turns it on or off, e.g. reply=false
+		preferences := make(map[string]bool, len(r.PostForm))
+		for notificationType, values := range r.PostForm {
+			enabled, err := strconv.ParseBool(values[0])
+			if err != nil {
+				http.Error(w, "Invalid "+notificationType+", want true or false", http.StatusBadRequest)
+				return
+			}
+			preferences[notificationType] = enabled
+		}
+
+		// Call the service method to store the preferences
+		updated, err := notificationService.SetPreferences(r.Context(), userID, preferences)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with whether each type is on
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+// readPage reads ?page=2&per_page=50, answering 400 when either is not a
+// positive number. per_page is capped at service.MaxPageSize.
+func readPage(w http.ResponseWriter, r *http.Request) (service.Page, bool) {
+	page := service.Page{Number: 1, Size: service.DefaultPageSize}
+	query := r.URL.Query()
+
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"page", &page.Number}, {"per_page", &page.Size}} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			http.Error(w, "Invalid "+param.name, http.StatusBadRequest)
+			return service.Page{}, false
+		}
+		*param.value = value
+	}
+
+	if page.Size > service.MaxPageSize {
+		page.Size = service.MaxPageSize
+	}
+	return page, true
+}
+
+// writePageHeaders tells the client how many items there are in all, in
+// X-Total-Count, and links the neighbouring pages in a Link header
+// (RFC 8288), keeping the rest of the query as it is.
+func writePageHeaders(w http.ResponseWriter, r *http.Request, page service.Page, total int64) {
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+
+	last := int((total + int64(page.Size) - 1) / int64(page.Size))
+	if last < 1 {
+		last = 1
+	}
+
+	link := func(number int, rel string) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(number))
+		query.Set("per_page", strconv.Itoa(page.Size))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, r.URL.Path, query.Encode(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if page.Number > 1 {
+		links = append(links, link(page.Number-1, "prev"))
+	}
+	if page.Number < last {
+		links = append(links, link(page.Number+1, "next"))
+	}
+	links = append(links, link(last, "last"))
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+// maxPatchBytes bounds the size of a PATCH body.
+const maxPatchBytes = 1 << 20
+
+// readPatch reads a PATCH body and its media type. Plain application/json
+// is treated as a merge patch. ok is false when an error response has
+// already been written.
+func readPatch(w http.ResponseWriter, r *http.Request) (patchType string, patch []byte, ok bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+
+	switch mediaType {
+	case service.MergePatchType, service.JSONPatchType:
+		patchType = mediaType
+	case "application/json":
+		patchType = service.MergePatchType
+	default:
+		w.Header().Set("Accept-Patch", service.MergePatchType+", "+service.JSONPatchType)
+		http.Error(w, "Unsupported patch media type", http.StatusUnsupportedMediaType)
+		return "", nil, false
+	}
+
+	patch, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchBytes))
+	if err != nil {
+		http.Error(w, "Error reading patch document", http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	return patchType, patch, true
+}
+
+// requireFormFields parses the form and answers 400 unless every field is
+// present. It reports whether the handler may carry on.
+func requireFormFields(w http.ResponseWriter, r *http.Request, fields ...string) bool {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form data", http.StatusBadRequest)
+		return false
+	}
+
+	var missing []string
+	for _, field := range fields {
+		if _, ok := r.Form[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		http.Error(w, "Missing required fields: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
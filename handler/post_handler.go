@@ -10,7 +10,7 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func CreatePostHandler(postService service.PostService, userService service.UserService) http.HandlerFunc {
+func CreatePostHandler(postService service.PostService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse form data
 		err := r.ParseForm()
@@ -23,6 +23,10 @@ func CreatePostHandler(postService service.PostService, userService service.User
 		user_id := r.Form.Get("user_id")
 		title := r.Form.Get("title")
 		content := r.Form.Get("content")
+		contentFormat := r.Form.Get("content_format")
+		excerpt := r.Form.Get("excerpt")
+		// tags are comma separated, or given as repeated fields
+		tags := splitList(r.Form["tags"])
 
 		// Convert user_id to uint
 		user_id_int, err := strconv.ParseUint(user_id, 10, 64)
@@ -31,24 +35,20 @@ func CreatePostHandler(postService service.PostService, userService service.User
 			return
 		}
 
-		// Check if the specified user ID exists
-		_, err = userService.GetUserByID(r.Context(), uint(user_id_int))
-		if err != nil {
-			http.Error(w, "User with the specified ID does not exist", http.StatusBadRequest)
-			return
-		}
-
 		// Create a GormPost instance
 		post := models.GormPost{
-			UserID:  uint(user_id_int),
-			Title:   title,
-			Content: content,
+			UserID:        uint(user_id_int),
+			Title:         title,
+			Content:       content,
+			ContentFormat: contentFormat,
+			Excerpt:       excerpt,
+			Tags:          tags,
 		}
 
 		// Call the service method to create a post
 		createdPost, err := postService.CreatePost(r.Context(), post)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), serviceErrorStatus(err))
 			return
 		}
 
@@ -60,20 +60,28 @@ func CreatePostHandler(postService service.PostService, userService service.User
 
 func GetAllPostsHandler(postService service.PostService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// The content is left out unless asked for with ?include=content
+		includeContent := includes(r, "content")
+
+		// Read the fields and relations asked for
+		fieldset, ok := readFieldset(w, r, "post", "content")
+		if !ok {
+			return
+		}
+
 		// Call the service method to get the posts
-		posts, err := postService.GetAllPosts(r.Context())
+		posts, err := postService.GetAllPosts(r.Context(), includeContent, fieldset)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// Respond with the posts
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(posts)
+		writeFieldset(w, fieldset, posts)
 	}
 }
 
-func GetPostHandler(postService service.PostService) http.HandlerFunc {
+func GetPostHandler(postService service.PostService, viewService service.ViewService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get the post ID from URL parameters
 		idStr := mux.Vars(r)["id"]
@@ -91,20 +99,28 @@ func GetPostHandler(postService service.PostService) http.HandlerFunc {
 			return
 		}
 
+		// Read the fields and relations asked for
+		fieldset, ok := readFieldset(w, r, "post")
+		if !ok {
+			return
+		}
+
 		// Call the service method to get the post
-		post, err := postService.GetPostByID(r.Context(), uint(id))
+		post, err := postService.GetPostByID(r.Context(), uint(id), fieldset)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), serviceErrorStatus(err))
 			return
 		}
 
-		// Respond with the user
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(post)
+		// Count the view, a revalidated one included
+		viewService.RecordView(service.Visit{PostID: uint(id), Visitor: visitor(r), UserAgent: r.UserAgent()})
+
+		// Respond with the post, or 304 when the client already has it
+		writeVersioned(w, r, post.Version, fieldset, post)
 	}
 }
 
-func UpdatePostHandler(postService service.PostService, userService service.UserService) http.HandlerFunc {
+func UpdatePostHandler(postService service.PostService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get the post ID from the URL parameters
 		vars := mux.Vars(r)
@@ -121,62 +137,105 @@ func UpdatePostHandler(postService service.PostService, userService service.User
 			return
 		}
 
+		// Read the version the client last saw
+		version, ok := ifMatchVersion(w, r)
+		if !ok {
+			return
+		}
+
 		// Parse the form data
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Error parsing form data", http.StatusBadRequest)
 			return
 		}
 
-		// Extract form values
-		userIDStr := r.Form.Get("user_id")
-		title := r.Form.Get("title")
-		content := r.Form.Get("content")
+		// PUT replaces the whole post, so every field must be present
+		if !requireFormFields(w, r, "user_id", "title", "content") {
+			return
+		}
 
 		// Convert user ID to uint
-		userID, err := strconv.ParseUint(userIDStr, 10, 64)
+		userID, err := strconv.ParseUint(r.Form.Get("user_id"), 10, 64)
 		if err != nil {
 			http.Error(w, "Invalid user ID", http.StatusBadRequest)
 			return
 		}
 
-		// Check if the specified user ID exists
-		_, err = userService.GetUserByID(r.Context(), uint(userID))
+		// is_published may be left out, which unpublishes the post
+		isPublished := false
+		if value := r.Form.Get("is_published"); value != "" {
+			isPublished, err = strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "Invalid is_published value", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Initialize the replacement GormPost
+		updatedPost := models.GormPost{
+			UserID:        uint(userID),
+			Title:         r.Form.Get("title"),
+			Content:       r.Form.Get("content"),
+			ContentFormat: r.Form.Get("content_format"),
+			Excerpt:       r.Form.Get("excerpt"),
+			IsPublished:   isPublished,
+			Tags:          splitList(r.Form["tags"]),
+		}
+
+		// Set the ID of the post to be updated
+		updatedPost.ID = uint(postID)
+		updatedPost.Version = version
+
+		// Call the service method to update the post
+		savedPost, err := postService.UpdatePostByID(r.Context(), uint(postID), updatedPost)
 		if err != nil {
-			http.Error(w, "User with specified ID not found", http.StatusBadRequest)
+			http.Error(w, err.Error(), serviceErrorStatus(err))
 			return
 		}
 
-		// Initialize an empty GormPost
-		var updatedPost models.GormPost
+		// Respond with the post as stored
+		writeUpdated(w, r, savedPost.Version, savedPost)
+	}
+}
 
-		// Set the values for the updated post
-		updatedPost.UserID = uint(userID)
-		updatedPost.Title = title
-		updatedPost.Content = content
+func PatchPostHandler(postService service.PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the post ID from the URL parameters
+		vars := mux.Vars(r)
+		id, ok := vars["id"]
+		if !ok {
+			http.Error(w, "Post ID is missing in URL", http.StatusBadRequest)
+			return
+		}
 
-		// Check if the form contains the "title" field
-		if title, ok := r.Form["title"]; ok {
-			updatedPost.Title = title[0]
+		// Parse the ID into an integer
+		postID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
 		}
 
-		// Check if the form contains the "content" field
-		if content, ok := r.Form["content"]; ok {
-			updatedPost.Content = content[0]
+		// Read the version the client last saw
+		version, ok := ifMatchVersion(w, r)
+		if !ok {
+			return
 		}
 
-		// Set the ID of the post to be updated
-		updatedPost.ID = uint(postID)
+		// Read the merge patch or JSON patch document
+		patchType, patch, ok := readPatch(w, r)
+		if !ok {
+			return
+		}
 
-		// Call the service method to update the post
-		existingPost, err := postService.UpdatePostByID(r.Context(), uint(postID), updatedPost)
+		// Call the service method to patch the post
+		patchedPost, err := postService.PatchPostByID(r.Context(), uint(postID), version, patchType, patch)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), serviceErrorStatus(err))
 			return
 		}
 
-		// Respond with the updated post
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(existingPost)
+		// Respond with the patched post
+		writeUpdated(w, r, patchedPost.Version, patchedPost)
 	}
 }
 
@@ -200,7 +259,7 @@ func DeletePostHandler(postService service.PostService) http.HandlerFunc {
 		// Call the service method to delete the post
 		err = postService.DeletePostByID(r.Context(), uint(postID))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), serviceErrorStatus(err))
 			return
 		}
 
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+func TestCreatePostHandler(t *testing.T) {
+	var created models.GormPost
+	posts := &fakePostService{createPost: func(ctx context.Context, post models.GormPost) (*models.GormPost, error) {
+		created = post
+		post.ID, post.Version = 9, 1
+		return &post, nil
+	}}
+	create := CreatePostHandler(posts)
+
+	form := url.Values{"user_id": {"7"}, "title": {"Hello"}, "content": {"Hi"}, "tags": {"go, sql", "web"}}
+	w := serve(create, newRequest(http.MethodPost, "/posts", nil, form))
+	checkStatus(t, w, http.StatusOK)
+	if created.UserID != 7 || created.Title != "Hello" || !reflect.DeepEqual([]string(created.Tags), []string{"go", "sql", "web"}) {
+		t.Errorf("service got %+v", created)
+	}
+	if body := decodeBody(t, w); body["ID"] != float64(9) || body["Title"] != "Hello" {
+		t.Errorf("body = %v", body)
+	}
+
+	w = serve(create, newRequest(http.MethodPost, "/posts", nil, url.Values{"user_id": {"me"}}))
+	checkStatus(t, w, http.StatusBadRequest)
+
+	posts.createPost = func(ctx context.Context, post models.GormPost) (*models.GormPost, error) {
+		return nil, service.ErrUserNotFound
+	}
+	w = serve(create, newRequest(http.MethodPost, "/posts", nil, form))
+	checkStatus(t, w, http.StatusBadRequest)
+
+	posts.createPost = func(ctx context.Context, post models.GormPost) (*models.GormPost, error) {
+		return nil, service.ErrConflict
+	}
+	w = serve(create, newRequest(http.MethodPost, "/posts", nil, form))
+	checkStatus(t, w, http.StatusConflict)
+}
+
+func TestGetPostHandler(t *testing.T) {
+	posts := &fakePostService{getPostByID: func(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormPost, error) {
+		if id != 9 {
+			return nil, service.ErrNotFound
+		}
+		post := &models.GormPost{Version: 5, UserID: 7, Title: "Hello", Content: "Hi"}
+		post.ID = id
+		return post, nil
+	}}
+	views := &fakeViewService{}
+	get := GetPostHandler(posts, views)
+
+	r := newRequest(http.MethodGet, "/posts/9?fields[post]=title", map[string]string{"id": "9"}, nil)
+	r.Header.Set("User-Agent", "test")
+	w := serve(get, r)
+	checkStatus(t, w, http.StatusOK)
+	body := decodeBody(t, w)
+	if body["ID"] != float64(9) || body["Title"] != "Hello" {
+		t.Errorf("body = %v", body)
+	}
+	if _, ok := body["Content"]; ok {
+		t.Errorf("body = %v, want no Content", body)
+	}
+	if len(views.visits) != 1 || views.visits[0].PostID != 9 || views.visits[0].UserAgent != "test" {
+		t.Errorf("visits = %+v", views.visits)
+	}
+
+	// a revalidated read is still a view
+	r = newRequest(http.MethodGet, "/posts/9?fields[post]=title", map[string]string{"id": "9"}, nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = serve(get, r)
+	checkStatus(t, w, http.StatusNotModified)
+	if len(views.visits) != 2 {
+		t.Errorf("visits = %+v, want 2", views.visits)
+	}
+
+	w = serve(get, newRequest(http.MethodGet, "/posts/9?include=likes", map[string]string{"id": "9"}, nil))
+	checkStatus(t, w, http.StatusBadRequest)
+
+	// a missing post is not found, and not a view
+	w = serve(get, newRequest(http.MethodGet, "/posts/12", map[string]string{"id": "12"}, nil))
+	checkStatus(t, w, http.StatusNotFound)
+	if len(views.visits) != 2 {
+		t.Errorf("visits = %+v, want 2", views.visits)
+	}
+}
+
+func TestGetPostHandlerReactions(t *testing.T) {
+	counts := models.ReactionCounts{"like": 1}
+	posts := &fakePostService{getPostByID: func(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormPost, error) {
+		post := &models.GormPost{Version: 5, Title: "Hello", ReactionCounts: counts}
+		post.ID = id
+		return post, nil
+	}}
+	get := GetPostHandler(posts, &fakeViewService{})
+
+	w := serve(get, newRequest(http.MethodGet, "/posts/9", map[string]string{"id": "9"}, nil))
+	checkStatus(t, w, http.StatusOK)
+	etag := checkRepresentationTag(t, w, 5)
+
+	// a reaction leaves the version alone but changes the representation
+	counts["like"]++
+	r := newRequest(http.MethodGet, "/posts/9", map[string]string{"id": "9"}, nil)
+	r.Header.Set("If-None-Match", etag)
+	w = serve(get, r)
+	checkStatus(t, w, http.StatusOK)
+	if got := checkRepresentationTag(t, w, 5); got == etag {
+		t.Errorf("ETag %s did not change with the reaction counts", got)
+	}
+	if body := decodeBody(t, w); body["ReactionCounts"].(map[string]interface{})["like"] != float64(2) {
+		t.Errorf("body = %v", body)
+	}
+
+	// the tag of a representation still names its version under If-Match
+	var gotVersion uint
+	posts.patchPost = func(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormPost, error) {
+		gotVersion = version
+		return &models.GormPost{Version: version + 1}, nil
+	}
+	r = newRequest(http.MethodPatch, "/posts/9", map[string]string{"id": "9"}, `{"title":"Hi"}`)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("If-Match", etag)
+	checkStatus(t, serve(PatchPostHandler(posts), r), http.StatusOK)
+	if gotVersion != 5 {
+		t.Errorf("service got version %d, want 5", gotVersion)
+	}
+}
+
+func TestGetPostHandlerPerReader(t *testing.T) {
+	// reader 1 bookmarked the post, reader 2 did not
+	posts := &fakePostService{getPostByID: func(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormPost, error) {
+		post := &models.GormPost{Version: 5, Title: "Hello"}
+		post.ID = id
+		if reader, ok := service.ReaderFrom(ctx); ok {
+			bookmarked := reader == 1
+			post.Bookmarked = &bookmarked
+		}
+		return post, nil
+	}}
+	get := Authenticate(GetPostHandler(posts, &fakeViewService{}))
+	read := func(reader string, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := newRequest(http.MethodGet, "/posts/9", map[string]string{"id": "9"}, nil)
+		if reader != "" {
+			r.Header.Set(ReaderHeader, reader)
+		}
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		get.ServeHTTP(w, r)
+		return w
+	}
+
+	tags := map[string]string{}
+	for _, reader := range []string{"", "1", "2"} {
+		w := read(reader, "")
+		checkStatus(t, w, http.StatusOK)
+		checkHeader(t, w, "Vary", ReaderHeader)
+		tags[reader] = checkRepresentationTag(t, w, 5)
+	}
+	if tags[""] == tags["1"] || tags["1"] == tags["2"] || tags[""] == tags["2"] {
+		t.Fatalf("readers with different bookmarks share ETags: %v", tags)
+	}
+
+	// a reader revalidates their own copy, but not another reader's
+	checkStatus(t, read("1", tags["1"]), http.StatusNotModified)
+	w := read("2", tags["1"])
+	checkStatus(t, w, http.StatusOK)
+	if body := decodeBody(t, w); body["Bookmarked"] != false {
+		t.Errorf("body = %v, want Bookmarked false", body)
+	}
+	checkStatus(t, read("", tags["1"]), http.StatusOK)
+}
+
+func TestUpdatePostHandler(t *testing.T) {
+	var updated models.GormPost
+	posts := &fakePostService{updatePost: func(ctx context.Context, id uint, post models.GormPost) (*models.GormPost, error) {
+		updated = post
+		post.Version++
+		return &post, nil
+	}}
+	update := UpdatePostHandler(posts)
+	form := url.Values{"user_id": {"7"}, "title": {"Hello"}, "content": {"Hi"}, "is_published": {"true"}}
+
+	r := newRequest(http.MethodPut, "/posts/9", map[string]string{"id": "9"}, form)
+	r.Header.Set("If-Match", `"5"`)
+	w := serve(update, r)
+	checkStatus(t, w, http.StatusOK)
+	checkHeader(t, w, "ETag", `"6"`)
+	if updated.ID != 9 || updated.Version != 5 || !updated.IsPublished {
+		t.Errorf("service got %+v", updated)
+	}
+
+	form.Set("is_published", "maybe")
+	w = serve(update, newRequest(http.MethodPut, "/posts/9", map[string]string{"id": "9"}, form))
+	checkStatus(t, w, http.StatusBadRequest)
+}
+
+func TestPatchPostHandler(t *testing.T) {
+	posts := &fakePostService{patchPost: func(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormPost, error) {
+		return nil, service.ErrInvalidPatch
+	}}
+
+	r := newRequest(http.MethodPatch, "/posts/9", map[string]string{"id": "9"}, `[{"op":"jump"}]`)
+	r.Header.Set("Content-Type", service.JSONPatchType)
+	checkStatus(t, serve(PatchPostHandler(posts), r), http.StatusUnprocessableEntity)
+}
+
+func TestDeletePostHandler(t *testing.T) {
+	posts := &fakePostService{deletePost: func(ctx context.Context, id uint) error {
+		if id != 9 {
+			return service.ErrNotFound
+		}
+		return nil
+	}}
+	remove := DeletePostHandler(posts)
+
+	w := serve(remove, newRequest(http.MethodDelete, "/posts/9", map[string]string{"id": "9"}, nil))
+	checkStatus(t, w, http.StatusOK)
+	if body := decodeBody(t, w); body["message"] != "Post deleted successfully!" {
+		t.Errorf("body = %v", body)
+	}
+
+	w = serve(remove, newRequest(http.MethodDelete, "/posts/10", map[string]string{"id": "10"}, nil))
+	checkStatus(t, w, http.StatusNotFound)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/gorilla/mux"
+)
+
+// react is one of the ReactionService methods, bound to a post or comment.
+type react func(ctx context.Context, id uint, userID uint, reactionType string) (models.ReactionCounts, error)
+
+func AddPostReactionHandler(reactionService service.ReactionService) http.HandlerFunc {
+	return reactionHandler("post", reactionService.AddPostReaction)
+}
+
+func RemovePostReactionHandler(reactionService service.ReactionService) http.HandlerFunc {
+	return reactionHandler("post", reactionService.RemovePostReaction)
+}
+
+func AddCommentReactionHandler(reactionService service.ReactionService) http.HandlerFunc {
+	return reactionHandler("comment", reactionService.AddCommentReaction)
+}
+
+func RemoveCommentReactionHandler(reactionService service.ReactionService) http.HandlerFunc {
+	return reactionHandler("comment", reactionService.RemoveCommentReaction)
+}
+
+// reactionHandler toggles the {type} reaction of user_id, read from the form
+// or the query string, on the post or comment {id}. PUT and DELETE are both
+// idempotent, so repeating either answers with the same counts.
+func reactionHandler(target string, toggle react) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the post or comment ID and the reaction type from the URL parameters
+		vars := mux.Vars(r)
+		id, err := strconv.ParseUint(vars["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid "+target+" ID", http.StatusBadRequest)
+			return
+		}
+
+		// Parse form data
+		err = r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form data", http.StatusBadRequest)
+			return
+		}
+
+		// Validate and convert form values
+		userID, err := strconv.ParseUint(r.Form.Get("user_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		// Call the service method to add or remove the reaction
+		counts, err := toggle(r.Context(), uint(id), uint(userID), vars["type"])
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the reaction counts as they are now
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(counts)
+	}
+}
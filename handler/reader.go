@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+// ReaderHeader names the authenticated user a request is made by. The API
+// has no sessions of its own: it expects to sit behind a gateway that
+// authenticates readers, sets the header and drops any sent by clients.
+const ReaderHeader = "X-User-ID"
+
+// Authenticate puts the user named by ReaderHeader in the request context,
+// where services look for the reader. Requests without it are anonymous.
+// Responses can differ by reader, so caches are told to keep them apart.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", ReaderHeader)
+
+		header := r.Header.Get(ReaderHeader)
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := strconv.ParseUint(header, 10, 64)
+		if err != nil || userID == 0 {
+			http.Error(w, "Invalid "+ReaderHeader+" header", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(service.WithReader(r.Context(), uint(userID))))
+	})
+}
+
+// requireReader answers 401 unless the request is by an authenticated user.
+func requireReader(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	userID, ok := service.ReaderFrom(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// prefersMinimal reports whether the client sent "Prefer: return=minimal"
+// (RFC 7240) and so does not want the updated resource echoed back.
+func prefersMinimal(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(preference), "return=minimal") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// includes reports whether name is among the comma separated values of the
+// "include" query parameter, as in ?include=content.
+func includes(r *http.Request, name string) bool {
+	return contains(splitList(r.URL.Query()["include"]), name)
+}
+
+// writeUpdated answers a successful PUT or PATCH with the new ETag and
+// either the updated resource or, when the client asked for it, 204.
+func writeUpdated(w http.ResponseWriter, r *http.Request, version uint, resource interface{}) {
+	w.Header().Set("ETag", versionETag(version))
+
+	if prefersMinimal(r) {
+		w.Header().Set("Preference-Applied", "return=minimal")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resource)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/bellaananda/go-postgresql-blog-http.git/sitemap"
+	"github.com/gorilla/mux"
+)
+
+// startedWriter notes whether any of the body went out, after which an
+// error can only cut the response short rather than replace it.
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *startedWriter) Write(p []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(p)
+}
+
+func GetSitemapHandler(sitemapService service.SitemapService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Call the service method to stream the sitemap
+		streamSitemap(w, func(body *startedWriter) error {
+			return sitemapService.WriteSitemap(r.Context(), body)
+		})
+	}
+}
+
+func GetSitemapPartHandler(sitemapService service.SitemapService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the number of the part from the URL parameters
+		part, err := strconv.Atoi(mux.Vars(r)["part"])
+		if err != nil {
+			http.Error(w, "Invalid sitemap part", http.StatusBadRequest)
+			return
+		}
+
+		// Call the service method to stream the part
+		streamSitemap(w, func(body *startedWriter) error {
+			return sitemapService.WriteSitemapPart(r.Context(), part, body)
+		})
+	}
+}
+
+// streamSitemap runs write against the response and answers an error with
+// its status, unless the sitemap had already started going out.
+func streamSitemap(w http.ResponseWriter, write func(body *startedWriter) error) {
+	w.Header().Set("Content-Type", sitemap.ContentType)
+
+	body := &startedWriter{ResponseWriter: w}
+	if err := write(body); err != nil && !body.started {
+		http.Error(w, err.Error(), serviceErrorStatus(err))
+	}
+}
+
+func GetRobotsHandler(sitemapService service.SitemapService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(sitemapService.RobotsTxt(r.Context())))
+	}
+}
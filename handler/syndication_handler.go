@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/bellaananda/go-postgresql-blog-http.git/syndication"
+	"github.com/gorilla/mux"
+)
+
+// feedCacheControl lets caches keep a feed for a few minutes; readers
+// polling more often than that revalidate with If-None-Match.
+const feedCacheControl = "public, max-age=300"
+
+func GetSiteFeedHandler(syndicationService service.SyndicationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The format comes from the extension, ?content= picks what items carry
+		options, ok := readFeedOptions(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to build the feed
+		feed, err := syndicationService.SiteFeed(r.Context(), options)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		serveFeed(w, r, feed, options)
+	}
+}
+
+func GetAuthorFeedHandler(syndicationService service.SyndicationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the ID of the author from the URL parameters
+		userID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		// The format comes from the extension, ?content= picks what items carry
+		options, ok := readFeedOptions(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to build the feed
+		feed, err := syndicationService.AuthorFeed(r.Context(), uint(userID), options)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		serveFeed(w, r, feed, options)
+	}
+}
+
+func GetTagFeedHandler(syndicationService service.SyndicationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The format comes from the extension, ?content= picks what items carry
+		options, ok := readFeedOptions(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to build the feed
+		feed, err := syndicationService.TagFeed(r.Context(), mux.Vars(r)["tag"], options)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		serveFeed(w, r, feed, options)
+	}
+}
+
+// readFeedOptions reads the format from the route and ?content=, which is
+// "excerpt", the default, or "full". ok is false when an error response has
+// already been written.
+func readFeedOptions(w http.ResponseWriter, r *http.Request) (service.FeedOptions, bool) {
+	options := service.FeedOptions{Format: mux.Vars(r)["format"]}
+
+	switch r.URL.Query().Get("content") {
+	case "", "excerpt":
+	case "full":
+		options.Full = true
+	default:
+		http.Error(w, "Invalid content, want excerpt or full", http.StatusBadRequest)
+		return options, false
+	}
+
+	return options, true
+}
+
+// serveFeed encodes feed in the requested format and answers conditional
+// requests: the ETag is a hash of the encoded feed, so it changes with
+// anything in it, and Last-Modified is when the latest post was updated.
+func serveFeed(w http.ResponseWriter, r *http.Request, feed *syndication.Feed, options service.FeedOptions) {
+	encode, contentType := syndication.RSS, syndication.RSSType
+	if options.Format == "atom" {
+		encode, contentType = syndication.Atom, syndication.AtomType
+	}
+
+	body, err := encode(*feed)
+	if err != nil {
+		http.Error(w, "Error encoding the feed", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", feedCacheControl)
+	w.Header().Set("Content-Type", contentType)
+
+	// ServeContent answers If-None-Match / If-Modified-Since with a 304
+	http.ServeContent(w, r, "", feed.Updated, bytes.NewReader(body))
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/content"
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/bellaananda/go-postgresql-blog-http.git/syndication"
+	"github.com/bellaananda/go-postgresql-blog-http.git/syndication/syndicationtest"
+	"github.com/gorilla/mux"
+)
+
+// newFeedRouter routes the site and tag feeds as the API does, over the
+// syndication service backed by an in-memory store holding two posts.
+func newFeedRouter(t *testing.T) *mux.Router {
+	t.Helper()
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+
+	author, err := repo.CreateUser(ctx, models.GormUser{Name: "Ann & Bo", Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	published := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	posts := []models.GormPost{
+		{UserID: author.ID, Title: "Ampersands & <angle brackets>", Content: "Some *markdown* with a ]]> in it", Excerpt: "An excerpt", Tags: models.Tags{"go"}},
+		{UserID: author.ID, Title: "Second", Content: "More", Tags: models.Tags{"go", "sql"}},
+	}
+	for i, post := range posts {
+		post.IsPublished = true
+		post.PublishedAt = published.Add(time.Duration(i) * time.Hour)
+		if _, err := repo.CreatePost(ctx, post); err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+	}
+
+	site := service.Site{URL: "https://blog.example.com", Title: "Blog", Description: "The latest posts"}
+	feeds := service.NewSyndicationService(repo, repo, site, content.NewCache(10))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feed.{format:rss|atom}", GetSiteFeedHandler(feeds)).Methods("GET", "HEAD")
+	router.HandleFunc("/tags/{tag}/feed.{format:rss|atom}", GetTagFeedHandler(feeds)).Methods("GET", "HEAD")
+	return router
+}
+
+func TestFeedHandlers(t *testing.T) {
+	router := newFeedRouter(t)
+
+	tests := []struct {
+		target      string
+		contentType string
+		check       func(testing.TB, []byte)
+	}{
+		{"/feed.rss", syndication.RSSType, syndicationtest.CheckRSS},
+		{"/feed.rss?content=full", syndication.RSSType, syndicationtest.CheckRSS},
+		{"/feed.atom", syndication.AtomType, syndicationtest.CheckAtom},
+		{"/feed.atom?content=full", syndication.AtomType, syndicationtest.CheckAtom},
+		{"/tags/sql/feed.atom", syndication.AtomType, syndicationtest.CheckAtom},
+	}
+
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.target, nil))
+			checkStatus(t, w, http.StatusOK)
+			checkHeader(t, w, "Content-Type", test.contentType)
+			checkHeader(t, w, "Cache-Control", feedCacheControl)
+			test.check(t, w.Body.Bytes())
+			if !strings.Contains(w.Body.String(), ">Second</title>") {
+				t.Errorf("feed lacks the latest post:\n%s", w.Body.String())
+			}
+
+			// the ETag revalidates the same feed
+			r := httptest.NewRequest(http.MethodGet, test.target, nil)
+			r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+			revalidated := httptest.NewRecorder()
+			router.ServeHTTP(revalidated, r)
+			checkStatus(t, revalidated, http.StatusNotModified)
+		})
+	}
+
+	t.Run("invalid content", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed.rss?content=all", nil))
+		checkStatus(t, w, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/gorilla/mux"
+)
+
+// thumbnailCacheControl lets browsers and proxies keep a thumbnail for an
+// hour; after that the ETag makes revalidation cheap.
+const thumbnailCacheControl = "public, max-age=3600"
+
+func UploadThumbnailHandler(postService service.PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the post ID from the URL parameters
+		postID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		// Read the version the client last saw
+		version, ok := ifMatchVersion(w, r)
+		if !ok {
+			return
+		}
+
+		// Leave room for the multipart framing around the file itself
+		r.Body = http.MaxBytesReader(w, r.Body, service.MaxThumbnailSize+64<<10)
+
+		// Stream the multipart body to the "thumbnail" part
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, "Expected a multipart/form-data upload", http.StatusBadRequest)
+			return
+		}
+		var image io.Reader
+		for image == nil {
+			part, err := reader.NextPart()
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, service.ErrTooLarge.Error(), http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, `Missing "thumbnail" file part`, http.StatusBadRequest)
+				return
+			}
+			if part.FormName() == "thumbnail" {
+				image = part
+			}
+		}
+
+		// Call the service method to store the thumbnail
+		post, err := postService.SetThumbnail(r.Context(), uint(postID), version, image)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				err = service.ErrTooLarge
+			}
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the post as stored
+		writeUpdated(w, r, post.Version, post)
+	}
+}
+
+func GetThumbnailHandler(postService service.PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the post ID from the URL parameters
+		postID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		// An optional ?w= picks the variant for that width
+		width := 0
+		if value := r.URL.Query().Get("w"); value != "" {
+			width, err = strconv.Atoi(value)
+			if err != nil || width < 1 {
+				http.Error(w, "Invalid width", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Call the service method to open the thumbnail
+		blob, err := postService.GetThumbnail(r.Context(), uint(postID), width)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+		defer blob.Close()
+
+		// Every upload gets a new key, so the key identifies the content
+		w.Header().Set("ETag", `"`+blob.Key+`"`)
+		w.Header().Set("Cache-Control", thumbnailCacheControl)
+		w.Header().Set("Content-Type", blob.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		// ServeContent answers ranges and If-None-Match / If-Modified-Since
+		http.ServeContent(w, r, "", blob.ModTime, blob)
+	}
+}
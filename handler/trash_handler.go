@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/gorilla/mux"
+)
+
+// trashItemID reads the {id} route variable, writing a 400 when it is not a
+// valid ID.
+func trashItemID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func GetDeletedUsersHandler(trashService service.TrashService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the page asked for
+		page, ok := readPage(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to get the page of deleted users
+		users, total, err := trashService.GetDeletedUsers(r.Context(), page)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Respond with the users and links to the other pages
+		writePageHeaders(w, r, page, total)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(users)
+	}
+}
+
+func GetDeletedPostsHandler(trashService service.TrashService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the page asked for
+		page, ok := readPage(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to get the page of deleted posts
+		posts, total, err := trashService.GetDeletedPosts(r.Context(), page)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Respond with the posts and links to the other pages
+		writePageHeaders(w, r, page, total)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(posts)
+	}
+}
+
+func GetDeletedCommentsHandler(trashService service.TrashService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the page asked for
+		page, ok := readPage(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to get the page of deleted comments
+		comments, total, err := trashService.GetDeletedComments(r.Context(), page)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Respond with the comments and links to the other pages
+		writePageHeaders(w, r, page, total)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(comments)
+	}
+}
+
+func RestoreUserHandler(trashService service.TrashService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the user ID from the URL parameters
+		userID, ok := trashItemID(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to restore the user
+		user, err := trashService.RestoreUserByID(r.Context(), userID)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the restored user
+		w.Header().Set("ETag", versionETag(user.Version))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+func RestorePostHandler(trashService service.TrashService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the post ID from the URL parameters
+		postID, ok := trashItemID(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to restore the post
+		post, err := trashService.RestorePostByID(r.Context(), postID)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the restored post
+		w.Header().Set("ETag", versionETag(post.Version))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(post)
+	}
+}
+
+func RestoreCommentHandler(trashService service.TrashService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the comment ID from the URL parameters
+		commentID, ok := trashItemID(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to restore the comment
+		comment, err := trashService.RestoreCommentByID(r.Context(), commentID)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the restored comment
+		w.Header().Set("ETag", versionETag(comment.Version))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(comment)
+	}
+}
+
+func PurgeUserHandler(trashService service.TrashService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the user ID from the URL parameters
+		userID, ok := trashItemID(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to remove the user for good
+		if err := trashService.PurgeUserByID(r.Context(), userID); err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func PurgePostHandler(trashService service.TrashService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the post ID from the URL parameters
+		postID, ok := trashItemID(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to remove the post for good
+		if err := trashService.PurgePostByID(r.Context(), postID); err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func PurgeCommentHandler(trashService service.TrashService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the comment ID from the URL parameters
+		commentID, ok := trashItemID(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to remove the comment for good
+		if err := trashService.PurgeCommentByID(r.Context(), commentID); err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
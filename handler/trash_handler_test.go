@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+func TestGetDeletedUsersHandler(t *testing.T) {
+	var gotPage service.Page
+	trash := &fakeTrashService{getDeletedUsers: func(ctx context.Context, page service.Page) ([]models.GormUser, int64, error) {
+		gotPage = page
+		user := models.GormUser{Username: "ann", Password: "secret"}
+		user.ID = 7
+		return []models.GormUser{user}, 3, nil
+	}}
+	list := GetDeletedUsersHandler(trash)
+
+	w := serve(list, newRequest(http.MethodGet, "/api/trash/users?page=2&per_page=1", nil, nil))
+	checkStatus(t, w, http.StatusOK)
+	if gotPage != (service.Page{Number: 2, Size: 1}) {
+		t.Errorf("service got page %+v, want 2 of size 1", gotPage)
+	}
+	checkHeader(t, w, "X-Total-Count", "3")
+	checkHeader(t, w, "Link", `</api/trash/users?page=1&per_page=1>; rel="first", </api/trash/users?page=1&per_page=1>; rel="prev", </api/trash/users?page=3&per_page=1>; rel="next", </api/trash/users?page=3&per_page=1>; rel="last"`)
+
+	var users []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil || len(users) != 1 {
+		t.Fatalf("body = %s, %v, want one user", w.Body.String(), err)
+	}
+	checkNoPassword(t, users[0])
+
+	w = serve(list, newRequest(http.MethodGet, "/api/trash/users?page=0", nil, nil))
+	checkStatus(t, w, http.StatusBadRequest)
+}
@@ -48,16 +48,21 @@ func CreateUserHandler(userService service.UserService) http.HandlerFunc {
 
 func GetAllUsersHandler(userService service.UserService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the fields and relations asked for
+		fieldset, ok := readFieldset(w, r, "user")
+		if !ok {
+			return
+		}
+
 		// Call the service method to get the users
-		users, err := userService.GetAllUsers(r.Context())
+		users, err := userService.GetAllUsers(r.Context(), fieldset)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// Respond with the users
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(users)
+		writeFieldset(w, fieldset, users)
 	}
 }
 
@@ -82,16 +87,21 @@ func GetUserHandler(userService service.UserService) http.HandlerFunc {
 		// Convert the ID to uint
 		uid := uint(id)
 
+		// Read the fields and relations asked for
+		fieldset, ok := readFieldset(w, r, "user")
+		if !ok {
+			return
+		}
+
 		// Call the service method to get the user by id
-		user, err := userService.GetUserByID(r.Context(), uid)
+		user, err := userService.GetUserByID(r.Context(), uid, fieldset)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Respond with the user
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(user)
+		// Respond with the user, or 304 when the client already has it
+		writeVersioned(w, r, user.Version, fieldset, user)
 	}
 }
 
@@ -112,48 +122,85 @@ func UpdateUserHandler(userService service.UserService) http.HandlerFunc {
 			return
 		}
 
+		// Read the version the client last saw
+		version, ok := ifMatchVersion(w, r)
+		if !ok {
+			return
+		}
+
 		// Parse the form data
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Error parsing form data", http.StatusBadRequest)
 			return
 		}
 
-		// Initialize an empty GormUser
-		var updatedUser models.GormUser
+		// PUT replaces the whole user, so every field must be present
+		if !requireFormFields(w, r, "name", "email", "password", "username") {
+			return
+		}
 
-		// Check if the form contains the "name" field
-		if name, ok := r.Form["name"]; ok {
-			updatedUser.Name = name[0]
+		// Initialize the replacement GormUser
+		updatedUser := models.GormUser{
+			Name:     r.Form.Get("name"),
+			Email:    r.Form.Get("email"),
+			Password: r.Form.Get("password"),
+			Username: r.Form.Get("username"),
 		}
 
-		// Check if the form contains the "email" field
-		if email, ok := r.Form["email"]; ok {
-			updatedUser.Email = email[0]
+		// Set the ID of the user to be updated
+		updatedUser.ID = uint(userID)
+		updatedUser.Version = version
+
+		// Call the service method to update the user
+		savedUser, err := userService.UpdateUserByID(r.Context(), uint(userID), updatedUser)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
 		}
 
-		// Check if the form contains the "password" field
-		if password, ok := r.Form["password"]; ok {
-			updatedUser.Password = password[0]
+		// Respond with the user as stored
+		writeUpdated(w, r, savedUser.Version, savedUser)
+	}
+}
+
+func PatchUserHandler(userService service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the user ID from the URL parameters
+		vars := mux.Vars(r)
+		id, ok := vars["id"]
+		if !ok {
+			http.Error(w, "User ID is missing in URL", http.StatusBadRequest)
+			return
 		}
 
-		// Check if the form contains the "username" field
-		if username, ok := r.Form["username"]; ok {
-			updatedUser.Username = username[0]
+		// Parse the ID into an integer
+		userID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
 		}
 
-		// Set the ID of the user to be updated
-		updatedUser.ID = uint(userID)
+		// Read the version the client last saw
+		version, ok := ifMatchVersion(w, r)
+		if !ok {
+			return
+		}
 
-		// Call the service method to update the user
-		existingUser, err := userService.UpdateUserByID(r.Context(), uint(userID), updatedUser)
+		// Read the merge patch or JSON patch document
+		patchType, patch, ok := readPatch(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to patch the user
+		patchedUser, err := userService.PatchUserByID(r.Context(), uint(userID), version, patchType, patch)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), serviceErrorStatus(err))
 			return
 		}
 
-		// Respond with the updated user
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(existingUser)
+		// Respond with the patched user
+		writeUpdated(w, r, patchedUser.Version, patchedUser)
 	}
 }
 
@@ -177,7 +224,7 @@ func DeleteUserHandler(userService service.UserService) http.HandlerFunc {
 		// Call the service method to delete the user
 		err = userService.DeleteUserByID(r.Context(), uint(userID))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), serviceErrorStatus(err))
 			return
 		}
 
@@ -186,3 +233,48 @@ func DeleteUserHandler(userService service.UserService) http.HandlerFunc {
 		json.NewEncoder(w).Encode(map[string]string{"message": "User deleted successfully"})
 	}
 }
+
+func RequestPasswordResetHandler(userService service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse form data and check the email is there
+		if !requireFormFields(w, r, "email") {
+			return
+		}
+
+		// Call the service method to mail a reset link
+		err := userService.RequestPasswordReset(r.Context(), r.Form.Get("email"))
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond the same whether or not the address is known
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"message": "If the address is known, a reset link is on its way"})
+	}
+}
+
+func ResetPasswordHandler(userService service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse form data; the token travels in the body to stay out of logs
+		if !requireFormFields(w, r, "token", "password") {
+			return
+		}
+		password := r.Form.Get("password")
+		if password == "" {
+			http.Error(w, "Password must not be empty", http.StatusBadRequest)
+			return
+		}
+
+		// Call the service method to set the new password
+		err := userService.ResetPassword(r.Context(), r.Form.Get("token"), password)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with no content
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
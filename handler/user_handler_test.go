@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+func TestCreateUserHandler(t *testing.T) {
+	var created models.GormUser
+	users := &fakeUserService{createUser: func(ctx context.Context, user models.GormUser) (*models.GormUser, error) {
+		created = user
+		user.ID, user.Version = 7, 1
+		return &user, nil
+	}}
+
+	form := url.Values{"name": {"Ann"}, "email": {"ann@example.com"}, "password": {"pw"}, "username": {"ann"}}
+	w := serve(CreateUserHandler(users), newRequest(http.MethodPost, "/users", nil, form))
+
+	checkStatus(t, w, http.StatusOK)
+	if created.Name != "Ann" || created.Email != "ann@example.com" || created.Password != "pw" || created.Username != "ann" {
+		t.Errorf("service got %+v", created)
+	}
+	body := decodeBody(t, w)
+	if body["ID"] != float64(7) || body["Username"] != "ann" {
+		t.Errorf("body = %v", body)
+	}
+	checkNoPassword(t, body)
+
+	users.createUser = func(ctx context.Context, user models.GormUser) (*models.GormUser, error) {
+		return nil, service.ErrConflict
+	}
+	w = serve(CreateUserHandler(users), newRequest(http.MethodPost, "/users", nil, form))
+	checkStatus(t, w, http.StatusInternalServerError)
+}
+
+func TestGetUserHandler(t *testing.T) {
+	var gotID uint
+	users := &fakeUserService{getUserByID: func(ctx context.Context, id uint, fieldset service.Fieldset) (*models.GormUser, error) {
+		gotID = id
+		user := &models.GormUser{Version: 3, Name: "Ann", Email: "ann@example.com", Password: "secret", Username: "ann"}
+		user.ID = id
+		return user, nil
+	}}
+	get := GetUserHandler(users)
+
+	t.Run("ok", func(t *testing.T) {
+		w := serve(get, newRequest(http.MethodGet, "/users/7", map[string]string{"id": "7"}, nil))
+		checkStatus(t, w, http.StatusOK)
+		checkRepresentationTag(t, w, 3)
+		if gotID != 7 {
+			t.Errorf("service got ID %d, want 7", gotID)
+		}
+		body := decodeBody(t, w)
+		if body["Email"] != "ann@example.com" {
+			t.Errorf("body = %v", body)
+		}
+		checkNoPassword(t, body)
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		w := serve(get, newRequest(http.MethodGet, "/users/7?fields[user]=username", map[string]string{"id": "7"}, nil))
+		checkStatus(t, w, http.StatusOK)
+		body := decodeBody(t, w)
+		if body["Username"] != "ann" {
+			t.Errorf("body = %v, want Username", body)
+		}
+		if _, ok := body["Email"]; ok {
+			t.Errorf("body = %v, want no Email", body)
+		}
+	})
+
+	t.Run("fields revalidate apart", func(t *testing.T) {
+		w := serve(get, newRequest(http.MethodGet, "/users/7?fields[user]=username", map[string]string{"id": "7"}, nil))
+		narrowed := checkRepresentationTag(t, w, 3)
+
+		r := newRequest(http.MethodGet, "/users/7", map[string]string{"id": "7"}, nil)
+		r.Header.Set("If-None-Match", narrowed)
+		w = serve(get, r)
+		checkStatus(t, w, http.StatusOK)
+		if checkRepresentationTag(t, w, 3) == narrowed {
+			t.Errorf("full and narrowed reads share ETag %s", narrowed)
+		}
+
+		r = newRequest(http.MethodGet, "/users/7?fields[user]=username,username", map[string]string{"id": "7"}, nil)
+		r.Header.Set("If-None-Match", narrowed)
+		checkStatus(t, serve(get, r), http.StatusNotModified)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		w := serve(get, newRequest(http.MethodGet, "/users/7?fields[user]=password", map[string]string{"id": "7"}, nil))
+		checkStatus(t, w, http.StatusBadRequest)
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		w := serve(get, newRequest(http.MethodGet, "/users/7", map[string]string{"id": "7"}, nil))
+		etag := checkRepresentationTag(t, w, 3)
+
+		r := newRequest(http.MethodGet, "/users/7", map[string]string{"id": "7"}, nil)
+		r.Header.Set("If-None-Match", `"2", `+etag)
+		w = serve(get, r)
+		checkStatus(t, w, http.StatusNotModified)
+		checkHeader(t, w, "ETag", etag)
+		if w.Body.Len() != 0 {
+			t.Errorf("body = %q, want none", w.Body.String())
+		}
+	})
+
+	t.Run("invalid ID", func(t *testing.T) {
+		w := serve(get, newRequest(http.MethodGet, "/users/x", map[string]string{"id": "x"}, nil))
+		checkStatus(t, w, http.StatusBadRequest)
+	})
+}
+
+func TestUpdateUserHandler(t *testing.T) {
+	form := url.Values{"name": {"Ann"}, "email": {"ann@example.com"}, "password": {"pw"}, "username": {"ann"}}
+	var updated models.GormUser
+	users := &fakeUserService{updateUser: func(ctx context.Context, id uint, user models.GormUser) (*models.GormUser, error) {
+		updated = user
+		if user.Version != 0 && user.Version != 3 {
+			return nil, service.ErrVersionConflict
+		}
+		user.Version = 4
+		return &user, nil
+	}}
+	update := UpdateUserHandler(users)
+
+	t.Run("ok", func(t *testing.T) {
+		r := newRequest(http.MethodPut, "/users/7", map[string]string{"id": "7"}, form)
+		r.Header.Set("If-Match", `"3"`)
+		w := serve(update, r)
+		checkStatus(t, w, http.StatusOK)
+		checkHeader(t, w, "ETag", `"4"`)
+		if updated.ID != 7 || updated.Version != 3 || updated.Username != "ann" {
+			t.Errorf("service got %+v", updated)
+		}
+		body := decodeBody(t, w)
+		if body["Version"] != float64(4) {
+			t.Errorf("body = %v", body)
+		}
+		checkNoPassword(t, body)
+	})
+
+	t.Run("return minimal", func(t *testing.T) {
+		r := newRequest(http.MethodPut, "/users/7", map[string]string{"id": "7"}, form)
+		r.Header.Set("Prefer", "return=minimal")
+		w := serve(update, r)
+		checkStatus(t, w, http.StatusNoContent)
+		checkHeader(t, w, "ETag", `"4"`)
+		checkHeader(t, w, "Preference-Applied", "return=minimal")
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		r := newRequest(http.MethodPut, "/users/7", map[string]string{"id": "7"}, form)
+		r.Header.Set("If-Match", `"2"`)
+		checkStatus(t, serve(update, r), http.StatusPreconditionFailed)
+	})
+
+	t.Run("malformed If-Match", func(t *testing.T) {
+		r := newRequest(http.MethodPut, "/users/7", map[string]string{"id": "7"}, form)
+		r.Header.Set("If-Match", `W/"3"`)
+		checkStatus(t, serve(update, r), http.StatusPreconditionFailed)
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		r := newRequest(http.MethodPut, "/users/7", map[string]string{"id": "7"}, url.Values{"name": {"Ann"}})
+		w := serve(update, r)
+		checkStatus(t, w, http.StatusBadRequest)
+	})
+
+	t.Run("If-Match required", func(t *testing.T) {
+		RequireIfMatch = true
+		defer func() { RequireIfMatch = false }()
+		r := newRequest(http.MethodPut, "/users/7", map[string]string{"id": "7"}, form)
+		checkStatus(t, serve(update, r), http.StatusPreconditionRequired)
+	})
+}
+
+func TestPatchUserHandler(t *testing.T) {
+	var gotType, gotPatch string
+	users := &fakeUserService{patchUser: func(ctx context.Context, id uint, version uint, patchType string, patch []byte) (*models.GormUser, error) {
+		gotType, gotPatch = patchType, string(patch)
+		if id != 7 {
+			return nil, service.ErrNotFound
+		}
+		user := &models.GormUser{Version: version + 1, Name: "Bo", Password: "secret"}
+		user.ID = id
+		return user, nil
+	}}
+	patch := PatchUserHandler(users)
+
+	t.Run("merge patch", func(t *testing.T) {
+		r := newRequest(http.MethodPatch, "/users/7", map[string]string{"id": "7"}, `{"name":"Bo"}`)
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("If-Match", `"3"`)
+		w := serve(patch, r)
+		checkStatus(t, w, http.StatusOK)
+		checkHeader(t, w, "ETag", `"4"`)
+		if gotType != service.MergePatchType || gotPatch != `{"name":"Bo"}` {
+			t.Errorf("service got %s %s", gotType, gotPatch)
+		}
+		body := decodeBody(t, w)
+		if body["Name"] != "Bo" {
+			t.Errorf("body = %v", body)
+		}
+		checkNoPassword(t, body)
+	})
+
+	t.Run("unsupported media type", func(t *testing.T) {
+		r := newRequest(http.MethodPatch, "/users/7", map[string]string{"id": "7"}, `name=Bo`)
+		r.Header.Set("Content-Type", "text/plain")
+		w := serve(patch, r)
+		checkStatus(t, w, http.StatusUnsupportedMediaType)
+		checkHeader(t, w, "Accept-Patch", service.MergePatchType+", "+service.JSONPatchType)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		r := newRequest(http.MethodPatch, "/users/8", map[string]string{"id": "8"}, `[]`)
+		r.Header.Set("Content-Type", service.JSONPatchType)
+		checkStatus(t, serve(patch, r), http.StatusNotFound)
+	})
+}
+
+func TestDeleteUserHandler(t *testing.T) {
+	var deleted uint
+	users := &fakeUserService{deleteUser: func(ctx context.Context, id uint) error {
+		deleted = id
+		return nil
+	}}
+
+	w := serve(DeleteUserHandler(users), newRequest(http.MethodDelete, "/users/7", map[string]string{"id": "7"}, nil))
+	checkStatus(t, w, http.StatusOK)
+	if deleted != 7 {
+		t.Errorf("service deleted %d, want 7", deleted)
+	}
+	if body := decodeBody(t, w); body["message"] != "User deleted successfully" {
+		t.Errorf("body = %v", body)
+	}
+
+	users.deleteUser = func(ctx context.Context, id uint) error {
+		return fmt.Errorf("user 7 has posts: %w", service.ErrDeleteBlocked)
+	}
+	w = serve(DeleteUserHandler(users), newRequest(http.MethodDelete, "/users/7", map[string]string{"id": "7"}, nil))
+	checkStatus(t, w, http.StatusConflict)
+
+	w = serve(DeleteUserHandler(users), newRequest(http.MethodDelete, "/users/-1", map[string]string{"id": "-1"}, nil))
+	checkStatus(t, w, http.StatusBadRequest)
+}
+
+// checkNoPassword fails the test when a user body carries the password under
+// any spelling.
+func checkNoPassword(t *testing.T, body map[string]interface{}) {
+	t.Helper()
+	for key := range body {
+		if strings.EqualFold(key, "password") {
+			t.Errorf("body = %v, want no password", body)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+)
+
+// TrustProxy takes the client address from X-Forwarded-For, which only a
+// proxy in front of the server can be trusted to set. Turn it on with
+// TRUST_PROXY=true.
+var TrustProxy = os.Getenv("TRUST_PROXY") == "true"
+
+// visitor tells readers apart for view counting without keeping who they
+// are: authenticated readers by id, anyone else by a hash of their address
+// and user agent.
+func visitor(r *http.Request) string {
+	if readerID, ok := service.ReaderFrom(r.Context()); ok {
+		return "user:" + strconv.FormatUint(uint64(readerID), 10)
+	}
+
+	address := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); TrustProxy && forwarded != "" {
+		address, _, _ = strings.Cut(forwarded, ",")
+		address = strings.TrimSpace(address)
+	}
+
+	sum := sha256.Sum256([]byte(address + "\x00" + r.UserAgent()))
+	return "anon:" + hex.EncodeToString(sum[:16])
+}
+
+// readAnalyticsQuery reads ?from=2024-01-01&to=2024-01-31, both days
+// included, along with interval, post_id, user_id and limit. ok is false
+// when an error response has already been written.
+func readAnalyticsQuery(w http.ResponseWriter, r *http.Request) (service.AnalyticsQuery, bool) {
+	values := r.URL.Query()
+	query := service.AnalyticsQuery{Interval: values.Get("interval")}
+
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+		days  int
+	}{{"from", &query.From, 0}, {"to", &query.To, 1}} {
+		raw := values.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			http.Error(w, "Invalid "+param.name+", want YYYY-MM-DD", http.StatusBadRequest)
+			return query, false
+		}
+		// to is the day after the last one included
+		*param.value = day.AddDate(0, 0, param.days)
+	}
+
+	for _, param := range []struct {
+		name  string
+		value *uint
+	}{{"post_id", &query.PostID}, {"user_id", &query.UserID}} {
+		raw := values.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || id == 0 {
+			http.Error(w, "Invalid "+param.name, http.StatusBadRequest)
+			return query, false
+		}
+		*param.value = uint(id)
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return query, false
+		}
+		query.Limit = limit
+	}
+
+	return query, true
+}
+
+func GetViewsOverTimeHandler(viewService service.ViewService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the period, interval and what to narrow the views down to
+		query, ok := readAnalyticsQuery(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to bucket the views
+		buckets, err := viewService.ViewsOverTime(r.Context(), query)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the buckets
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buckets)
+	}
+}
+
+func GetTopPostsHandler(viewService service.ViewService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the period, limit and what to narrow the views down to
+		query, ok := readAnalyticsQuery(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to rank the posts
+		posts, err := viewService.TopPosts(r.Context(), query)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the posts
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(posts)
+	}
+}
+
+func GetTopAuthorsHandler(viewService service.ViewService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the period and limit
+		query, ok := readAnalyticsQuery(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to rank the authors
+		authors, err := viewService.TopAuthors(r.Context(), query)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the authors
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(authors)
+	}
+}
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/gorilla/mux"
+)
+
+// readWebhookForm reads the url, events and active fields shared by
+// creating and replacing a webhook. Webhooks are active unless active=false
+// is sent.
+func readWebhookForm(w http.ResponseWriter, r *http.Request) (models.GormWebhook, bool) {
+	if !requireFormFields(w, r, "url", "events") {
+		return models.GormWebhook{}, false
+	}
+
+	active := true
+	if value := r.Form.Get("active"); value != "" {
+		var err error
+		active, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid active, want true or false", http.StatusBadRequest)
+			return models.GormWebhook{}, false
+		}
+	}
+
+	return models.GormWebhook{
+		URL:    r.Form.Get("url"),
+		Secret: r.Form.Get("secret"),
+		Events: models.EventTypes(splitList(r.Form["events"])),
+		Active: active,
+	}, true
+}
+
+// readWebhookID parses the webhook ID in the URL.
+func readWebhookID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func CreateWebhookHandler(webhookService service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the url, events=a,b and optional secret and active
+		hook, ok := readWebhookForm(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to create the webhook
+		createdWebhook, err := webhookService.CreateWebhook(r.Context(), hook)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the webhook, and this once with its secret
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(struct {
+			*models.GormWebhook
+			Secret string
+		}{createdWebhook, createdWebhook.Secret})
+	}
+}
+
+func GetWebhooksHandler(webhookService service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Call the service method to get the webhooks
+		webhooks, err := webhookService.GetWebhooks(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the webhooks
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(webhooks)
+	}
+}
+
+func GetWebhookHandler(webhookService service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the webhook ID from the URL parameters
+		id, ok := readWebhookID(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to get the webhook
+		hook, err := webhookService.GetWebhookByID(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the webhook
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hook)
+	}
+}
+
+func UpdateWebhookHandler(webhookService service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the webhook ID from the URL parameters
+		id, ok := readWebhookID(w, r)
+		if !ok {
+			return
+		}
+
+		// Read the url, events=a,b and optional secret and active
+		hook, ok := readWebhookForm(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to replace the webhook
+		updatedWebhook, err := webhookService.UpdateWebhook(r.Context(), id, hook)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the updated webhook
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updatedWebhook)
+	}
+}
+
+func DeleteWebhookHandler(webhookService service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the webhook ID from the URL parameters
+		id, ok := readWebhookID(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to delete the webhook and its deliveries
+		if err := webhookService.DeleteWebhook(r.Context(), id); err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with a success message
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted successfully!"})
+	}
+}
+
+func GetWebhookDeliveriesHandler(webhookService service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the webhook ID from the URL parameters
+		id, ok := readWebhookID(w, r)
+		if !ok {
+			return
+		}
+
+		// Read the page asked for
+		page, ok := readPage(w, r)
+		if !ok {
+			return
+		}
+
+		// Call the service method to get the page of the delivery log,
+		// ?status=dead leaves out the others
+		deliveries, total, err := webhookService.GetDeliveries(r.Context(), id, r.URL.Query().Get("status"), page)
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the deliveries
+		writePageHeaders(w, r, page, total)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deliveries)
+	}
+}
+
+func RedeliverWebhookHandler(webhookService service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the webhook and delivery IDs from the URL parameters
+		id, ok := readWebhookID(w, r)
+		if !ok {
+			return
+		}
+		deliveryID, err := strconv.ParseUint(mux.Vars(r)["delivery"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+			return
+		}
+
+		// Call the service method to send the delivery again
+		delivery, err := webhookService.Redeliver(r.Context(), id, uint(deliveryID))
+		if err != nil {
+			http.Error(w, err.Error(), serviceErrorStatus(err))
+			return
+		}
+
+		// Respond with the delivery, which goes out in the background
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(delivery)
+	}
+}
@@ -0,0 +1,190 @@
+// Package imaging turns an uploaded image into a clean copy and resized
+// variants that are safe to serve: EXIF orientation is applied to the
+// pixels, and since every image is encoded afresh no metadata from the
+// upload survives.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // registers the decoder for GIF uploads
+	"image/jpeg"
+	"image/png"
+	"sort"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // registers the decoder for WebP uploads
+)
+
+// MaxPixels refuses images that would take too much memory to decode, such
+// as a small file claiming enormous dimensions.
+const MaxPixels = 40_000_000
+
+// JPEGQuality is used for every JPEG variant.
+const JPEGQuality = 85
+
+var ErrTooManyPixels = errors.New("image dimensions are too large")
+
+// Variant is one encoded size of an image.
+type Variant struct {
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+// Variants decodes data and returns one variant per width narrower than the
+// image, plus one at the image's own width, narrowest first. Images are
+// never scaled up. JPEG uploads stay JPEG; everything else becomes PNG so
+// transparency is kept.
+func Variants(data []byte, widths []int) ([]Variant, error) {
+	img, encode, contentType, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	full := img.Bounds().Dx()
+	targets := []int{}
+	for _, width := range widths {
+		if width > 0 && width < full {
+			targets = append(targets, width)
+		}
+	}
+	targets = append(targets, full)
+
+	variants := make([]Variant, 0, len(targets))
+	seen := map[int]bool{}
+	for _, width := range targets {
+		if seen[width] {
+			continue
+		}
+		seen[width] = true
+
+		resized := Resize(img, width)
+		var buf bytes.Buffer
+		if err := encode(&buf, resized); err != nil {
+			return nil, err
+		}
+		variants = append(variants, Variant{
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+			ContentType: contentType,
+			Data:        buf.Bytes(),
+		})
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].Width < variants[j].Width })
+
+	return variants, nil
+}
+
+// Clean decodes data and encodes it afresh at its own size, upright and
+// without any of the upload's metadata, in the type Variants would use.
+func Clean(data []byte) (Variant, error) {
+	img, encode, contentType, err := decode(data)
+	if err != nil {
+		return Variant{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		return Variant{}, err
+	}
+	return Variant{
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		ContentType: contentType,
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+// decode reads an upload the right way up and picks how it is encoded again.
+func decode(data []byte) (image.Image, func(*bytes.Buffer, image.Image) error, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, nil, "", fmt.Errorf("%w: %dx%d", ErrTooManyPixels, config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if format == "jpeg" {
+		return Orient(img, Orientation(data)), encodeJPEG, "image/jpeg", nil
+	}
+	return img, encodePNG, "image/png", nil
+}
+
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error {
+	return jpeg.Encode(buf, img, &jpeg.Options{Quality: JPEGQuality})
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error {
+	return png.Encode(buf, img)
+}
+
+// Resize scales img to width, keeping its aspect ratio. An image already
+// that width is copied as is.
+func Resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if width == bounds.Dx() {
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+		return dst
+	}
+
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// Orient applies an EXIF orientation so the image displays upright without
+// its metadata.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// orientations 5 to 8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored along the main diagonal
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // mirrored along the anti-diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return dst
+}
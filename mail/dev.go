@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer writes every message to a .eml file of its own under a
+// directory, where any mail client can open it. It is meant for
+// development.
+type FileMailer struct {
+	dir  string
+	from *netmail.Address
+}
+
+func NewFileMailer(dir string, from string) (Mailer, error) {
+	address, err := parseFrom(from)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{dir: dir, from: address}, nil
+}
+
+func (mailer *FileMailer) Send(ctx context.Context, message Message) error {
+	now := time.Now()
+	data, err := message.encode(mailer.from, now)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(mailer.dir, name), data, 0o644)
+}
+
+// LogMailer logs the recipients and subject of every message instead of
+// sending it. It is the default, so a development setup needs no mail
+// server. The text is left out, since it can carry links and tokens that
+// must only reach the recipient; use FileMailer to read messages in full.
+type LogMailer struct {
+	from *netmail.Address
+}
+
+func NewLogMailer(from string) (Mailer, error) {
+	address, err := parseFrom(from)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogMailer{from: address}, nil
+}
+
+func (mailer *LogMailer) Send(ctx context.Context, message Message) error {
+	if _, err := message.recipients(); err != nil {
+		return err
+	}
+
+	log.Printf("Mail from %s to %s: %s", mailer.from, strings.Join(message.To, ", "), message.Subject)
+	return nil
+}
@@ -0,0 +1,66 @@
+// Package mail sends email. Messages are rendered from Templates and handed
+// to a Mailer, which delivers them over SMTP or, in development, writes
+// them to a directory or the log.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"os"
+)
+
+var ErrInvalidMessage = errors.New("invalid mail message")
+
+// Message is one email. Text is always sent; HTML, when set, is offered as
+// the alternative clients prefer.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages from the address it was set up with.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// DefaultFrom is the sender when MAIL_FROM is not set.
+const DefaultFrom = "Blog <no-reply@localhost>"
+
+// NewMailerFromEnv picks the mailer named by MAILER: "log" (the default)
+// logs who every message is to and its subject, "file" writes them under MAIL_DIR, and "smtp" sends
+// them to the server described by the SMTP_* variables. Messages are sent
+// from MAIL_FROM.
+func NewMailerFromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = DefaultFrom
+	}
+
+	switch backend := os.Getenv("MAILER"); backend {
+	case "", "log":
+		return NewLogMailer(from)
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return NewFileMailer(dir, from)
+	case "smtp":
+		return NewSMTPMailer(SMTPConfigFromEnv(), from)
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", backend)
+	}
+}
+
+// parseFrom checks the sender address once, when a mailer is set up.
+func parseFrom(from string) (*netmail.Address, error) {
+	address, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q: %w", from, err)
+	}
+	return address, nil
+}
@@ -0,0 +1,105 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// recipients parses the To addresses, so nothing but addresses ends up in
+// the header or the envelope.
+func (message Message) recipients() ([]*netmail.Address, error) {
+	if len(message.To) == 0 {
+		return nil, fmt.Errorf("%w: no recipients", ErrInvalidMessage)
+	}
+
+	addresses := make([]*netmail.Address, 0, len(message.To))
+	for _, to := range message.To {
+		address, err := netmail.ParseAddress(to)
+		if err != nil {
+			return nil, fmt.Errorf("%w: recipient %q: %v", ErrInvalidMessage, to, err)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// encode writes the message out in RFC 5322 form: a text/plain body, or a
+// multipart/alternative one with the HTML part last when there is HTML.
+// Bodies are quoted-printable so long lines and UTF-8 survive any relay.
+func (message Message) encode(from *netmail.Address, date time.Time) ([]byte, error) {
+	to, err := message.recipients()
+	if err != nil {
+		return nil, err
+	}
+	if message.Text == "" {
+		return nil, fmt.Errorf("%w: no text", ErrInvalidMessage)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	addresses := make([]string, 0, len(to))
+	for _, address := range to {
+		addresses = append(addresses, address.String())
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", from.String())
+	fmt.Fprintf(&out, "To: %s\r\n", strings.Join(addresses, ", "))
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	out.WriteString("MIME-Version: 1.0\r\n")
+
+	if message.HTML == "" {
+		out.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		out.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&out, message.Text); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&out)
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", message.Text},
+		{"text/html; charset=utf-8", message.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(writer, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func writeQuotedPrintable(out io.Writer, body string) error {
+	writer := quotedprintable.NewWriter(out)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,164 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parse reads an encoded message back, failing the test if it is not one.
+func parse(t *testing.T, data []byte) *netmail.Message {
+	t.Helper()
+	parsed, err := netmail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v\n%s", err, data)
+	}
+	return parsed
+}
+
+func TestEncodeHeaders(t *testing.T) {
+	from, err := parseFrom(DefaultFrom)
+	if err != nil {
+		t.Fatalf("parseFrom: %v", err)
+	}
+	date := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	var decoder mime.WordDecoder
+
+	for _, tt := range []struct {
+		name    string
+		to      string
+		subject string
+	}{
+		{"plain", "Ann <ann@example.com>", "Welcome"},
+		{"utf-8", "Zoë <zoe@example.com>", "Willkommen, Zoë – schön"},
+		{"subject with a header", "ann@example.com", "Hi\r\nBcc: mallory@example.com"},
+		{"subject with a bare newline", "ann@example.com", "Hi\nBcc: mallory@example.com\n\nforged body"},
+		{"name with a header", (&netmail.Address{Name: "Ann\r\nBcc: mallory@example.com", Address: "ann@example.com"}).String(), "Hi"},
+		{"name with quotes", (&netmail.Address{Name: `Ann "the admin" <root>`, Address: "ann@example.com"}).String(), "Hi"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Message{To: []string{tt.to}, Subject: tt.subject, Text: "Hello"}.encode(from, date)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			parsed := parse(t, data)
+
+			for key := range parsed.Header {
+				switch key {
+				case "From", "To", "Subject", "Date", "Message-Id", "Mime-Version", "Content-Type", "Content-Transfer-Encoding":
+				default:
+					t.Errorf("unexpected header %s: %q", key, parsed.Header.Get(key))
+				}
+			}
+			to, err := parsed.Header.AddressList("To")
+			if err != nil || len(to) != 1 || to[0].Address != "ann@example.com" && to[0].Address != "zoe@example.com" {
+				t.Errorf("To = %v, %v, want the one recipient", to, err)
+			}
+			subject, err := decoder.DecodeHeader(parsed.Header.Get("Subject"))
+			if err != nil || subject != tt.subject {
+				t.Errorf("Subject decodes to %q, %v, want %q", subject, err, tt.subject)
+			}
+			body, _ := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+			if string(body) != "Hello" {
+				t.Errorf("body = %q, want Hello", body)
+			}
+		})
+	}
+}
+
+func TestEncodeRejectsBadRecipients(t *testing.T) {
+	from, _ := parseFrom(DefaultFrom)
+
+	for _, to := range [][]string{
+		nil,
+		{"not an address"},
+		{"ann@example.com\r\nBcc: mallory@example.com"},
+		{"ann@example.com, mallory@example.com"},
+	} {
+		_, err := Message{To: to, Subject: "Hi", Text: "Hello"}.encode(from, time.Now())
+		if !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("encode to %q err = %v, want ErrInvalidMessage", to, err)
+		}
+	}
+
+	if _, err := (Message{To: []string{"ann@example.com"}, Subject: "Hi"}).encode(from, time.Now()); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("encode without text err = %v, want ErrInvalidMessage", err)
+	}
+}
+
+func TestEncodeAlternative(t *testing.T) {
+	from, _ := parseFrom(DefaultFrom)
+	text := "Hi Zoë,\n" + strings.Repeat("a long line ", 20)
+	html := "<p>Hi Zoë</p>"
+
+	data, err := Message{To: []string{"zoe@example.com"}, Subject: "Hi", Text: text, HTML: html}.encode(from, time.Now())
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	parsed := parse(t, data)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", parsed.Header.Get("Content-Type"))
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		// quoted-printable text ends its lines the way mail does
+		{"text/plain; charset=utf-8", strings.ReplaceAll(text, "\n", "\r\n")},
+		{"text/html; charset=utf-8", html},
+	} {
+		// the reader undoes the quoted-printable encoding itself
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		if got := part.Header.Get("Content-Type"); got != want.contentType {
+			t.Errorf("part Content-Type = %q, want %q", got, want.contentType)
+		}
+		body, _ := io.ReadAll(part)
+		if string(body) != want.body {
+			t.Errorf("part body = %q, want %q", body, want.body)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("NextPart after the HTML = %v, want io.EOF", err)
+	}
+
+	// quoted-printable keeps body lines short
+	_, body, _ := strings.Cut(string(data), "\r\n\r\n")
+	for _, line := range strings.Split(body, "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("line of %d characters: %q", len(line), line)
+		}
+	}
+}
+
+func TestRenderFoldsSubjects(t *testing.T) {
+	templates, err := DefaultTemplates()
+	if err != nil {
+		t.Fatalf("DefaultTemplates: %v", err)
+	}
+
+	message, err := templates.Render("welcome", map[string]string{
+		"Name":      "<b>Ann</b>",
+		"Username":  "ann",
+		"SiteTitle": "Blog\r\nBcc: mallory@example.com",
+		"SiteURL":   "https://blog.example.com/",
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if strings.ContainsAny(message.Subject, "\r\n") {
+		t.Errorf("Subject = %q, want it on one line", message.Subject)
+	}
+	if strings.Contains(message.HTML, "<b>Ann</b>") {
+		t.Errorf("HTML = %q, want the name escaped", message.HTML)
+	}
+}
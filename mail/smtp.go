@@ -0,0 +1,112 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"time"
+)
+
+// SMTPTimeout bounds a whole delivery when the context has no deadline.
+const SMTPTimeout = 30 * time.Second
+
+// SMTPConfig describes a mail server. Username may be empty for servers
+// that take mail without logging in, such as a local MailHog.
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+}
+
+// SMTPConfigFromEnv reads SMTP_ADDR (host:port), SMTP_USERNAME and
+// SMTP_PASSWORD.
+func SMTPConfigFromEnv() SMTPConfig {
+	return SMTPConfig{
+		Addr:     os.Getenv("SMTP_ADDR"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+}
+
+// SMTPMailer sends each message over a connection of its own, upgraded
+// with STARTTLS whenever the server offers it.
+type SMTPMailer struct {
+	config SMTPConfig
+	host   string
+	from   *netmail.Address
+}
+
+func NewSMTPMailer(config SMTPConfig, from string) (Mailer, error) {
+	host, _, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_ADDR %q: %w", config.Addr, err)
+	}
+	address, err := parseFrom(from)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SMTPMailer{config: config, host: host, from: address}, nil
+}
+
+func (mailer *SMTPMailer) Send(ctx context.Context, message Message) error {
+	data, err := message.encode(mailer.from, time.Now())
+	if err != nil {
+		return err
+	}
+	to, _ := message.recipients()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", mailer.config.Addr)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(SMTPTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, mailer.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: mailer.host}); err != nil {
+			return err
+		}
+	}
+	// PlainAuth refuses to send the password unencrypted, except to localhost
+	if mailer.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", mailer.config.Username, mailer.config.Password, mailer.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(mailer.from.Address); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err := client.Rcpt(address.Address); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
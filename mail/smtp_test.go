@@ -0,0 +1,112 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// smtpSession is what a fakeSMTPServer was told.
+type smtpSession struct {
+	from string
+	rcpt []string
+	data string
+}
+
+// fakeSMTPServer answers one SMTP session on a local port, without STARTTLS
+// or AUTH, and sends what it was told on the channel.
+func fakeSMTPServer(t *testing.T) (string, <-chan smtpSession) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+
+		var session smtpSession
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL":
+				session.from = arg
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				session.rcpt = append(session.rcpt, arg)
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				session.data = string(data)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				sessions <- session
+				return
+			default:
+				text.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), sessions
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	addr, sessions := fakeSMTPServer(t)
+	mailer, err := NewSMTPMailer(SMTPConfig{Addr: addr}, "Blog <no-reply@blog.example.com>")
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+
+	err = mailer.Send(context.Background(), Message{
+		To:      []string{(&netmail.Address{Name: "Ann\r\nRCPT TO:<mallory@example.com>", Address: "ann@example.com"}).String()},
+		Subject: "Hi\r\nBcc: mallory@example.com",
+		Text:    "Hello\n.\nstill here",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	session := <-sessions
+	if session.from != "FROM:<no-reply@blog.example.com>" {
+		t.Errorf("MAIL %s, want the sender", session.from)
+	}
+	if len(session.rcpt) != 1 || session.rcpt[0] != "TO:<ann@example.com>" {
+		t.Errorf("RCPT %v, want Ann alone", session.rcpt)
+	}
+
+	parsed := parse(t, []byte(session.data))
+	if bcc := parsed.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("Bcc = %q, want none", bcc)
+	}
+	body := bufio.NewScanner(parsed.Body)
+	var lines []string
+	for body.Scan() {
+		lines = append(lines, body.Text())
+	}
+	if strings.Join(lines, "\n") != "Hello\n.\nstill here" {
+		t.Errorf("body = %q, want the lone dot kept", lines)
+	}
+}
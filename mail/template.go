@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Templates renders messages from a pair of templates per kind of mail:
+// name.txt through text/template for the plain text every message carries,
+// and optionally name.html through html/template, which escapes what it is
+// given. The subject is the "name.subject" template, defined in name.txt.
+type Templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// DefaultTemplates parses the templates built into the binary.
+func DefaultTemplates() (*Templates, error) {
+	fsys, err := fs.Sub(templateFiles, "templates")
+	if err != nil {
+		return nil, err
+	}
+	return ParseTemplates(fsys)
+}
+
+// TemplatesFromEnv parses the templates in the directory named by
+// MAIL_TEMPLATES, or the built-in ones when it is not set.
+func TemplatesFromEnv() (*Templates, error) {
+	if dir := os.Getenv("MAIL_TEMPLATES"); dir != "" {
+		return ParseTemplates(os.DirFS(dir))
+	}
+	return DefaultTemplates()
+}
+
+// ParseTemplates parses every *.txt and *.html file at the top of fsys.
+func ParseTemplates(fsys fs.FS) (*Templates, error) {
+	text, err := texttemplate.ParseFS(fsys, "*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	templates := &Templates{text: text}
+	if htmlFiles, _ := fs.Glob(fsys, "*.html"); len(htmlFiles) > 0 {
+		templates.html, err = htmltemplate.ParseFS(fsys, "*.html")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// Render fills in the subject and bodies of the mail called name with data,
+// leaving the recipients to the caller.
+func (templates *Templates) Render(name string, data interface{}) (Message, error) {
+	var subject, text bytes.Buffer
+	if err := templates.text.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return Message{}, fmt.Errorf("subject of %s: %w", name, err)
+	}
+	if err := templates.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, fmt.Errorf("text of %s: %w", name, err)
+	}
+	message := Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}
+
+	// without an HTML template the text stands alone
+	if templates.html == nil || templates.html.Lookup(name+".html") == nil {
+		return message, nil
+	}
+	var html bytes.Buffer
+	if err := templates.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, fmt.Errorf("HTML of %s: %w", name, err)
+	}
+	message.HTML = html.String()
+	return message, nil
+}
@@ -1,15 +1,117 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/bellaananda/go-postgresql-blog-http.git/app"
+	"github.com/bellaananda/go-postgresql-blog-http.git/database"
 	"github.com/bellaananda/go-postgresql-blog-http.git/router"
+	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/bellaananda/go-postgresql-blog-http.git/telemetry"
 )
 
+// trashRetention reads how many days soft-deleted rows stay in the trash
+// from TRASH_RETENTION_DAYS, defaulting to 30.
+func trashRetention() time.Duration {
+	days := 30
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			log.Fatalf("Invalid TRASH_RETENTION_DAYS %q", value)
+		}
+		days = parsed
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// shutdownTimeout is how long in-flight requests get to finish once a
+// shutdown starts. Comment streams never finish on their own, so they are
+// cut off when it runs out and their clients reconnect elsewhere.
+const shutdownTimeout = 20 * time.Second
+
 func main() {
-	r := router.Router()
+	// SIGINT or SIGTERM, as sent on every deploy, starts a graceful shutdown
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the background workers stop only after the server has, so the views
+	// of the last requests are still flushed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdown, err := telemetry.Setup(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := database.RunDatabase()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	config, err := app.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	application := app.New(db, config)
+
+	// purge expired trash once an hour
+	go service.RunTrashRetention(ctx, application.TrashService, trashRetention(), time.Hour)
+
+	// make thumbnail variants in the background
+	go application.MediaService.Run(ctx, 2)
+
+	// send queued mail in the background
+	go application.MailService.Run(ctx, 2)
+
+	// deliver webhook events as they are committed, and retries every 5 seconds
+	go application.WebhookService.Run(ctx, 5*time.Second)
+
+	// wake comment streams on events committed by any replica, and keep a
+	// day of events to resume from
+	go application.CommentStreamService.Run(ctx, service.CommentEventRetention)
+
+	// write buffered post views every 10 seconds, and once more on the way out
+	viewsFlushed := make(chan struct{})
+	go func() {
+		application.ViewService.Run(ctx, 10*time.Second)
+		close(viewsFlushed)
+	}()
+
+	server := &http.Server{Addr: ":8080", Handler: router.NewRouter(application)}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ListenAndServe()
+	}()
 	fmt.Println("Starting server...")
-	log.Fatal(http.ListenAndServe(":8080", r))
+
+	select {
+	case err = <-served:
+		log.Printf("Server stopped: %v", err)
+	case <-signals.Done():
+		fmt.Println("Shutting down server...")
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down server: %v", err)
+			server.Close()
+		}
+		cancelShutdown()
+	}
+
+	// flush pending views and spans before exiting
+	cancel()
+	<-viewsFlushed
+	if err := shutdown(context.Background()); err != nil {
+		log.Printf("Error shutting down telemetry: %v", err)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// GormBookmark saves a post for a user to read later. List names the
+// reading list the post was saved to; the empty name is the user's
+// unsorted bookmarks. A post can be saved to several lists, but once per
+// list. Rows are removed outright, so there is no soft delete.
+type GormBookmark struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_bookmarks_user_list_post"`
+	List      string    `gorm:"size:100;not null;default:'';uniqueIndex:idx_bookmarks_user_list_post"`
+	PostID    uint      `gorm:"index;not null;uniqueIndex:idx_bookmarks_user_list_post"`
+	User      *GormUser `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Post      *GormPost `json:",omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// BookmarkList is one of a user's reading lists and how many posts are
+// saved to it.
+type BookmarkList struct {
+	Name  string
+	Count int64
+}
@@ -8,10 +8,18 @@ import (
 
 type GormComment struct {
 	gorm.Model
-	UserID      uint   `gorm:"index;not null"`
-	PostID      uint   `gorm:"index;not null"`
-	Content     string `gorm:"type:text"`
-	PublishedAt time.Time
-	User        *GormUser `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
-	Post        *GormPost `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Version       uint   `gorm:"not null;default:1"`
+	UserID        uint   `gorm:"index;not null;uniqueIndex:idx_comments_user_post,priority:1,where:deleted_at IS NULL"`
+	PostID        uint   `gorm:"index;not null;uniqueIndex:idx_comments_user_post,priority:2,where:deleted_at IS NULL"`
+	Content       string `gorm:"type:text"`
+	ContentFormat string `gorm:"size:16;not null;default:markdown"`
+	PublishedAt   time.Time
+	User          *GormUser `gorm:"foreignkey:UserID"`
+	Post          *GormPost `gorm:"foreignkey:PostID"`
+
+	// Only ever changed by adding or removing reactions.
+	ReactionCounts ReactionCounts `gorm:"type:jsonb;not null;default:'{}'"`
+
+	// Rendered on read, never stored.
+	ContentHTML string `gorm:"-" json:",omitempty"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// GormCommentEvent records that a comment on a post was created, updated or
+// deleted, and carries the comment as it was then in Data. Readers following
+// a post's comments are sent the events in ID order and resume from the last
+// ID they saw. There are no foreign keys: an event outlives the comment it
+// describes, and events are pruned by age instead.
+type GormCommentEvent struct {
+	ID        uint      `gorm:"primarykey;index:idx_comment_events_post,priority:2"`
+	CreatedAt time.Time `gorm:"index"`
+	PostID    uint      `gorm:"not null;index:idx_comment_events_post,priority:1"`
+	CommentID uint      `gorm:"not null"`
+	Type      string    `gorm:"size:16;not null"`
+	Data      string    `gorm:"type:jsonb;not null"`
+}
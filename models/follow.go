@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// GormFollow is one user following another. Rows are removed outright when
+// a user unfollows, so there is no soft delete.
+type GormFollow struct {
+	FollowerID uint `gorm:"primaryKey;autoIncrement:false;check:chk_follows_self,follower_id <> followee_id"`
+	FolloweeID uint `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt  time.Time
+	Follower   *GormUser `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Followee   *GormUser `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// GormTimelineEntry puts a published post on the feed of one of its
+// author's followers. Entries are written when the post is published and
+// when the follower starts following, so a feed is read from its own rows
+// instead of from the posts of everyone followed. PublishedAt is copied
+// from the post to page through a feed in one index.
+type GormTimelineEntry struct {
+	UserID      uint      `gorm:"primaryKey;autoIncrement:false;index:idx_timeline_feed,priority:1"`
+	PostID      uint      `gorm:"primaryKey;autoIncrement:false;index;index:idx_timeline_feed,priority:3,sort:desc"`
+	AuthorID    uint      `gorm:"not null;index"`
+	PublishedAt time.Time `gorm:"not null;index:idx_timeline_feed,priority:2,sort:desc"`
+	User        *GormUser `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Post        *GormPost `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Author      *GormUser `json:"-" gorm:"foreignKey:AuthorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// GormNotification tells a user that someone else did something concerning
+// them: Type says what, ActorID who, and PostID, CommentID and Reaction
+// what it was done to, when that applies. ReadAt stays null until the user
+// marks it read. Rows go with their user, actor, post or comment when those
+// are purged, so there is no soft delete.
+type GormNotification struct {
+	ID        uint `gorm:"primarykey;index:idx_notifications_user,priority:2,sort:desc"`
+	CreatedAt time.Time
+	UserID    uint         `gorm:"not null;index:idx_notifications_user,priority:1;index:idx_notifications_unread,where:read_at IS NULL"`
+	ActorID   uint         `gorm:"not null;index"`
+	Type      string       `gorm:"size:32;not null"`
+	PostID    *uint        `gorm:"index"`
+	CommentID *uint        `gorm:"index"`
+	Reaction  string       `gorm:"size:32;not null;default:''" json:",omitempty"`
+	ReadAt    *time.Time   `json:",omitempty"`
+	User      *GormUser    `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Actor     *GormUser    `json:"-" gorm:"foreignKey:ActorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Post      *GormPost    `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Comment   *GormComment `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// GormNotificationPreference turns one type of notification on or off for
+// a user. Types a user never set are on.
+type GormNotificationPreference struct {
+	UserID  uint      `gorm:"primaryKey;autoIncrement:false"`
+	Type    string    `gorm:"primaryKey;size:32"`
+	Enabled bool      `gorm:"not null"`
+	User    *GormUser `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
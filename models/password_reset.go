@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// GormPasswordReset lets whoever holds the token it was made for choose a
+// new password for a user until ExpiresAt. Only the SHA-256 of the token is
+// kept, so the table is no use to someone who reads it. Rows are removed
+// once used, so there is no soft delete.
+type GormPasswordReset struct {
+	TokenHash string `gorm:"primaryKey;size:64"`
+	CreatedAt time.Time
+	UserID    uint      `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	User      *GormUser `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
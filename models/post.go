@@ -8,12 +8,42 @@ import (
 
 type GormPost struct {
 	gorm.Model
-	UserID      uint   `gorm:"index;not null"`
-	Title       string `gorm:"size:255"`
-	Content     string `gorm:"type:text"`
-	Thumbnail   string `gorm:"type:text"`
-	IsPublished bool   `gorm:"default:false"`
-	PublishedAt time.Time
-	User        *GormUser      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
-	Comments    []*GormComment `gorm:"foreignkey:PostID"`
+	Version       uint           `gorm:"not null;default:1"`
+	UserID        uint           `gorm:"index;not null;index:idx_posts_pulled,priority:1,where:fanned_out = false AND is_published"`
+	Title         string         `gorm:"size:255"`
+	Content       string         `gorm:"type:text" json:",omitempty"`
+	ContentFormat string         `gorm:"size:16;not null;default:markdown"`
+	Thumbnail     string         `gorm:"type:text"`
+	IsPublished   bool           `gorm:"default:false"`
+	PublishedAt   time.Time      `gorm:"index:idx_posts_pulled,priority:2,sort:desc"`
+	User          *GormUser      `gorm:"foreignkey:UserID"`
+	Comments      []*GormComment `gorm:"foreignkey:PostID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+
+	// Kept up to date with the content on every write. CustomExcerpt is set
+	// when the author wrote the excerpt instead of having it taken from the
+	// content.
+	Excerpt        string `gorm:"type:text"`
+	CustomExcerpt  bool   `gorm:"not null;default:false"`
+	WordCount      int    `gorm:"not null;default:0"`
+	ReadingMinutes int    `gorm:"not null;default:0"`
+
+	Tags Tags `gorm:"type:jsonb;not null;default:'[]';index:idx_posts_tags,type:gin"`
+
+	// Only ever changed by adding or removing reactions, never by writes to
+	// the post itself.
+	ReactionCounts ReactionCounts `gorm:"type:jsonb;not null;default:'{}'"`
+
+	// Whether the post went out to the timelines of its author's followers
+	// when it was published. Posts of authors with too many followers are
+	// not fanned out and are read straight from the posts table instead.
+	FannedOut bool `gorm:"not null;default:false" json:"-"`
+
+	// Filled in on read, never stored on the post.
+	ContentHTML     string `gorm:"-" json:",omitempty"`
+	ThumbnailURL    string `gorm:"-"`
+	ThumbnailSrcset string `gorm:"-"`
+
+	// Whether the reader saved the post to any of their reading lists; only
+	// set when the request is by an authenticated user.
+	Bookmarked *bool `gorm:"-" json:",omitempty"`
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// GormPostMedia is one processed variant of an image attached to a post.
+// SourceKey is the upload it was made from, so variants of a replaced
+// thumbnail can be told apart from current ones. Rows are removed outright
+// together with their files, so there is no soft delete.
+type GormPostMedia struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	PostID      uint      `gorm:"index;not null"`
+	SourceKey   string    `gorm:"uniqueIndex:idx_post_media_variant;size:255;not null"`
+	Width       int       `gorm:"uniqueIndex:idx_post_media_variant;not null"`
+	Height      int       `gorm:"not null"`
+	Key         string    `gorm:"size:255;not null"`
+	ContentType string    `gorm:"size:100"`
+	Post        *GormPost `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+}
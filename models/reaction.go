@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GormReaction is one reader's reaction of one type to either a post or a
+// comment, whichever of PostID and CommentID is set. A reader can leave
+// several types on the same post or comment, but each type only once.
+// Rows are removed outright, so there is no soft delete.
+type GormReaction struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint         `gorm:"index;not null;uniqueIndex:idx_reactions_post,where:post_id IS NOT NULL;uniqueIndex:idx_reactions_comment,where:comment_id IS NOT NULL"`
+	PostID    *uint        `gorm:"index;uniqueIndex:idx_reactions_post;check:chk_reactions_target,(post_id IS NULL) <> (comment_id IS NULL)"`
+	CommentID *uint        `gorm:"index;uniqueIndex:idx_reactions_comment"`
+	Type      string       `gorm:"size:32;not null;uniqueIndex:idx_reactions_post;uniqueIndex:idx_reactions_comment"`
+	User      *GormUser    `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	Post      *GormPost    `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Comment   *GormComment `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ReactionCounts holds how many reactions of each type a post or comment
+// has; types nobody left are absent. It is stored as a jsonb column and kept
+// up to date as reactions come and go, so reading it costs no extra query.
+type ReactionCounts map[string]int
+
+func (counts ReactionCounts) Value() (driver.Value, error) {
+	if counts == nil {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(map[string]int(counts))
+	return string(encoded), err
+}
+
+func (counts *ReactionCounts) Scan(value interface{}) error {
+	var data []byte
+	switch value := value.(type) {
+	case nil:
+		*counts = ReactionCounts{}
+		return nil
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		return fmt.Errorf("cannot scan %T into ReactionCounts", value)
+	}
+	return json.Unmarshal(data, (*map[string]int)(counts))
+}
+
+// MarshalJSON writes no reactions as {} rather than null.
+func (counts ReactionCounts) MarshalJSON() ([]byte, error) {
+	if counts == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string]int(counts))
+}
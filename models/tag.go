@@ -0,0 +1,54 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Tags are the tags a post is filed under, lowercase and without
+// duplicates. They are stored as a jsonb array so that posts with a tag can
+// be found through a GIN index with @>.
+type Tags []string
+
+func (tags Tags) Value() (driver.Value, error) {
+	if tags == nil {
+		return "[]", nil
+	}
+	encoded, err := json.Marshal([]string(tags))
+	return string(encoded), err
+}
+
+func (tags *Tags) Scan(value interface{}) error {
+	var data []byte
+	switch value := value.(type) {
+	case nil:
+		*tags = Tags{}
+		return nil
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		return fmt.Errorf("cannot scan %T into Tags", value)
+	}
+	return json.Unmarshal(data, (*[]string)(tags))
+}
+
+// MarshalJSON writes no tags as [] rather than null.
+func (tags Tags) MarshalJSON() ([]byte, error) {
+	if tags == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(tags))
+}
+
+// Has reports whether tag is among tags.
+func (tags Tags) Has(tag string) bool {
+	for _, each := range tags {
+		if each == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -6,10 +6,16 @@ import (
 
 type GormUser struct {
 	gorm.Model
-	Name     string         `gorm:"size:255"`
-	Email    string         `gorm:"unique;size:255;not null"`
-	Password string         `gorm:"size:255"`
-	Username string         `gorm:"unique;size:255;not null"`
-	Posts    []*GormPost    `gorm:"foreignkey:UserID"`
-	Comments []*GormComment `gorm:"foreignkey:UserID"`
+	Version  uint   `gorm:"not null;default:1"`
+	Name     string `gorm:"size:255"`
+	Email    string `gorm:"size:255;not null"`
+	Password string `gorm:"size:255" json:"-"` // never sent back to clients
+	Username string `gorm:"size:255;not null"`
+
+	// The foreign keys of posts and comments are named after and built from
+	// these fields, not from the User fields on the other side. A user with
+	// posts or comments cannot be purged until the delete policies have
+	// dealt with them.
+	Posts    []*GormPost    `gorm:"foreignkey:UserID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	Comments []*GormComment `gorm:"foreignkey:UserID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
 }
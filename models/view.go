@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// GormPostView counts the views of a post within one hour. Views are added
+// up in memory and written in batches, so one write carries many views,
+// and reports bucket the hours into days, weeks or months.
+type GormPostView struct {
+	PostID uint      `gorm:"primaryKey;autoIncrement:false"`
+	Hour   time.Time `gorm:"primaryKey;index"`
+	Views  int64     `gorm:"not null;default:0"`
+	Post   *GormPost `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ViewBucket is how many views fell within the day, week or month starting
+// at Start.
+type ViewBucket struct {
+	Start time.Time
+	Views int64
+}
+
+// PostViews is how many views one post had over a period.
+type PostViews struct {
+	PostID uint
+	UserID uint
+	Title  string
+	Views  int64
+}
+
+// AuthorViews is how many views the posts of one author had over a period,
+// and how many of their posts were viewed.
+type AuthorViews struct {
+	UserID uint
+	Name   string
+	Posts  int64
+	Views  int64
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// The states a webhook delivery goes through. A pending delivery is sent
+// at NextAttemptAt, again after every failure until it runs out of
+// attempts and is dead. Dead deliveries stay until they are redelivered.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// GormWebhook subscribes a URL to the types of event named in Events. Every
+// request to it is signed with Secret, which is only shown when the webhook
+// is created. Inactive webhooks keep their pending deliveries until they
+// are turned back on.
+type GormWebhook struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	URL       string     `gorm:"type:text;not null"`
+	Secret    string     `gorm:"size:128;not null" json:"-"`
+	Events    EventTypes `gorm:"type:jsonb;not null;default:'[]'"`
+	Active    bool       `gorm:"not null"`
+}
+
+// EventTypes are the types of event a webhook is subscribed to, stored as
+// a jsonb array the way Tags are.
+type EventTypes []string
+
+func (types EventTypes) Value() (driver.Value, error) {
+	return Tags(types).Value()
+}
+
+func (types *EventTypes) Scan(value interface{}) error {
+	return (*Tags)(types).Scan(value)
+}
+
+// MarshalJSON writes no types as [] rather than null.
+func (types EventTypes) MarshalJSON() ([]byte, error) {
+	return Tags(types).MarshalJSON()
+}
+
+// Has reports whether eventType is among types.
+func (types EventTypes) Has(eventType string) bool {
+	return Tags(types).Has(eventType)
+}
+
+// GormWebhookEvent is the outbox: events are written in the transaction of
+// the change they describe, so they exist exactly when it committed, and
+// are turned into deliveries afterwards. DispatchedAt is set once they
+// are. Data is the JSON the event carries.
+type GormWebhookEvent struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	Type         string     `gorm:"size:64;not null"`
+	Data         string     `gorm:"type:jsonb;not null"`
+	DispatchedAt *time.Time `gorm:"index:idx_webhook_events_pending,where:dispatched_at IS NULL"`
+}
+
+// GormWebhookDelivery is one event sent to one webhook, and the log of how
+// that went: how many attempts were made, and the response status or
+// error of the last one.
+type GormWebhookDelivery struct {
+	ID             uint `gorm:"primarykey;index:idx_webhook_deliveries_webhook,priority:2,sort:desc"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	WebhookID      uint              `gorm:"not null;index:idx_webhook_deliveries_webhook,priority:1;uniqueIndex:idx_webhook_deliveries_event,priority:1"`
+	EventID        uint              `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event,priority:2"`
+	EventType      string            `gorm:"size:64;not null"`
+	Status         string            `gorm:"size:16;not null"`
+	Attempts       int               `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time         `gorm:"index:idx_webhook_deliveries_due,where:status = 'pending'"`
+	LastAttemptAt  *time.Time        `json:",omitempty"`
+	ResponseStatus int               `gorm:"not null;default:0" json:",omitempty"`
+	Error          string            `gorm:"type:text;not null;default:''" json:",omitempty"`
+	Webhook        *GormWebhook      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Event          *GormWebhookEvent `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
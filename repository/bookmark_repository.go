@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BookmarkRepo struct {
+	gormRepository
+}
+
+func NewBookmarkRepository(db *gorm.DB) BookmarkRepository {
+	return &BookmarkRepo{gormRepository{db}}
+}
+
+func (repo *BookmarkRepo) MigrateBookmark(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormBookmark{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateBookmark inserts with ON CONFLICT DO NOTHING, since a failed insert
+// would abort the surrounding transaction.
+func (repo *BookmarkRepo) CreateBookmark(ctx context.Context, bookmark models.GormBookmark) (*models.GormBookmark, bool, error) {
+	bookmark.User, bookmark.Post = nil, nil
+	createRes := repo.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&bookmark)
+	if err := createRes.Error; err != nil {
+		return nil, false, repo.translateError(err)
+	}
+	if createRes.RowsAffected > 0 {
+		return &bookmark, true, nil
+	}
+
+	var existing models.GormBookmark
+	err := repo.conn(ctx).
+		Where("user_id = ? AND list = ? AND post_id = ?", bookmark.UserID, bookmark.List, bookmark.PostID).
+		First(&existing).Error
+	if err != nil {
+		return nil, false, repo.translateError(err)
+	}
+	return &existing, false, nil
+}
+
+func (repo *BookmarkRepo) DeleteBookmark(ctx context.Context, userID uint, postID uint, list string) (bool, error) {
+	deleteRes := repo.conn(ctx).
+		Where("user_id = ? AND list = ? AND post_id = ?", userID, list, postID).
+		Delete(&models.GormBookmark{})
+	if err := deleteRes.Error; err != nil {
+		return false, repo.translateError(err)
+	}
+
+	return deleteRes.RowsAffected > 0, nil
+}
+
+func (repo *BookmarkRepo) Bookmarks(ctx context.Context, userID uint, list *string, page Page) ([]models.GormBookmark, int64, error) {
+	// bookmarks of trashed posts stay, but are not listed until the post
+	// is restored
+	query := repo.conn(ctx).Model(&models.GormBookmark{}).
+		Joins("JOIN gorm_posts ON gorm_posts.id = gorm_bookmarks.post_id AND gorm_posts.deleted_at IS NULL").
+		Where("gorm_bookmarks.user_id = ?", userID)
+	if list != nil {
+		query = query.Where("gorm_bookmarks.list = ?", *list)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	bookmarks := []models.GormBookmark{}
+	err := query.
+		Preload("Post", func(db *gorm.DB) *gorm.DB { return db.Omit("content") }).
+		Order("gorm_bookmarks.created_at DESC, gorm_bookmarks.id DESC").
+		Limit(page.Size).Offset(page.offset()).
+		Find(&bookmarks).Error
+	if err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	return bookmarks, total, nil
+}
+
+func (repo *BookmarkRepo) BookmarkLists(ctx context.Context, userID uint) ([]models.BookmarkList, error) {
+	lists := []models.BookmarkList{}
+	err := repo.conn(ctx).Model(&models.GormBookmark{}).
+		Select("gorm_bookmarks.list AS name, COUNT(*) AS count").
+		Joins("JOIN gorm_posts ON gorm_posts.id = gorm_bookmarks.post_id AND gorm_posts.deleted_at IS NULL").
+		Where("gorm_bookmarks.user_id = ?", userID).
+		Group("gorm_bookmarks.list").
+		Order("gorm_bookmarks.list").
+		Scan(&lists).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return lists, nil
+}
+
+func (repo *BookmarkRepo) BookmarkedPostIDs(ctx context.Context, userID uint, postIDs []uint) (map[uint]bool, error) {
+	bookmarked := make(map[uint]bool, len(postIDs))
+	if len(postIDs) == 0 {
+		return bookmarked, nil
+	}
+
+	var ids []uint
+	err := repo.conn(ctx).Model(&models.GormBookmark{}).
+		Distinct("post_id").
+		Where("user_id = ? AND post_id IN ?", userID, postIDs).
+		Pluck("post_id", &ids).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	for _, id := range ids {
+		bookmarked[id] = true
+	}
+	return bookmarked, nil
+}
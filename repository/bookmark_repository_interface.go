@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// BookmarkRepository stores the posts users saved for later, sorted into
+// named reading lists.
+type BookmarkRepository interface {
+	Transactor
+	MigrateBookmark(ctx context.Context) error
+	// CreateBookmark reports false, and returns the bookmark already
+	// stored, when the post was saved to the same list before.
+	CreateBookmark(ctx context.Context, bookmark models.GormBookmark) (*models.GormBookmark, bool, error)
+	// DeleteBookmark reports false when the post was not saved to the list.
+	DeleteBookmark(ctx context.Context, userID uint, postID uint, list string) (bool, error)
+	// Bookmarks returns one page of a user's bookmarks of live posts,
+	// newest first and with their posts minus the content, and how many
+	// there are in all. A nil list reads every list.
+	Bookmarks(ctx context.Context, userID uint, list *string, page Page) ([]models.GormBookmark, int64, error)
+	BookmarkLists(ctx context.Context, userID uint) ([]models.BookmarkList, error)
+	// BookmarkedPostIDs tells which of postIDs the user saved to any list,
+	// in one query however many posts are asked about.
+	BookmarkedPostIDs(ctx context.Context, userID uint, postIDs []uint) (map[uint]bool, error)
+}
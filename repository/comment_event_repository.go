@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// commentEventsChannel is the NOTIFY channel events are announced on. The
+// payload is the ID of the post, which stays well under the 8000 byte limit
+// whatever the comment holds; listeners read the events themselves.
+const commentEventsChannel = "comment_events"
+
+// commentEventLock is the first key of the advisory locks taken per post, so
+// they cannot clash with locks taken for anything else.
+const commentEventLock = 0x636f6d6d
+
+type CommentEventRepo struct {
+	gormRepository
+}
+
+func NewCommentEventRepository(db *gorm.DB) CommentEventRepository {
+	return &CommentEventRepo{gormRepository{db}}
+}
+
+func (repo *CommentEventRepo) MigrateCommentEvent(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormCommentEvent{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateCommentEvent holds a lock on the post until the transaction ends,
+// so the next event of the post only gets its ID once this one committed.
+// NOTIFY is transactional too: it goes out on commit, and never on
+// rollback.
+func (repo *CommentEventRepo) CreateCommentEvent(ctx context.Context, event models.GormCommentEvent) error {
+	return repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := repo.conn(ctx).Exec("SELECT pg_advisory_xact_lock(?, ?)", commentEventLock, int32(event.PostID)).Error; err != nil {
+			return repo.translateError(err)
+		}
+		if err := repo.conn(ctx).Create(&event).Error; err != nil {
+			return repo.translateError(err)
+		}
+		err := repo.conn(ctx).Exec("SELECT pg_notify(?, ?)", commentEventsChannel, strconv.FormatUint(uint64(event.PostID), 10)).Error
+		if err != nil {
+			return repo.translateError(err)
+		}
+		return nil
+	})
+}
+
+func (repo *CommentEventRepo) CommentEventsAfter(ctx context.Context, postID uint, afterID uint, limit int) ([]models.GormCommentEvent, error) {
+	events := []models.GormCommentEvent{}
+	err := repo.conn(ctx).
+		Where("post_id = ? AND id > ?", postID, afterID).
+		Order("id").Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+	return events, nil
+}
+
+func (repo *CommentEventRepo) LastCommentEventID(ctx context.Context, postID uint) (uint, error) {
+	var id uint
+	err := repo.conn(ctx).Model(&models.GormCommentEvent{}).
+		Where("post_id = ?", postID).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&id).Error
+	if err != nil {
+		return 0, repo.translateError(err)
+	}
+	return id, nil
+}
+
+func (repo *CommentEventRepo) PurgeCommentEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	deleteRes := repo.conn(ctx).Where("created_at < ?", before).Delete(&models.GormCommentEvent{})
+	if err := deleteRes.Error; err != nil {
+		return 0, repo.translateError(err)
+	}
+	return deleteRes.RowsAffected, nil
+}
+
+// ListenCommentEvents takes a connection out of the pool for as long as it
+// listens, since notifications only reach the session that asked for them.
+func (repo *CommentEventRepo) ListenCommentEvents(ctx context.Context, fn func(postID uint)) error {
+	sqlDB, err := repo.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("cannot listen on a %T connection", driverConn)
+		}
+		pgxConn := stdlibConn.Conn()
+
+		if _, err := pgxConn.Exec(ctx, "LISTEN "+commentEventsChannel); err != nil {
+			return err
+		}
+		// the connection goes back to the pool, so stop listening on it
+		defer func() {
+			unlistenCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			pgxConn.Exec(unlistenCtx, "UNLISTEN "+commentEventsChannel)
+		}()
+
+		for {
+			notification, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+
+			postID, err := strconv.ParseUint(notification.Payload, 10, 64)
+			if err != nil {
+				continue
+			}
+			fn(uint(postID))
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// CommentEventRepository keeps the log of what happened to the comments of
+// each post, and tells every replica when it grows.
+type CommentEventRepository interface {
+	Transactor
+	MigrateCommentEvent(ctx context.Context) error
+	// CreateCommentEvent appends an event to the log of its post. Events of
+	// one post are committed in the order of their IDs, so a reader that has
+	// seen an ID never misses a lower one committed later. Listeners hear
+	// about the event once the transaction commits.
+	CreateCommentEvent(ctx context.Context, event models.GormCommentEvent) error
+	// CommentEventsAfter returns up to limit events of a post with IDs above
+	// afterID, oldest first.
+	CommentEventsAfter(ctx context.Context, postID uint, afterID uint, limit int) ([]models.GormCommentEvent, error)
+	// LastCommentEventID returns the ID of the newest event of a post, or 0
+	// when there is none.
+	LastCommentEventID(ctx context.Context, postID uint) (uint, error)
+	// PurgeCommentEventsBefore removes the events created before the given
+	// time and returns how many there were.
+	PurgeCommentEventsBefore(ctx context.Context, before time.Time) (int64, error)
+	// ListenCommentEvents calls fn with the post of every event committed
+	// from now on, by this process or any other sharing the database, until
+	// ctx is done or the connection it listens on fails.
+	ListenCommentEvents(ctx context.Context, fn func(postID uint)) error
+}
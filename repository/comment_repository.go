@@ -3,118 +3,141 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/bellaananda/go-postgresql-blog-http.git/models"
-	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func (repo *PostgreSQLGORMRepository) MigrateComment(ctx context.Context) error {
-	err := repo.db.WithContext(ctx).AutoMigrate(&models.GormComment{})
-	if err != nil {
-		return err
-	}
-	return nil
+type CommentRepo struct {
+	gormRepository
 }
 
 func NewCommentRepository(db *gorm.DB) CommentRepository {
-	return &PostgreSQLGORMRepository{db}
+	return &CommentRepo{gormRepository{db}}
 }
 
-func (repo *PostgreSQLGORMRepository) CreateComment(ctx context.Context, comment models.GormComment) (*models.GormComment, error) {
-	if err := repo.db.WithContext(ctx).Create(&comment).Error; err != nil {
-		var pgxError *pgconn.PgError
-		if errors.As(err, &pgxError) {
-			if pgxError.Code == "23505" {
-				return nil, ErrDuplicate
-			}
-		}
-		return nil, err
+func (repo *CommentRepo) MigrateComment(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormComment{})
+	if err != nil {
+		return err
+	}
+	if err := repo.migrateConstraints(ctx, &models.GormUser{}, "Comments"); err != nil {
+		return err
+	}
+	return repo.migrateConstraints(ctx, &models.GormPost{}, "Comments")
+}
+
+func (repo *CommentRepo) CreateComment(ctx context.Context, comment models.GormComment) (*models.GormComment, error) {
+	if err := repo.conn(ctx).Create(&comment).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &comment, nil
 }
 
-func (repo *PostgreSQLGORMRepository) AllComments(ctx context.Context) ([]models.GormComment, error) {
+func (repo *CommentRepo) AllComments(ctx context.Context, query Query) ([]models.GormComment, error) {
 	var allComments []models.GormComment
-	if err := repo.db.WithContext(ctx).Preload("User").Preload("Post").Find(&allComments).Error; err != nil {
-		return nil, err
+	if err := query.scope(repo.conn(ctx), "comments", "user", "post").Find(&allComments).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return allComments, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetCommentByID(ctx context.Context, id uint) (*models.GormComment, error) {
+func (repo *CommentRepo) GetCommentByID(ctx context.Context, id uint) (*models.GormComment, error) {
+	return repo.FindComment(ctx, id, Query{})
+}
+
+func (repo *CommentRepo) FindComment(ctx context.Context, id uint, query Query) (*models.GormComment, error) {
 	var gormComment models.GormComment
-	if err := repo.db.WithContext(ctx).Preload("User").Preload("Post").First(&gormComment, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := query.scope(repo.conn(ctx), "comments", "user", "post").First(&gormComment, id).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &gormComment, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetCommentByUserID(ctx context.Context, userid uint) ([]models.GormComment, error) {
+func (repo *CommentRepo) GetCommentByUserID(ctx context.Context, userid uint) ([]models.GormComment, error) {
 	var gormComment []models.GormComment
-	if err := repo.db.WithContext(ctx).Where("user_id = ?", userid).Find(&gormComment).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := repo.conn(ctx).Where("user_id = ?", userid).Find(&gormComment).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return gormComment, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetCommentByPostID(ctx context.Context, postid uint) ([]models.GormComment, error) {
+func (repo *CommentRepo) GetCommentByPostID(ctx context.Context, postid uint) ([]models.GormComment, error) {
 	var gormComment []models.GormComment
-	if err := repo.db.WithContext(ctx).Where("post_id = ?", postid).Find(&gormComment).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := repo.conn(ctx).Where("post_id = ?", postid).Find(&gormComment).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return gormComment, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetCommentByUserIDPostID(ctx context.Context, userid uint, postid uint) (*models.GormComment, error) {
+func (repo *CommentRepo) GetCommentByUserIDPostID(ctx context.Context, userid uint, postid uint) (*models.GormComment, error) {
 	var gormComment models.GormComment
-	if err := repo.db.WithContext(ctx).Where("user_id = ? AND post_id = ?", userid, postid).First(&gormComment).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := repo.conn(ctx).Where("user_id = ? AND post_id = ?", userid, postid).First(&gormComment).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &gormComment, nil
 }
 
-func (repo *PostgreSQLGORMRepository) UpdateComment(ctx context.Context, id uint, updated models.GormComment) (*models.GormComment, error) {
-	updateRes := repo.db.WithContext(ctx).Where("id = ?", id).Save(&updated)
+func (repo *CommentRepo) UpdateComment(ctx context.Context, id uint, updated models.GormComment) (*models.GormComment, error) {
+	// only write over the version the caller read, and bump it
+	expectedVersion := updated.Version
+	updated.ID = id
+	updated.Version = expectedVersion + 1
+
+	// RETURNING hands back the row as stored, created_at included
+	updateRes := repo.conn(ctx).Model(&updated).Clauses(clause.Returning{}).Where("version = ?", expectedVersion).Select("*").Omit("created_at", "reaction_counts").Updates(&updated)
 	if err := updateRes.Error; err != nil {
-		var pgxError *pgconn.PgError
-		if errors.As(err, &pgxError) {
-			if pgxError.Code == "23505" {
-				return nil, ErrDuplicate
-			}
-		}
-		return nil, err
+		return nil, repo.translateError(err)
 	}
 
 	rowsAffected := updateRes.RowsAffected
 	if rowsAffected == 0 {
-		return nil, ErrUpdateFailed
+		return nil, repo.updateFailure(ctx, &models.GormComment{}, id)
+	}
+
+	if err := repo.loadUserAndPost(ctx, &updated); err != nil {
+		return nil, err
 	}
 	return &updated, nil
 }
 
-func (repo *PostgreSQLGORMRepository) DeleteComment(ctx context.Context, id uint) error {
-	res := repo.db.WithContext(ctx).Delete(&models.GormComment{}, id)
+// PatchComment writes only the given columns, provided the stored row is still
+// at version, and returns the row as RETURNING reports it.
+func (repo *CommentRepo) PatchComment(ctx context.Context, id uint, version uint, columns map[string]interface{}) (*models.GormComment, error) {
+	assignments := make(map[string]interface{}, len(columns)+1)
+	for column, value := range columns {
+		assignments[column] = value
+	}
+	assignments["version"] = version + 1
+
+	var patched models.GormComment
+	updateRes := repo.conn(ctx).Model(&patched).Clauses(clause.Returning{}).Where("id = ? AND version = ?", id, version).Updates(assignments)
+	if err := updateRes.Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	if updateRes.RowsAffected == 0 {
+		return nil, repo.updateFailure(ctx, &models.GormComment{}, id)
+	}
+
+	if err := repo.loadUserAndPost(ctx, &patched); err != nil {
+		return nil, err
+	}
+	return &patched, nil
+}
+
+func (repo *CommentRepo) DeleteComment(ctx context.Context, id uint) error {
+	res := repo.conn(ctx).Delete(&models.GormComment{}, id)
 	if err := res.Error; err != nil {
-		return err
+		return repo.translateError(err)
 	}
 
 	rowsAffected := res.RowsAffected
@@ -124,3 +147,131 @@ func (repo *PostgreSQLGORMRepository) DeleteComment(ctx context.Context, id uint
 
 	return nil
 }
+
+// loadUserAndPost fills comment.User and comment.Post the way
+// Preload("User").Preload("Post") does, without reading the comment again.
+func (repo *CommentRepo) loadUserAndPost(ctx context.Context, comment *models.GormComment) error {
+	comment.User = nil
+	comment.Post = nil
+
+	var user models.GormUser
+	err := omitPassword(repo.conn(ctx)).First(&user, comment.UserID).Error
+	if err == nil {
+		comment.User = &user
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return repo.translateError(err)
+	}
+
+	var post models.GormPost
+	err = repo.conn(ctx).First(&post, comment.PostID).Error
+	if err == nil {
+		comment.Post = &post
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return repo.translateError(err)
+	}
+
+	return nil
+}
+
+// DeletedComments lists the soft-deleted comments, most recently deleted first.
+func (repo *CommentRepo) DeletedComments(ctx context.Context, page Page) ([]models.GormComment, int64, error) {
+	// a session of its own, so the count leaves the query as it was
+	query := repo.conn(ctx).Unscoped().Model(&models.GormComment{}).Where("deleted_at IS NOT NULL").Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	deleted := []models.GormComment{}
+	err := query.
+		Order("deleted_at DESC, id DESC").
+		Limit(page.Size).Offset(page.offset()).
+		Find(&deleted).Error
+	if err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	return deleted, total, nil
+}
+
+// RestoreComment takes a comment out of the trash. It returns ErrNotExist when the
+// comment is not in the trash.
+func (repo *CommentRepo) RestoreComment(ctx context.Context, id uint) (*models.GormComment, error) {
+	var restored models.GormComment
+	res := repo.conn(ctx).Unscoped().Model(&restored).Clauses(clause.Returning{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if err := res.Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	if res.RowsAffected == 0 {
+		return nil, ErrNotExist
+	}
+	return &restored, nil
+}
+
+// PurgeComment permanently removes a comment that is already in the trash.
+func (repo *CommentRepo) PurgeComment(ctx context.Context, id uint) error {
+	res := repo.conn(ctx).Unscoped().Where("deleted_at IS NOT NULL").Delete(&models.GormComment{}, id)
+	if err := res.Error; err != nil {
+		return repo.translateError(err)
+	}
+
+	if res.RowsAffected == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (repo *CommentRepo) ExpiredCommentIDs(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := repo.conn(ctx).Unscoped().Model(&models.GormComment{}).
+		Where("deleted_at < ? AND id > ?", before, afterID).
+		Order("id").Limit(limit).Pluck("id", &ids).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return ids, nil
+}
+
+// CommentIDsByUserID lists the ids of a user's comments, including the ones
+// in the trash when withDeleted is set.
+func (repo *CommentRepo) CommentIDsByUserID(ctx context.Context, userID uint, withDeleted bool) ([]uint, error) {
+	return repo.commentIDs(ctx, "user_id = ?", userID, withDeleted)
+}
+
+// CommentIDsByPostID lists the ids of the comments on a post, including the
+// ones in the trash when withDeleted is set.
+func (repo *CommentRepo) CommentIDsByPostID(ctx context.Context, postID uint, withDeleted bool) ([]uint, error) {
+	return repo.commentIDs(ctx, "post_id = ?", postID, withDeleted)
+}
+
+func (repo *CommentRepo) commentIDs(ctx context.Context, condition string, value uint, withDeleted bool) ([]uint, error) {
+	query := repo.conn(ctx).Model(&models.GormComment{})
+	if withDeleted {
+		query = query.Unscoped()
+	}
+
+	var ids []uint
+	if err := query.Where(condition, value).Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return ids, nil
+}
+
+// ReassignComments hands every comment of one user, trashed ones included,
+// to another user.
+func (repo *CommentRepo) ReassignComments(ctx context.Context, fromUserID uint, toUserID uint) error {
+	err := repo.conn(ctx).Unscoped().Model(&models.GormComment{}).
+		Where("user_id = ?", fromUserID).
+		Updates(map[string]interface{}{"user_id": toUserID, "version": gorm.Expr("version + 1")}).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+
+	return nil
+}
@@ -2,19 +2,34 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/bellaananda/go-postgresql-blog-http.git/models"
 )
 
 // Repository provides access to the website storage.
 type CommentRepository interface {
+	Transactor
 	MigrateComment(ctx context.Context) error
 	CreateComment(ctx context.Context, comment models.GormComment) (*models.GormComment, error)
-	AllComments(ctx context.Context) ([]models.GormComment, error)
+	AllComments(ctx context.Context, query Query) ([]models.GormComment, error)
 	GetCommentByID(ctx context.Context, id uint) (*models.GormComment, error)
+	FindComment(ctx context.Context, id uint, query Query) (*models.GormComment, error)
 	GetCommentByUserID(ctx context.Context, userid uint) ([]models.GormComment, error)
 	GetCommentByPostID(ctx context.Context, postid uint) ([]models.GormComment, error)
 	GetCommentByUserIDPostID(ctx context.Context, userid uint, postid uint) (*models.GormComment, error)
 	UpdateComment(ctx context.Context, id uint, updated models.GormComment) (*models.GormComment, error)
+	PatchComment(ctx context.Context, id uint, version uint, columns map[string]interface{}) (*models.GormComment, error)
 	DeleteComment(ctx context.Context, id uint) error
+	// DeletedComments reads one page of the comments in the trash, most recently
+	// deleted first, and how many there are in all.
+	DeletedComments(ctx context.Context, page Page) ([]models.GormComment, int64, error)
+	RestoreComment(ctx context.Context, id uint) (*models.GormComment, error)
+	PurgeComment(ctx context.Context, id uint) error
+	// ExpiredCommentIDs lists, in id order, the ids after afterID of up to
+	// limit comments that went into the trash before before.
+	ExpiredCommentIDs(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error)
+	CommentIDsByUserID(ctx context.Context, userID uint, withDeleted bool) ([]uint, error)
+	CommentIDsByPostID(ctx context.Context, postID uint, withDeleted bool) ([]uint, error)
+	ReassignComments(ctx context.Context, fromUserID uint, toUserID uint) error
 }
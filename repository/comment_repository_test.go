@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+func TestCommentRepoStatements(t *testing.T) {
+	db, recorder, tables := newDryRun(t)
+	repo := NewCommentRepository(db)
+
+	runDryRun(t, recorder, tables, []dryRunCase{
+		{"MigrateComment", func(ctx context.Context) error {
+			return repo.MigrateComment(ctx)
+		}, []string{
+			`CREATE TABLE "gorm_comments"`,
+			`REFERENCES "gorm_posts"("id") ON DELETE RESTRICT ON UPDATE CASCADE`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS "idx_comments_user_post" ON "gorm_comments" ("user_id","post_id") WHERE deleted_at IS NULL`,
+			`constraint_name = 'fk_gorm_users_comments'`,
+			`constraint_name = 'fk_gorm_posts_comments'`,
+		}},
+		{"CreateComment", func(ctx context.Context) error {
+			_, err := repo.CreateComment(ctx, models.GormComment{UserID: 1, PostID: 2, Content: "c"})
+			return err
+		}, []string{`INSERT INTO "gorm_comments"`}},
+		{"AllComments", func(ctx context.Context) error {
+			_, err := repo.AllComments(ctx, Query{Fields: map[string][]string{"comments": {"id", "user_id", "post_id"}}})
+			return err
+		}, []string{`SELECT "id","user_id","post_id" FROM "gorm_comments" WHERE "gorm_comments"."deleted_at" IS NULL`}},
+		{"GetCommentByID", func(ctx context.Context) error {
+			_, err := repo.GetCommentByID(ctx, 1)
+			return err
+		}, []string{`FROM "gorm_comments" WHERE "gorm_comments"."id" = 1 AND "gorm_comments"."deleted_at" IS NULL`}},
+		{"GetCommentByUserID", func(ctx context.Context) error {
+			_, err := repo.GetCommentByUserID(ctx, 1)
+			return err
+		}, []string{`FROM "gorm_comments" WHERE user_id = 1 AND "gorm_comments"."deleted_at" IS NULL`}},
+		{"GetCommentByPostID", func(ctx context.Context) error {
+			_, err := repo.GetCommentByPostID(ctx, 2)
+			return err
+		}, []string{`FROM "gorm_comments" WHERE post_id = 2 AND "gorm_comments"."deleted_at" IS NULL`}},
+		{"GetCommentByUserIDPostID", func(ctx context.Context) error {
+			_, err := repo.GetCommentByUserIDPostID(ctx, 1, 2)
+			return err
+		}, []string{`WHERE (user_id = 1 AND post_id = 2) AND "gorm_comments"."deleted_at" IS NULL`}},
+		{"UpdateComment", func(ctx context.Context) error {
+			_, err := repo.UpdateComment(ctx, 1, models.GormComment{Version: 3, Content: "c"})
+			return err
+		}, []string{`UPDATE "gorm_comments" SET`, `"version"=4`, `WHERE version = 3`, `RETURNING *`, `SELECT count(*) FROM "gorm_comments" WHERE id = 1`}},
+		{"PatchComment", func(ctx context.Context) error {
+			_, err := repo.PatchComment(ctx, 1, 3, map[string]interface{}{"content": "c"})
+			return err
+		}, []string{`UPDATE "gorm_comments" SET`, `"version"=4`, `WHERE (id = 1 AND version = 3)`, `RETURNING *`}},
+		{"DeleteComment", func(ctx context.Context) error {
+			return repo.DeleteComment(ctx, 1)
+		}, []string{`UPDATE "gorm_comments" SET "deleted_at"=`, `WHERE "gorm_comments"."id" = 1 AND "gorm_comments"."deleted_at" IS NULL`}},
+		{"DeletedComments", func(ctx context.Context) error {
+			_, _, err := repo.DeletedComments(ctx, Page{Number: 2, Size: 10})
+			return err
+		}, []string{`SELECT count(*) FROM "gorm_comments" WHERE deleted_at IS NOT NULL`, `WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC LIMIT 10 OFFSET 10`}},
+		{"RestoreComment", func(ctx context.Context) error {
+			_, err := repo.RestoreComment(ctx, 1)
+			return err
+		}, []string{`UPDATE "gorm_comments" SET "deleted_at"=NULL,"version"=version + 1`, `WHERE id = 1 AND deleted_at IS NOT NULL`}},
+		{"PurgeComment", func(ctx context.Context) error {
+			return repo.PurgeComment(ctx, 1)
+		}, []string{`DELETE FROM "gorm_comments" WHERE deleted_at IS NOT NULL AND "gorm_comments"."id" = 1`}},
+		{"ExpiredCommentIDs", func(ctx context.Context) error {
+			_, err := repo.ExpiredCommentIDs(ctx, time.Now(), 5, 100)
+			return err
+		}, []string{`SELECT "id" FROM "gorm_comments" WHERE deleted_at < `, `AND id > 5 ORDER BY id LIMIT 100`}},
+		{"CommentIDsByUserID", func(ctx context.Context) error {
+			_, err := repo.CommentIDsByUserID(ctx, 1, false)
+			return err
+		}, []string{`SELECT "id" FROM "gorm_comments" WHERE user_id = 1 AND "gorm_comments"."deleted_at" IS NULL ORDER BY id`}},
+		{"CommentIDsByPostID", func(ctx context.Context) error {
+			_, err := repo.CommentIDsByPostID(ctx, 2, true)
+			return err
+		}, []string{`SELECT "id" FROM "gorm_comments" WHERE post_id = 2 ORDER BY id`}},
+		{"ReassignComments", func(ctx context.Context) error {
+			return repo.ReassignComments(ctx, 1, 2)
+		}, []string{`UPDATE "gorm_comments" SET "user_id"=2,"version"=version + 1`, `WHERE user_id = 1`}},
+	})
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FollowRepo struct {
+	gormRepository
+}
+
+func NewFollowRepository(db *gorm.DB) FollowRepository {
+	return &FollowRepo{gormRepository{db}}
+}
+
+func (repo *FollowRepo) MigrateFollow(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormFollow{}, &models.GormTimelineEntry{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateFollow inserts with ON CONFLICT DO NOTHING, since a failed insert
+// would abort the surrounding transaction.
+func (repo *FollowRepo) CreateFollow(ctx context.Context, followerID uint, followeeID uint) (*models.GormFollow, bool, error) {
+	follow := models.GormFollow{FollowerID: followerID, FolloweeID: followeeID}
+	createRes := repo.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&follow)
+	if err := createRes.Error; err != nil {
+		return nil, false, repo.translateError(err)
+	}
+	if createRes.RowsAffected > 0 {
+		return &follow, true, nil
+	}
+
+	var existing models.GormFollow
+	err := repo.conn(ctx).Where("follower_id = ? AND followee_id = ?", followerID, followeeID).First(&existing).Error
+	if err != nil {
+		return nil, false, repo.translateError(err)
+	}
+	return &existing, false, nil
+}
+
+func (repo *FollowRepo) DeleteFollow(ctx context.Context, followerID uint, followeeID uint) (bool, error) {
+	deleteRes := repo.conn(ctx).Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&models.GormFollow{})
+	if err := deleteRes.Error; err != nil {
+		return false, repo.translateError(err)
+	}
+
+	return deleteRes.RowsAffected > 0, nil
+}
+
+func (repo *FollowRepo) Followers(ctx context.Context, userID uint, page Page) ([]models.GormUser, int64, error) {
+	return repo.follows(ctx, "gorm_follows.follower_id", "gorm_follows.followee_id", userID, page)
+}
+
+func (repo *FollowRepo) Following(ctx context.Context, userID uint, page Page) ([]models.GormUser, int64, error) {
+	return repo.follows(ctx, "gorm_follows.followee_id", "gorm_follows.follower_id", userID, page)
+}
+
+// follows lists the users on the listed side of the follows whose other
+// side is userID.
+func (repo *FollowRepo) follows(ctx context.Context, listed string, other string, userID uint, page Page) ([]models.GormUser, int64, error) {
+	query := repo.conn(ctx).Model(&models.GormUser{}).
+		Joins("JOIN gorm_follows ON "+listed+" = gorm_users.id").
+		Where(other+" = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	users := []models.GormUser{}
+	err := omitPassword(query).
+		Order("gorm_follows.created_at DESC, gorm_users.id").
+		Limit(page.Size).Offset(page.offset()).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	return users, total, nil
+}
+
+func (repo *FollowRepo) CountFollowers(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	if err := repo.conn(ctx).Model(&models.GormFollow{}).Where("followee_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, repo.translateError(err)
+	}
+	return count, nil
+}
+
+// FanOut copies the post onto every timeline in one INSERT ... SELECT, so
+// the followers never travel through the application.
+func (repo *FollowRepo) FanOut(ctx context.Context, post models.GormPost) error {
+	err := repo.conn(ctx).Exec(`DELETE FROM gorm_timeline_entries WHERE post_id = ? AND author_id <> ?`, post.ID, post.UserID).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+
+	err = repo.conn(ctx).Exec(`INSERT INTO gorm_timeline_entries (user_id, post_id, author_id, published_at)
+		SELECT follower_id, @post, @author, @published FROM gorm_follows WHERE followee_id = @author
+		ON CONFLICT DO NOTHING`,
+		map[string]interface{}{"post": post.ID, "author": post.UserID, "published": post.PublishedAt},
+	).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+
+	return repo.setFannedOut(ctx, post.ID, true)
+}
+
+func (repo *FollowRepo) SkipFanOut(ctx context.Context, postID uint) error {
+	return repo.setFannedOut(ctx, postID, false)
+}
+
+// setFannedOut leaves the version and updated_at alone: how a post reaches
+// feeds is not an edit of the post.
+func (repo *FollowRepo) setFannedOut(ctx context.Context, postID uint, fannedOut bool) error {
+	err := repo.conn(ctx).Model(&models.GormPost{}).Where("id = ?", postID).UpdateColumn("fanned_out", fannedOut).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+	return nil
+}
+
+func (repo *FollowRepo) Backfill(ctx context.Context, followerID uint, followeeID uint) error {
+	err := repo.conn(ctx).Exec(`INSERT INTO gorm_timeline_entries (user_id, post_id, author_id, published_at)
+		SELECT @follower, id, user_id, published_at FROM gorm_posts
+		WHERE user_id = @followee AND is_published AND fanned_out
+		ON CONFLICT DO NOTHING`,
+		map[string]interface{}{"follower": followerID, "followee": followeeID},
+	).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+	return nil
+}
+
+func (repo *FollowRepo) ClearTimeline(ctx context.Context, userID uint, authorID uint) error {
+	err := repo.conn(ctx).Where("user_id = ? AND author_id = ?", userID, authorID).Delete(&models.GormTimelineEntry{}).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+	return nil
+}
+
+func (repo *FollowRepo) Timeline(ctx context.Context, userID uint, after *Cursor, limit int) ([]models.GormPost, error) {
+	query := repo.conn(ctx).Model(&models.GormPost{}).
+		Joins("JOIN gorm_timeline_entries ON gorm_timeline_entries.post_id = gorm_posts.id").
+		Where("gorm_timeline_entries.user_id = ? AND gorm_posts.is_published", userID)
+	if after != nil {
+		query = query.Where("(gorm_timeline_entries.published_at, gorm_timeline_entries.post_id) < (?, ?)", after.PublishedAt, after.PostID)
+	}
+
+	return repo.feedPage(query.Order("gorm_timeline_entries.published_at DESC, gorm_timeline_entries.post_id DESC"), limit)
+}
+
+func (repo *FollowRepo) PulledPosts(ctx context.Context, userID uint, after *Cursor, limit int) ([]models.GormPost, error) {
+	query := repo.conn(ctx).Model(&models.GormPost{}).
+		Joins("JOIN gorm_follows ON gorm_follows.followee_id = gorm_posts.user_id AND gorm_follows.follower_id = ?", userID).
+		Where("gorm_posts.is_published AND NOT gorm_posts.fanned_out")
+	if after != nil {
+		query = query.Where("(gorm_posts.published_at, gorm_posts.id) < (?, ?)", after.PublishedAt, after.PostID)
+	}
+
+	return repo.feedPage(query.Order("gorm_posts.published_at DESC, gorm_posts.id DESC"), limit)
+}
+
+// feedPage reads one page of a feed query with the authors of its posts.
+func (repo *FollowRepo) feedPage(query *gorm.DB, limit int) ([]models.GormPost, error) {
+	posts := []models.GormPost{}
+	if err := query.Omit("content").Preload("User", omitPassword).Limit(limit).Find(&posts).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+	return posts, nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// FollowRepository stores who follows whom and the timelines their feeds
+// are read from.
+type FollowRepository interface {
+	Transactor
+	MigrateFollow(ctx context.Context) error
+	// CreateFollow reports false, and returns the follow already stored,
+	// when followerID already follows followeeID.
+	CreateFollow(ctx context.Context, followerID uint, followeeID uint) (*models.GormFollow, bool, error)
+	// DeleteFollow reports false when followerID did not follow followeeID.
+	DeleteFollow(ctx context.Context, followerID uint, followeeID uint) (bool, error)
+	// Followers and Following return one page of the live users following
+	// or followed by a user, most recently followed first, and how many
+	// there are in all.
+	Followers(ctx context.Context, userID uint, page Page) ([]models.GormUser, int64, error)
+	Following(ctx context.Context, userID uint, page Page) ([]models.GormUser, int64, error)
+	CountFollowers(ctx context.Context, userID uint) (int64, error)
+
+	// FanOut puts a published post on the timeline of every follower of its
+	// author, takes it off the timelines of followers of a former author,
+	// and marks it as fanned out.
+	FanOut(ctx context.Context, post models.GormPost) error
+	// SkipFanOut marks a post as read by feeds through PulledPosts.
+	SkipFanOut(ctx context.Context, postID uint) error
+	// Backfill puts the fanned out posts of followeeID on the timeline of
+	// followerID, who just followed them.
+	Backfill(ctx context.Context, followerID uint, followeeID uint) error
+	// ClearTimeline takes the posts of authorID off the timeline of userID.
+	ClearTimeline(ctx context.Context, userID uint, authorID uint) error
+	// Timeline and PulledPosts each read up to limit live, published posts
+	// for the feed of userID after the cursor, newest first and without
+	// their content: Timeline from the user's timeline, PulledPosts from
+	// the posts of followed authors that were not fanned out. A nil cursor
+	// reads from the start.
+	Timeline(ctx context.Context, userID uint, after *Cursor, limit int) ([]models.GormPost, error)
+	PulledPosts(ctx context.Context, userID uint, after *Cursor, limit int) ([]models.GormPost, error)
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Postgres error codes the repositories translate into sentinel errors.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// gormRepository is embedded by every Postgres-backed repository and holds
+// what they share: the connection and the mapping of driver errors onto
+// the package's sentinel errors.
+type gormRepository struct {
+	db *gorm.DB
+}
+
+// translateError maps pg constraint violations and missing rows onto
+// ErrDuplicate, ErrForeignKey and ErrNotExist. Anything else is returned
+// unchanged.
+func (repo gormRepository) translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotExist
+	}
+
+	var pgxError *pgconn.PgError
+	if errors.As(err, &pgxError) {
+		switch pgxError.Code {
+		case pgUniqueViolation:
+			return ErrDuplicate
+		case pgForeignKeyViolation:
+			return ErrForeignKey
+		}
+	}
+
+	return err
+}
+
+// updateFailure explains why a versioned update touched no rows: the row is
+// gone (ErrUpdateFailed) or someone else bumped its version first
+// (ErrVersionConflict).
+func (repo gormRepository) updateFailure(ctx context.Context, model interface{}, id uint) error {
+	var count int64
+	if err := repo.conn(ctx).Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return repo.translateError(err)
+	}
+	if count == 0 {
+		return ErrUpdateFailed
+	}
+	return ErrVersionConflict
+}
+
+// createLiveUniqueIndex makes column unique among the rows of table that are
+// not in the trash. The index is not declared in a gorm tag: GORM turns a
+// unique index over a single column into a UNIQUE constraint on the column
+// as well, which would keep the values of trashed rows reserved. Such
+// constraints left by earlier migrations are dropped.
+func (repo gormRepository) createLiveUniqueIndex(ctx context.Context, table string, name string, column string) error {
+	for _, constraint := range []string{table + "_" + column + "_key", "idx_" + table + "_" + column} {
+		if err := repo.conn(ctx).Exec("ALTER TABLE " + table + " DROP CONSTRAINT IF EXISTS " + constraint).Error; err != nil {
+			return err
+		}
+	}
+
+	return repo.conn(ctx).Exec("CREATE UNIQUE INDEX IF NOT EXISTS ? ON ? (?) WHERE deleted_at IS NULL",
+		clause.Column{Name: name}, clause.Table{Name: table}, clause.Column{Name: column}).Error
+}
+
+// migrateConstraints brings the foreign keys built from model's relationship
+// fields in line with their tags. AutoMigrate only creates the constraints
+// that are missing, so one made before its ON DELETE or ON UPDATE rule
+// changed is dropped and made again, in one transaction.
+func (repo gormRepository) migrateConstraints(ctx context.Context, model interface{}, fields ...string) error {
+	stmt := &gorm.Statement{DB: repo.conn(ctx)}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		var constraint *schema.Constraint
+		if relationship, ok := stmt.Schema.Relationships.Relations[field]; ok {
+			constraint = relationship.ParseConstraint()
+		}
+		if constraint == nil {
+			return fmt.Errorf("%s.%s has no foreign key", stmt.Schema.Name, field)
+		}
+
+		var rules referentialRules
+		err := repo.conn(ctx).Raw("SELECT delete_rule, update_rule FROM information_schema.referential_constraints WHERE constraint_schema = CURRENT_SCHEMA() AND constraint_name = ?", constraint.Name).
+			Find(&rules).Error
+		if err != nil {
+			return err
+		}
+		// a missing constraint is left to AutoMigrate
+		if rules.DeleteRule == "" || (rules.DeleteRule == referentialAction(constraint.OnDelete) && rules.UpdateRule == referentialAction(constraint.OnUpdate)) {
+			continue
+		}
+
+		err = repo.WithTx(ctx, func(ctx context.Context) error {
+			migrator := repo.conn(ctx).Migrator()
+			if err := migrator.DropConstraint(model, constraint.Name); err != nil {
+				return err
+			}
+			return migrator.CreateConstraint(model, constraint.Name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// referentialRules are the actions of a foreign key as information_schema
+// reports them.
+type referentialRules struct {
+	DeleteRule string
+	UpdateRule string
+}
+
+// referentialAction spells an OnDelete or OnUpdate tag setting the way
+// information_schema reports it.
+func referentialAction(setting string) string {
+	if setting == "" {
+		return "NO ACTION"
+	}
+	return strings.ToUpper(setting)
+}
@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestTranslateError(t *testing.T) {
+	other := errors.New("connection reset")
+	check := &pgconn.PgError{Code: "23514"}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"nil", nil, nil},
+		{"record not found", gorm.ErrRecordNotFound, ErrNotExist},
+		{"wrapped record not found", fmt.Errorf("first: %w", gorm.ErrRecordNotFound), ErrNotExist},
+		{"unique violation", &pgconn.PgError{Code: pgUniqueViolation}, ErrDuplicate},
+		{"wrapped unique violation", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"}), ErrDuplicate},
+		{"foreign key violation", &pgconn.PgError{Code: pgForeignKeyViolation}, ErrForeignKey},
+		{"wrapped foreign key violation", fmt.Errorf("delete: %w", &pgconn.PgError{Code: "23503"}), ErrForeignKey},
+		{"other constraint", check, check},
+		{"other error", other, other},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := gormRepository{}.translateError(test.err)
+			if got != test.want {
+				t.Errorf("translateError(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+// dryRunPool stands in for the database under a dry run, where GORM builds
+// every statement without sending it. Only transactions reach the pool.
+type dryRunPool struct{}
+
+var errDryRun = errors.New("dry run: no database")
+
+func (pool *dryRunPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errDryRun
+}
+
+func (pool *dryRunPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, errDryRun
+}
+
+func (pool *dryRunPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errDryRun
+}
+
+func (pool *dryRunPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func (pool *dryRunPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return pool, nil
+}
+
+func (pool *dryRunPool) Commit() error   { return nil }
+func (pool *dryRunPool) Rollback() error { return nil }
+
+// sqlRecorder is a GORM logger keeping every statement built.
+type sqlRecorder struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (recorder *sqlRecorder) LogMode(logger.LogLevel) logger.Interface      { return recorder }
+func (recorder *sqlRecorder) Info(context.Context, string, ...interface{})  {}
+func (recorder *sqlRecorder) Warn(context.Context, string, ...interface{})  {}
+func (recorder *sqlRecorder) Error(context.Context, string, ...interface{}) {}
+
+func (recorder *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	statement, _ := fc()
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.statements = append(recorder.statements, statement)
+}
+
+// take returns the statements recorded since the last call.
+func (recorder *sqlRecorder) take() []string {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	statements := recorder.statements
+	recorder.statements = nil
+	return statements
+}
+
+// newDryRun opens a Postgres-dialect GORM handle that records the SQL it
+// would run instead of running it, and returns it with the tables every
+// migration creates.
+func newDryRun(t *testing.T) (*gorm.DB, *sqlRecorder, map[string]bool) {
+	t.Helper()
+	recorder := &sqlRecorder{}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: &dryRunPool{}}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               recorder,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+
+	ctx := context.Background()
+	migrations := []func(context.Context) error{
+		NewUserRepository(db).MigrateUser,
+		NewPostRepository(db).MigratePost,
+		NewCommentRepository(db).MigrateComment,
+		NewMediaRepository(db).MigrateMedia,
+		NewReactionRepository(db).MigrateReaction,
+		NewBookmarkRepository(db).MigrateBookmark,
+		NewFollowRepository(db).MigrateFollow,
+		NewViewRepository(db).MigrateView,
+		NewNotificationRepository(db).MigrateNotification,
+		NewWebhookRepository(db).MigrateWebhook,
+		NewCommentEventRepository(db).MigrateCommentEvent,
+	}
+	for _, migrate := range migrations {
+		if err := migrate(ctx); err != nil {
+			t.Fatalf("dry run migration: %v", err)
+		}
+	}
+
+	tables := map[string]bool{}
+	for _, statement := range recorder.take() {
+		if match := createTable.FindStringSubmatch(statement); match != nil {
+			tables[match[1]] = true
+		}
+	}
+	return db, recorder, tables
+}
+
+var (
+	createTable = regexp.MustCompile(`^CREATE TABLE "(\w+)"`)
+	// stringLiteral matches the values the recorder writes into statements
+	stringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	// relationName matches what a statement reads or writes, and any
+	// quoted alias given to it
+	relationName = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|UPDATE|INTO|TABLE|REFERENCES)\s+(?:ONLY\s+)?("?)([A-Za-z_]\w*)"?(\(?)(?:\s+(?:AS\s+)?"(\w+)")?`)
+	indexTable   = regexp.MustCompile(`(?i)\bINDEX\s+(?:IF NOT EXISTS\s+)?"?\w+"?\s+ON\s+"?(\w+)"?`)
+	aliasName    = regexp.MustCompile(`(?i)\bAS\s+"?([A-Za-z_]\w*)"?`)
+	qualifier    = regexp.MustCompile(`("?)([A-Za-z_]\w*)"?\.["*A-Za-z_]`)
+)
+
+// sqlKeywords may follow the words relationName looks for without naming
+// a relation, as in ON UPDATE CASCADE or DO UPDATE SET.
+var sqlKeywords = map[string]bool{"set": true, "cascade": true, "if": true, "lateral": true}
+
+// systemSchemas are the schemas statements may read besides the tables.
+var systemSchemas = map[string]bool{"information_schema": true, "pg_catalog": true}
+
+// unknownTables returns the tables a statement names that no migration
+// creates, such as one without GORM's gorm_ prefix.
+func unknownTables(tables map[string]bool, statement string) []string {
+	statement = stringLiteral.ReplaceAllString(statement, "''")
+
+	known := map[string]bool{"excluded": true}
+	for name := range tables {
+		known[name] = true
+	}
+	for name := range systemSchemas {
+		known[name] = true
+	}
+	for _, match := range aliasName.FindAllStringSubmatch(statement, -1) {
+		known[match[1]] = true
+	}
+
+	named := []string{}
+	for _, match := range relationName.FindAllStringSubmatch(statement, -1) {
+		name, function, alias := match[2], match[3] == "(", match[4]
+		if function || match[1] == "" && sqlKeywords[strings.ToLower(name)] {
+			continue
+		}
+		named = append(named, name)
+		if alias != "" {
+			known[alias] = true
+		}
+	}
+	for _, match := range indexTable.FindAllStringSubmatch(statement, -1) {
+		named = append(named, match[1])
+	}
+	for _, match := range qualifier.FindAllStringSubmatch(statement, -1) {
+		named = append(named, match[2])
+	}
+
+	unknown := []string{}
+	for _, name := range named {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// checkTables fails the test when a statement names an unknown table.
+func checkTables(t *testing.T, tables map[string]bool, statements []string) {
+	t.Helper()
+	for _, statement := range statements {
+		if unknown := unknownTables(tables, statement); len(unknown) > 0 {
+			t.Errorf("statement names unknown tables %q:\n%s", unknown, statement)
+		}
+	}
+}
+
+// dryRunCase is one call of a repository method and what its statements
+// must contain.
+type dryRunCase struct {
+	name string
+	call func(ctx context.Context) error
+	want []string
+}
+
+// runDryRun runs each case, checking the tables its statements name and
+// that they contain what the case wants, in order.
+func runDryRun(t *testing.T, recorder *sqlRecorder, tables map[string]bool, cases []dryRunCase) {
+	t.Helper()
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			// dry runs find no rows, so errors such as ErrNotExist are
+			// expected; only the statements are checked
+			test.call(context.Background())
+			statements := recorder.take()
+			if len(statements) == 0 {
+				t.Fatal("no statement was built")
+			}
+			checkTables(t, tables, statements)
+
+			all := strings.Join(statements, "\n")
+			for _, want := range test.want {
+				index := strings.Index(all, want)
+				if index < 0 {
+					t.Errorf("statements lack %q:\n%s", want, strings.Join(statements, "\n"))
+					return
+				}
+				all = all[index+len(want):]
+			}
+		})
+	}
+}
+
+func TestUnknownTables(t *testing.T) {
+	tables := map[string]bool{"gorm_posts": true, "gorm_bookmarks": true}
+
+	good := []string{
+		`SELECT gorm_posts.* FROM "gorm_posts" JOIN gorm_bookmarks ON gorm_bookmarks.post_id = gorm_posts.id WHERE gorm_bookmarks.list = 'a.b'`,
+		`SELECT * FROM "gorm_bookmarks" LEFT JOIN "gorm_posts" "Post" ON "gorm_bookmarks"."post_id" = "Post"."id"`,
+		`INSERT INTO "gorm_posts" ("id") VALUES (1) ON CONFLICT ("id") DO UPDATE SET "id"="excluded"."id"`,
+		`SELECT count(*) FROM information_schema.tables WHERE table_name = 'gorm_posts'`,
+		`SELECT counts.n FROM (SELECT COUNT(*) AS n FROM gorm_posts) AS counts`,
+	}
+	for _, statement := range good {
+		if unknown := unknownTables(tables, statement); len(unknown) > 0 {
+			t.Errorf("unknownTables(%s) = %q, want none", statement, unknown)
+		}
+	}
+
+	bad := []string{
+		`SELECT posts.* FROM "gorm_posts"`,
+		`UPDATE comments SET reaction_counts = '{}'`,
+		`ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key`,
+		`SELECT * FROM gorm_posts JOIN bookmarks ON bookmarks.post_id = gorm_posts.id`,
+	}
+	for _, statement := range bad {
+		if unknown := unknownTables(tables, statement); len(unknown) == 0 {
+			t.Errorf("unknownTables(%s) found none", statement)
+		}
+	}
+}
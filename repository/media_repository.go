@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MediaRepo struct {
+	gormRepository
+}
+
+func NewMediaRepository(db *gorm.DB) MediaRepository {
+	return &MediaRepo{gormRepository{db}}
+}
+
+func (repo *MediaRepo) MigrateMedia(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormPostMedia{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (repo *MediaRepo) CreateMedia(ctx context.Context, media models.GormPostMedia) (*models.GormPostMedia, error) {
+	media.Post = nil
+	if err := repo.conn(ctx).Create(&media).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return &media, nil
+}
+
+// MediaByPostIDs loads the variants of a whole page of posts in one query,
+// narrowest first.
+func (repo *MediaRepo) MediaByPostIDs(ctx context.Context, postIDs []uint) ([]models.GormPostMedia, error) {
+	var media []models.GormPostMedia
+	if len(postIDs) == 0 {
+		return media, nil
+	}
+
+	if err := repo.conn(ctx).Where("post_id IN ?", postIDs).Order("post_id, width").Find(&media).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return media, nil
+}
+
+// DeleteMediaBySourceKey removes the variants made from one upload and
+// returns them, so their files can be removed too.
+func (repo *MediaRepo) DeleteMediaBySourceKey(ctx context.Context, sourceKey string) ([]models.GormPostMedia, error) {
+	return repo.deleteMedia(ctx, "source_key = ?", sourceKey)
+}
+
+// DeleteMediaByPostID removes every variant of a post and returns them.
+func (repo *MediaRepo) DeleteMediaByPostID(ctx context.Context, postID uint) ([]models.GormPostMedia, error) {
+	return repo.deleteMedia(ctx, "post_id = ?", postID)
+}
+
+func (repo *MediaRepo) deleteMedia(ctx context.Context, condition string, value interface{}) ([]models.GormPostMedia, error) {
+	var deleted []models.GormPostMedia
+	if err := repo.conn(ctx).Clauses(clause.Returning{}).Where(condition, value).Delete(&deleted).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// MediaRepository stores the processed image variants of posts.
+type MediaRepository interface {
+	Transactor
+	MigrateMedia(ctx context.Context) error
+	CreateMedia(ctx context.Context, media models.GormPostMedia) (*models.GormPostMedia, error)
+	MediaByPostIDs(ctx context.Context, postIDs []uint) ([]models.GormPostMedia, error)
+	DeleteMediaBySourceKey(ctx context.Context, sourceKey string) ([]models.GormPostMedia, error)
+	DeleteMediaByPostID(ctx context.Context, postID uint) ([]models.GormPostMedia, error)
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+func NewInMemoryBookmarkRepository() BookmarkRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateBookmark(ctx context.Context) error {
+	return nil
+}
+
+func (repo *InMemoryRepository) CreateBookmark(ctx context.Context, bookmark models.GormBookmark) (*models.GormBookmark, bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.users[bookmark.UserID]; !ok {
+		return nil, false, ErrForeignKey
+	}
+	if _, ok := repo.posts[bookmark.PostID]; !ok {
+		return nil, false, ErrForeignKey
+	}
+	for _, existing := range repo.bookmarks {
+		if existing.UserID == bookmark.UserID && existing.List == bookmark.List && existing.PostID == bookmark.PostID {
+			return &existing, false, nil
+		}
+	}
+
+	bookmark.ID = repo.nextID("bookmarks")
+	if bookmark.CreatedAt.IsZero() {
+		bookmark.CreatedAt = time.Now()
+	}
+	bookmark.User, bookmark.Post = nil, nil
+	repo.bookmarks[bookmark.ID] = bookmark
+
+	return &bookmark, true, nil
+}
+
+func (repo *InMemoryRepository) DeleteBookmark(ctx context.Context, userID uint, postID uint, list string) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	deleted := repo.cascadeBookmarks(func(bookmark models.GormBookmark) bool {
+		return bookmark.UserID == userID && bookmark.List == list && bookmark.PostID == postID
+	})
+	return deleted > 0, nil
+}
+
+func (repo *InMemoryRepository) Bookmarks(ctx context.Context, userID uint, list *string, page Page) ([]models.GormBookmark, int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	bookmarks := repo.liveBookmarks(userID)
+	if list != nil {
+		listed := []models.GormBookmark{}
+		for _, bookmark := range bookmarks {
+			if bookmark.List == *list {
+				listed = append(listed, bookmark)
+			}
+		}
+		bookmarks = listed
+	}
+	sort.Slice(bookmarks, func(i, j int) bool {
+		if !bookmarks[i].CreatedAt.Equal(bookmarks[j].CreatedAt) {
+			return bookmarks[i].CreatedAt.After(bookmarks[j].CreatedAt)
+		}
+		return bookmarks[i].ID > bookmarks[j].ID
+	})
+
+	total := int64(len(bookmarks))
+	start, end := page.offset(), page.offset()+page.Size
+	if start > len(bookmarks) {
+		start = len(bookmarks)
+	}
+	if end > len(bookmarks) {
+		end = len(bookmarks)
+	}
+	bookmarks = bookmarks[start:end]
+
+	for i := range bookmarks {
+		post, _ := repo.livePost(bookmarks[i].PostID)
+		post.Content = ""
+		bookmarks[i].Post = &post
+	}
+
+	return bookmarks, total, nil
+}
+
+func (repo *InMemoryRepository) BookmarkLists(ctx context.Context, userID uint) ([]models.BookmarkList, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	counts := map[string]int64{}
+	for _, bookmark := range repo.liveBookmarks(userID) {
+		counts[bookmark.List]++
+	}
+
+	lists := []models.BookmarkList{}
+	for name, count := range counts {
+		lists = append(lists, models.BookmarkList{Name: name, Count: count})
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].Name < lists[j].Name })
+
+	return lists, nil
+}
+
+func (repo *InMemoryRepository) BookmarkedPostIDs(ctx context.Context, userID uint, postIDs []uint) (map[uint]bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	asked := make(map[uint]bool, len(postIDs))
+	for _, id := range postIDs {
+		asked[id] = true
+	}
+
+	bookmarked := make(map[uint]bool, len(postIDs))
+	for _, bookmark := range repo.bookmarks {
+		if bookmark.UserID == userID && asked[bookmark.PostID] {
+			bookmarked[bookmark.PostID] = true
+		}
+	}
+	return bookmarked, nil
+}
+
+// liveBookmarks returns the bookmarks of a user whose posts are not in the
+// trash. Callers must hold the lock.
+func (repo *InMemoryRepository) liveBookmarks(userID uint) []models.GormBookmark {
+	bookmarks := []models.GormBookmark{}
+	for _, bookmark := range repo.bookmarks {
+		if _, ok := repo.livePost(bookmark.PostID); ok && bookmark.UserID == userID {
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+	return bookmarks
+}
+
+// cascadeBookmarks removes the matching bookmarks, the way ON DELETE CASCADE
+// does when their user or post is purged, and returns how many there were.
+// Callers must hold the lock.
+func (repo *InMemoryRepository) cascadeBookmarks(match func(models.GormBookmark) bool) int {
+	deleted := 0
+	for id, bookmark := range repo.bookmarks {
+		if match(bookmark) {
+			delete(repo.bookmarks, id)
+			deleted++
+		}
+	}
+	return deleted
+}
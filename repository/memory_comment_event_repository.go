@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+func NewInMemoryCommentEventRepository() CommentEventRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateCommentEvent(ctx context.Context) error {
+	return nil
+}
+
+// CreateCommentEvent tells the listeners of this repository once the
+// transaction commits, the way NOTIFY does.
+func (repo *InMemoryRepository) CreateCommentEvent(ctx context.Context, event models.GormCommentEvent) error {
+	repo.mu.Lock()
+	event.ID = repo.nextID("comment_events")
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	repo.commentEvents[event.ID] = event
+	repo.mu.Unlock()
+
+	AfterCommit(ctx, func() {
+		repo.listenMu.Lock()
+		defer repo.listenMu.Unlock()
+		for _, listener := range repo.listeners {
+			listener(event.PostID)
+		}
+	})
+	return nil
+}
+
+func (repo *InMemoryRepository) CommentEventsAfter(ctx context.Context, postID uint, afterID uint, limit int) ([]models.GormCommentEvent, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	events := []models.GormCommentEvent{}
+	for _, event := range repo.commentEvents {
+		if event.PostID == postID && event.ID > afterID {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func (repo *InMemoryRepository) LastCommentEventID(ctx context.Context, postID uint) (uint, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var last uint
+	for _, event := range repo.commentEvents {
+		if event.PostID == postID && event.ID > last {
+			last = event.ID
+		}
+	}
+	return last, nil
+}
+
+func (repo *InMemoryRepository) PurgeCommentEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var purged int64
+	for id, event := range repo.commentEvents {
+		if event.CreatedAt.Before(before) {
+			delete(repo.commentEvents, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (repo *InMemoryRepository) ListenCommentEvents(ctx context.Context, fn func(postID uint)) error {
+	repo.listenMu.Lock()
+	repo.lastListener++
+	key := repo.lastListener
+	repo.listeners[key] = fn
+	repo.listenMu.Unlock()
+
+	<-ctx.Done()
+
+	repo.listenMu.Lock()
+	delete(repo.listeners, key)
+	repo.listenMu.Unlock()
+	return ctx.Err()
+}
@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+)
+
+func NewInMemoryCommentRepository() CommentRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateComment(ctx context.Context) error {
+	return nil
+}
+
+// withUserAndPost attaches the author and post the way
+// Preload("User").Preload("Post") does. Callers must hold the lock.
+func (repo *InMemoryRepository) withUserAndPost(comment models.GormComment) models.GormComment {
+	comment.User = nil
+	comment.Post = nil
+	if user, ok := repo.liveAuthor(comment.UserID); ok {
+		comment.User = &user
+	}
+	if post, ok := repo.livePost(comment.PostID); ok {
+		comment.Post = &post
+	}
+	return comment
+}
+
+// Callers must hold the lock.
+func (repo *InMemoryRepository) sortedComments(match func(models.GormComment) bool) []models.GormComment {
+	comments := []models.GormComment{}
+	for id := range repo.comments {
+		if comment, ok := repo.liveComment(id); ok && match(comment) {
+			comments = append(comments, comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+
+	return comments
+}
+
+// commentConflicts reports whether the user has another live comment on
+// the post, as idx_comments_user_post would. Callers must hold the lock.
+func (repo *InMemoryRepository) commentConflicts(comment models.GormComment) bool {
+	for id, existing := range repo.comments {
+		if id == comment.ID || existing.DeletedAt.Valid {
+			continue
+		}
+		if existing.UserID == comment.UserID && existing.PostID == comment.PostID {
+			return true
+		}
+	}
+	return false
+}
+
+func (repo *InMemoryRepository) CreateComment(ctx context.Context, comment models.GormComment) (*models.GormComment, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	comment.ID = 0
+	if repo.commentConflicts(comment) {
+		return nil, ErrDuplicate
+	}
+
+	comment.ID = repo.nextID("comments")
+	comment.Version = 1
+	stampCreate(&comment.CreatedAt, &comment.UpdatedAt)
+	// the column defaults
+	if comment.ContentFormat == "" {
+		comment.ContentFormat = "markdown"
+	}
+	comment.ReactionCounts = models.ReactionCounts{}
+	comment.User = nil
+	comment.Post = nil
+	repo.comments[comment.ID] = comment
+
+	return &comment, nil
+}
+
+func (repo *InMemoryRepository) AllComments(ctx context.Context, query Query) ([]models.GormComment, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	allComments := repo.sortedComments(func(models.GormComment) bool { return true })
+	for i := range allComments {
+		allComments[i] = repo.queryComment(allComments[i], query, "user", "post")
+	}
+
+	return allComments, nil
+}
+
+func (repo *InMemoryRepository) GetCommentByID(ctx context.Context, id uint) (*models.GormComment, error) {
+	return repo.FindComment(ctx, id, Query{})
+}
+
+func (repo *InMemoryRepository) FindComment(ctx context.Context, id uint, query Query) (*models.GormComment, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	comment, ok := repo.liveComment(id)
+	if !ok {
+		return nil, ErrNotExist
+	}
+	comment = repo.queryComment(comment, query, "user", "post")
+
+	return &comment, nil
+}
+
+func (repo *InMemoryRepository) GetCommentByUserID(ctx context.Context, userid uint) ([]models.GormComment, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.sortedComments(func(comment models.GormComment) bool { return comment.UserID == userid }), nil
+}
+
+func (repo *InMemoryRepository) GetCommentByPostID(ctx context.Context, postid uint) ([]models.GormComment, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.sortedComments(func(comment models.GormComment) bool { return comment.PostID == postid }), nil
+}
+
+func (repo *InMemoryRepository) GetCommentByUserIDPostID(ctx context.Context, userid uint, postid uint) (*models.GormComment, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	comments := repo.sortedComments(func(comment models.GormComment) bool {
+		return comment.UserID == userid && comment.PostID == postid
+	})
+	if len(comments) == 0 {
+		return nil, ErrNotExist
+	}
+
+	return &comments[0], nil
+}
+
+func (repo *InMemoryRepository) UpdateComment(ctx context.Context, id uint, updated models.GormComment) (*models.GormComment, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	existing, ok := repo.liveComment(id)
+	if !ok {
+		return nil, ErrUpdateFailed
+	}
+	if existing.Version != updated.Version {
+		return nil, ErrVersionConflict
+	}
+
+	updated.ID = id
+	if repo.commentConflicts(updated) {
+		return nil, ErrDuplicate
+	}
+
+	updated.Version++
+	updated.CreatedAt = existing.CreatedAt
+	updated.ReactionCounts = existing.ReactionCounts
+	updated.UpdatedAt = time.Now()
+	updated.DeletedAt = gorm.DeletedAt{}
+	updated.User = nil
+	updated.Post = nil
+	repo.comments[id] = updated
+	updated = repo.withUserAndPost(updated)
+
+	return &updated, nil
+}
+
+func (repo *InMemoryRepository) PatchComment(ctx context.Context, id uint, version uint, columns map[string]interface{}) (*models.GormComment, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	patched, ok := repo.liveComment(id)
+	if !ok {
+		return nil, ErrUpdateFailed
+	}
+	if patched.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	if err := setColumns(&patched, columns); err != nil {
+		return nil, err
+	}
+	if repo.commentConflicts(patched) {
+		return nil, ErrDuplicate
+	}
+	patched.Version++
+	patched.UpdatedAt = time.Now()
+	repo.comments[id] = patched
+
+	patched = repo.withUserAndPost(patched)
+
+	return &patched, nil
+}
+
+func (repo *InMemoryRepository) DeleteComment(ctx context.Context, id uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	comment, ok := repo.liveComment(id)
+	if !ok {
+		return ErrDeleteFailed
+	}
+
+	comment.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	repo.comments[id] = comment
+
+	return nil
+}
+
+func (repo *InMemoryRepository) DeletedComments(ctx context.Context, page Page) ([]models.GormComment, int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	deleted := []models.GormComment{}
+	for _, comment := range repo.comments {
+		if comment.DeletedAt.Valid {
+			deleted = append(deleted, comment)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool {
+		if !deleted[i].DeletedAt.Time.Equal(deleted[j].DeletedAt.Time) {
+			return deleted[i].DeletedAt.Time.After(deleted[j].DeletedAt.Time)
+		}
+		return deleted[i].ID > deleted[j].ID
+	})
+
+	return pageOf(deleted, page), int64(len(deleted)), nil
+}
+
+func (repo *InMemoryRepository) RestoreComment(ctx context.Context, id uint) (*models.GormComment, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	restored, ok := repo.comments[id]
+	if !ok || !restored.DeletedAt.Valid {
+		return nil, ErrNotExist
+	}
+	if repo.commentConflicts(restored) {
+		return nil, ErrDuplicate
+	}
+
+	restored.DeletedAt = gorm.DeletedAt{}
+	restored.Version++
+	restored.UpdatedAt = time.Now()
+	repo.comments[id] = restored
+
+	return &restored, nil
+}
+
+func (repo *InMemoryRepository) PurgeComment(ctx context.Context, id uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	comment, ok := repo.comments[id]
+	if !ok || !comment.DeletedAt.Valid {
+		return ErrNotExist
+	}
+
+	delete(repo.comments, id)
+	repo.cascadeReactions(func(reaction models.GormReaction) bool {
+		return reaction.CommentID != nil && *reaction.CommentID == id
+	})
+	repo.cascadeNotifications(func(notification models.GormNotification) bool {
+		return notification.CommentID != nil && *notification.CommentID == id
+	})
+	return nil
+}
+
+func (repo *InMemoryRepository) ExpiredCommentIDs(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	ids := []uint{}
+	for id, comment := range repo.comments {
+		if comment.DeletedAt.Valid && comment.DeletedAt.Time.Before(before) && id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	return firstIDs(ids, limit), nil
+}
+
+func (repo *InMemoryRepository) CommentIDsByUserID(ctx context.Context, userID uint, withDeleted bool) ([]uint, error) {
+	return repo.commentIDs(func(comment models.GormComment) bool { return comment.UserID == userID }, withDeleted), nil
+}
+
+func (repo *InMemoryRepository) CommentIDsByPostID(ctx context.Context, postID uint, withDeleted bool) ([]uint, error) {
+	return repo.commentIDs(func(comment models.GormComment) bool { return comment.PostID == postID }, withDeleted), nil
+}
+
+func (repo *InMemoryRepository) commentIDs(match func(models.GormComment) bool, withDeleted bool) []uint {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	ids := []uint{}
+	for id, comment := range repo.comments {
+		if match(comment) && (withDeleted || !comment.DeletedAt.Valid) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+func (repo *InMemoryRepository) ReassignComments(ctx context.Context, fromUserID uint, toUserID uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for id, comment := range repo.comments {
+		if comment.UserID == fromUserID {
+			comment.UserID = toUserID
+			comment.Version++
+			comment.UpdatedAt = time.Now()
+			repo.comments[id] = comment
+		}
+	}
+
+	return nil
+}
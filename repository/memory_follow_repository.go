@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// followKey and timelineKey are the primary keys of follows and timeline
+// entries.
+type followKey struct{ followerID, followeeID uint }
+
+type timelineKey struct{ userID, postID uint }
+
+func NewInMemoryFollowRepository() FollowRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateFollow(ctx context.Context) error {
+	return nil
+}
+
+func (repo *InMemoryRepository) CreateFollow(ctx context.Context, followerID uint, followeeID uint) (*models.GormFollow, bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.users[followerID]; !ok {
+		return nil, false, ErrForeignKey
+	}
+	if _, ok := repo.users[followeeID]; !ok {
+		return nil, false, ErrForeignKey
+	}
+	key := followKey{followerID, followeeID}
+	if existing, ok := repo.follows[key]; ok {
+		return &existing, false, nil
+	}
+
+	follow := models.GormFollow{FollowerID: followerID, FolloweeID: followeeID, CreatedAt: time.Now()}
+	repo.follows[key] = follow
+
+	return &follow, true, nil
+}
+
+func (repo *InMemoryRepository) DeleteFollow(ctx context.Context, followerID uint, followeeID uint) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := followKey{followerID, followeeID}
+	if _, ok := repo.follows[key]; !ok {
+		return false, nil
+	}
+	delete(repo.follows, key)
+	return true, nil
+}
+
+func (repo *InMemoryRepository) Followers(ctx context.Context, userID uint, page Page) ([]models.GormUser, int64, error) {
+	return repo.followPage(page, func(follow models.GormFollow) (uint, bool) {
+		return follow.FollowerID, follow.FolloweeID == userID
+	})
+}
+
+func (repo *InMemoryRepository) Following(ctx context.Context, userID uint, page Page) ([]models.GormUser, int64, error) {
+	return repo.followPage(page, func(follow models.GormFollow) (uint, bool) {
+		return follow.FolloweeID, follow.FollowerID == userID
+	})
+}
+
+// followPage lists the live users that side picks out of the follows it
+// matches, most recently followed first.
+func (repo *InMemoryRepository) followPage(page Page, side func(models.GormFollow) (uint, bool)) ([]models.GormUser, int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	follows := []models.GormFollow{}
+	for _, follow := range repo.follows {
+		if id, ok := side(follow); ok {
+			if _, live := repo.liveUser(id); live {
+				follows = append(follows, follow)
+			}
+		}
+	}
+	sort.Slice(follows, func(i, j int) bool {
+		if !follows[i].CreatedAt.Equal(follows[j].CreatedAt) {
+			return follows[i].CreatedAt.After(follows[j].CreatedAt)
+		}
+		a, _ := side(follows[i])
+		b, _ := side(follows[j])
+		return a < b
+	})
+
+	total := int64(len(follows))
+	start, end := page.offset(), page.offset()+page.Size
+	if start > len(follows) {
+		start = len(follows)
+	}
+	if end > len(follows) {
+		end = len(follows)
+	}
+
+	users := []models.GormUser{}
+	for _, follow := range follows[start:end] {
+		id, _ := side(follow)
+		user, _ := repo.liveAuthor(id)
+		users = append(users, user)
+	}
+
+	return users, total, nil
+}
+
+func (repo *InMemoryRepository) CountFollowers(ctx context.Context, userID uint) (int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var count int64
+	for key := range repo.follows {
+		if key.followeeID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (repo *InMemoryRepository) FanOut(ctx context.Context, post models.GormPost) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.cascadeTimeline(func(entry models.GormTimelineEntry) bool {
+		return entry.PostID == post.ID && entry.AuthorID != post.UserID
+	})
+	for key := range repo.follows {
+		if key.followeeID == post.UserID {
+			repo.addTimelineEntry(key.followerID, post)
+		}
+	}
+
+	return repo.setFannedOut(post.ID, true)
+}
+
+func (repo *InMemoryRepository) SkipFanOut(ctx context.Context, postID uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return repo.setFannedOut(postID, false)
+}
+
+// Callers must hold the write lock.
+func (repo *InMemoryRepository) setFannedOut(postID uint, fannedOut bool) error {
+	if post, ok := repo.posts[postID]; ok {
+		post.FannedOut = fannedOut
+		repo.posts[postID] = post
+	}
+	return nil
+}
+
+func (repo *InMemoryRepository) Backfill(ctx context.Context, followerID uint, followeeID uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, post := range repo.posts {
+		if post.UserID == followeeID && post.IsPublished && post.FannedOut {
+			repo.addTimelineEntry(followerID, post)
+		}
+	}
+	return nil
+}
+
+// addTimelineEntry does nothing when the post is already on the timeline,
+// like ON CONFLICT DO NOTHING. Callers must hold the write lock.
+func (repo *InMemoryRepository) addTimelineEntry(userID uint, post models.GormPost) {
+	key := timelineKey{userID, post.ID}
+	if _, ok := repo.timeline[key]; ok {
+		return
+	}
+	repo.timeline[key] = models.GormTimelineEntry{UserID: userID, PostID: post.ID, AuthorID: post.UserID, PublishedAt: post.PublishedAt}
+}
+
+func (repo *InMemoryRepository) ClearTimeline(ctx context.Context, userID uint, authorID uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.cascadeTimeline(func(entry models.GormTimelineEntry) bool {
+		return entry.UserID == userID && entry.AuthorID == authorID
+	})
+	return nil
+}
+
+func (repo *InMemoryRepository) Timeline(ctx context.Context, userID uint, after *Cursor, limit int) ([]models.GormPost, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	posts := []models.GormPost{}
+	for key, entry := range repo.timeline {
+		if key.userID != userID {
+			continue
+		}
+		if post, ok := repo.livePost(entry.PostID); ok && post.IsPublished {
+			posts = append(posts, post)
+		}
+	}
+
+	return repo.feedPage(posts, after, limit), nil
+}
+
+func (repo *InMemoryRepository) PulledPosts(ctx context.Context, userID uint, after *Cursor, limit int) ([]models.GormPost, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	posts := repo.sortedPosts(func(post models.GormPost) bool {
+		_, followed := repo.follows[followKey{userID, post.UserID}]
+		return followed && post.IsPublished && !post.FannedOut
+	})
+
+	return repo.feedPage(posts, after, limit), nil
+}
+
+// feedPage sorts posts newest first and returns up to limit of them after
+// the cursor, with their authors and without their content. Callers must
+// hold the lock.
+func (repo *InMemoryRepository) feedPage(posts []models.GormPost, after *Cursor, limit int) []models.GormPost {
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].PublishedAt.Equal(posts[j].PublishedAt) {
+			return posts[i].PublishedAt.After(posts[j].PublishedAt)
+		}
+		return posts[i].ID > posts[j].ID
+	})
+
+	page := []models.GormPost{}
+	for _, post := range posts {
+		if len(page) == limit {
+			break
+		}
+		if after != nil {
+			older := post.PublishedAt.Before(after.PublishedAt) ||
+				post.PublishedAt.Equal(after.PublishedAt) && post.ID < after.PostID
+			if !older {
+				continue
+			}
+		}
+		post.Content = ""
+		page = append(page, repo.withUser(post))
+	}
+	return page
+}
+
+// cascadeFollows and cascadeTimeline remove the matching rows, the way ON
+// DELETE CASCADE does when a user or post is purged. Callers must hold the
+// write lock.
+func (repo *InMemoryRepository) cascadeFollows(match func(models.GormFollow) bool) {
+	for key, follow := range repo.follows {
+		if match(follow) {
+			delete(repo.follows, key)
+		}
+	}
+}
+
+func (repo *InMemoryRepository) cascadeTimeline(match func(models.GormTimelineEntry) bool) {
+	for key, entry := range repo.timeline {
+		if match(entry) {
+			delete(repo.timeline, key)
+		}
+	}
+}
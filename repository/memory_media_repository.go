@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+func NewInMemoryMediaRepository() MediaRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateMedia(ctx context.Context) error {
+	return nil
+}
+
+func (repo *InMemoryRepository) CreateMedia(ctx context.Context, media models.GormPostMedia) (*models.GormPostMedia, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.posts[media.PostID]; !ok {
+		return nil, ErrForeignKey
+	}
+	for _, existing := range repo.media {
+		if existing.SourceKey == media.SourceKey && existing.Width == media.Width {
+			return nil, ErrDuplicate
+		}
+	}
+
+	media.ID = repo.nextID("post_media")
+	if media.CreatedAt.IsZero() {
+		media.CreatedAt = time.Now()
+	}
+	media.Post = nil
+	repo.media[media.ID] = media
+
+	return &media, nil
+}
+
+func (repo *InMemoryRepository) MediaByPostIDs(ctx context.Context, postIDs []uint) ([]models.GormPostMedia, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	wanted := make(map[uint]bool, len(postIDs))
+	for _, id := range postIDs {
+		wanted[id] = true
+	}
+
+	media := []models.GormPostMedia{}
+	for _, variant := range repo.media {
+		if wanted[variant.PostID] {
+			media = append(media, variant)
+		}
+	}
+	sortMedia(media)
+
+	return media, nil
+}
+
+func (repo *InMemoryRepository) DeleteMediaBySourceKey(ctx context.Context, sourceKey string) ([]models.GormPostMedia, error) {
+	return repo.deleteMedia(func(media models.GormPostMedia) bool { return media.SourceKey == sourceKey }), nil
+}
+
+func (repo *InMemoryRepository) DeleteMediaByPostID(ctx context.Context, postID uint) ([]models.GormPostMedia, error) {
+	return repo.deleteMedia(func(media models.GormPostMedia) bool { return media.PostID == postID }), nil
+}
+
+func (repo *InMemoryRepository) deleteMedia(match func(models.GormPostMedia) bool) []models.GormPostMedia {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	deleted := []models.GormPostMedia{}
+	for id, media := range repo.media {
+		if match(media) {
+			deleted = append(deleted, media)
+			delete(repo.media, id)
+		}
+	}
+	sortMedia(deleted)
+
+	return deleted
+}
+
+func sortMedia(media []models.GormPostMedia) {
+	sort.Slice(media, func(i, j int) bool {
+		if media[i].PostID != media[j].PostID {
+			return media[i].PostID < media[j].PostID
+		}
+		return media[i].Width < media[j].Width
+	})
+}
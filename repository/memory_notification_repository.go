@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// preferenceKey is the primary key of notification preferences.
+type preferenceKey struct {
+	userID           uint
+	notificationType string
+}
+
+func NewInMemoryNotificationRepository() NotificationRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateNotification(ctx context.Context) error {
+	return nil
+}
+
+func (repo *InMemoryRepository) CreateNotifications(ctx context.Context, notifications []models.GormNotification) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, notification := range notifications {
+		if _, ok := repo.users[notification.UserID]; !ok {
+			return ErrForeignKey
+		}
+		if _, ok := repo.users[notification.ActorID]; !ok {
+			return ErrForeignKey
+		}
+		if notification.PostID != nil {
+			if _, ok := repo.posts[*notification.PostID]; !ok {
+				return ErrForeignKey
+			}
+		}
+		if notification.CommentID != nil {
+			if _, ok := repo.comments[*notification.CommentID]; !ok {
+				return ErrForeignKey
+			}
+		}
+	}
+
+	for _, notification := range notifications {
+		notification.ID = repo.nextID("notifications")
+		if notification.CreatedAt.IsZero() {
+			notification.CreatedAt = time.Now()
+		}
+		notification.User, notification.Actor, notification.Post, notification.Comment = nil, nil, nil, nil
+		repo.notifications[notification.ID] = notification
+	}
+	return nil
+}
+
+func (repo *InMemoryRepository) Notifications(ctx context.Context, userID uint, unread bool, page Page) ([]models.GormNotification, int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	notifications := []models.GormNotification{}
+	for _, notification := range repo.notifications {
+		if notification.UserID == userID && (!unread || notification.ReadAt == nil) {
+			notifications = append(notifications, notification)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID > notifications[j].ID })
+
+	total := int64(len(notifications))
+	start, end := page.offset(), page.offset()+page.Size
+	if start > len(notifications) {
+		start = len(notifications)
+	}
+	if end > len(notifications) {
+		end = len(notifications)
+	}
+
+	return notifications[start:end], total, nil
+}
+
+func (repo *InMemoryRepository) UnreadCount(ctx context.Context, userID uint) (int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var unread int64
+	for _, notification := range repo.notifications {
+		if notification.UserID == userID && notification.ReadAt == nil {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (repo *InMemoryRepository) MarkRead(ctx context.Context, userID uint, ids []uint) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	asked := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		asked[id] = true
+	}
+
+	now := time.Now()
+	var marked int64
+	for id, notification := range repo.notifications {
+		if notification.UserID != userID || notification.ReadAt != nil || (ids != nil && !asked[id]) {
+			continue
+		}
+		readAt := now
+		notification.ReadAt = &readAt
+		repo.notifications[id] = notification
+		marked++
+	}
+	return marked, nil
+}
+
+func (repo *InMemoryRepository) NotificationPreferences(ctx context.Context, userID uint) (map[string]bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	preferences := map[string]bool{}
+	for key, preference := range repo.preferences {
+		if key.userID == userID {
+			preferences[key.notificationType] = preference.Enabled
+		}
+	}
+	return preferences, nil
+}
+
+func (repo *InMemoryRepository) SetNotificationPreferences(ctx context.Context, userID uint, preferences map[string]bool) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if len(preferences) == 0 {
+		return nil
+	}
+	if _, ok := repo.users[userID]; !ok {
+		return ErrForeignKey
+	}
+
+	for notificationType, enabled := range preferences {
+		repo.preferences[preferenceKey{userID, notificationType}] = models.GormNotificationPreference{
+			UserID:  userID,
+			Type:    notificationType,
+			Enabled: enabled,
+		}
+	}
+	return nil
+}
+
+func (repo *InMemoryRepository) MutedUserIDs(ctx context.Context, notificationType string, userIDs []uint) (map[uint]bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	muted := make(map[uint]bool, len(userIDs))
+	for _, id := range userIDs {
+		if preference, ok := repo.preferences[preferenceKey{id, notificationType}]; ok && !preference.Enabled {
+			muted[id] = true
+		}
+	}
+	return muted, nil
+}
+
+// cascadeNotifications removes the matching notifications, the way ON
+// DELETE CASCADE does when their user, actor, post or comment is purged.
+// Callers must hold the lock.
+func (repo *InMemoryRepository) cascadeNotifications(match func(models.GormNotification) bool) {
+	for id, notification := range repo.notifications {
+		if match(notification) {
+			delete(repo.notifications, id)
+		}
+	}
+}
+
+// cascadePreferences removes the preferences of a purged user.
+// Callers must hold the lock.
+func (repo *InMemoryRepository) cascadePreferences(userID uint) {
+	for key := range repo.preferences {
+		if key.userID == userID {
+			delete(repo.preferences, key)
+		}
+	}
+}
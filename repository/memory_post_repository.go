@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+)
+
+func NewInMemoryPostRepository() PostRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigratePost(ctx context.Context) error {
+	return nil
+}
+
+// withUser attaches the author the way Preload("User") does.
+// Callers must hold the lock.
+func (repo *InMemoryRepository) withUser(post models.GormPost) models.GormPost {
+	post.User = nil
+	if user, ok := repo.liveAuthor(post.UserID); ok {
+		post.User = &user
+	}
+	return post
+}
+
+// postConflicts reports whether another live post has the title, as
+// idx_posts_title would. Callers must hold the lock.
+func (repo *InMemoryRepository) postConflicts(post models.GormPost) bool {
+	for id, existing := range repo.posts {
+		if id == post.ID || existing.DeletedAt.Valid {
+			continue
+		}
+		if existing.Title == post.Title {
+			return true
+		}
+	}
+	return false
+}
+
+// Callers must hold the lock.
+func (repo *InMemoryRepository) sortedPosts(match func(models.GormPost) bool) []models.GormPost {
+	posts := []models.GormPost{}
+	for id := range repo.posts {
+		if post, ok := repo.livePost(id); ok && match(post) {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+
+	return posts
+}
+
+func (repo *InMemoryRepository) CreatePost(ctx context.Context, post models.GormPost) (*models.GormPost, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	post.ID = 0
+	if repo.postConflicts(post) {
+		return nil, ErrDuplicate
+	}
+
+	post.ID = repo.nextID("posts")
+	post.Version = 1
+	stampCreate(&post.CreatedAt, &post.UpdatedAt)
+	// the column defaults
+	if post.ContentFormat == "" {
+		post.ContentFormat = "markdown"
+	}
+	if post.Tags == nil {
+		post.Tags = models.Tags{}
+	}
+	post.ReactionCounts = models.ReactionCounts{}
+	post.User = nil
+	post.Comments = nil
+	repo.posts[post.ID] = post
+
+	return &post, nil
+}
+
+func (repo *InMemoryRepository) AllPosts(ctx context.Context, query Query) ([]models.GormPost, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	allPosts := repo.sortedPosts(func(models.GormPost) bool { return true })
+	for i := range allPosts {
+		allPosts[i] = repo.queryPost(allPosts[i], query, "user")
+	}
+
+	return allPosts, nil
+}
+
+func (repo *InMemoryRepository) GetPostByID(ctx context.Context, id uint) (*models.GormPost, error) {
+	return repo.FindPost(ctx, id, Query{})
+}
+
+func (repo *InMemoryRepository) FindPost(ctx context.Context, id uint, query Query) (*models.GormPost, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	post, ok := repo.livePost(id)
+	if !ok {
+		return nil, ErrNotExist
+	}
+	post = repo.queryPost(post, query, "user")
+
+	return &post, nil
+}
+
+func (repo *InMemoryRepository) GetPostByTitle(ctx context.Context, title string) (*models.GormPost, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	posts := repo.sortedPosts(func(post models.GormPost) bool { return post.Title == title })
+	if len(posts) == 0 {
+		return nil, ErrNotExist
+	}
+
+	return &posts[0], nil
+}
+
+func (repo *InMemoryRepository) GetPostByUserID(ctx context.Context, userid uint) ([]models.GormPost, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.sortedPosts(func(post models.GormPost) bool { return post.UserID == userid }), nil
+}
+
+func (repo *InMemoryRepository) UpdatePost(ctx context.Context, id uint, updated models.GormPost) (*models.GormPost, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	existing, ok := repo.livePost(id)
+	if !ok {
+		return nil, ErrUpdateFailed
+	}
+	if existing.Version != updated.Version {
+		return nil, ErrVersionConflict
+	}
+
+	updated.ID = id
+	if repo.postConflicts(updated) {
+		return nil, ErrDuplicate
+	}
+
+	updated.Version++
+	updated.CreatedAt = existing.CreatedAt
+	updated.ReactionCounts = existing.ReactionCounts
+	updated.FannedOut = existing.FannedOut
+	updated.UpdatedAt = time.Now()
+	updated.DeletedAt = gorm.DeletedAt{}
+	updated.User = nil
+	updated.Comments = nil
+	repo.posts[id] = updated
+	updated = repo.withUser(updated)
+
+	return &updated, nil
+}
+
+func (repo *InMemoryRepository) PatchPost(ctx context.Context, id uint, version uint, columns map[string]interface{}) (*models.GormPost, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	patched, ok := repo.livePost(id)
+	if !ok {
+		return nil, ErrUpdateFailed
+	}
+	if patched.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	if err := setColumns(&patched, columns); err != nil {
+		return nil, err
+	}
+	if repo.postConflicts(patched) {
+		return nil, ErrDuplicate
+	}
+	patched.Version++
+	patched.UpdatedAt = time.Now()
+	repo.posts[id] = patched
+
+	patched = repo.withUser(patched)
+
+	return &patched, nil
+}
+
+func (repo *InMemoryRepository) DeletePost(ctx context.Context, id uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	post, ok := repo.livePost(id)
+	if !ok {
+		return ErrDeleteFailed
+	}
+
+	post.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	repo.posts[id] = post
+
+	return nil
+}
+
+func (repo *InMemoryRepository) DeletedPosts(ctx context.Context, page Page) ([]models.GormPost, int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	deleted := []models.GormPost{}
+	for _, post := range repo.posts {
+		if post.DeletedAt.Valid {
+			deleted = append(deleted, post)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool {
+		if !deleted[i].DeletedAt.Time.Equal(deleted[j].DeletedAt.Time) {
+			return deleted[i].DeletedAt.Time.After(deleted[j].DeletedAt.Time)
+		}
+		return deleted[i].ID > deleted[j].ID
+	})
+
+	return pageOf(deleted, page), int64(len(deleted)), nil
+}
+
+func (repo *InMemoryRepository) RestorePost(ctx context.Context, id uint) (*models.GormPost, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	restored, ok := repo.posts[id]
+	if !ok || !restored.DeletedAt.Valid {
+		return nil, ErrNotExist
+	}
+	if repo.postConflicts(restored) {
+		return nil, ErrDuplicate
+	}
+
+	restored.DeletedAt = gorm.DeletedAt{}
+	restored.Version++
+	restored.UpdatedAt = time.Now()
+	repo.posts[id] = restored
+
+	return &restored, nil
+}
+
+func (repo *InMemoryRepository) PurgePost(ctx context.Context, id uint) (*models.GormPost, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	post, ok := repo.posts[id]
+	if !ok || !post.DeletedAt.Valid {
+		return nil, ErrNotExist
+	}
+
+	delete(repo.posts, id)
+	repo.cascadeReactions(func(reaction models.GormReaction) bool {
+		return reaction.PostID != nil && *reaction.PostID == id
+	})
+	repo.cascadeBookmarks(func(bookmark models.GormBookmark) bool { return bookmark.PostID == id })
+	repo.cascadeTimeline(func(entry models.GormTimelineEntry) bool { return entry.PostID == id })
+	repo.cascadeViews(id)
+	repo.cascadeNotifications(func(notification models.GormNotification) bool {
+		return notification.PostID != nil && *notification.PostID == id
+	})
+	return &post, nil
+}
+
+func (repo *InMemoryRepository) ExpiredPostIDs(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	ids := []uint{}
+	for id, post := range repo.posts {
+		if post.DeletedAt.Valid && post.DeletedAt.Time.Before(before) && id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	return firstIDs(ids, limit), nil
+}
+
+func (repo *InMemoryRepository) PostIDsByUserID(ctx context.Context, userID uint, withDeleted bool) ([]uint, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	ids := []uint{}
+	for id, post := range repo.posts {
+		if post.UserID == userID && (withDeleted || !post.DeletedAt.Valid) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids, nil
+}
+
+func (repo *InMemoryRepository) ReassignPosts(ctx context.Context, fromUserID uint, toUserID uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for id, post := range repo.posts {
+		if post.UserID == fromUserID {
+			post.UserID = toUserID
+			post.Version++
+			post.UpdatedAt = time.Now()
+			repo.posts[id] = post
+		}
+	}
+
+	return nil
+}
+
+func (repo *InMemoryRepository) PublishedPosts(ctx context.Context, userID uint, tag string, limit int) ([]models.GormPost, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	posts := repo.sortedPosts(func(post models.GormPost) bool {
+		return post.IsPublished &&
+			(userID == 0 || post.UserID == userID) &&
+			(tag == "" || post.Tags.Has(tag))
+	})
+	sort.SliceStable(posts, func(i, j int) bool {
+		if !posts[i].PublishedAt.Equal(posts[j].PublishedAt) {
+			return posts[i].PublishedAt.After(posts[j].PublishedAt)
+		}
+		return posts[i].ID > posts[j].ID
+	})
+
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	for i := range posts {
+		posts[i] = repo.withUser(posts[i])
+	}
+
+	return posts, nil
+}
+
+func (repo *InMemoryRepository) SitemapParts(ctx context.Context, size int) ([]SitemapPart, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	parts := []SitemapPart{}
+	for i, post := range repo.sortedPosts(func(post models.GormPost) bool { return post.IsPublished }) {
+		if i%size == 0 {
+			parts = append(parts, SitemapPart{Number: len(parts) + 1})
+		}
+		part := &parts[len(parts)-1]
+		part.URLs++
+		if post.UpdatedAt.After(part.LastModified) {
+			part.LastModified = post.UpdatedAt
+		}
+	}
+
+	return parts, nil
+}
+
+func (repo *InMemoryRepository) ScanPublishedPosts(ctx context.Context, offset int, limit int, fn func(models.GormPost) error) error {
+	// a copy taken under the lock stands in for the cursor, so fn may
+	// call back into the repository
+	repo.mu.RLock()
+	posts := repo.sortedPosts(func(post models.GormPost) bool { return post.IsPublished })
+	repo.mu.RUnlock()
+
+	if offset > len(posts) {
+		offset = len(posts)
+	}
+	posts = posts[offset:]
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	for _, post := range posts {
+		if err := fn(models.GormPost{Model: gorm.Model{ID: post.ID, UpdatedAt: post.UpdatedAt}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"reflect"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm/schema"
+)
+
+// narrow zeroes the fields of row whose columns query does not read from
+// table, the way Select leaves them unset.
+func narrow[T any](row T, query Query, table string) T {
+	columns, ok := query.Fields[table]
+	if !ok {
+		return row
+	}
+
+	var narrowed T
+	from := reflect.ValueOf(&row).Elem()
+	to := reflect.ValueOf(&narrowed).Elem()
+	naming := schema.NamingStrategy{}
+	for _, column := range columns {
+		field, ok := from.Type().FieldByNameFunc(func(name string) bool {
+			return naming.ColumnName("", name) == column
+		})
+		if ok {
+			to.FieldByIndex(field.Index).Set(from.FieldByIndex(field.Index))
+		}
+	}
+	return narrowed
+}
+
+// queryUser applies query to a user the way UserRepo does with Select and
+// Preload. Callers must hold the lock.
+func (repo *InMemoryRepository) queryUser(user models.GormUser, query Query, defaults ...string) models.GormUser {
+	user = narrow(user, query, "users")
+	user.Posts = nil
+	user.Comments = nil
+
+	for _, name := range query.include(defaults...) {
+		switch name {
+		case "posts":
+			user.Posts = []*models.GormPost{}
+			for _, post := range repo.sortedPosts(func(post models.GormPost) bool { return post.UserID == user.ID }) {
+				narrowed := narrow(post, query, "posts")
+				user.Posts = append(user.Posts, &narrowed)
+			}
+		case "comments":
+			user.Comments = []*models.GormComment{}
+			for _, comment := range repo.sortedComments(func(comment models.GormComment) bool { return comment.UserID == user.ID }) {
+				narrowed := narrow(comment, query, "comments")
+				user.Comments = append(user.Comments, &narrowed)
+			}
+		}
+	}
+
+	return user
+}
+
+// queryPost applies query to a post the way PostRepo does with Select and
+// Preload. Callers must hold the lock.
+func (repo *InMemoryRepository) queryPost(post models.GormPost, query Query, defaults ...string) models.GormPost {
+	post = narrow(post, query, "posts")
+	post.User = nil
+	post.Comments = nil
+
+	for _, name := range query.include(defaults...) {
+		switch name {
+		case "user":
+			if user, ok := repo.liveAuthor(post.UserID); ok {
+				user = narrow(user, query, "users")
+				post.User = &user
+			}
+		case "comments":
+			post.Comments = []*models.GormComment{}
+			for _, comment := range repo.sortedComments(func(comment models.GormComment) bool { return comment.PostID == post.ID }) {
+				narrowed := narrow(comment, query, "comments")
+				post.Comments = append(post.Comments, &narrowed)
+			}
+		}
+	}
+
+	return post
+}
+
+// queryComment applies query to a comment the way CommentRepo does with
+// Select and Preload. Callers must hold the lock.
+func (repo *InMemoryRepository) queryComment(comment models.GormComment, query Query, defaults ...string) models.GormComment {
+	comment = narrow(comment, query, "comments")
+	comment.User = nil
+	comment.Post = nil
+
+	for _, name := range query.include(defaults...) {
+		switch name {
+		case "user":
+			if user, ok := repo.liveAuthor(comment.UserID); ok {
+				user = narrow(user, query, "users")
+				comment.User = &user
+			}
+		case "post":
+			if post, ok := repo.livePost(comment.PostID); ok {
+				post = narrow(post, query, "posts")
+				comment.Post = &post
+			}
+		}
+	}
+
+	return comment
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+func NewInMemoryReactionRepository() ReactionRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateReaction(ctx context.Context) error {
+	return nil
+}
+
+// sameReaction reports whether a and b are the same user's reaction of the
+// same type to the same post or comment.
+func sameReaction(a, b models.GormReaction) bool {
+	return a.UserID == b.UserID && a.Type == b.Type && sameTarget(a.PostID, b.PostID) && sameTarget(a.CommentID, b.CommentID)
+}
+
+func sameTarget(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (repo *InMemoryRepository) CreateReaction(ctx context.Context, reaction models.GormReaction) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.users[reaction.UserID]; !ok {
+		return false, ErrForeignKey
+	}
+	if reaction.PostID != nil {
+		if _, ok := repo.posts[*reaction.PostID]; !ok {
+			return false, ErrForeignKey
+		}
+	}
+	if reaction.CommentID != nil {
+		if _, ok := repo.comments[*reaction.CommentID]; !ok {
+			return false, ErrForeignKey
+		}
+	}
+	for _, existing := range repo.reactions {
+		if sameReaction(existing, reaction) {
+			return false, nil
+		}
+	}
+
+	reaction.ID = repo.nextID("reactions")
+	if reaction.CreatedAt.IsZero() {
+		reaction.CreatedAt = time.Now()
+	}
+	reaction.User, reaction.Post, reaction.Comment = nil, nil, nil
+	repo.reactions[reaction.ID] = reaction
+
+	return true, nil
+}
+
+func (repo *InMemoryRepository) DeleteReaction(ctx context.Context, reaction models.GormReaction) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for id, existing := range repo.reactions {
+		if sameReaction(existing, reaction) {
+			delete(repo.reactions, id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (repo *InMemoryRepository) DeleteReactionsByUserID(ctx context.Context, userID uint) ([]models.GormReaction, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return repo.cascadeReactions(func(reaction models.GormReaction) bool { return reaction.UserID == userID }), nil
+}
+
+// cascadeReactions removes the matching reactions, the way ON DELETE CASCADE
+// does when their post or comment is purged. Callers must hold the lock.
+func (repo *InMemoryRepository) cascadeReactions(match func(models.GormReaction) bool) []models.GormReaction {
+	deleted := []models.GormReaction{}
+	for id, reaction := range repo.reactions {
+		if match(reaction) {
+			deleted = append(deleted, reaction)
+			delete(repo.reactions, id)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].ID < deleted[j].ID })
+
+	return deleted
+}
+
+func (repo *InMemoryRepository) AdjustReactionCount(ctx context.Context, reaction models.GormReaction, delta int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if reaction.PostID != nil {
+		if post, ok := repo.posts[*reaction.PostID]; ok {
+			post.ReactionCounts = adjustCounts(post.ReactionCounts, reaction.Type, delta)
+			repo.posts[post.ID] = post
+		}
+		return nil
+	}
+	if comment, ok := repo.comments[*reaction.CommentID]; ok {
+		comment.ReactionCounts = adjustCounts(comment.ReactionCounts, reaction.Type, delta)
+		repo.comments[comment.ID] = comment
+	}
+	return nil
+}
+
+// adjustCounts returns a copy of counts with delta added to reactionType,
+// leaving counts itself alone for the snapshot WithTx may restore.
+func adjustCounts(counts models.ReactionCounts, reactionType string, delta int) models.ReactionCounts {
+	adjusted := models.ReactionCounts{}
+	for name, count := range counts {
+		adjusted[name] = count
+	}
+	if count := adjusted[reactionType] + delta; count > 0 {
+		adjusted[reactionType] = count
+	} else {
+		delete(adjusted, reactionType)
+	}
+	return adjusted
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm/schema"
+)
+
+// InMemoryRepository keeps users, posts, comments, post media, reactions,
+// bookmarks, follows, timelines, post views, notifications, password
+// resets, webhooks and comment events in maps guarded by a single lock. It
+// mirrors the GORM repositories closely enough to stand in for them in
+// tests: rows are soft deleted, unique columns are only enforced among live
+// rows, and the same sentinel errors are returned.
+type InMemoryRepository struct {
+	txMu          sync.Mutex
+	mu            sync.RWMutex
+	users         map[uint]models.GormUser
+	posts         map[uint]models.GormPost
+	comments      map[uint]models.GormComment
+	media         map[uint]models.GormPostMedia
+	reactions     map[uint]models.GormReaction
+	bookmarks     map[uint]models.GormBookmark
+	follows       map[followKey]models.GormFollow
+	timeline      map[timelineKey]models.GormTimelineEntry
+	views         map[viewKey]models.GormPostView
+	notifications map[uint]models.GormNotification
+	preferences   map[preferenceKey]models.GormNotificationPreference
+	resets        map[string]models.GormPasswordReset
+	webhooks      map[uint]models.GormWebhook
+	webhookEvents map[uint]models.GormWebhookEvent
+	deliveries    map[uint]models.GormWebhookDelivery
+	commentEvents map[uint]models.GormCommentEvent
+	lastID        map[string]uint
+
+	// listeners stand in for LISTEN sessions, under their own lock so they
+	// may read the tables when called.
+	listenMu     sync.Mutex
+	listeners    map[uint]func(postID uint)
+	lastListener uint
+}
+
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{
+		users:         make(map[uint]models.GormUser),
+		posts:         make(map[uint]models.GormPost),
+		comments:      make(map[uint]models.GormComment),
+		media:         make(map[uint]models.GormPostMedia),
+		reactions:     make(map[uint]models.GormReaction),
+		bookmarks:     make(map[uint]models.GormBookmark),
+		follows:       make(map[followKey]models.GormFollow),
+		timeline:      make(map[timelineKey]models.GormTimelineEntry),
+		views:         make(map[viewKey]models.GormPostView),
+		notifications: make(map[uint]models.GormNotification),
+		preferences:   make(map[preferenceKey]models.GormNotificationPreference),
+		resets:        make(map[string]models.GormPasswordReset),
+		webhooks:      make(map[uint]models.GormWebhook),
+		webhookEvents: make(map[uint]models.GormWebhookEvent),
+		deliveries:    make(map[uint]models.GormWebhookDelivery),
+		commentEvents: make(map[uint]models.GormCommentEvent),
+		lastID:        make(map[string]uint),
+		listeners:     make(map[uint]func(postID uint)),
+	}
+}
+
+type memoryTxKey struct{}
+
+// WithTx serialises transactions against each other and restores a snapshot
+// of every table when fn fails. Like a serial column, handed out ids are
+// not given back on rollback. Writes made outside a transaction while one
+// is running are lost if it rolls back, which is fine for tests.
+// AfterCommit hooks run once the lock is given up again.
+func (repo *InMemoryRepository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memoryTxKey{}) == repo {
+		return fn(ctx)
+	}
+
+	return withAfterCommit(ctx, func(ctx context.Context) error {
+		return repo.serialTx(ctx, fn)
+	})
+}
+
+func (repo *InMemoryRepository) serialTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	repo.txMu.Lock()
+	defer repo.txMu.Unlock()
+
+	repo.mu.RLock()
+	users, posts, comments, media := cloneMap(repo.users), cloneMap(repo.posts), cloneMap(repo.comments), cloneMap(repo.media)
+	reactions, bookmarks, follows, timeline := cloneMap(repo.reactions), cloneMap(repo.bookmarks), cloneMap(repo.follows), cloneMap(repo.timeline)
+	views, notifications, preferences, resets := cloneMap(repo.views), cloneMap(repo.notifications), cloneMap(repo.preferences), cloneMap(repo.resets)
+	webhooks, webhookEvents, deliveries, commentEvents := cloneMap(repo.webhooks), cloneMap(repo.webhookEvents), cloneMap(repo.deliveries), cloneMap(repo.commentEvents)
+	repo.mu.RUnlock()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, repo)); err != nil {
+		repo.mu.Lock()
+		repo.users, repo.posts, repo.comments, repo.media = users, posts, comments, media
+		repo.reactions, repo.bookmarks, repo.follows, repo.timeline = reactions, bookmarks, follows, timeline
+		repo.views, repo.notifications, repo.preferences, repo.resets = views, notifications, preferences, resets
+		repo.webhooks, repo.webhookEvents, repo.deliveries, repo.commentEvents = webhooks, webhookEvents, deliveries, commentEvents
+		repo.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	clone := make(map[K]V, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
+
+// pageOf cuts one page out of rows.
+func pageOf[T any](rows []T, page Page) []T {
+	start, end := page.offset(), page.offset()+page.Size
+	if start > len(rows) {
+		start = len(rows)
+	}
+	if end > len(rows) {
+		end = len(rows)
+	}
+	return rows[start:end]
+}
+
+// firstIDs sorts ids and keeps the first limit of them.
+func firstIDs(ids []uint, limit int) []uint {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
+
+// nextID hands out ids per table the way a serial column does.
+// Callers must hold the write lock.
+func (repo *InMemoryRepository) nextID(table string) uint {
+	repo.lastID[table]++
+	return repo.lastID[table]
+}
+
+// Callers must hold the lock.
+func (repo *InMemoryRepository) liveUser(id uint) (models.GormUser, bool) {
+	user, ok := repo.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return models.GormUser{}, false
+	}
+	return user, true
+}
+
+// liveAuthor is liveUser read for someone else to see, without the password
+// the way omitPassword leaves it out. Callers must hold the lock.
+func (repo *InMemoryRepository) liveAuthor(id uint) (models.GormUser, bool) {
+	user, ok := repo.liveUser(id)
+	user.Password = ""
+	return user, ok
+}
+
+// Callers must hold the lock.
+func (repo *InMemoryRepository) livePost(id uint) (models.GormPost, bool) {
+	post, ok := repo.posts[id]
+	if !ok || post.DeletedAt.Valid {
+		return models.GormPost{}, false
+	}
+	return post, true
+}
+
+// Callers must hold the lock.
+func (repo *InMemoryRepository) liveComment(id uint) (models.GormComment, bool) {
+	comment, ok := repo.comments[id]
+	if !ok || comment.DeletedAt.Valid {
+		return models.GormComment{}, false
+	}
+	return comment, true
+}
+
+// stampCreate fills the gorm.Model timestamps the way GORM does on insert.
+func stampCreate(createdAt, updatedAt *time.Time) {
+	now := time.Now()
+	if createdAt.IsZero() {
+		*createdAt = now
+	}
+	if updatedAt.IsZero() {
+		*updatedAt = now
+	}
+}
+
+// setColumns assigns column values onto the matching fields of the struct
+// dest points to, naming columns the way GORM does.
+func setColumns(dest interface{}, columns map[string]interface{}) error {
+	value := reflect.ValueOf(dest).Elem()
+	naming := schema.NamingStrategy{}
+
+	for column, columnValue := range columns {
+		field, ok := value.Type().FieldByNameFunc(func(name string) bool {
+			return naming.ColumnName("", name) == column
+		})
+		if !ok {
+			return fmt.Errorf("column %q does not exist", column)
+		}
+		value.FieldByIndex(field.Index).Set(reflect.ValueOf(columnValue).Convert(field.Type))
+	}
+
+	return nil
+}
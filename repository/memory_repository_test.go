@@ -0,0 +1,71 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository/repositorytest"
+)
+
+// newInMemoryRepositories backs every repository with one store, as the
+// GORM repositories share one database.
+func newInMemoryRepositories() repositorytest.Repositories {
+	repo := repository.NewInMemoryRepository()
+	return repositorytest.Repositories{
+		Users:         repo,
+		Posts:         repo,
+		Comments:      repo,
+		Media:         repo,
+		Reactions:     repo,
+		Bookmarks:     repo,
+		Follows:       repo,
+		Views:         repo,
+		Notifications: repo,
+		Webhooks:      repo,
+		CommentEvents: repo,
+	}
+}
+
+func TestInMemoryUserRepository(t *testing.T) {
+	repositorytest.TestUserRepository(t, repository.NewInMemoryUserRepository)
+}
+
+func TestInMemoryPostRepository(t *testing.T) {
+	repositorytest.TestPostRepository(t, newInMemoryRepositories)
+}
+
+func TestInMemoryCommentRepository(t *testing.T) {
+	repositorytest.TestCommentRepository(t, newInMemoryRepositories)
+}
+
+func TestInMemoryMediaRepository(t *testing.T) {
+	repositorytest.TestMediaRepository(t, newInMemoryRepositories)
+}
+
+func TestInMemoryReactionRepository(t *testing.T) {
+	repositorytest.TestReactionRepository(t, newInMemoryRepositories)
+}
+
+func TestInMemoryBookmarkRepository(t *testing.T) {
+	repositorytest.TestBookmarkRepository(t, newInMemoryRepositories)
+}
+
+func TestInMemoryFollowRepository(t *testing.T) {
+	repositorytest.TestFollowRepository(t, newInMemoryRepositories)
+}
+
+func TestInMemoryViewRepository(t *testing.T) {
+	repositorytest.TestViewRepository(t, newInMemoryRepositories)
+}
+
+func TestInMemoryNotificationRepository(t *testing.T) {
+	repositorytest.TestNotificationRepository(t, newInMemoryRepositories)
+}
+
+func TestInMemoryWebhookRepository(t *testing.T) {
+	repositorytest.TestWebhookRepository(t, newInMemoryRepositories)
+}
+
+func TestInMemoryCommentEventRepository(t *testing.T) {
+	repositorytest.TestCommentEventRepository(t, newInMemoryRepositories)
+}
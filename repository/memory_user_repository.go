@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+)
+
+func NewInMemoryUserRepository() UserRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateUser(ctx context.Context) error {
+	return nil
+}
+
+// Callers must hold the lock.
+func (repo *InMemoryRepository) userConflicts(user models.GormUser) bool {
+	for id, existing := range repo.users {
+		if id == user.ID || existing.DeletedAt.Valid {
+			continue
+		}
+		if existing.Email == user.Email || existing.Username == user.Username {
+			return true
+		}
+	}
+	return false
+}
+
+func (repo *InMemoryRepository) CreateUser(ctx context.Context, user models.GormUser) (*models.GormUser, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	user.ID = 0
+	if repo.userConflicts(user) {
+		return nil, ErrDuplicate
+	}
+
+	user.ID = repo.nextID("users")
+	user.Version = 1
+	stampCreate(&user.CreatedAt, &user.UpdatedAt)
+	user.Posts = nil
+	user.Comments = nil
+	repo.users[user.ID] = user
+
+	return &user, nil
+}
+
+func (repo *InMemoryRepository) AllUsers(ctx context.Context, query Query) ([]models.GormUser, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	allUsers := []models.GormUser{}
+	for id := range repo.users {
+		if user, ok := repo.liveUser(id); ok {
+			allUsers = append(allUsers, user)
+		}
+	}
+	sort.Slice(allUsers, func(i, j int) bool { return allUsers[i].ID < allUsers[j].ID })
+	for i := range allUsers {
+		allUsers[i] = repo.queryUser(allUsers[i], query)
+	}
+
+	return allUsers, nil
+}
+
+func (repo *InMemoryRepository) GetUserByID(ctx context.Context, id uint) (*models.GormUser, error) {
+	return repo.FindUser(ctx, id, Query{})
+}
+
+func (repo *InMemoryRepository) FindUser(ctx context.Context, id uint, query Query) (*models.GormUser, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user, ok := repo.liveUser(id)
+	if !ok {
+		return nil, ErrNotExist
+	}
+	user = repo.queryUser(user, query)
+
+	return &user, nil
+}
+
+func (repo *InMemoryRepository) GetUserByEmail(ctx context.Context, email string) (*models.GormUser, error) {
+	return repo.findUser(func(user models.GormUser) bool {
+		return user.Email == email
+	})
+}
+
+func (repo *InMemoryRepository) GetUserByUsernameAndPassword(ctx context.Context, username, password string) (*models.GormUser, error) {
+	return repo.findUser(func(user models.GormUser) bool {
+		return user.Username == username && user.Password == password
+	})
+}
+
+// findUser returns the live user with the lowest id matching the predicate,
+// like First does.
+func (repo *InMemoryRepository) findUser(match func(models.GormUser) bool) (*models.GormUser, error) {
+	users, _ := repo.AllUsers(context.Background(), Query{})
+	for _, user := range users {
+		if match(user) {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotExist
+}
+
+func (repo *InMemoryRepository) UpdateUser(ctx context.Context, id uint, updated models.GormUser) (*models.GormUser, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	existing, ok := repo.liveUser(id)
+	if !ok {
+		return nil, ErrUpdateFailed
+	}
+	if existing.Version != updated.Version {
+		return nil, ErrVersionConflict
+	}
+
+	updated.ID = id
+	if repo.userConflicts(updated) {
+		return nil, ErrDuplicate
+	}
+
+	updated.Version++
+	updated.CreatedAt = existing.CreatedAt
+	updated.UpdatedAt = time.Now()
+	updated.DeletedAt = gorm.DeletedAt{}
+	updated.Posts = nil
+	updated.Comments = nil
+	repo.users[id] = updated
+
+	return &updated, nil
+}
+
+func (repo *InMemoryRepository) PatchUser(ctx context.Context, id uint, version uint, columns map[string]interface{}) (*models.GormUser, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	patched, ok := repo.liveUser(id)
+	if !ok {
+		return nil, ErrUpdateFailed
+	}
+	if patched.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	if err := setColumns(&patched, columns); err != nil {
+		return nil, err
+	}
+	if repo.userConflicts(patched) {
+		return nil, ErrDuplicate
+	}
+	patched.Version++
+	patched.UpdatedAt = time.Now()
+	repo.users[id] = patched
+
+	return &patched, nil
+}
+
+func (repo *InMemoryRepository) DeleteUser(ctx context.Context, id uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	user, ok := repo.liveUser(id)
+	if !ok {
+		return ErrDeleteFailed
+	}
+
+	user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	repo.users[id] = user
+
+	return nil
+}
+
+func (repo *InMemoryRepository) DeletedUsers(ctx context.Context, page Page) ([]models.GormUser, int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	deleted := []models.GormUser{}
+	for _, user := range repo.users {
+		if user.DeletedAt.Valid {
+			user.Password = ""
+			deleted = append(deleted, user)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool {
+		if !deleted[i].DeletedAt.Time.Equal(deleted[j].DeletedAt.Time) {
+			return deleted[i].DeletedAt.Time.After(deleted[j].DeletedAt.Time)
+		}
+		return deleted[i].ID > deleted[j].ID
+	})
+
+	return pageOf(deleted, page), int64(len(deleted)), nil
+}
+
+func (repo *InMemoryRepository) RestoreUser(ctx context.Context, id uint) (*models.GormUser, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	restored, ok := repo.users[id]
+	if !ok || !restored.DeletedAt.Valid {
+		return nil, ErrNotExist
+	}
+	if repo.userConflicts(restored) {
+		return nil, ErrDuplicate
+	}
+
+	restored.DeletedAt = gorm.DeletedAt{}
+	restored.Version++
+	restored.UpdatedAt = time.Now()
+	repo.users[id] = restored
+
+	return &restored, nil
+}
+
+func (repo *InMemoryRepository) PurgeUser(ctx context.Context, id uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	user, ok := repo.users[id]
+	if !ok || !user.DeletedAt.Valid {
+		return ErrNotExist
+	}
+
+	delete(repo.users, id)
+	repo.cascadeBookmarks(func(bookmark models.GormBookmark) bool { return bookmark.UserID == id })
+	repo.cascadeFollows(func(follow models.GormFollow) bool { return follow.FollowerID == id || follow.FolloweeID == id })
+	repo.cascadeTimeline(func(entry models.GormTimelineEntry) bool { return entry.UserID == id || entry.AuthorID == id })
+	repo.cascadeNotifications(func(notification models.GormNotification) bool {
+		return notification.UserID == id || notification.ActorID == id
+	})
+	repo.cascadePreferences(id)
+	repo.cascadeResets(id)
+	return nil
+}
+
+func (repo *InMemoryRepository) ExpiredUserIDs(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	ids := []uint{}
+	for id, user := range repo.users {
+		if user.DeletedAt.Valid && user.DeletedAt.Time.Before(before) && id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	return firstIDs(ids, limit), nil
+}
+
+func (repo *InMemoryRepository) CreatePasswordReset(ctx context.Context, reset models.GormPasswordReset) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.users[reset.UserID]; !ok {
+		return ErrForeignKey
+	}
+	if _, ok := repo.resets[reset.TokenHash]; ok {
+		return ErrDuplicate
+	}
+
+	now := time.Now()
+	for tokenHash, stored := range repo.resets {
+		if !stored.ExpiresAt.After(now) {
+			delete(repo.resets, tokenHash)
+		}
+	}
+
+	if reset.CreatedAt.IsZero() {
+		reset.CreatedAt = now
+	}
+	reset.User = nil
+	repo.resets[reset.TokenHash] = reset
+	return nil
+}
+
+func (repo *InMemoryRepository) TakePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*models.GormPasswordReset, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	reset, ok := repo.resets[tokenHash]
+	if !ok || !reset.ExpiresAt.After(now) {
+		return nil, ErrNotExist
+	}
+
+	repo.cascadeResets(reset.UserID)
+	return &reset, nil
+}
+
+// cascadeResets removes the password resets of a user, the way ON DELETE
+// CASCADE does when the user is purged. Callers must hold the lock.
+func (repo *InMemoryRepository) cascadeResets(userID uint) {
+	for tokenHash, reset := range repo.resets {
+		if reset.UserID == userID {
+			delete(repo.resets, tokenHash)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// viewKey is the primary key of post views; the hour is kept as Unix
+// seconds, since equal times can differ as map keys.
+type viewKey struct {
+	postID uint
+	hour   int64
+}
+
+func NewInMemoryViewRepository() ViewRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateView(ctx context.Context) error {
+	return nil
+}
+
+func (repo *InMemoryRepository) AddViews(ctx context.Context, views []models.GormPostView) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, view := range views {
+		if post, ok := repo.livePost(view.PostID); !ok || !post.IsPublished {
+			continue
+		}
+
+		key := viewKey{view.PostID, view.Hour.Unix()}
+		stored, ok := repo.views[key]
+		if !ok {
+			stored = models.GormPostView{PostID: view.PostID, Hour: view.Hour}
+		}
+		stored.Views += view.Views
+		repo.views[key] = stored
+	}
+
+	return nil
+}
+
+// matchingViews lists the views filter lets through. Callers must hold the
+// lock.
+func (repo *InMemoryRepository) matchingViews(filter ViewFilter) []models.GormPostView {
+	views := []models.GormPostView{}
+	for _, view := range repo.views {
+		if view.Hour.Before(filter.Since) || !view.Hour.Before(filter.Until) {
+			continue
+		}
+		if filter.PostID != 0 && view.PostID != filter.PostID {
+			continue
+		}
+		if filter.UserID != 0 && repo.posts[view.PostID].UserID != filter.UserID {
+			continue
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// bucketStart truncates t to the start of its interval in UTC, the way
+// date_trunc does; weeks start on Monday.
+func bucketStart(t time.Time, interval string) time.Time {
+	year, month, day := t.UTC().Date()
+	switch interval {
+	case "week":
+		start := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// nextBucket is the start of the interval after the one starting at start.
+func nextBucket(start time.Time, interval string) time.Time {
+	switch interval {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+func (repo *InMemoryRepository) ViewBuckets(ctx context.Context, filter ViewFilter, interval string) ([]models.ViewBucket, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	sums := map[int64]int64{}
+	for _, view := range repo.matchingViews(filter) {
+		sums[bucketStart(view.Hour, interval).Unix()] += view.Views
+	}
+
+	buckets := []models.ViewBucket{}
+	for start := bucketStart(filter.Since, interval); start.Before(filter.Until); start = nextBucket(start, interval) {
+		buckets = append(buckets, models.ViewBucket{Start: start, Views: sums[start.Unix()]})
+	}
+
+	return buckets, nil
+}
+
+func (repo *InMemoryRepository) TopPosts(ctx context.Context, filter ViewFilter, limit int) ([]models.PostViews, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	byPost := map[uint]*models.PostViews{}
+	for _, view := range repo.matchingViews(filter) {
+		post, ok := repo.livePost(view.PostID)
+		if !ok {
+			continue
+		}
+		if byPost[post.ID] == nil {
+			byPost[post.ID] = &models.PostViews{PostID: post.ID, UserID: post.UserID, Title: post.Title}
+		}
+		byPost[post.ID].Views += view.Views
+	}
+
+	posts := []models.PostViews{}
+	for _, post := range byPost {
+		posts = append(posts, *post)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if posts[i].Views != posts[j].Views {
+			return posts[i].Views > posts[j].Views
+		}
+		return posts[i].PostID < posts[j].PostID
+	})
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	return posts, nil
+}
+
+func (repo *InMemoryRepository) TopAuthors(ctx context.Context, filter ViewFilter, limit int) ([]models.AuthorViews, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	byAuthor := map[uint]*models.AuthorViews{}
+	viewed := map[uint]bool{}
+	for _, view := range repo.matchingViews(filter) {
+		post, ok := repo.livePost(view.PostID)
+		if !ok {
+			continue
+		}
+		user, ok := repo.liveUser(post.UserID)
+		if !ok {
+			continue
+		}
+		if byAuthor[user.ID] == nil {
+			byAuthor[user.ID] = &models.AuthorViews{UserID: user.ID, Name: user.Name}
+		}
+		byAuthor[user.ID].Views += view.Views
+		if !viewed[post.ID] {
+			viewed[post.ID] = true
+			byAuthor[user.ID].Posts++
+		}
+	}
+
+	authors := []models.AuthorViews{}
+	for _, author := range byAuthor {
+		authors = append(authors, *author)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if authors[i].Views != authors[j].Views {
+			return authors[i].Views > authors[j].Views
+		}
+		return authors[i].UserID < authors[j].UserID
+	})
+	if len(authors) > limit {
+		authors = authors[:limit]
+	}
+
+	return authors, nil
+}
+
+// cascadeViews removes the views of a purged post, the way ON DELETE
+// CASCADE does. Callers must hold the write lock.
+func (repo *InMemoryRepository) cascadeViews(postID uint) {
+	for key := range repo.views {
+		if key.postID == postID {
+			delete(repo.views, key)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+func NewInMemoryWebhookRepository() WebhookRepository {
+	return NewInMemoryRepository()
+}
+
+func (repo *InMemoryRepository) MigrateWebhook(ctx context.Context) error {
+	return nil
+}
+
+func (repo *InMemoryRepository) CreateWebhook(ctx context.Context, webhook models.GormWebhook) (*models.GormWebhook, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	webhook.ID = repo.nextID("webhooks")
+	stampCreate(&webhook.CreatedAt, &webhook.UpdatedAt)
+	repo.webhooks[webhook.ID] = webhook
+	return &webhook, nil
+}
+
+func (repo *InMemoryRepository) Webhooks(ctx context.Context) ([]models.GormWebhook, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	webhooks := make([]models.GormWebhook, 0, len(repo.webhooks))
+	for _, webhook := range repo.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (repo *InMemoryRepository) GetWebhookByID(ctx context.Context, id uint) (*models.GormWebhook, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	webhook, ok := repo.webhooks[id]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return &webhook, nil
+}
+
+func (repo *InMemoryRepository) UpdateWebhook(ctx context.Context, webhook models.GormWebhook) (*models.GormWebhook, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.webhooks[webhook.ID]
+	if !ok {
+		return nil, ErrNotExist
+	}
+
+	stored.URL, stored.Secret, stored.Events, stored.Active = webhook.URL, webhook.Secret, webhook.Events, webhook.Active
+	stored.UpdatedAt = time.Now()
+	repo.webhooks[webhook.ID] = stored
+	return &stored, nil
+}
+
+func (repo *InMemoryRepository) DeleteWebhook(ctx context.Context, id uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.webhooks[id]; !ok {
+		return ErrNotExist
+	}
+
+	delete(repo.webhooks, id)
+	for deliveryID, delivery := range repo.deliveries {
+		if delivery.WebhookID == id {
+			delete(repo.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (repo *InMemoryRepository) CreateWebhookEvent(ctx context.Context, event models.GormWebhookEvent) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	event.ID = repo.nextID("webhook_events")
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	repo.webhookEvents[event.ID] = event
+	return nil
+}
+
+// PendingWebhookEvents needs no locks of its own: transactions already run
+// one at a time.
+func (repo *InMemoryRepository) PendingWebhookEvents(ctx context.Context, limit int) ([]models.GormWebhookEvent, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	events := []models.GormWebhookEvent{}
+	for _, event := range repo.webhookEvents {
+		if event.DispatchedAt == nil {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func (repo *InMemoryRepository) DispatchWebhookEvents(ctx context.Context, eventIDs []uint, deliveries []models.GormWebhookDelivery) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		if _, ok := repo.webhooks[delivery.WebhookID]; !ok {
+			return ErrForeignKey
+		}
+		if _, ok := repo.webhookEvents[delivery.EventID]; !ok {
+			return ErrForeignKey
+		}
+	}
+
+	// like ON CONFLICT DO NOTHING on the webhook and event
+	delivered := map[[2]uint]bool{}
+	for _, delivery := range repo.deliveries {
+		delivered[[2]uint{delivery.WebhookID, delivery.EventID}] = true
+	}
+	for _, delivery := range deliveries {
+		key := [2]uint{delivery.WebhookID, delivery.EventID}
+		if delivered[key] {
+			continue
+		}
+		delivered[key] = true
+
+		delivery.ID = repo.nextID("webhook_deliveries")
+		stampCreate(&delivery.CreatedAt, &delivery.UpdatedAt)
+		delivery.Webhook, delivery.Event = nil, nil
+		repo.deliveries[delivery.ID] = delivery
+	}
+
+	now := time.Now()
+	for _, id := range eventIDs {
+		if event, ok := repo.webhookEvents[id]; ok {
+			dispatchedAt := now
+			event.DispatchedAt = &dispatchedAt
+			repo.webhookEvents[id] = event
+		}
+	}
+	return nil
+}
+
+func (repo *InMemoryRepository) ClaimWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.GormWebhookDelivery, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	deliveries := []models.GormWebhookDelivery{}
+	for _, delivery := range repo.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) && repo.webhooks[delivery.WebhookID].Active {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt) })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	for i := range deliveries {
+		deliveries[i].NextAttemptAt = until
+		repo.deliveries[deliveries[i].ID] = deliveries[i]
+
+		webhook, event := repo.webhooks[deliveries[i].WebhookID], repo.webhookEvents[deliveries[i].EventID]
+		deliveries[i].Webhook, deliveries[i].Event = &webhook, &event
+	}
+	return deliveries, nil
+}
+
+func (repo *InMemoryRepository) UpdateWebhookDelivery(ctx context.Context, delivery models.GormWebhookDelivery) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.deliveries[delivery.ID]
+	if !ok {
+		return ErrNotExist
+	}
+
+	stored.Status, stored.Attempts, stored.NextAttemptAt = delivery.Status, delivery.Attempts, delivery.NextAttemptAt
+	stored.LastAttemptAt, stored.ResponseStatus, stored.Error = delivery.LastAttemptAt, delivery.ResponseStatus, delivery.Error
+	stored.UpdatedAt = time.Now()
+	repo.deliveries[delivery.ID] = stored
+	return nil
+}
+
+func (repo *InMemoryRepository) GetWebhookDelivery(ctx context.Context, id uint) (*models.GormWebhookDelivery, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	delivery, ok := repo.deliveries[id]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return &delivery, nil
+}
+
+func (repo *InMemoryRepository) WebhookDeliveries(ctx context.Context, webhookID uint, status string, page Page) ([]models.GormWebhookDelivery, int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	deliveries := []models.GormWebhookDelivery{}
+	for _, delivery := range repo.deliveries {
+		if delivery.WebhookID == webhookID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+
+	total := int64(len(deliveries))
+	start, end := page.offset(), page.offset()+page.Size
+	if start > len(deliveries) {
+		start = len(deliveries)
+	}
+	if end > len(deliveries) {
+		end = len(deliveries)
+	}
+
+	return deliveries[start:end], total, nil
+}
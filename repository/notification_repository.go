@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepo struct {
+	gormRepository
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &NotificationRepo{gormRepository{db}}
+}
+
+func (repo *NotificationRepo) MigrateNotification(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormNotification{}, &models.GormNotificationPreference{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (repo *NotificationRepo) CreateNotifications(ctx context.Context, notifications []models.GormNotification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	if err := repo.conn(ctx).Omit(clause.Associations).Create(&notifications).Error; err != nil {
+		return repo.translateError(err)
+	}
+	return nil
+}
+
+func (repo *NotificationRepo) Notifications(ctx context.Context, userID uint, unread bool, page Page) ([]models.GormNotification, int64, error) {
+	query := repo.conn(ctx).Model(&models.GormNotification{}).Where("user_id = ?", userID)
+	if unread {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	notifications := []models.GormNotification{}
+	err := query.Order("id DESC").Limit(page.Size).Offset(page.offset()).Find(&notifications).Error
+	if err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	return notifications, total, nil
+}
+
+func (repo *NotificationRepo) UnreadCount(ctx context.Context, userID uint) (int64, error) {
+	var unread int64
+	err := repo.conn(ctx).Model(&models.GormNotification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&unread).Error
+	if err != nil {
+		return 0, repo.translateError(err)
+	}
+	return unread, nil
+}
+
+func (repo *NotificationRepo) MarkRead(ctx context.Context, userID uint, ids []uint) (int64, error) {
+	query := repo.conn(ctx).Model(&models.GormNotification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if ids != nil {
+		if len(ids) == 0 {
+			return 0, nil
+		}
+		query = query.Where("id IN ?", ids)
+	}
+
+	updateRes := query.UpdateColumn("read_at", time.Now())
+	if err := updateRes.Error; err != nil {
+		return 0, repo.translateError(err)
+	}
+	return updateRes.RowsAffected, nil
+}
+
+func (repo *NotificationRepo) NotificationPreferences(ctx context.Context, userID uint) (map[string]bool, error) {
+	var rows []models.GormNotificationPreference
+	if err := repo.conn(ctx).Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	preferences := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		preferences[row.Type] = row.Enabled
+	}
+	return preferences, nil
+}
+
+// SetNotificationPreferences upserts one row per type in a single
+// statement.
+func (repo *NotificationRepo) SetNotificationPreferences(ctx context.Context, userID uint, preferences map[string]bool) error {
+	if len(preferences) == 0 {
+		return nil
+	}
+
+	rows := make([]models.GormNotificationPreference, 0, len(preferences))
+	for notificationType, enabled := range preferences {
+		rows = append(rows, models.GormNotificationPreference{UserID: userID, Type: notificationType, Enabled: enabled})
+	}
+
+	err := repo.conn(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled"}),
+		}).
+		Create(&rows).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+	return nil
+}
+
+func (repo *NotificationRepo) MutedUserIDs(ctx context.Context, notificationType string, userIDs []uint) (map[uint]bool, error) {
+	muted := make(map[uint]bool, len(userIDs))
+	if len(userIDs) == 0 {
+		return muted, nil
+	}
+
+	var ids []uint
+	err := repo.conn(ctx).Model(&models.GormNotificationPreference{}).
+		Where("type = ? AND NOT enabled AND user_id IN ?", notificationType, userIDs).
+		Pluck("user_id", &ids).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	for _, id := range ids {
+		muted[id] = true
+	}
+	return muted, nil
+}
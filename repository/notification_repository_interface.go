@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// NotificationRepository stores what users are told about, whether they
+// read it, and which types of notification they turned off.
+type NotificationRepository interface {
+	Transactor
+	MigrateNotification(ctx context.Context) error
+	CreateNotifications(ctx context.Context, notifications []models.GormNotification) error
+	// Notifications returns one page of a user's notifications, newest
+	// first, and how many there are in all. unread leaves out the ones
+	// already read.
+	Notifications(ctx context.Context, userID uint, unread bool, page Page) ([]models.GormNotification, int64, error)
+	UnreadCount(ctx context.Context, userID uint) (int64, error)
+	// MarkRead marks the user's notifications among ids read, or all of
+	// them when ids is nil, and returns how many were unread before.
+	MarkRead(ctx context.Context, userID uint, ids []uint) (int64, error)
+	// NotificationPreferences returns the types a user turned on or off;
+	// types never set are left out.
+	NotificationPreferences(ctx context.Context, userID uint) (map[string]bool, error)
+	SetNotificationPreferences(ctx context.Context, userID uint, preferences map[string]bool) error
+	// MutedUserIDs tells which of userIDs turned notificationType off, in
+	// one query however many users are asked about.
+	MutedUserIDs(ctx context.Context, notificationType string, userIDs []uint) (map[uint]bool, error)
+}
@@ -3,106 +3,132 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/bellaananda/go-postgresql-blog-http.git/models"
-	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func (repo *PostgreSQLGORMRepository) MigratePost(ctx context.Context) error {
-	err := repo.db.WithContext(ctx).AutoMigrate(&models.GormPost{})
-	if err != nil {
-		return err
-	}
-	return nil
+type PostRepo struct {
+	gormRepository
 }
 
 func NewPostRepository(db *gorm.DB) PostRepository {
-	return &PostgreSQLGORMRepository{db}
+	return &PostRepo{gormRepository{db}}
 }
 
-func (repo *PostgreSQLGORMRepository) CreatePost(ctx context.Context, post models.GormPost) (*models.GormPost, error) {
-	if err := repo.db.WithContext(ctx).Create(&post).Error; err != nil {
-		var pgxError *pgconn.PgError
-		if errors.As(err, &pgxError) {
-			if pgxError.Code == "23505" {
-				return nil, ErrDuplicate
-			}
-		}
-		return nil, err
+func (repo *PostRepo) MigratePost(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormPost{})
+	if err != nil {
+		return err
+	}
+	if err := repo.migrateConstraints(ctx, &models.GormUser{}, "Posts"); err != nil {
+		return err
+	}
+	return repo.createLiveUniqueIndex(ctx, "gorm_posts", "idx_posts_title", "title")
+}
+
+func (repo *PostRepo) CreatePost(ctx context.Context, post models.GormPost) (*models.GormPost, error) {
+	if err := repo.conn(ctx).Create(&post).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &post, nil
 }
 
-func (repo *PostgreSQLGORMRepository) AllPosts(ctx context.Context) ([]models.GormPost, error) {
+func (repo *PostRepo) AllPosts(ctx context.Context, query Query) ([]models.GormPost, error) {
 	var allPosts []models.GormPost
-	if err := repo.db.WithContext(ctx).Preload("User").Find(&allPosts).Error; err != nil {
-		return nil, err
+	if err := query.scope(repo.conn(ctx), "posts", "user").Find(&allPosts).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return allPosts, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetPostByID(ctx context.Context, id uint) (*models.GormPost, error) {
+func (repo *PostRepo) GetPostByID(ctx context.Context, id uint) (*models.GormPost, error) {
+	return repo.FindPost(ctx, id, Query{})
+}
+
+func (repo *PostRepo) FindPost(ctx context.Context, id uint, query Query) (*models.GormPost, error) {
 	var gormPost models.GormPost
-	if err := repo.db.WithContext(ctx).Preload("User").First(&gormPost, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := query.scope(repo.conn(ctx), "posts", "user").First(&gormPost, id).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &gormPost, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetPostByTitle(ctx context.Context, title string) (*models.GormPost, error) {
+func (repo *PostRepo) GetPostByTitle(ctx context.Context, title string) (*models.GormPost, error) {
 	var gormPost models.GormPost
-	if err := repo.db.WithContext(ctx).Where("title = ?", title).First(&gormPost).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := repo.conn(ctx).Where("title = ?", title).First(&gormPost).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &gormPost, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetPostByUserID(ctx context.Context, userid uint) ([]models.GormPost, error) {
+func (repo *PostRepo) GetPostByUserID(ctx context.Context, userid uint) ([]models.GormPost, error) {
 	var gormPost []models.GormPost
-	if err := repo.db.WithContext(ctx).Where("user_id = ?", userid).Find(&gormPost).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := repo.conn(ctx).Where("user_id = ?", userid).Find(&gormPost).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return gormPost, nil
 }
 
-func (repo *PostgreSQLGORMRepository) UpdatePost(ctx context.Context, id uint, updated models.GormPost) (*models.GormPost, error) {
-	updateRes := repo.db.WithContext(ctx).Where("id = ?", id).Save(&updated)
+func (repo *PostRepo) UpdatePost(ctx context.Context, id uint, updated models.GormPost) (*models.GormPost, error) {
+	// only write over the version the caller read, and bump it
+	expectedVersion := updated.Version
+	updated.ID = id
+	updated.Version = expectedVersion + 1
+
+	// RETURNING hands back the row as stored, created_at included
+	updateRes := repo.conn(ctx).Model(&updated).Clauses(clause.Returning{}).Where("version = ?", expectedVersion).Select("*").Omit("created_at", "reaction_counts", "fanned_out").Updates(&updated)
 	if err := updateRes.Error; err != nil {
-		var pgxError *pgconn.PgError
-		if errors.As(err, &pgxError) {
-			if pgxError.Code == "23505" {
-				return nil, ErrDuplicate
-			}
-		}
-		return nil, err
+		return nil, repo.translateError(err)
 	}
 
 	rowsAffected := updateRes.RowsAffected
 	if rowsAffected == 0 {
-		return nil, ErrUpdateFailed
+		return nil, repo.updateFailure(ctx, &models.GormPost{}, id)
+	}
+
+	if err := repo.loadUser(ctx, &updated); err != nil {
+		return nil, err
 	}
 	return &updated, nil
 }
 
-func (repo *PostgreSQLGORMRepository) DeletePost(ctx context.Context, id uint) error {
-	res := repo.db.WithContext(ctx).Delete(&models.GormPost{}, id)
+// PatchPost writes only the given columns, provided the stored row is still
+// at version, and returns the row as RETURNING reports it.
+func (repo *PostRepo) PatchPost(ctx context.Context, id uint, version uint, columns map[string]interface{}) (*models.GormPost, error) {
+	assignments := make(map[string]interface{}, len(columns)+1)
+	for column, value := range columns {
+		assignments[column] = value
+	}
+	assignments["version"] = version + 1
+
+	var patched models.GormPost
+	updateRes := repo.conn(ctx).Model(&patched).Clauses(clause.Returning{}).Where("id = ? AND version = ?", id, version).Updates(assignments)
+	if err := updateRes.Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	if updateRes.RowsAffected == 0 {
+		return nil, repo.updateFailure(ctx, &models.GormPost{}, id)
+	}
+
+	if err := repo.loadUser(ctx, &patched); err != nil {
+		return nil, err
+	}
+	return &patched, nil
+}
+
+func (repo *PostRepo) DeletePost(ctx context.Context, id uint) error {
+	res := repo.conn(ctx).Delete(&models.GormPost{}, id)
 	if err := res.Error; err != nil {
-		return err
+		return repo.translateError(err)
 	}
 
 	rowsAffected := res.RowsAffected
@@ -112,3 +138,172 @@ func (repo *PostgreSQLGORMRepository) DeletePost(ctx context.Context, id uint) e
 
 	return nil
 }
+
+// loadUser fills post.User the way Preload("User") does, without reading
+// the post again.
+func (repo *PostRepo) loadUser(ctx context.Context, post *models.GormPost) error {
+	var user models.GormUser
+	err := omitPassword(repo.conn(ctx)).First(&user, post.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		post.User = nil
+		return nil
+	}
+	if err != nil {
+		return repo.translateError(err)
+	}
+
+	post.User = &user
+	return nil
+}
+
+// DeletedPosts lists the soft-deleted posts, most recently deleted first.
+func (repo *PostRepo) DeletedPosts(ctx context.Context, page Page) ([]models.GormPost, int64, error) {
+	// a session of its own, so the count leaves the query as it was
+	query := repo.conn(ctx).Unscoped().Model(&models.GormPost{}).Where("deleted_at IS NOT NULL").Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	deleted := []models.GormPost{}
+	err := query.
+		Order("deleted_at DESC, id DESC").
+		Limit(page.Size).Offset(page.offset()).
+		Find(&deleted).Error
+	if err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	return deleted, total, nil
+}
+
+// RestorePost takes a post out of the trash. It returns ErrNotExist when the
+// post is not in the trash.
+func (repo *PostRepo) RestorePost(ctx context.Context, id uint) (*models.GormPost, error) {
+	var restored models.GormPost
+	res := repo.conn(ctx).Unscoped().Model(&restored).Clauses(clause.Returning{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if err := res.Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	if res.RowsAffected == 0 {
+		return nil, ErrNotExist
+	}
+	return &restored, nil
+}
+
+// PurgePost permanently removes a post that is already in the trash and
+// returns the row as it was, so callers can clean up what it pointed at.
+func (repo *PostRepo) PurgePost(ctx context.Context, id uint) (*models.GormPost, error) {
+	var purged models.GormPost
+	res := repo.conn(ctx).Unscoped().Clauses(clause.Returning{}).Where("deleted_at IS NOT NULL").Delete(&purged, id)
+	if err := res.Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	if res.RowsAffected == 0 {
+		return nil, ErrNotExist
+	}
+	return &purged, nil
+}
+
+func (repo *PostRepo) ExpiredPostIDs(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := repo.conn(ctx).Unscoped().Model(&models.GormPost{}).
+		Where("deleted_at < ? AND id > ?", before, afterID).
+		Order("id").Limit(limit).Pluck("id", &ids).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return ids, nil
+}
+
+// PostIDsByUserID lists the ids of a user's posts, including the ones in the
+// trash when withDeleted is set.
+func (repo *PostRepo) PostIDsByUserID(ctx context.Context, userID uint, withDeleted bool) ([]uint, error) {
+	query := repo.conn(ctx).Model(&models.GormPost{})
+	if withDeleted {
+		query = query.Unscoped()
+	}
+
+	var ids []uint
+	if err := query.Where("user_id = ?", userID).Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return ids, nil
+}
+
+// ReassignPosts hands every post of one user, trashed ones included, to
+// another user.
+func (repo *PostRepo) ReassignPosts(ctx context.Context, fromUserID uint, toUserID uint) error {
+	err := repo.conn(ctx).Unscoped().Model(&models.GormPost{}).
+		Where("user_id = ?", fromUserID).
+		Updates(map[string]interface{}{"user_id": toUserID, "version": gorm.Expr("version + 1")}).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+
+	return nil
+}
+
+func (repo *PostRepo) PublishedPosts(ctx context.Context, userID uint, tag string, limit int) ([]models.GormPost, error) {
+	query := repo.conn(ctx).Where("is_published")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if tag != "" {
+		query = query.Where("tags @> ?::jsonb", models.Tags{tag})
+	}
+
+	posts := []models.GormPost{}
+	if err := query.Order("published_at DESC, id DESC").Preload("User", omitPassword).Limit(limit).Find(&posts).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return posts, nil
+}
+
+func (repo *PostRepo) SitemapParts(ctx context.Context, size int) ([]SitemapPart, error) {
+	// numbering the rows lets Postgres do the split in one pass
+	parts := []SitemapPart{}
+	err := repo.conn(ctx).Raw(`
+		SELECT part + 1 AS number, count(*) AS urls, max(updated_at) AS last_modified
+		FROM (
+			SELECT (row_number() OVER (ORDER BY id) - 1) / ? AS part, updated_at
+			FROM gorm_posts
+			WHERE is_published AND deleted_at IS NULL
+		) numbered
+		GROUP BY part
+		ORDER BY part`, size).Scan(&parts).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return parts, nil
+}
+
+func (repo *PostRepo) ScanPublishedPosts(ctx context.Context, offset int, limit int, fn func(models.GormPost) error) error {
+	db := repo.conn(ctx)
+	rows, err := db.Model(&models.GormPost{}).Select("id", "updated_at").Where("is_published").Order("id").Offset(offset).Limit(limit).Rows()
+	if err != nil {
+		return repo.translateError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post models.GormPost
+		if err := db.ScanRows(rows, &post); err != nil {
+			return repo.translateError(err)
+		}
+		if err := fn(post); err != nil {
+			return err
+		}
+	}
+
+	return repo.translateError(rows.Err())
+}
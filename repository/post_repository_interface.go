@@ -2,18 +2,53 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/bellaananda/go-postgresql-blog-http.git/models"
 )
 
 // Repository provides access to the website storage.
 type PostRepository interface {
+	Transactor
 	MigratePost(ctx context.Context) error
 	CreatePost(ctx context.Context, post models.GormPost) (*models.GormPost, error)
-	AllPosts(ctx context.Context) ([]models.GormPost, error)
+	AllPosts(ctx context.Context, query Query) ([]models.GormPost, error)
 	GetPostByID(ctx context.Context, id uint) (*models.GormPost, error)
+	FindPost(ctx context.Context, id uint, query Query) (*models.GormPost, error)
 	GetPostByTitle(ctx context.Context, title string) (*models.GormPost, error)
 	GetPostByUserID(ctx context.Context, userid uint) ([]models.GormPost, error)
 	UpdatePost(ctx context.Context, id uint, updated models.GormPost) (*models.GormPost, error)
+	PatchPost(ctx context.Context, id uint, version uint, columns map[string]interface{}) (*models.GormPost, error)
 	DeletePost(ctx context.Context, id uint) error
+	// DeletedPosts reads one page of the posts in the trash, most recently
+	// deleted first, and how many there are in all.
+	DeletedPosts(ctx context.Context, page Page) ([]models.GormPost, int64, error)
+	RestorePost(ctx context.Context, id uint) (*models.GormPost, error)
+	PurgePost(ctx context.Context, id uint) (*models.GormPost, error)
+	// ExpiredPostIDs lists, in id order, the ids after afterID of up to limit
+	// posts that went into the trash before before.
+	ExpiredPostIDs(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error)
+	PostIDsByUserID(ctx context.Context, userID uint, withDeleted bool) ([]uint, error)
+	ReassignPosts(ctx context.Context, fromUserID uint, toUserID uint) error
+	// PublishedPosts reads up to limit live, published posts newest first,
+	// with their authors: only those by userID unless it is 0, and only
+	// those tagged tag unless it is empty.
+	PublishedPosts(ctx context.Context, userID uint, tag string, limit int) ([]models.GormPost, error)
+	// SitemapParts splits the live, published posts, in id order, into
+	// parts of up to size posts, without reading the posts themselves.
+	SitemapParts(ctx context.Context, size int) ([]SitemapPart, error)
+	// ScanPublishedPosts calls fn with the id and update time of the live,
+	// published posts in id order, skipping the first offset and stopping
+	// after limit. The posts come off a cursor one at a time, so they are
+	// never all held at once. An error from fn stops the scan and is
+	// returned.
+	ScanPublishedPosts(ctx context.Context, offset int, limit int, fn func(models.GormPost) error) error
+}
+
+// SitemapPart is one slice of the published posts, numbered from 1: how
+// many posts it holds and when the last of them changed.
+type SitemapPart struct {
+	Number       int
+	URLs         int64
+	LastModified time.Time
 }
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+func TestPostRepoStatements(t *testing.T) {
+	db, recorder, tables := newDryRun(t)
+	repo := NewPostRepository(db)
+
+	runDryRun(t, recorder, tables, []dryRunCase{
+		{"MigratePost", func(ctx context.Context) error {
+			return repo.MigratePost(ctx)
+		}, []string{
+			`CREATE TABLE "gorm_posts"`,
+			`"title" varchar(255),`,
+			`CONSTRAINT "fk_gorm_users_posts" FOREIGN KEY ("user_id") REFERENCES "gorm_users"("id") ON DELETE RESTRICT ON UPDATE CASCADE`,
+			`SELECT delete_rule, update_rule FROM information_schema.referential_constraints WHERE constraint_schema = CURRENT_SCHEMA() AND constraint_name = 'fk_gorm_users_posts'`,
+			"ALTER TABLE gorm_posts DROP CONSTRAINT IF EXISTS idx_gorm_posts_title",
+			`CREATE UNIQUE INDEX IF NOT EXISTS "idx_posts_title" ON "gorm_posts" ("title") WHERE deleted_at IS NULL`,
+		}},
+		{"CreatePost", func(ctx context.Context) error {
+			_, err := repo.CreatePost(ctx, models.GormPost{UserID: 1, Title: "T"})
+			return err
+		}, []string{`INSERT INTO "gorm_posts"`}},
+		{"AllPosts", func(ctx context.Context) error {
+			_, err := repo.AllPosts(ctx, Query{Fields: map[string][]string{"posts": {"id", "title"}}, Include: []string{}})
+			return err
+		}, []string{`SELECT "id","title" FROM "gorm_posts" WHERE "gorm_posts"."deleted_at" IS NULL`}},
+		{"GetPostByID", func(ctx context.Context) error {
+			_, err := repo.GetPostByID(ctx, 1)
+			return err
+		}, []string{`FROM "gorm_posts" WHERE "gorm_posts"."id" = 1 AND "gorm_posts"."deleted_at" IS NULL`}},
+		{"GetPostByTitle", func(ctx context.Context) error {
+			_, err := repo.GetPostByTitle(ctx, "T")
+			return err
+		}, []string{`FROM "gorm_posts" WHERE title = 'T' AND "gorm_posts"."deleted_at" IS NULL`}},
+		{"GetPostByUserID", func(ctx context.Context) error {
+			_, err := repo.GetPostByUserID(ctx, 1)
+			return err
+		}, []string{`FROM "gorm_posts" WHERE user_id = 1 AND "gorm_posts"."deleted_at" IS NULL`}},
+		{"UpdatePost", func(ctx context.Context) error {
+			_, err := repo.UpdatePost(ctx, 1, models.GormPost{Version: 3, Title: "T"})
+			return err
+		}, []string{`UPDATE "gorm_posts" SET`, `"version"=4`, `WHERE version = 3`, `RETURNING *`, `SELECT count(*) FROM "gorm_posts" WHERE id = 1`}},
+		{"PatchPost", func(ctx context.Context) error {
+			_, err := repo.PatchPost(ctx, 1, 3, map[string]interface{}{"title": "T"})
+			return err
+		}, []string{`UPDATE "gorm_posts" SET`, `"version"=4`, `WHERE (id = 1 AND version = 3)`, `RETURNING *`}},
+		{"DeletePost", func(ctx context.Context) error {
+			return repo.DeletePost(ctx, 1)
+		}, []string{`UPDATE "gorm_posts" SET "deleted_at"=`, `WHERE "gorm_posts"."id" = 1 AND "gorm_posts"."deleted_at" IS NULL`}},
+		{"DeletedPosts", func(ctx context.Context) error {
+			_, _, err := repo.DeletedPosts(ctx, Page{Number: 2, Size: 10})
+			return err
+		}, []string{`SELECT count(*) FROM "gorm_posts" WHERE deleted_at IS NOT NULL`, `WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC LIMIT 10 OFFSET 10`}},
+		{"RestorePost", func(ctx context.Context) error {
+			_, err := repo.RestorePost(ctx, 1)
+			return err
+		}, []string{`UPDATE "gorm_posts" SET "deleted_at"=NULL,"version"=version + 1`, `WHERE id = 1 AND deleted_at IS NOT NULL`}},
+		{"PurgePost", func(ctx context.Context) error {
+			_, err := repo.PurgePost(ctx, 1)
+			return err
+		}, []string{`DELETE FROM "gorm_posts" WHERE deleted_at IS NOT NULL AND "gorm_posts"."id" = 1 RETURNING *`}},
+		{"ExpiredPostIDs", func(ctx context.Context) error {
+			_, err := repo.ExpiredPostIDs(ctx, time.Now(), 5, 100)
+			return err
+		}, []string{`SELECT "id" FROM "gorm_posts" WHERE deleted_at < `, `AND id > 5 ORDER BY id LIMIT 100`}},
+		{"PostIDsByUserID", func(ctx context.Context) error {
+			_, err := repo.PostIDsByUserID(ctx, 1, true)
+			return err
+		}, []string{`SELECT "id" FROM "gorm_posts" WHERE user_id = 1 ORDER BY id`}},
+		{"ReassignPosts", func(ctx context.Context) error {
+			return repo.ReassignPosts(ctx, 1, 2)
+		}, []string{`UPDATE "gorm_posts" SET "user_id"=2,"version"=version + 1`, `WHERE user_id = 1`}},
+		{"PublishedPosts", func(ctx context.Context) error {
+			_, err := repo.PublishedPosts(ctx, 1, "go", 10)
+			return err
+		}, []string{`FROM "gorm_posts" WHERE is_published AND user_id = 1 AND tags @> '["go"]'::jsonb`, `ORDER BY published_at DESC, id DESC LIMIT 10`}},
+		{"SitemapParts", func(ctx context.Context) error {
+			_, err := repo.SitemapParts(ctx, 100)
+			return err
+		}, []string{`FROM gorm_posts`, `WHERE is_published AND deleted_at IS NULL`}},
+		{"ScanPublishedPosts", func(ctx context.Context) error {
+			return repo.ScanPublishedPosts(ctx, 0, 10, func(models.GormPost) error { return nil })
+		}, []string{`SELECT "id","updated_at" FROM "gorm_posts" WHERE is_published AND "gorm_posts"."deleted_at" IS NULL ORDER BY id LIMIT 10`}},
+	})
+}
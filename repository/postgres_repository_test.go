@@ -0,0 +1,171 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/database"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository/repositorytest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// postgresDSNEnv names the variable holding the database the Postgres suites
+// run against. Every table in it is emptied before each test, so never
+// point it at one holding data you want to keep.
+const postgresDSNEnv = "TEST_DATABASE_URL"
+
+var (
+	postgresOnce sync.Once
+	postgresDB   *gorm.DB
+	postgresErr  error
+)
+
+// openPostgres connects and migrates once per run, or skips the test when
+// no database is configured.
+func openPostgres(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+
+	postgresOnce.Do(func() {
+		postgresDB, postgresErr = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if postgresErr != nil {
+			return
+		}
+		postgresErr = database.Migrate(context.Background(), postgresDB)
+	})
+	if postgresErr != nil {
+		t.Fatalf("opening %s: %v", postgresDSNEnv, postgresErr)
+	}
+	return postgresDB
+}
+
+// truncateAll empties every table and restarts their ID sequences, so each
+// suite starts from the same empty store as the in-memory backend.
+func truncateAll(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	var tables []string
+	err := db.Raw("SELECT quote_ident(tablename) FROM pg_tables WHERE schemaname = current_schema()").Scan(&tables).Error
+	if err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+	if len(tables) == 0 {
+		return
+	}
+	err = db.Exec(fmt.Sprintf("TRUNCATE %s RESTART IDENTITY CASCADE", strings.Join(tables, ", "))).Error
+	if err != nil {
+		t.Fatalf("truncating tables: %v", err)
+	}
+}
+
+// postgresRepositories returns a constructor of the GORM repositories over
+// an emptied database.
+func postgresRepositories(t *testing.T) func() repositorytest.Repositories {
+	db := openPostgres(t)
+	return func() repositorytest.Repositories {
+		truncateAll(t, db)
+		return repositorytest.Repositories{
+			Users:         repository.NewUserRepository(db),
+			Posts:         repository.NewPostRepository(db),
+			Comments:      repository.NewCommentRepository(db),
+			Media:         repository.NewMediaRepository(db),
+			Reactions:     repository.NewReactionRepository(db),
+			Bookmarks:     repository.NewBookmarkRepository(db),
+			Follows:       repository.NewFollowRepository(db),
+			Views:         repository.NewViewRepository(db),
+			Notifications: repository.NewNotificationRepository(db),
+			Webhooks:      repository.NewWebhookRepository(db),
+			CommentEvents: repository.NewCommentEventRepository(db),
+		}
+	}
+}
+
+func TestPostgresUserRepository(t *testing.T) {
+	db := openPostgres(t)
+	repositorytest.TestUserRepository(t, func() repository.UserRepository {
+		truncateAll(t, db)
+		return repository.NewUserRepository(db)
+	})
+}
+
+func TestPostgresPostRepository(t *testing.T) {
+	repositorytest.TestPostRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresCommentRepository(t *testing.T) {
+	repositorytest.TestCommentRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresMediaRepository(t *testing.T) {
+	repositorytest.TestMediaRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresReactionRepository(t *testing.T) {
+	repositorytest.TestReactionRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresBookmarkRepository(t *testing.T) {
+	repositorytest.TestBookmarkRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresFollowRepository(t *testing.T) {
+	repositorytest.TestFollowRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresViewRepository(t *testing.T) {
+	repositorytest.TestViewRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresNotificationRepository(t *testing.T) {
+	repositorytest.TestNotificationRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresWebhookRepository(t *testing.T) {
+	repositorytest.TestWebhookRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresCommentEventRepository(t *testing.T) {
+	repositorytest.TestCommentEventRepository(t, postgresRepositories(t))
+}
+
+func TestPostgresMigrateConstraints(t *testing.T) {
+	db := openPostgres(t)
+	foreignKeys := []struct{ table, name, column, references string }{
+		{"gorm_posts", "fk_gorm_users_posts", "user_id", "gorm_users"},
+		{"gorm_comments", "fk_gorm_users_comments", "user_id", "gorm_users"},
+		{"gorm_comments", "fk_gorm_posts_comments", "post_id", "gorm_posts"},
+	}
+
+	// installs from before the delete policies have the same foreign keys
+	// without any ON DELETE rule
+	for _, fk := range foreignKeys {
+		err := db.Exec(fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s, ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(id)",
+			fk.table, fk.name, fk.name, fk.column, fk.references)).Error
+		if err != nil {
+			t.Fatalf("replacing %s: %v", fk.name, err)
+		}
+	}
+	if err := database.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	for _, fk := range foreignKeys {
+		var rule string
+		err := db.Raw("SELECT delete_rule FROM information_schema.referential_constraints WHERE constraint_schema = CURRENT_SCHEMA() AND constraint_name = ?", fk.name).Scan(&rule).Error
+		if err != nil {
+			t.Fatalf("reading %s: %v", fk.name, err)
+		}
+		if rule != "RESTRICT" {
+			t.Errorf("%s deletes with %q after Migrate, want RESTRICT", fk.name, rule)
+		}
+	}
+}
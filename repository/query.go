@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Query narrows a read down to some of the columns of the rows read and
+// picks the relations loaded with them. Columns and relations are trusted
+// as they are: callers validate them against an allowlist first.
+type Query struct {
+	// Columns to read per table ("users", "posts" or "comments"). A table
+	// without an entry has all of its columns read. Columns that relations
+	// are loaded by, the ids and foreign keys, must be among them.
+	Fields map[string][]string
+	// Relations to load ("user", "post", "posts" or "comments"). nil loads
+	// whatever the read loads by default, an empty slice nothing.
+	Include []string
+}
+
+// relation is the GORM name of a relation and the table its rows come from.
+type relation struct {
+	name  string
+	table string
+}
+
+var relations = map[string]relation{
+	"user":     {"User", "users"},
+	"post":     {"Post", "posts"},
+	"posts":    {"Posts", "posts"},
+	"comments": {"Comments", "comments"},
+}
+
+// include returns the relations query loads, falling back to defaults.
+func (query Query) include(defaults ...string) []string {
+	if query.Include == nil {
+		return defaults
+	}
+	return query.Include
+}
+
+// scope applies query to a read from table with Select and Preload.
+func (query Query) scope(db *gorm.DB, table string, defaults ...string) *gorm.DB {
+	if columns, ok := query.Fields[table]; ok {
+		db = db.Select(columns)
+	}
+
+	for _, name := range query.include(defaults...) {
+		relation := relations[name]
+		columns, narrowed := query.Fields[relation.table]
+		db = db.Preload(relation.name, func(db *gorm.DB) *gorm.DB {
+			if narrowed {
+				db = db.Select(columns)
+			}
+			if relation.table == "users" {
+				db = omitPassword(db)
+			}
+			return db
+		})
+	}
+
+	return db
+}
+
+// omitPassword leaves the password out of users read for someone else to
+// see, such as the author of a post or the followers of a user.
+func omitPassword(db *gorm.DB) *gorm.DB {
+	return db.Omit("password")
+}
+
+// Page is one page of a listing, numbered from 1, of Size rows each.
+type Page struct {
+	Number int
+	Size   int
+}
+
+// offset is how many rows come before the page.
+func (page Page) offset() int {
+	return (page.Number - 1) * page.Size
+}
+
+// Cursor is a position in a feed read newest first: the publication time
+// and id of the last post read. The next page starts right after it.
+type Cursor struct {
+	PublishedAt time.Time
+	PostID      uint
+}
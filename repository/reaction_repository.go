@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReactionRepo struct {
+	gormRepository
+}
+
+func NewReactionRepository(db *gorm.DB) ReactionRepository {
+	return &ReactionRepo{gormRepository{db}}
+}
+
+func (repo *ReactionRepo) MigrateReaction(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormReaction{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateReaction inserts with ON CONFLICT DO NOTHING, since a failed insert
+// would abort the surrounding transaction.
+func (repo *ReactionRepo) CreateReaction(ctx context.Context, reaction models.GormReaction) (bool, error) {
+	reaction.User, reaction.Post, reaction.Comment = nil, nil, nil
+	createRes := repo.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+	if err := createRes.Error; err != nil {
+		return false, repo.translateError(err)
+	}
+
+	return createRes.RowsAffected > 0, nil
+}
+
+func (repo *ReactionRepo) DeleteReaction(ctx context.Context, reaction models.GormReaction) (bool, error) {
+	query := repo.conn(ctx).Where("user_id = ? AND type = ?", reaction.UserID, reaction.Type)
+	if reaction.PostID != nil {
+		query = query.Where("post_id = ?", *reaction.PostID)
+	} else {
+		query = query.Where("comment_id = ?", *reaction.CommentID)
+	}
+
+	deleteRes := query.Delete(&models.GormReaction{})
+	if err := deleteRes.Error; err != nil {
+		return false, repo.translateError(err)
+	}
+
+	return deleteRes.RowsAffected > 0, nil
+}
+
+// DeleteReactionsByUserID removes every reaction of a user and returns them,
+// so the counters they were counted in can be brought down.
+func (repo *ReactionRepo) DeleteReactionsByUserID(ctx context.Context, userID uint) ([]models.GormReaction, error) {
+	var deleted []models.GormReaction
+	if err := repo.conn(ctx).Clauses(clause.Returning{}).Where("user_id = ?", userID).Delete(&deleted).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return deleted, nil
+}
+
+// AdjustReactionCount updates the counter in place, so concurrent reactions
+// serialise on the row lock instead of overwriting each other. A counter
+// that drops to zero is removed.
+func (repo *ReactionRepo) AdjustReactionCount(ctx context.Context, reaction models.GormReaction, delta int) error {
+	table, id := "gorm_posts", reaction.PostID
+	if reaction.CommentID != nil {
+		table, id = "gorm_comments", reaction.CommentID
+	}
+
+	err := repo.conn(ctx).Exec(`UPDATE `+table+` SET reaction_counts = CASE
+			WHEN COALESCE((reaction_counts->>CAST(@type AS text))::int, 0) + @delta > 0
+			THEN reaction_counts || jsonb_build_object(CAST(@type AS text), COALESCE((reaction_counts->>CAST(@type AS text))::int, 0) + @delta)
+			ELSE reaction_counts - CAST(@type AS text)
+		END
+		WHERE id = @id`,
+		map[string]interface{}{"type": reaction.Type, "delta": delta, "id": *id},
+	).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+	return nil
+}
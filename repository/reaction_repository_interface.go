@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// ReactionRepository stores the reactions readers leave on posts and
+// comments, and the counters kept on those.
+type ReactionRepository interface {
+	Transactor
+	MigrateReaction(ctx context.Context) error
+	// CreateReaction reports false, rather than ErrDuplicate, when the user
+	// already left a reaction of that type on the same post or comment.
+	CreateReaction(ctx context.Context, reaction models.GormReaction) (bool, error)
+	// DeleteReaction removes the reaction matching the user, target and type
+	// of reaction, and reports false when there was none.
+	DeleteReaction(ctx context.Context, reaction models.GormReaction) (bool, error)
+	DeleteReactionsByUserID(ctx context.Context, userID uint) ([]models.GormReaction, error)
+	// AdjustReactionCount adds delta to the counter of the reaction's type
+	// on its post or comment, trashed or not.
+	AdjustReactionCount(ctx context.Context, reaction models.GormReaction, delta int) error
+}
@@ -0,0 +1,1209 @@
+// Package repositorytest holds the behaviour every repository backend must
+// share. Each backend runs the same suites against a fresh, empty store:
+//
+//	repositorytest.TestUserRepository(t, func() repository.UserRepository {
+//		return repository.NewInMemoryUserRepository()
+//	})
+//
+// The in-memory backend runs every suite on each go test; the GORM one runs
+// them against the database named by TEST_DATABASE_URL, and is skipped
+// without it.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// Repositories is the full set the suites of repositories referencing users
+// and posts need.
+type Repositories struct {
+	Users         repository.UserRepository
+	Posts         repository.PostRepository
+	Comments      repository.CommentRepository
+	Media         repository.MediaRepository
+	Reactions     repository.ReactionRepository
+	Bookmarks     repository.BookmarkRepository
+	Follows       repository.FollowRepository
+	Views         repository.ViewRepository
+	Notifications repository.NotificationRepository
+	Webhooks      repository.WebhookRepository
+	CommentEvents repository.CommentEventRepository
+}
+
+func TestUserRepository(t *testing.T, newRepo func() repository.UserRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo()
+		created, err := repo.CreateUser(ctx, models.GormUser{Name: "Ann", Email: "ann@example.com", Username: "ann", Password: "secret"})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if created.ID == 0 {
+			t.Fatal("CreateUser did not assign an ID")
+		}
+
+		got, err := repo.GetUserByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if got.Email != "ann@example.com" {
+			t.Errorf("GetUserByID email = %q, want %q", got.Email, "ann@example.com")
+		}
+
+		if _, err := repo.GetUserByEmail(ctx, "ann@example.com"); err != nil {
+			t.Errorf("GetUserByEmail: %v", err)
+		}
+		if _, err := repo.GetUserByUsernameAndPassword(ctx, "ann", "secret"); err != nil {
+			t.Errorf("GetUserByUsernameAndPassword: %v", err)
+		}
+		if _, err := repo.GetUserByUsernameAndPassword(ctx, "ann", "wrong"); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("GetUserByUsernameAndPassword with wrong password err = %v, want ErrNotExist", err)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		repo := newRepo()
+		if _, err := repo.CreateUser(ctx, models.GormUser{Email: "dup@example.com", Username: "dup"}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if _, err := repo.CreateUser(ctx, models.GormUser{Email: "dup@example.com", Username: "other"}); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("CreateUser with duplicate email err = %v, want ErrDuplicate", err)
+		}
+		if _, err := repo.CreateUser(ctx, models.GormUser{Email: "other@example.com", Username: "dup"}); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("CreateUser with duplicate username err = %v, want ErrDuplicate", err)
+		}
+	})
+
+	t.Run("NotExist", func(t *testing.T) {
+		repo := newRepo()
+		if _, err := repo.GetUserByID(ctx, 999999); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("GetUserByID err = %v, want ErrNotExist", err)
+		}
+		if _, err := repo.GetUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("GetUserByEmail err = %v, want ErrNotExist", err)
+		}
+	})
+
+	t.Run("UpdateAndDelete", func(t *testing.T) {
+		repo := newRepo()
+		created, err := repo.CreateUser(ctx, models.GormUser{Email: "old@example.com", Username: "old"})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		updated := *created
+		updated.Email = "new@example.com"
+		if _, err := repo.UpdateUser(ctx, created.ID, updated); err != nil {
+			t.Fatalf("UpdateUser: %v", err)
+		}
+		got, err := repo.GetUserByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if got.Email != "new@example.com" {
+			t.Errorf("email after update = %q, want %q", got.Email, "new@example.com")
+		}
+
+		if err := repo.DeleteUser(ctx, created.ID); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		if _, err := repo.GetUserByID(ctx, created.ID); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("GetUserByID after delete err = %v, want ErrNotExist", err)
+		}
+		if err := repo.DeleteUser(ctx, created.ID); !errors.Is(err, repository.ErrDeleteFailed) {
+			t.Errorf("second DeleteUser err = %v, want ErrDeleteFailed", err)
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		repo := newRepo()
+		created, err := repo.CreateUser(ctx, models.GormUser{Email: "trash@example.com", Username: "trash"})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if err := repo.DeleteUser(ctx, created.ID); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+
+		// a deleted user no longer holds its email or username
+		taken, err := repo.CreateUser(ctx, models.GormUser{Email: "trash@example.com", Username: "trash", Password: "secret"})
+		if err != nil {
+			t.Fatalf("CreateUser reusing a deleted email: %v", err)
+		}
+		if _, err := repo.RestoreUser(ctx, created.ID); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("RestoreUser over a live duplicate err = %v, want ErrDuplicate", err)
+		}
+
+		if err := repo.DeleteUser(ctx, taken.ID); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		expired, err := repo.ExpiredUserIDs(ctx, time.Now().Add(time.Minute), 0, 10)
+		if err != nil || len(expired) != 2 || expired[0] != created.ID || expired[1] != taken.ID {
+			t.Errorf("ExpiredUserIDs = %v, %v, want [%d %d]", expired, err, created.ID, taken.ID)
+		}
+		if expired, err := repo.ExpiredUserIDs(ctx, time.Now().Add(time.Minute), created.ID, 10); err != nil || len(expired) != 1 || expired[0] != taken.ID {
+			t.Errorf("ExpiredUserIDs after %d = %v, %v, want [%d]", created.ID, expired, err, taken.ID)
+		}
+		if expired, err := repo.ExpiredUserIDs(ctx, time.Now().Add(-time.Hour), 0, 10); err != nil || len(expired) != 0 {
+			t.Errorf("ExpiredUserIDs an hour ago = %v, %v, want none", expired, err)
+		}
+		listed, total, err := repo.DeletedUsers(ctx, repository.Page{Number: 1, Size: 1})
+		if err != nil {
+			t.Fatalf("DeletedUsers: %v", err)
+		}
+		if total != 2 || len(listed) != 1 || listed[0].ID != taken.ID {
+			t.Errorf("DeletedUsers first page = %d users of %d, want user %d of 2", len(listed), total, taken.ID)
+		} else {
+			checkNoPassword(t, "DeletedUsers", &listed[0])
+		}
+		if err := repo.PurgeUser(ctx, taken.ID); err != nil {
+			t.Fatalf("PurgeUser: %v", err)
+		}
+		restored, err := repo.RestoreUser(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("RestoreUser: %v", err)
+		}
+		if restored.DeletedAt.Valid {
+			t.Error("RestoreUser left the user deleted")
+		}
+
+		deleted, total, err := repo.DeletedUsers(ctx, repository.Page{Number: 1, Size: 10})
+		if err != nil {
+			t.Fatalf("DeletedUsers: %v", err)
+		}
+		if len(deleted) != 0 || total != 0 {
+			t.Errorf("DeletedUsers returned %d users of %d, want 0", len(deleted), total)
+		}
+		if err := repo.PurgeUser(ctx, created.ID); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("PurgeUser on a live user err = %v, want ErrNotExist", err)
+		}
+	})
+
+	t.Run("PasswordResets", func(t *testing.T) {
+		repo := newRepo()
+		user := mustCreateUser(t, repo, "ann")
+		now := time.Now()
+		for _, reset := range []models.GormPasswordReset{
+			{TokenHash: "first", UserID: user.ID, ExpiresAt: now.Add(time.Hour)},
+			{TokenHash: "second", UserID: user.ID, ExpiresAt: now.Add(time.Hour)},
+			{TokenHash: "stale", UserID: user.ID, ExpiresAt: now.Add(time.Minute)},
+		} {
+			if err := repo.CreatePasswordReset(ctx, reset); err != nil {
+				t.Fatalf("CreatePasswordReset %s: %v", reset.TokenHash, err)
+			}
+		}
+
+		if _, err := repo.TakePasswordReset(ctx, "stale", now.Add(2*time.Minute)); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("TakePasswordReset of an expired reset err = %v, want ErrNotExist", err)
+		}
+		taken, err := repo.TakePasswordReset(ctx, "first", now)
+		if err != nil || taken.UserID != user.ID {
+			t.Fatalf("TakePasswordReset = %+v, %v, want the reset of the user", taken, err)
+		}
+		for _, tokenHash := range []string{"first", "second"} {
+			if _, err := repo.TakePasswordReset(ctx, tokenHash, now); !errors.Is(err, repository.ErrNotExist) {
+				t.Errorf("TakePasswordReset %s after one was taken err = %v, want ErrNotExist", tokenHash, err)
+			}
+		}
+	})
+}
+
+func TestPostRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "author")
+		created, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Hello", Content: "World"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+
+		got, err := repos.Posts.GetPostByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetPostByID: %v", err)
+		}
+		if got.User == nil || got.User.ID != user.ID {
+			t.Errorf("GetPostByID did not load the author")
+		}
+		checkNoPassword(t, "GetPostByID", got.User)
+		found, err := repos.Posts.FindPost(ctx, created.ID, repository.Query{Include: []string{"user"}})
+		if err != nil || found.User == nil {
+			t.Fatalf("FindPost including the user = %+v, %v", found, err)
+		}
+		checkNoPassword(t, "FindPost", found.User)
+		updated, err := repos.Posts.UpdatePost(ctx, created.ID, models.GormPost{UserID: user.ID, Version: created.Version, Title: "Hello", Content: "Again"})
+		if err != nil {
+			t.Fatalf("UpdatePost: %v", err)
+		}
+		checkNoPassword(t, "UpdatePost", updated.User)
+		if _, err := repos.Posts.GetPostByTitle(ctx, "Hello"); err != nil {
+			t.Errorf("GetPostByTitle: %v", err)
+		}
+
+		posts, err := repos.Posts.GetPostByUserID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetPostByUserID: %v", err)
+		}
+		if len(posts) != 1 {
+			t.Errorf("GetPostByUserID returned %d posts, want 1", len(posts))
+		}
+	})
+
+	t.Run("NotExist", func(t *testing.T) {
+		repos := newRepos()
+		if _, err := repos.Posts.GetPostByID(ctx, 999999); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("GetPostByID err = %v, want ErrNotExist", err)
+		}
+		if _, err := repos.Posts.GetPostByTitle(ctx, "missing"); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("GetPostByTitle err = %v, want ErrNotExist", err)
+		}
+		if err := repos.Posts.DeletePost(ctx, 999999); !errors.Is(err, repository.ErrDeleteFailed) {
+			t.Errorf("DeletePost err = %v, want ErrDeleteFailed", err)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "repeater")
+		created, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Twice"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		if _, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Twice"}); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("CreatePost with a taken title err = %v, want ErrDuplicate", err)
+		}
+
+		// a deleted post no longer holds its title
+		if err := repos.Posts.DeletePost(ctx, created.ID); err != nil {
+			t.Fatalf("DeletePost: %v", err)
+		}
+		if _, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Twice"}); err != nil {
+			t.Fatalf("CreatePost reusing a deleted title: %v", err)
+		}
+		if _, err := repos.Posts.RestorePost(ctx, created.ID); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("RestorePost over a live duplicate err = %v, want ErrDuplicate", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "deleter")
+		created, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Gone"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		if err := repos.Posts.DeletePost(ctx, created.ID); err != nil {
+			t.Fatalf("DeletePost: %v", err)
+		}
+		posts, err := repos.Posts.AllPosts(ctx, repository.Query{})
+		if err != nil {
+			t.Fatalf("AllPosts: %v", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("AllPosts after delete returned %d posts, want 0", len(posts))
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "narrow")
+		created, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Narrow", Content: "Left out"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		if _, err := repos.Comments.CreateComment(ctx, models.GormComment{UserID: user.ID, PostID: created.ID, Content: "First"}); err != nil {
+			t.Fatalf("CreateComment: %v", err)
+		}
+
+		got, err := repos.Posts.FindPost(ctx, created.ID, repository.Query{
+			Fields: map[string][]string{
+				"posts":    {"id", "user_id", "title"},
+				"comments": {"id", "post_id"},
+			},
+			Include: []string{"comments"},
+		})
+		if err != nil {
+			t.Fatalf("FindPost: %v", err)
+		}
+		if got.Title != "Narrow" || got.Content != "" {
+			t.Errorf("FindPost read title %q and content %q, want only the title", got.Title, got.Content)
+		}
+		if got.User != nil {
+			t.Errorf("FindPost loaded the author, which was not included")
+		}
+		if len(got.Comments) != 1 || got.Comments[0].Content != "" {
+			t.Errorf("FindPost loaded %d comments, want 1 without its content", len(got.Comments))
+		}
+
+		posts, err := repos.Posts.AllPosts(ctx, repository.Query{Include: []string{}})
+		if err != nil {
+			t.Fatalf("AllPosts: %v", err)
+		}
+		if len(posts) != 1 || posts[0].User != nil || posts[0].Content != "Left out" {
+			t.Errorf("AllPosts without includes did not read every column and no relation")
+		}
+	})
+	t.Run("PublishedPosts", func(t *testing.T) {
+		repos := newRepos()
+		ann := mustCreateUser(t, repos.Users, "ann")
+		bob := mustCreateUser(t, repos.Users, "bob")
+		published := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+		create := func(user uint, title string, isPublished bool, offset time.Duration, tags ...string) *models.GormPost {
+			t.Helper()
+			post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user, Title: title, IsPublished: isPublished, PublishedAt: published.Add(offset), Tags: tags})
+			if err != nil {
+				t.Fatalf("CreatePost: %v", err)
+			}
+			return post
+		}
+		older := create(ann.ID, "Older", true, 0, "go", "feeds")
+		newer := create(bob.ID, "Newer", true, time.Minute, "go")
+		create(ann.ID, "Draft", false, 2*time.Minute, "go")
+		trashed := create(ann.ID, "Trashed", true, 3*time.Minute, "go")
+		if err := repos.Posts.DeletePost(ctx, trashed.ID); err != nil {
+			t.Fatalf("DeletePost: %v", err)
+		}
+
+		titles := func(userID uint, tag string, limit int) []string {
+			t.Helper()
+			posts, err := repos.Posts.PublishedPosts(ctx, userID, tag, limit)
+			if err != nil {
+				t.Fatalf("PublishedPosts: %v", err)
+			}
+			names := []string{}
+			for _, post := range posts {
+				if post.User == nil || post.User.ID != post.UserID {
+					t.Errorf("PublishedPosts did not load the author of %q", post.Title)
+				}
+				checkNoPassword(t, "PublishedPosts", post.User)
+				names = append(names, post.Title)
+			}
+			return names
+		}
+
+		want := func(got []string, want ...string) {
+			t.Helper()
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("PublishedPosts = %v, want %v", got, want)
+			}
+		}
+		want(titles(0, "", 10), newer.Title, older.Title)
+		want(titles(0, "", 1), newer.Title)
+		want(titles(ann.ID, "", 10), older.Title)
+		want(titles(0, "feeds", 10), older.Title)
+		want(titles(bob.ID, "feeds", 10))
+		want(titles(0, "fee", 10))
+
+		got, err := repos.Posts.GetPostByID(ctx, older.ID)
+		if err != nil {
+			t.Fatalf("GetPostByID: %v", err)
+		}
+		if strings.Join(got.Tags, ",") != "go,feeds" {
+			t.Errorf("GetPostByID tags = %v, want [go feeds]", got.Tags)
+		}
+	})
+	t.Run("Sitemap", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "mapped")
+		ids := []uint{}
+		for i := 0; i < 5; i++ {
+			post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: fmt.Sprintf("Mapped %d", i), IsPublished: i != 1, PublishedAt: time.Now()})
+			if err != nil {
+				t.Fatalf("CreatePost: %v", err)
+			}
+			if i != 1 {
+				ids = append(ids, post.ID)
+			}
+		}
+		if err := repos.Posts.DeletePost(ctx, ids[3]); err != nil {
+			t.Fatalf("DeletePost: %v", err)
+		}
+		ids = ids[:3]
+
+		parts, err := repos.Posts.SitemapParts(ctx, 2)
+		if err != nil {
+			t.Fatalf("SitemapParts: %v", err)
+		}
+		if len(parts) != 2 || parts[0].Number != 1 || parts[0].URLs != 2 || parts[1].Number != 2 || parts[1].URLs != 1 {
+			t.Fatalf("SitemapParts = %+v, want parts 1 and 2 of 2 and 1 posts", parts)
+		}
+		if parts[0].LastModified.IsZero() {
+			t.Errorf("SitemapParts did not report when part 1 last changed")
+		}
+
+		scan := func(offset, limit int) []uint {
+			t.Helper()
+			scanned := []uint{}
+			err := repos.Posts.ScanPublishedPosts(ctx, offset, limit, func(post models.GormPost) error {
+				if post.UpdatedAt.IsZero() {
+					t.Errorf("ScanPublishedPosts did not read the update time of post %d", post.ID)
+				}
+				scanned = append(scanned, post.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ScanPublishedPosts: %v", err)
+			}
+			return scanned
+		}
+		if got := scan(0, 2); fmt.Sprint(got) != fmt.Sprint(ids[:2]) {
+			t.Errorf("ScanPublishedPosts(0, 2) = %v, want %v", got, ids[:2])
+		}
+		if got := scan(2, 2); fmt.Sprint(got) != fmt.Sprint(ids[2:]) {
+			t.Errorf("ScanPublishedPosts(2, 2) = %v, want %v", got, ids[2:])
+		}
+		if got := scan(4, 2); len(got) != 0 {
+			t.Errorf("ScanPublishedPosts past the end = %v, want none", got)
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = repos.Posts.ScanPublishedPosts(ctx, 0, 10, func(models.GormPost) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("ScanPublishedPosts after fn failed err = %v after %d calls, want stop after 1", err, calls)
+		}
+	})
+}
+
+func TestCommentRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "commenter")
+		post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Commented"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+
+		created, err := repos.Comments.CreateComment(ctx, models.GormComment{UserID: user.ID, PostID: post.ID, Content: "Nice"})
+		if err != nil {
+			t.Fatalf("CreateComment: %v", err)
+		}
+
+		got, err := repos.Comments.GetCommentByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetCommentByID: %v", err)
+		}
+		if got.User == nil || got.Post == nil {
+			t.Errorf("GetCommentByID did not load the user and post")
+		}
+		checkNoPassword(t, "GetCommentByID", got.User)
+		updated, err := repos.Comments.PatchComment(ctx, created.ID, created.Version, map[string]interface{}{"content": "Nicer"})
+		if err != nil {
+			t.Fatalf("PatchComment: %v", err)
+		}
+		checkNoPassword(t, "PatchComment", updated.User)
+		if _, err := repos.Comments.GetCommentByUserIDPostID(ctx, user.ID, post.ID); err != nil {
+			t.Errorf("GetCommentByUserIDPostID: %v", err)
+		}
+
+		comments, err := repos.Comments.GetCommentByPostID(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("GetCommentByPostID: %v", err)
+		}
+		if len(comments) != 1 {
+			t.Errorf("GetCommentByPostID returned %d comments, want 1", len(comments))
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "repeater")
+		post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Commented twice"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		created, err := repos.Comments.CreateComment(ctx, models.GormComment{UserID: user.ID, PostID: post.ID, Content: "First"})
+		if err != nil {
+			t.Fatalf("CreateComment: %v", err)
+		}
+		if _, err := repos.Comments.CreateComment(ctx, models.GormComment{UserID: user.ID, PostID: post.ID, Content: "Second"}); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("CreateComment on an already commented post err = %v, want ErrDuplicate", err)
+		}
+
+		// a deleted comment no longer holds the user's place on the post
+		if err := repos.Comments.DeleteComment(ctx, created.ID); err != nil {
+			t.Fatalf("DeleteComment: %v", err)
+		}
+		if _, err := repos.Comments.CreateComment(ctx, models.GormComment{UserID: user.ID, PostID: post.ID, Content: "Again"}); err != nil {
+			t.Fatalf("CreateComment after the first was deleted: %v", err)
+		}
+		if _, err := repos.Comments.RestoreComment(ctx, created.ID); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("RestoreComment over a live duplicate err = %v, want ErrDuplicate", err)
+		}
+	})
+
+	t.Run("NotExist", func(t *testing.T) {
+		repos := newRepos()
+		if _, err := repos.Comments.GetCommentByID(ctx, 999999); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("GetCommentByID err = %v, want ErrNotExist", err)
+		}
+		if err := repos.Comments.DeleteComment(ctx, 999999); !errors.Is(err, repository.ErrDeleteFailed) {
+			t.Errorf("DeleteComment err = %v, want ErrDeleteFailed", err)
+		}
+	})
+}
+
+func TestMediaRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("CreateListDelete", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "photographer")
+		post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Pictured"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+
+		for _, width := range []int{768, 320} {
+			variant := models.GormPostMedia{PostID: post.ID, SourceKey: "a.jpg", Width: width, Height: width / 2, Key: "a-variant.jpg"}
+			if _, err := repos.Media.CreateMedia(ctx, variant); err != nil {
+				t.Fatalf("CreateMedia: %v", err)
+			}
+		}
+		duplicate := models.GormPostMedia{PostID: post.ID, SourceKey: "a.jpg", Width: 320, Height: 160, Key: "again.jpg"}
+		if _, err := repos.Media.CreateMedia(ctx, duplicate); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("CreateMedia with a taken width err = %v, want ErrDuplicate", err)
+		}
+
+		media, err := repos.Media.MediaByPostIDs(ctx, []uint{post.ID})
+		if err != nil {
+			t.Fatalf("MediaByPostIDs: %v", err)
+		}
+		if len(media) != 2 || media[0].Width != 320 {
+			t.Errorf("MediaByPostIDs = %+v, want two variants narrowest first", media)
+		}
+
+		deleted, err := repos.Media.DeleteMediaBySourceKey(ctx, "a.jpg")
+		if err != nil {
+			t.Fatalf("DeleteMediaBySourceKey: %v", err)
+		}
+		if len(deleted) != 2 {
+			t.Errorf("DeleteMediaBySourceKey returned %d variants, want 2", len(deleted))
+		}
+		if media, _ := repos.Media.MediaByPostIDs(ctx, []uint{post.ID}); len(media) != 0 {
+			t.Errorf("MediaByPostIDs after delete returned %d variants, want 0", len(media))
+		}
+	})
+}
+
+func TestReactionRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("ToggleAndCount", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "reader")
+		post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: "Liked"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		like := models.GormReaction{UserID: user.ID, PostID: &post.ID, Type: "like"}
+
+		for i, want := range []bool{true, false} {
+			created, err := repos.Reactions.CreateReaction(ctx, like)
+			if err != nil {
+				t.Fatalf("CreateReaction: %v", err)
+			}
+			if created != want {
+				t.Errorf("CreateReaction #%d created = %v, want %v", i+1, created, want)
+			}
+		}
+		if err := repos.Reactions.AdjustReactionCount(ctx, like, 1); err != nil {
+			t.Fatalf("AdjustReactionCount: %v", err)
+		}
+		if got, _ := repos.Posts.GetPostByID(ctx, post.ID); got.ReactionCounts["like"] != 1 {
+			t.Errorf("ReactionCounts = %v, want one like", got.ReactionCounts)
+		}
+
+		for i, want := range []bool{true, false} {
+			deleted, err := repos.Reactions.DeleteReaction(ctx, like)
+			if err != nil {
+				t.Fatalf("DeleteReaction: %v", err)
+			}
+			if deleted != want {
+				t.Errorf("DeleteReaction #%d deleted = %v, want %v", i+1, deleted, want)
+			}
+		}
+		if err := repos.Reactions.AdjustReactionCount(ctx, like, -1); err != nil {
+			t.Fatalf("AdjustReactionCount: %v", err)
+		}
+		if got, _ := repos.Posts.GetPostByID(ctx, post.ID); len(got.ReactionCounts) != 0 {
+			t.Errorf("ReactionCounts = %v, want none", got.ReactionCounts)
+		}
+	})
+
+	t.Run("ForeignKey", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "reader")
+		missing := uint(999)
+		_, err := repos.Reactions.CreateReaction(ctx, models.GormReaction{UserID: user.ID, PostID: &missing, Type: "like"})
+		if !errors.Is(err, repository.ErrForeignKey) {
+			t.Errorf("CreateReaction on a missing post err = %v, want ErrForeignKey", err)
+		}
+	})
+}
+
+func TestBookmarkRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("ListsAndPages", func(t *testing.T) {
+		repos := newRepos()
+		user := mustCreateUser(t, repos.Users, "reader")
+		var postIDs []uint
+		for _, title := range []string{"First", "Second", "Third"} {
+			post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user.ID, Title: title})
+			if err != nil {
+				t.Fatalf("CreatePost: %v", err)
+			}
+			postIDs = append(postIDs, post.ID)
+		}
+
+		for i, postID := range postIDs {
+			list := ""
+			if i > 0 {
+				list = "later"
+			}
+			if _, created, err := repos.Bookmarks.CreateBookmark(ctx, models.GormBookmark{UserID: user.ID, PostID: postID, List: list}); err != nil || !created {
+				t.Fatalf("CreateBookmark created = %v, err = %v", created, err)
+			}
+		}
+		again, created, err := repos.Bookmarks.CreateBookmark(ctx, models.GormBookmark{UserID: user.ID, PostID: postIDs[0]})
+		if err != nil || created || again.PostID != postIDs[0] {
+			t.Errorf("CreateBookmark again = %+v, %v, %v, want the stored bookmark", again, created, err)
+		}
+
+		later := "later"
+		page, total, err := repos.Bookmarks.Bookmarks(ctx, user.ID, &later, repository.Page{Number: 1, Size: 1})
+		if err != nil {
+			t.Fatalf("Bookmarks: %v", err)
+		}
+		if total != 2 || len(page) != 1 || page[0].Post == nil {
+			t.Errorf("Bookmarks = %d of %d, want 1 of 2 with its post", len(page), total)
+		}
+
+		lists, err := repos.Bookmarks.BookmarkLists(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("BookmarkLists: %v", err)
+		}
+		if len(lists) != 2 || lists[0].Name != "" || lists[1].Count != 2 {
+			t.Errorf("BookmarkLists = %+v, want the unsorted list and two later", lists)
+		}
+
+		if deleted, err := repos.Bookmarks.DeleteBookmark(ctx, user.ID, postIDs[0], ""); err != nil || !deleted {
+			t.Errorf("DeleteBookmark deleted = %v, err = %v", deleted, err)
+		}
+		bookmarked, err := repos.Bookmarks.BookmarkedPostIDs(ctx, user.ID, postIDs)
+		if err != nil {
+			t.Fatalf("BookmarkedPostIDs: %v", err)
+		}
+		if bookmarked[postIDs[0]] || !bookmarked[postIDs[1]] || !bookmarked[postIDs[2]] {
+			t.Errorf("BookmarkedPostIDs = %v, want the second and third post", bookmarked)
+		}
+	})
+}
+
+func TestFollowRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("FollowAndFeed", func(t *testing.T) {
+		repos := newRepos()
+		reader := mustCreateUser(t, repos.Users, "reader")
+		author := mustCreateUser(t, repos.Users, "author")
+		published := time.Now().Add(-time.Hour)
+		post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Fanned", IsPublished: true, PublishedAt: published})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		if err := repos.Follows.FanOut(ctx, *post); err != nil {
+			t.Fatalf("FanOut: %v", err)
+		}
+		pulled, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Pulled", IsPublished: true, PublishedAt: published.Add(time.Minute)})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		if err := repos.Follows.SkipFanOut(ctx, pulled.ID); err != nil {
+			t.Fatalf("SkipFanOut: %v", err)
+		}
+
+		for i, want := range []bool{true, false} {
+			if _, created, err := repos.Follows.CreateFollow(ctx, reader.ID, author.ID); err != nil || created != want {
+				t.Errorf("CreateFollow #%d created = %v, err = %v, want %v", i+1, created, err, want)
+			}
+		}
+		if err := repos.Follows.Backfill(ctx, reader.ID, author.ID); err != nil {
+			t.Fatalf("Backfill: %v", err)
+		}
+
+		timeline, err := repos.Follows.Timeline(ctx, reader.ID, nil, 10)
+		if err != nil {
+			t.Fatalf("Timeline: %v", err)
+		}
+		if len(timeline) != 1 || timeline[0].ID != post.ID {
+			t.Errorf("Timeline = %+v, want the fanned out post", timeline)
+		} else {
+			checkNoPassword(t, "Timeline", timeline[0].User)
+		}
+		feed, err := repos.Follows.PulledPosts(ctx, reader.ID, nil, 10)
+		if err != nil {
+			t.Fatalf("PulledPosts: %v", err)
+		}
+		if len(feed) != 1 || feed[0].ID != pulled.ID {
+			t.Errorf("PulledPosts = %+v, want the post that was not fanned out", feed)
+		}
+		after := &repository.Cursor{PublishedAt: pulled.PublishedAt, PostID: pulled.ID}
+		if feed, _ := repos.Follows.PulledPosts(ctx, reader.ID, after, 10); len(feed) != 0 {
+			t.Errorf("PulledPosts after the last post = %+v, want none", feed)
+		}
+
+		followers, total, err := repos.Follows.Followers(ctx, author.ID, repository.Page{Number: 1, Size: 10})
+		if err != nil || total != 1 || len(followers) != 1 || followers[0].ID != reader.ID {
+			t.Errorf("Followers = %+v, %d, %v, want the reader", followers, total, err)
+		} else {
+			checkNoPassword(t, "Followers", &followers[0])
+		}
+
+		if deleted, err := repos.Follows.DeleteFollow(ctx, reader.ID, author.ID); err != nil || !deleted {
+			t.Errorf("DeleteFollow deleted = %v, err = %v", deleted, err)
+		}
+		if err := repos.Follows.ClearTimeline(ctx, reader.ID, author.ID); err != nil {
+			t.Fatalf("ClearTimeline: %v", err)
+		}
+		if timeline, _ := repos.Follows.Timeline(ctx, reader.ID, nil, 10); len(timeline) != 0 {
+			t.Errorf("Timeline after unfollowing = %+v, want none", timeline)
+		}
+	})
+}
+
+func TestViewRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("AddAndReport", func(t *testing.T) {
+		repos := newRepos()
+		ann := mustCreateUser(t, repos.Users, "viewed")
+		bob := mustCreateUser(t, repos.Users, "unread")
+		create := func(user uint, title string, isPublished bool) *models.GormPost {
+			t.Helper()
+			post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: user, Title: title, IsPublished: isPublished, PublishedAt: time.Now()})
+			if err != nil {
+				t.Fatalf("CreatePost: %v", err)
+			}
+			return post
+		}
+		first := create(ann.ID, "First", true)
+		second := create(bob.ID, "Second", true)
+		draft := create(ann.ID, "Draft", false)
+
+		// a Sunday and the Monday after, so days and weeks split differently
+		sunday := time.Date(2024, 3, 3, 10, 0, 0, 0, time.UTC)
+		monday := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+		err := repos.Views.AddViews(ctx, []models.GormPostView{
+			{PostID: first.ID, Hour: sunday, Views: 3},
+			{PostID: first.ID, Hour: monday, Views: 2},
+			{PostID: second.ID, Hour: monday, Views: 4},
+			{PostID: draft.ID, Hour: monday, Views: 7},
+		})
+		if err != nil {
+			t.Fatalf("AddViews: %v", err)
+		}
+		// adding to an hour already counted adds up
+		if err := repos.Views.AddViews(ctx, []models.GormPostView{{PostID: first.ID, Hour: monday, Views: 1}}); err != nil {
+			t.Fatalf("AddViews: %v", err)
+		}
+
+		filter := repository.ViewFilter{Since: time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC), Until: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)}
+		days, err := repos.Views.ViewBuckets(ctx, filter, "day")
+		if err != nil {
+			t.Fatalf("ViewBuckets: %v", err)
+		}
+		if fmt.Sprint(bucketViews(days)) != "[0 3 7]" || !days[0].Start.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("ViewBuckets by day = %+v, want 0, 3 and 7 views from March 2", days)
+		}
+		weeks, err := repos.Views.ViewBuckets(ctx, filter, "week")
+		if err != nil {
+			t.Fatalf("ViewBuckets: %v", err)
+		}
+		if fmt.Sprint(bucketViews(weeks)) != "[3 7]" || !weeks[1].Start.Equal(monday.Truncate(24*time.Hour)) {
+			t.Errorf("ViewBuckets by week = %+v, want 3 and 7 views, the second week from Monday", weeks)
+		}
+
+		filter.UserID = ann.ID
+		if months, _ := repos.Views.ViewBuckets(ctx, filter, "month"); fmt.Sprint(bucketViews(months)) != "[6]" {
+			t.Errorf("ViewBuckets of one author by month = %+v, want 6 views", months)
+		}
+		filter.UserID = 0
+
+		posts, err := repos.Views.TopPosts(ctx, filter, 10)
+		if err != nil {
+			t.Fatalf("TopPosts: %v", err)
+		}
+		if len(posts) != 2 || posts[0].PostID != first.ID || posts[0].Views != 6 || posts[0].Title != "First" || posts[1].Views != 4 {
+			t.Errorf("TopPosts = %+v, want First with 6 views, then Second with 4", posts)
+		}
+		if posts, _ := repos.Views.TopPosts(ctx, filter, 1); len(posts) != 1 {
+			t.Errorf("TopPosts with a limit of 1 returned %d posts", len(posts))
+		}
+
+		authors, err := repos.Views.TopAuthors(ctx, filter, 10)
+		if err != nil {
+			t.Fatalf("TopAuthors: %v", err)
+		}
+		if len(authors) != 2 || authors[0].UserID != ann.ID || authors[0].Views != 6 || authors[0].Posts != 1 || authors[1].UserID != bob.ID {
+			t.Errorf("TopAuthors = %+v, want ann with 6 views of 1 post, then bob", authors)
+		}
+
+		filter.Since = monday
+		filter.PostID = first.ID
+		if posts, _ := repos.Views.TopPosts(ctx, filter, 10); len(posts) != 1 || posts[0].Views != 3 {
+			t.Errorf("TopPosts of one post since Monday = %+v, want 3 views", posts)
+		}
+	})
+}
+
+func TestNotificationRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("ReadAndPreferences", func(t *testing.T) {
+		repos := newRepos()
+		author := mustCreateUser(t, repos.Users, "author")
+		reader := mustCreateUser(t, repos.Users, "reader")
+		post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Noticed"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+
+		err = repos.Notifications.CreateNotifications(ctx, []models.GormNotification{
+			{UserID: author.ID, ActorID: reader.ID, Type: "follow"},
+			{UserID: author.ID, ActorID: reader.ID, Type: "reaction", PostID: &post.ID, Reaction: "like"},
+			{UserID: reader.ID, ActorID: author.ID, Type: "follow"},
+		})
+		if err != nil {
+			t.Fatalf("CreateNotifications: %v", err)
+		}
+
+		page, total, err := repos.Notifications.Notifications(ctx, author.ID, false, repository.Page{Number: 1, Size: 1})
+		if err != nil {
+			t.Fatalf("Notifications: %v", err)
+		}
+		if total != 2 || len(page) != 1 || page[0].Type != "reaction" || page[0].ReadAt != nil {
+			t.Fatalf("Notifications = %+v of %d, want the unread reaction of 2", page, total)
+		}
+
+		if marked, err := repos.Notifications.MarkRead(ctx, author.ID, []uint{page[0].ID}); err != nil || marked != 1 {
+			t.Errorf("MarkRead = %d, %v, want 1", marked, err)
+		}
+		if marked, err := repos.Notifications.MarkRead(ctx, reader.ID, []uint{page[0].ID}); err != nil || marked != 0 {
+			t.Errorf("MarkRead of another user's = %d, %v, want 0", marked, err)
+		}
+		if unread, err := repos.Notifications.UnreadCount(ctx, author.ID); err != nil || unread != 1 {
+			t.Errorf("UnreadCount = %d, %v, want 1", unread, err)
+		}
+		if unread, _, err := repos.Notifications.Notifications(ctx, author.ID, true, repository.Page{Number: 1, Size: 10}); err != nil || len(unread) != 1 || unread[0].Type != "follow" {
+			t.Errorf("unread Notifications = %+v, %v, want the follow", unread, err)
+		}
+		if marked, err := repos.Notifications.MarkRead(ctx, author.ID, nil); err != nil || marked != 1 {
+			t.Errorf("MarkRead all = %d, %v, want 1", marked, err)
+		}
+		if unread, err := repos.Notifications.UnreadCount(ctx, reader.ID); err != nil || unread != 1 {
+			t.Errorf("UnreadCount of the reader = %d, %v, want 1", unread, err)
+		}
+
+		if err := repos.Notifications.SetNotificationPreferences(ctx, reader.ID, map[string]bool{"follow": false, "reply": true}); err != nil {
+			t.Fatalf("SetNotificationPreferences: %v", err)
+		}
+		if err := repos.Notifications.SetNotificationPreferences(ctx, reader.ID, map[string]bool{"reply": false}); err != nil {
+			t.Fatalf("SetNotificationPreferences again: %v", err)
+		}
+		preferences, err := repos.Notifications.NotificationPreferences(ctx, reader.ID)
+		if err != nil {
+			t.Fatalf("NotificationPreferences: %v", err)
+		}
+		if len(preferences) != 2 || preferences["follow"] || preferences["reply"] {
+			t.Errorf("NotificationPreferences = %v, want follow and reply off", preferences)
+		}
+		muted, err := repos.Notifications.MutedUserIDs(ctx, "follow", []uint{author.ID, reader.ID})
+		if err != nil {
+			t.Fatalf("MutedUserIDs: %v", err)
+		}
+		if muted[author.ID] || !muted[reader.ID] {
+			t.Errorf("MutedUserIDs = %v, want only the reader", muted)
+		}
+	})
+
+	t.Run("PurgeCascades", func(t *testing.T) {
+		repos := newRepos()
+		author := mustCreateUser(t, repos.Users, "author")
+		reader := mustCreateUser(t, repos.Users, "reader")
+		post, err := repos.Posts.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Purged"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		err = repos.Notifications.CreateNotifications(ctx, []models.GormNotification{
+			{UserID: author.ID, ActorID: reader.ID, Type: "reaction", PostID: &post.ID, Reaction: "like"},
+			{UserID: author.ID, ActorID: reader.ID, Type: "follow"},
+		})
+		if err != nil {
+			t.Fatalf("CreateNotifications: %v", err)
+		}
+
+		if err := repos.Posts.DeletePost(ctx, post.ID); err != nil {
+			t.Fatalf("DeletePost: %v", err)
+		}
+		if _, err := repos.Posts.PurgePost(ctx, post.ID); err != nil {
+			t.Fatalf("PurgePost: %v", err)
+		}
+		notifications, total, err := repos.Notifications.Notifications(ctx, author.ID, false, repository.Page{Number: 1, Size: 10})
+		if err != nil {
+			t.Fatalf("Notifications: %v", err)
+		}
+		if total != 1 || notifications[0].Type != "follow" {
+			t.Errorf("Notifications after PurgePost = %+v, want only the follow", notifications)
+		}
+	})
+}
+
+func TestWebhookRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("OutboxAndDeliveries", func(t *testing.T) {
+		repos := newRepos()
+		comments, err := repos.Webhooks.CreateWebhook(ctx, models.GormWebhook{URL: "https://example.com/comments", Secret: "s1", Events: models.EventTypes{"comment.created"}, Active: true})
+		if err != nil {
+			t.Fatalf("CreateWebhook: %v", err)
+		}
+		paused, err := repos.Webhooks.CreateWebhook(ctx, models.GormWebhook{URL: "https://example.com/paused", Secret: "s2", Events: models.EventTypes{"comment.created"}})
+		if err != nil {
+			t.Fatalf("CreateWebhook: %v", err)
+		}
+
+		for _, eventType := range []string{"comment.created", "post.published"} {
+			if err := repos.Webhooks.CreateWebhookEvent(ctx, models.GormWebhookEvent{Type: eventType, Data: `{"ID":1}`}); err != nil {
+				t.Fatalf("CreateWebhookEvent: %v", err)
+			}
+		}
+		events, err := repos.Webhooks.PendingWebhookEvents(ctx, 10)
+		if err != nil {
+			t.Fatalf("PendingWebhookEvents: %v", err)
+		}
+		if len(events) != 2 || events[0].Type != "comment.created" || events[0].Data != `{"ID":1}` {
+			t.Fatalf("PendingWebhookEvents = %+v, want both events, oldest first", events)
+		}
+
+		now := time.Now()
+		deliveries := []models.GormWebhookDelivery{
+			{WebhookID: comments.ID, EventID: events[0].ID, EventType: "comment.created", Status: models.DeliveryPending, NextAttemptAt: now},
+			{WebhookID: paused.ID, EventID: events[0].ID, EventType: "comment.created", Status: models.DeliveryPending, NextAttemptAt: now},
+		}
+		if err := repos.Webhooks.DispatchWebhookEvents(ctx, []uint{events[0].ID, events[1].ID}, deliveries); err != nil {
+			t.Fatalf("DispatchWebhookEvents: %v", err)
+		}
+		if err := repos.Webhooks.DispatchWebhookEvents(ctx, []uint{events[0].ID}, deliveries[:1]); err != nil {
+			t.Errorf("DispatchWebhookEvents again: %v", err)
+		}
+		if pending, err := repos.Webhooks.PendingWebhookEvents(ctx, 10); err != nil || len(pending) != 0 {
+			t.Errorf("PendingWebhookEvents after dispatch = %+v, %v, want none", pending, err)
+		}
+
+		claimed, err := repos.Webhooks.ClaimWebhookDeliveries(ctx, now.Add(time.Second), now.Add(time.Minute), 10)
+		if err != nil {
+			t.Fatalf("ClaimWebhookDeliveries: %v", err)
+		}
+		if len(claimed) != 1 || claimed[0].WebhookID != comments.ID || claimed[0].Webhook == nil || claimed[0].Webhook.Secret != "s1" || claimed[0].Event == nil || claimed[0].Event.Data != `{"ID":1}` {
+			t.Fatalf("ClaimWebhookDeliveries = %+v, want the delivery to the active webhook with it and its event", claimed)
+		}
+		if again, err := repos.Webhooks.ClaimWebhookDeliveries(ctx, now.Add(time.Second), now.Add(time.Minute), 10); err != nil || len(again) != 0 {
+			t.Errorf("ClaimWebhookDeliveries again = %+v, %v, want none while claimed", again, err)
+		}
+
+		attempted := now.Add(2 * time.Second)
+		delivery := claimed[0]
+		delivery.Status, delivery.Attempts, delivery.LastAttemptAt, delivery.ResponseStatus, delivery.Error = models.DeliveryDead, 3, &attempted, 500, "webhook responded 500"
+		if err := repos.Webhooks.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			t.Fatalf("UpdateWebhookDelivery: %v", err)
+		}
+		stored, err := repos.Webhooks.GetWebhookDelivery(ctx, delivery.ID)
+		if err != nil {
+			t.Fatalf("GetWebhookDelivery: %v", err)
+		}
+		if stored.Status != models.DeliveryDead || stored.Attempts != 3 || stored.ResponseStatus != 500 || stored.Error == "" || stored.LastAttemptAt == nil {
+			t.Errorf("GetWebhookDelivery = %+v, want the recorded attempt", stored)
+		}
+
+		log, total, err := repos.Webhooks.WebhookDeliveries(ctx, comments.ID, models.DeliveryDead, repository.Page{Number: 1, Size: 10})
+		if err != nil || total != 1 || len(log) != 1 || log[0].ID != delivery.ID {
+			t.Errorf("WebhookDeliveries dead = %+v of %d, %v, want the one delivery", log, total, err)
+		}
+		if log, total, err := repos.Webhooks.WebhookDeliveries(ctx, comments.ID, models.DeliveryPending, repository.Page{Number: 1, Size: 10}); err != nil || total != 0 || len(log) != 0 {
+			t.Errorf("WebhookDeliveries pending = %+v of %d, %v, want none", log, total, err)
+		}
+
+		paused.Active = true
+		if _, err := repos.Webhooks.UpdateWebhook(ctx, *paused); err != nil {
+			t.Fatalf("UpdateWebhook: %v", err)
+		}
+		if resumed, err := repos.Webhooks.ClaimWebhookDeliveries(ctx, now.Add(time.Second), now.Add(time.Minute), 10); err != nil || len(resumed) != 1 || resumed[0].WebhookID != paused.ID {
+			t.Errorf("ClaimWebhookDeliveries after turning on = %+v, %v, want the waiting delivery", resumed, err)
+		}
+
+		if err := repos.Webhooks.DeleteWebhook(ctx, comments.ID); err != nil {
+			t.Fatalf("DeleteWebhook: %v", err)
+		}
+		if _, err := repos.Webhooks.GetWebhookDelivery(ctx, delivery.ID); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("GetWebhookDelivery after DeleteWebhook err = %v, want ErrNotExist", err)
+		}
+		if err := repos.Webhooks.DeleteWebhook(ctx, comments.ID); !errors.Is(err, repository.ErrNotExist) {
+			t.Errorf("DeleteWebhook again err = %v, want ErrNotExist", err)
+		}
+		webhooks, err := repos.Webhooks.Webhooks(ctx)
+		if err != nil || len(webhooks) != 1 || webhooks[0].ID != paused.ID || !webhooks[0].Active {
+			t.Errorf("Webhooks = %+v, %v, want the one left, active", webhooks, err)
+		}
+	})
+}
+
+func TestCommentEventRepository(t *testing.T, newRepos func() Repositories) {
+	ctx := context.Background()
+
+	t.Run("LogAndPurge", func(t *testing.T) {
+		repos := newRepos()
+		for i, postID := range []uint{1, 2, 1, 1} {
+			event := models.GormCommentEvent{PostID: postID, CommentID: uint(i + 1), Type: "created", Data: fmt.Sprintf(`{"ID":%d}`, i+1)}
+			if err := repos.CommentEvents.CreateCommentEvent(ctx, event); err != nil {
+				t.Fatalf("CreateCommentEvent: %v", err)
+			}
+		}
+
+		events, err := repos.CommentEvents.CommentEventsAfter(ctx, 1, 0, 10)
+		if err != nil {
+			t.Fatalf("CommentEventsAfter: %v", err)
+		}
+		if len(events) != 3 || events[0].CommentID != 1 || events[1].CommentID != 3 || events[2].CommentID != 4 || events[0].Data != `{"ID":1}` {
+			t.Fatalf("CommentEventsAfter = %+v, want the three events of post 1, oldest first", events)
+		}
+		after, err := repos.CommentEvents.CommentEventsAfter(ctx, 1, events[0].ID, 1)
+		if err != nil || len(after) != 1 || after[0].ID != events[1].ID {
+			t.Errorf("CommentEventsAfter the first, limit 1 = %+v, %v, want the second", after, err)
+		}
+
+		if last, err := repos.CommentEvents.LastCommentEventID(ctx, 1); err != nil || last != events[2].ID {
+			t.Errorf("LastCommentEventID = %d, %v, want %d", last, err, events[2].ID)
+		}
+		if last, err := repos.CommentEvents.LastCommentEventID(ctx, 3); err != nil || last != 0 {
+			t.Errorf("LastCommentEventID without events = %d, %v, want 0", last, err)
+		}
+
+		if purged, err := repos.CommentEvents.PurgeCommentEventsBefore(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+			t.Errorf("PurgeCommentEventsBefore an hour ago = %d, %v, want 0", purged, err)
+		}
+		if purged, err := repos.CommentEvents.PurgeCommentEventsBefore(ctx, time.Now().Add(time.Hour)); err != nil || purged != 4 {
+			t.Errorf("PurgeCommentEventsBefore in an hour = %d, %v, want 4", purged, err)
+		}
+		if events, err := repos.CommentEvents.CommentEventsAfter(ctx, 1, 0, 10); err != nil || len(events) != 0 {
+			t.Errorf("CommentEventsAfter purge = %+v, %v, want none", events, err)
+		}
+	})
+
+	t.Run("ListenHearsCommits", func(t *testing.T) {
+		repos := newRepos()
+		listenCtx, cancel := context.WithCancel(ctx)
+		heard := make(chan uint, 100)
+		done := make(chan error, 1)
+		go func() {
+			done <- repos.CommentEvents.ListenCommentEvents(listenCtx, func(postID uint) { heard <- postID })
+		}()
+
+		// the listener starts in the background, so record events on post 1
+		// until it hears one
+		listening := false
+		for deadline := time.Now().Add(5 * time.Second); !listening && time.Now().Before(deadline); {
+			if err := repos.CommentEvents.CreateCommentEvent(ctx, models.GormCommentEvent{PostID: 1, CommentID: 1, Type: "created", Data: `{}`}); err != nil {
+				t.Fatalf("CreateCommentEvent: %v", err)
+			}
+			select {
+			case <-heard:
+				listening = true
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		if !listening {
+			t.Fatal("ListenCommentEvents heard nothing")
+		}
+
+		rollback := errors.New("rollback")
+		err := repos.CommentEvents.WithTx(ctx, func(ctx context.Context) error {
+			if err := repos.CommentEvents.CreateCommentEvent(ctx, models.GormCommentEvent{PostID: 2, CommentID: 2, Type: "created", Data: `{}`}); err != nil {
+				return err
+			}
+			return rollback
+		})
+		if !errors.Is(err, rollback) {
+			t.Fatalf("WithTx err = %v, want rollback", err)
+		}
+		if err := repos.CommentEvents.CreateCommentEvent(ctx, models.GormCommentEvent{PostID: 3, CommentID: 3, Type: "created", Data: `{}`}); err != nil {
+			t.Fatalf("CreateCommentEvent: %v", err)
+		}
+
+		// events of post 1 from the probe may still be arriving
+		for postID := uint(1); postID == 1; {
+			select {
+			case postID = <-heard:
+				if postID == 2 {
+					t.Errorf("ListenCommentEvents heard a rolled back event")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("ListenCommentEvents did not hear the committed event")
+			}
+		}
+		if events, err := repos.CommentEvents.CommentEventsAfter(ctx, 2, 0, 10); err != nil || len(events) != 0 {
+			t.Errorf("CommentEventsAfter a rollback = %+v, %v, want none", events, err)
+		}
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("ListenCommentEvents did not return once ctx was done")
+		}
+	})
+}
+
+// bucketViews lists the views of each bucket.
+func bucketViews(buckets []models.ViewBucket) []int64 {
+	views := []int64{}
+	for _, bucket := range buckets {
+		views = append(views, bucket.Views)
+	}
+	return views
+}
+
+func mustCreateUser(t *testing.T, repo repository.UserRepository, username string) *models.GormUser {
+	t.Helper()
+	user, err := repo.CreateUser(context.Background(), models.GormUser{Email: username + "@example.com", Username: username, Password: username + "-secret"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// checkNoPassword fails the test when a user read for someone else to see,
+// such as the author of a post, carries a password.
+func checkNoPassword(t *testing.T, what string, user *models.GormUser) {
+	t.Helper()
+	if user != nil && user.Password != "" {
+		t.Errorf("%s read the password of user %d", what, user.ID)
+	}
+}
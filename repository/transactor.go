@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Transactor runs fn as one unit of work. Repository calls made with the
+// context handed to fn join the same transaction, whichever repository they
+// go through; fn returning an error rolls everything back. Calling WithTx
+// again inside fn reuses the outer transaction.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+type afterCommitKey struct{}
+
+// AfterCommit runs fn once the transaction carried by ctx commits, and
+// never if it rolls back. Without a transaction fn runs right away. It is
+// for side effects outside the database, such as queueing mail, that must
+// not happen for writes that are undone.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+		return
+	}
+	fn()
+}
+
+// withAfterCommit runs fn with a place for AfterCommit hooks in its context
+// and runs them when fn, the whole transaction, succeeds.
+func withAfterCommit(ctx context.Context, fn func(ctx context.Context) error) error {
+	hooks := []func(){}
+	if err := fn(context.WithValue(ctx, afterCommitKey{}, &hooks)); err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		hook()
+	}
+	return nil
+}
+
+func NewTransactor(db *gorm.DB) Transactor {
+	return gormRepository{db}
+}
+
+func (repo gormRepository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	return withAfterCommit(ctx, func(ctx context.Context) error {
+		return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, tx))
+		})
+	})
+}
+
+// conn returns the transaction carried by ctx, or the plain connection when
+// there is none.
+func (repo gormRepository) conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return repo.db.WithContext(ctx)
+}
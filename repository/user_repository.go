@@ -2,111 +2,125 @@ package repository
 
 import (
 	"context"
-	"errors"
+	"time"
 
 	"github.com/bellaananda/go-postgresql-blog-http.git/models"
-	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type PostgreSQLGORMRepository struct {
-	db *gorm.DB
+type UserRepo struct {
+	gormRepository
 }
 
-func (repo *PostgreSQLGORMRepository) MigrateUser(ctx context.Context) error {
-	err := repo.db.WithContext(ctx).AutoMigrate(&models.GormUser{})
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &UserRepo{gormRepository{db}}
+}
+
+func (repo *UserRepo) MigrateUser(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormUser{}, &models.GormPasswordReset{})
 	if err != nil {
 		return err
 	}
-	return nil
-}
 
-func NewUserRepository(db *gorm.DB) UserRepository {
-	return &PostgreSQLGORMRepository{db}
+	if err := repo.createLiveUniqueIndex(ctx, "gorm_users", "idx_users_email", "email"); err != nil {
+		return err
+	}
+	return repo.createLiveUniqueIndex(ctx, "gorm_users", "idx_users_username", "username")
 }
 
-func (repo *PostgreSQLGORMRepository) CreateUser(ctx context.Context, user models.GormUser) (*models.GormUser, error) {
-	if err := repo.db.WithContext(ctx).Create(&user).Error; err != nil {
-		var pgxError *pgconn.PgError
-		if errors.As(err, &pgxError) {
-			if pgxError.Code == "23505" {
-				return nil, ErrDuplicate
-			}
-		}
-		return nil, err
+func (repo *UserRepo) CreateUser(ctx context.Context, user models.GormUser) (*models.GormUser, error) {
+	if err := repo.conn(ctx).Create(&user).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &user, nil
 }
 
-func (repo *PostgreSQLGORMRepository) AllUsers(ctx context.Context) ([]models.GormUser, error) {
+func (repo *UserRepo) AllUsers(ctx context.Context, query Query) ([]models.GormUser, error) {
 	var allUsers []models.GormUser
-	if err := repo.db.WithContext(ctx).Find(&allUsers).Error; err != nil {
-		return nil, err
+	if err := query.scope(repo.conn(ctx), "users").Find(&allUsers).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return allUsers, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetUserByID(ctx context.Context, id uint) (*models.GormUser, error) {
+func (repo *UserRepo) GetUserByID(ctx context.Context, id uint) (*models.GormUser, error) {
+	return repo.FindUser(ctx, id, Query{})
+}
+
+func (repo *UserRepo) FindUser(ctx context.Context, id uint, query Query) (*models.GormUser, error) {
 	var gormUser models.GormUser
-	if err := repo.db.WithContext(ctx).Where("id = ?", id).First(&gormUser).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := query.scope(repo.conn(ctx), "users").Where("id = ?", id).First(&gormUser).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &gormUser, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetUserByEmail(ctx context.Context, email string) (*models.GormUser, error) {
+func (repo *UserRepo) GetUserByEmail(ctx context.Context, email string) (*models.GormUser, error) {
 	var gormUser models.GormUser
-	if err := repo.db.WithContext(ctx).Where("email = ?", email).First(&gormUser).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := repo.conn(ctx).Where("email = ?", email).First(&gormUser).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &gormUser, nil
 }
 
-func (repo *PostgreSQLGORMRepository) GetUserByUsernameAndPassword(ctx context.Context, username, password string) (*models.GormUser, error) {
+func (repo *UserRepo) GetUserByUsernameAndPassword(ctx context.Context, username, password string) (*models.GormUser, error) {
 	var gormUser models.GormUser
-	if err := repo.db.WithContext(ctx).Where("username = ? AND password = ?", username, password).First(&gormUser).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotExist
-		}
-		return nil, err
+	if err := repo.conn(ctx).Where("username = ? AND password = ?", username, password).First(&gormUser).Error; err != nil {
+		return nil, repo.translateError(err)
 	}
 
 	return &gormUser, nil
 }
 
-func (repo *PostgreSQLGORMRepository) UpdateUser(ctx context.Context, id uint, updated models.GormUser) (*models.GormUser, error) {
-	updateRes := repo.db.WithContext(ctx).Where("id = ?", id).Save(&updated)
+func (repo *UserRepo) UpdateUser(ctx context.Context, id uint, updated models.GormUser) (*models.GormUser, error) {
+	// only write over the version the caller read, and bump it
+	expectedVersion := updated.Version
+	updated.ID = id
+	updated.Version = expectedVersion + 1
+
+	// RETURNING hands back the row as stored, created_at included
+	updateRes := repo.conn(ctx).Model(&updated).Clauses(clause.Returning{}).Where("version = ?", expectedVersion).Select("*").Omit("created_at").Updates(&updated)
 	if err := updateRes.Error; err != nil {
-		var pgxError *pgconn.PgError
-		if errors.As(err, &pgxError) {
-			if pgxError.Code == "23505" {
-				return nil, ErrDuplicate
-			}
-		}
-		return nil, err
+		return nil, repo.translateError(err)
 	}
 
 	rowsAffected := updateRes.RowsAffected
 	if rowsAffected == 0 {
-		return nil, ErrUpdateFailed
+		return nil, repo.updateFailure(ctx, &models.GormUser{}, id)
 	}
 	return &updated, nil
 }
 
-func (repo *PostgreSQLGORMRepository) DeleteUser(ctx context.Context, id uint) error {
-	res := repo.db.WithContext(ctx).Delete(&models.GormUser{}, id)
+// PatchUser writes only the given columns, provided the stored row is still
+// at version, and returns the row as RETURNING reports it.
+func (repo *UserRepo) PatchUser(ctx context.Context, id uint, version uint, columns map[string]interface{}) (*models.GormUser, error) {
+	assignments := make(map[string]interface{}, len(columns)+1)
+	for column, value := range columns {
+		assignments[column] = value
+	}
+	assignments["version"] = version + 1
+
+	var patched models.GormUser
+	updateRes := repo.conn(ctx).Model(&patched).Clauses(clause.Returning{}).Where("id = ? AND version = ?", id, version).Updates(assignments)
+	if err := updateRes.Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	if updateRes.RowsAffected == 0 {
+		return nil, repo.updateFailure(ctx, &models.GormUser{}, id)
+	}
+	return &patched, nil
+}
+
+func (repo *UserRepo) DeleteUser(ctx context.Context, id uint) error {
+	res := repo.conn(ctx).Delete(&models.GormUser{}, id)
 	if err := res.Error; err != nil {
-		return err
+		return repo.translateError(err)
 	}
 
 	rowsAffected := res.RowsAffected
@@ -116,3 +130,98 @@ func (repo *PostgreSQLGORMRepository) DeleteUser(ctx context.Context, id uint) e
 
 	return nil
 }
+
+// DeletedUsers lists the soft-deleted users, most recently deleted first.
+func (repo *UserRepo) DeletedUsers(ctx context.Context, page Page) ([]models.GormUser, int64, error) {
+	// a session of its own, so the count leaves the query as it was
+	query := repo.conn(ctx).Unscoped().Model(&models.GormUser{}).Where("deleted_at IS NOT NULL").Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	deleted := []models.GormUser{}
+	err := omitPassword(query).
+		Order("deleted_at DESC, id DESC").
+		Limit(page.Size).Offset(page.offset()).
+		Find(&deleted).Error
+	if err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	return deleted, total, nil
+}
+
+// RestoreUser takes a user out of the trash. It returns ErrNotExist when the
+// user is not in the trash.
+func (repo *UserRepo) RestoreUser(ctx context.Context, id uint) (*models.GormUser, error) {
+	var restored models.GormUser
+	res := repo.conn(ctx).Unscoped().Model(&restored).Clauses(clause.Returning{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if err := res.Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	if res.RowsAffected == 0 {
+		return nil, ErrNotExist
+	}
+	return &restored, nil
+}
+
+// PurgeUser permanently removes a user that is already in the trash.
+func (repo *UserRepo) PurgeUser(ctx context.Context, id uint) error {
+	res := repo.conn(ctx).Unscoped().Where("deleted_at IS NOT NULL").Delete(&models.GormUser{}, id)
+	if err := res.Error; err != nil {
+		return repo.translateError(err)
+	}
+
+	if res.RowsAffected == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (repo *UserRepo) ExpiredUserIDs(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := repo.conn(ctx).Unscoped().Model(&models.GormUser{}).
+		Where("deleted_at < ? AND id > ?", before, afterID).
+		Order("id").Limit(limit).Pluck("id", &ids).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return ids, nil
+}
+
+func (repo *UserRepo) CreatePasswordReset(ctx context.Context, reset models.GormPasswordReset) error {
+	if err := repo.conn(ctx).Where("expires_at <= ?", time.Now()).Delete(&models.GormPasswordReset{}).Error; err != nil {
+		return repo.translateError(err)
+	}
+
+	reset.User = nil
+	if err := repo.conn(ctx).Create(&reset).Error; err != nil {
+		return repo.translateError(err)
+	}
+	return nil
+}
+
+func (repo *UserRepo) TakePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*models.GormPasswordReset, error) {
+	var resets []models.GormPasswordReset
+	err := repo.conn(ctx).Clauses(clause.Returning{}).
+		Where("token_hash = ? AND expires_at > ?", tokenHash, now).
+		Delete(&resets).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+	if len(resets) == 0 {
+		return nil, ErrNotExist
+	}
+
+	// one reset used makes the others of the user moot
+	if err := repo.conn(ctx).Where("user_id = ?", resets[0].UserID).Delete(&models.GormPasswordReset{}).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+	return &resets[0], nil
+}
@@ -3,25 +3,45 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/bellaananda/go-postgresql-blog-http.git/models"
 )
 
 var (
-	ErrDuplicate    = errors.New("record already exists")
-	ErrNotExist     = errors.New("row does not exist")
-	ErrUpdateFailed = errors.New("update failed")
-	ErrDeleteFailed = errors.New("delete failed")
+	ErrDuplicate       = errors.New("record already exists")
+	ErrNotExist        = errors.New("row does not exist")
+	ErrUpdateFailed    = errors.New("update failed")
+	ErrDeleteFailed    = errors.New("delete failed")
+	ErrForeignKey      = errors.New("referenced record does not exist or is still referenced")
+	ErrVersionConflict = errors.New("record was modified since it was read")
 )
 
 // Repository provides access to the website storage.
 type UserRepository interface {
+	Transactor
 	MigrateUser(ctx context.Context) error
 	CreateUser(ctx context.Context, user models.GormUser) (*models.GormUser, error)
-	AllUsers(ctx context.Context) ([]models.GormUser, error)
+	AllUsers(ctx context.Context, query Query) ([]models.GormUser, error)
 	GetUserByID(ctx context.Context, id uint) (*models.GormUser, error)
+	FindUser(ctx context.Context, id uint, query Query) (*models.GormUser, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.GormUser, error)
 	GetUserByUsernameAndPassword(ctx context.Context, username string, password string) (*models.GormUser, error)
 	UpdateUser(ctx context.Context, id uint, updated models.GormUser) (*models.GormUser, error)
+	PatchUser(ctx context.Context, id uint, version uint, columns map[string]interface{}) (*models.GormUser, error)
 	DeleteUser(ctx context.Context, id uint) error
+	// DeletedUsers reads one page of the users in the trash, most recently
+	// deleted first, and how many there are in all.
+	DeletedUsers(ctx context.Context, page Page) ([]models.GormUser, int64, error)
+	RestoreUser(ctx context.Context, id uint) (*models.GormUser, error)
+	PurgeUser(ctx context.Context, id uint) error
+	// ExpiredUserIDs lists, in id order, the ids after afterID of up to limit
+	// users that went into the trash before before.
+	ExpiredUserIDs(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error)
+	// CreatePasswordReset stores a reset and clears the ones that expired.
+	CreatePasswordReset(ctx context.Context, reset models.GormPasswordReset) error
+	// TakePasswordReset removes the reset stored under tokenHash, unless it
+	// expired before now, along with every other reset of the same user.
+	// It returns ErrNotExist when there is no such reset to take.
+	TakePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*models.GormPasswordReset, error)
 }
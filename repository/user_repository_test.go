@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+func TestUserRepoStatements(t *testing.T) {
+	db, recorder, tables := newDryRun(t)
+	repo := NewUserRepository(db)
+	now := time.Now()
+
+	runDryRun(t, recorder, tables, []dryRunCase{
+		{"MigrateUser", func(ctx context.Context) error {
+			return repo.MigrateUser(ctx)
+		}, []string{
+			`"email" varchar(255) NOT NULL,`,
+			"ALTER TABLE gorm_users DROP CONSTRAINT IF EXISTS gorm_users_email_key",
+			"ALTER TABLE gorm_users DROP CONSTRAINT IF EXISTS idx_gorm_users_email",
+			`CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "gorm_users" ("email") WHERE deleted_at IS NULL`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_username" ON "gorm_users" ("username") WHERE deleted_at IS NULL`,
+		}},
+		{"CreateUser", func(ctx context.Context) error {
+			_, err := repo.CreateUser(ctx, models.GormUser{Email: "a@example.com", Username: "a"})
+			return err
+		}, []string{`INSERT INTO "gorm_users"`}},
+		{"AllUsers", func(ctx context.Context) error {
+			_, err := repo.AllUsers(ctx, Query{Fields: map[string][]string{"users": {"id", "username"}}})
+			return err
+		}, []string{`SELECT "id","username" FROM "gorm_users" WHERE "gorm_users"."deleted_at" IS NULL`}},
+		{"GetUserByID", func(ctx context.Context) error {
+			_, err := repo.GetUserByID(ctx, 1)
+			return err
+		}, []string{`FROM "gorm_users" WHERE id = 1 AND "gorm_users"."deleted_at" IS NULL`}},
+		{"GetUserByEmail", func(ctx context.Context) error {
+			_, err := repo.GetUserByEmail(ctx, "a@example.com")
+			return err
+		}, []string{`WHERE email = 'a@example.com' AND "gorm_users"."deleted_at" IS NULL`}},
+		{"GetUserByUsernameAndPassword", func(ctx context.Context) error {
+			_, err := repo.GetUserByUsernameAndPassword(ctx, "a", "pw")
+			return err
+		}, []string{`WHERE (username = 'a' AND password = 'pw') AND "gorm_users"."deleted_at" IS NULL`}},
+		{"UpdateUser", func(ctx context.Context) error {
+			_, err := repo.UpdateUser(ctx, 1, models.GormUser{Version: 3, Username: "b"})
+			return err
+		}, []string{`UPDATE "gorm_users" SET`, `"version"=4`, `WHERE version = 3`, `RETURNING *`, `SELECT count(*) FROM "gorm_users" WHERE id = 1`}},
+		{"PatchUser", func(ctx context.Context) error {
+			_, err := repo.PatchUser(ctx, 1, 3, map[string]interface{}{"name": "B"})
+			return err
+		}, []string{`UPDATE "gorm_users" SET`, `"version"=4`, `WHERE (id = 1 AND version = 3)`, `RETURNING *`}},
+		{"DeleteUser", func(ctx context.Context) error {
+			return repo.DeleteUser(ctx, 1)
+		}, []string{`UPDATE "gorm_users" SET "deleted_at"=`, `WHERE "gorm_users"."id" = 1 AND "gorm_users"."deleted_at" IS NULL`}},
+		{"DeletedUsers", func(ctx context.Context) error {
+			_, _, err := repo.DeletedUsers(ctx, Page{Number: 2, Size: 10})
+			return err
+		}, []string{`SELECT count(*) FROM "gorm_users" WHERE deleted_at IS NOT NULL`, `WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC LIMIT 10 OFFSET 10`}},
+		{"RestoreUser", func(ctx context.Context) error {
+			_, err := repo.RestoreUser(ctx, 1)
+			return err
+		}, []string{`UPDATE "gorm_users" SET "deleted_at"=NULL,"version"=version + 1`, `WHERE id = 1 AND deleted_at IS NOT NULL`}},
+		{"PurgeUser", func(ctx context.Context) error {
+			return repo.PurgeUser(ctx, 1)
+		}, []string{`DELETE FROM "gorm_users" WHERE deleted_at IS NOT NULL AND "gorm_users"."id" = 1`}},
+		{"ExpiredUserIDs", func(ctx context.Context) error {
+			_, err := repo.ExpiredUserIDs(ctx, now, 5, 100)
+			return err
+		}, []string{`SELECT "id" FROM "gorm_users" WHERE deleted_at < `, `AND id > 5 ORDER BY id LIMIT 100`}},
+		{"CreatePasswordReset", func(ctx context.Context) error {
+			return repo.CreatePasswordReset(ctx, models.GormPasswordReset{TokenHash: "h", UserID: 1, ExpiresAt: now})
+		}, []string{`DELETE FROM "gorm_password_resets" WHERE expires_at <=`, `INSERT INTO "gorm_password_resets"`}},
+		{"TakePasswordReset", func(ctx context.Context) error {
+			_, err := repo.TakePasswordReset(ctx, "h", now)
+			return err
+		}, []string{`DELETE FROM "gorm_password_resets" WHERE token_hash = 'h' AND expires_at >`, `RETURNING *`}},
+	})
+}
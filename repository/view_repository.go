@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+)
+
+type ViewRepo struct {
+	gormRepository
+}
+
+func NewViewRepository(db *gorm.DB) ViewRepository {
+	return &ViewRepo{gormRepository{db}}
+}
+
+func (repo *ViewRepo) MigrateView(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormPostView{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// viewBatchRow is a count in the JSON the batch travels in.
+type viewBatchRow struct {
+	PostID uint      `json:"post_id"`
+	Hour   time.Time `json:"hour"`
+	Views  int64     `json:"views"`
+}
+
+// AddViews sends the whole batch as one jsonb parameter. Rows are written
+// in key order, so flushes running side by side cannot deadlock.
+func (repo *ViewRepo) AddViews(ctx context.Context, views []models.GormPostView) error {
+	if len(views) == 0 {
+		return nil
+	}
+
+	rows := make([]viewBatchRow, 0, len(views))
+	for _, view := range views {
+		rows = append(rows, viewBatchRow{PostID: view.PostID, Hour: view.Hour, Views: view.Views})
+	}
+	batch, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+
+	err = repo.conn(ctx).Exec(`
+		INSERT INTO gorm_post_views (post_id, hour, views)
+		SELECT batch.post_id, batch.hour, sum(batch.views)
+		FROM jsonb_to_recordset(?::jsonb) AS batch(post_id bigint, hour timestamptz, views bigint)
+		JOIN gorm_posts ON gorm_posts.id = batch.post_id AND gorm_posts.is_published AND gorm_posts.deleted_at IS NULL
+		GROUP BY batch.post_id, batch.hour
+		ORDER BY batch.post_id, batch.hour
+		ON CONFLICT (post_id, hour) DO UPDATE SET views = gorm_post_views.views + EXCLUDED.views`, string(batch)).Error
+	if err != nil {
+		return repo.translateError(err)
+	}
+
+	return nil
+}
+
+// conditions narrows gorm_post_views, aliased v, down to filter.
+func (filter ViewFilter) conditions() (string, []interface{}) {
+	conditions := "v.hour >= ? AND v.hour < ?"
+	args := []interface{}{filter.Since, filter.Until}
+	if filter.PostID != 0 {
+		conditions += " AND v.post_id = ?"
+		args = append(args, filter.PostID)
+	}
+	if filter.UserID != 0 {
+		conditions += " AND v.post_id IN (SELECT id FROM gorm_posts WHERE user_id = ?)"
+		args = append(args, filter.UserID)
+	}
+	return conditions, args
+}
+
+// ViewBuckets buckets in UTC, as timestamps without a time zone, so that
+// days and months do not shift with the session's time zone or daylight
+// saving.
+func (repo *ViewRepo) ViewBuckets(ctx context.Context, filter ViewFilter, interval string) ([]models.ViewBucket, error) {
+	conditions, args := filter.conditions()
+	args = append([]interface{}{interval, filter.Since, filter.Until, interval, interval}, args...)
+
+	buckets := []models.ViewBucket{}
+	err := repo.conn(ctx).Raw(`
+		SELECT buckets.start AT TIME ZONE 'UTC' AS start, COALESCE(sum(v.views), 0) AS views
+		FROM generate_series(
+			date_trunc(?, ?::timestamptz AT TIME ZONE 'UTC'),
+			(?::timestamptz AT TIME ZONE 'UTC') - interval '1 microsecond',
+			('1 ' || ?)::interval
+		) AS buckets(start)
+		LEFT JOIN gorm_post_views v ON date_trunc(?, v.hour AT TIME ZONE 'UTC') = buckets.start AND `+conditions+`
+		GROUP BY buckets.start
+		ORDER BY buckets.start`, args...).Scan(&buckets).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return buckets, nil
+}
+
+func (repo *ViewRepo) TopPosts(ctx context.Context, filter ViewFilter, limit int) ([]models.PostViews, error) {
+	conditions, args := filter.conditions()
+
+	posts := []models.PostViews{}
+	err := repo.conn(ctx).Raw(`
+		SELECT p.id AS post_id, p.user_id, p.title, sum(v.views) AS views
+		FROM gorm_post_views v
+		JOIN gorm_posts p ON p.id = v.post_id AND p.deleted_at IS NULL
+		WHERE `+conditions+`
+		GROUP BY p.id
+		ORDER BY views DESC, p.id
+		LIMIT ?`, append(args, limit)...).Scan(&posts).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return posts, nil
+}
+
+func (repo *ViewRepo) TopAuthors(ctx context.Context, filter ViewFilter, limit int) ([]models.AuthorViews, error) {
+	conditions, args := filter.conditions()
+
+	authors := []models.AuthorViews{}
+	err := repo.conn(ctx).Raw(`
+		SELECT u.id AS user_id, u.name, count(DISTINCT p.id) AS posts, sum(v.views) AS views
+		FROM gorm_post_views v
+		JOIN gorm_posts p ON p.id = v.post_id AND p.deleted_at IS NULL
+		JOIN gorm_users u ON u.id = p.user_id AND u.deleted_at IS NULL
+		WHERE `+conditions+`
+		GROUP BY u.id
+		ORDER BY views DESC, u.id
+		LIMIT ?`, append(args, limit)...).Scan(&authors).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+
+	return authors, nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// ViewFilter narrows the views read to the hours from Since up to, not
+// including, Until, and to one post or the posts of one author when PostID
+// or UserID is set.
+type ViewFilter struct {
+	Since  time.Time
+	Until  time.Time
+	PostID uint
+	UserID uint
+}
+
+// ViewRepository stores how often posts are viewed, hour by hour.
+type ViewRepository interface {
+	Transactor
+	MigrateView(ctx context.Context) error
+	// AddViews adds each count to the row of its post and hour, creating
+	// rows as needed. Counts for posts that are not live and published
+	// any more are dropped.
+	AddViews(ctx context.Context, views []models.GormPostView) error
+	// ViewBuckets sums the views per interval ("day", "week" or "month",
+	// weeks starting on Monday, in UTC) oldest first, with a bucket of 0
+	// views for every interval without any.
+	ViewBuckets(ctx context.Context, filter ViewFilter, interval string) ([]models.ViewBucket, error)
+	// TopPosts and TopAuthors read up to limit live posts or authors, most
+	// viewed first.
+	TopPosts(ctx context.Context, filter ViewFilter, limit int) ([]models.PostViews, error)
+	TopAuthors(ctx context.Context, filter ViewFilter, limit int) ([]models.AuthorViews, error)
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepo struct {
+	gormRepository
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &WebhookRepo{gormRepository{db}}
+}
+
+// skipLocked locks the rows read for the rest of the transaction and
+// leaves out rows another transaction holds.
+var skipLocked = clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}, Options: "SKIP LOCKED"}
+
+func (repo *WebhookRepo) MigrateWebhook(ctx context.Context) error {
+	err := repo.conn(ctx).AutoMigrate(&models.GormWebhook{}, &models.GormWebhookEvent{}, &models.GormWebhookDelivery{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (repo *WebhookRepo) CreateWebhook(ctx context.Context, webhook models.GormWebhook) (*models.GormWebhook, error) {
+	if err := repo.conn(ctx).Create(&webhook).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+	return &webhook, nil
+}
+
+func (repo *WebhookRepo) Webhooks(ctx context.Context) ([]models.GormWebhook, error) {
+	webhooks := []models.GormWebhook{}
+	if err := repo.conn(ctx).Order("id").Find(&webhooks).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+	return webhooks, nil
+}
+
+func (repo *WebhookRepo) GetWebhookByID(ctx context.Context, id uint) (*models.GormWebhook, error) {
+	var webhook models.GormWebhook
+	if err := repo.conn(ctx).First(&webhook, id).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+	return &webhook, nil
+}
+
+func (repo *WebhookRepo) UpdateWebhook(ctx context.Context, webhook models.GormWebhook) (*models.GormWebhook, error) {
+	updateRes := repo.conn(ctx).Model(&webhook).Clauses(clause.Returning{}).
+		Select("url", "secret", "events", "active", "updated_at").
+		Updates(&webhook)
+	if err := updateRes.Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+	if updateRes.RowsAffected == 0 {
+		return nil, ErrNotExist
+	}
+	return &webhook, nil
+}
+
+func (repo *WebhookRepo) DeleteWebhook(ctx context.Context, id uint) error {
+	deleteRes := repo.conn(ctx).Delete(&models.GormWebhook{}, id)
+	if err := deleteRes.Error; err != nil {
+		return repo.translateError(err)
+	}
+	if deleteRes.RowsAffected == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (repo *WebhookRepo) CreateWebhookEvent(ctx context.Context, event models.GormWebhookEvent) error {
+	if err := repo.conn(ctx).Create(&event).Error; err != nil {
+		return repo.translateError(err)
+	}
+	return nil
+}
+
+func (repo *WebhookRepo) PendingWebhookEvents(ctx context.Context, limit int) ([]models.GormWebhookEvent, error) {
+	events := []models.GormWebhookEvent{}
+	err := repo.conn(ctx).Clauses(skipLocked).
+		Where("dispatched_at IS NULL").
+		Order("id").Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, repo.translateError(err)
+	}
+	return events, nil
+}
+
+func (repo *WebhookRepo) DispatchWebhookEvents(ctx context.Context, eventIDs []uint, deliveries []models.GormWebhookDelivery) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	return repo.WithTx(ctx, func(ctx context.Context) error {
+		if len(deliveries) > 0 {
+			err := repo.conn(ctx).Omit(clause.Associations).
+				Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&deliveries).Error
+			if err != nil {
+				return repo.translateError(err)
+			}
+		}
+
+		err := repo.conn(ctx).Model(&models.GormWebhookEvent{}).
+			Where("id IN ?", eventIDs).
+			UpdateColumn("dispatched_at", time.Now()).Error
+		if err != nil {
+			return repo.translateError(err)
+		}
+		return nil
+	})
+}
+
+// ClaimWebhookDeliveries locks the due rows while it moves their next
+// attempt, so two dispatchers never claim the same delivery.
+func (repo *WebhookRepo) ClaimWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.GormWebhookDelivery, error) {
+	deliveries := []models.GormWebhookDelivery{}
+	err := repo.WithTx(ctx, func(ctx context.Context) error {
+		err := repo.conn(ctx).Joins("Webhook").Joins("Event").Clauses(skipLocked).
+			Where("gorm_webhook_deliveries.status = ? AND gorm_webhook_deliveries.next_attempt_at <= ?", models.DeliveryPending, now).
+			Where(`"Webhook".active`).
+			Order("gorm_webhook_deliveries.next_attempt_at").Limit(limit).
+			Find(&deliveries).Error
+		if err != nil {
+			return repo.translateError(err)
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+			deliveries[i].NextAttemptAt = until
+		}
+		err = repo.conn(ctx).Model(&models.GormWebhookDelivery{}).
+			Where("id IN ?", ids).
+			UpdateColumn("next_attempt_at", until).Error
+		if err != nil {
+			return repo.translateError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (repo *WebhookRepo) UpdateWebhookDelivery(ctx context.Context, delivery models.GormWebhookDelivery) error {
+	updateRes := repo.conn(ctx).Model(&delivery).Omit(clause.Associations).
+		Select("status", "attempts", "next_attempt_at", "last_attempt_at", "response_status", "error", "updated_at").
+		Updates(&delivery)
+	if err := updateRes.Error; err != nil {
+		return repo.translateError(err)
+	}
+	if updateRes.RowsAffected == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (repo *WebhookRepo) GetWebhookDelivery(ctx context.Context, id uint) (*models.GormWebhookDelivery, error) {
+	var delivery models.GormWebhookDelivery
+	if err := repo.conn(ctx).First(&delivery, id).Error; err != nil {
+		return nil, repo.translateError(err)
+	}
+	return &delivery, nil
+}
+
+func (repo *WebhookRepo) WebhookDeliveries(ctx context.Context, webhookID uint, status string, page Page) ([]models.GormWebhookDelivery, int64, error) {
+	query := repo.conn(ctx).Model(&models.GormWebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	deliveries := []models.GormWebhookDelivery{}
+	err := query.Order("id DESC").Limit(page.Size).Offset(page.offset()).Find(&deliveries).Error
+	if err != nil {
+		return nil, 0, repo.translateError(err)
+	}
+
+	return deliveries, total, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// WebhookRepository stores webhook subscriptions, the outbox of events
+// waiting to be sent to them, and the deliveries of those events.
+type WebhookRepository interface {
+	Transactor
+	MigrateWebhook(ctx context.Context) error
+	CreateWebhook(ctx context.Context, webhook models.GormWebhook) (*models.GormWebhook, error)
+	// Webhooks returns every webhook, oldest first.
+	Webhooks(ctx context.Context) ([]models.GormWebhook, error)
+	GetWebhookByID(ctx context.Context, id uint) (*models.GormWebhook, error)
+	// UpdateWebhook writes the URL, secret, events and whether the webhook
+	// is active.
+	UpdateWebhook(ctx context.Context, webhook models.GormWebhook) (*models.GormWebhook, error)
+	// DeleteWebhook removes a webhook along with its deliveries.
+	DeleteWebhook(ctx context.Context, id uint) error
+
+	// CreateWebhookEvent adds an event to the outbox.
+	CreateWebhookEvent(ctx context.Context, event models.GormWebhookEvent) error
+	// PendingWebhookEvents returns up to limit events not dispatched yet,
+	// oldest first. Inside a transaction they stay locked until it ends,
+	// and other transactions asking for pending events skip them.
+	PendingWebhookEvents(ctx context.Context, limit int) ([]models.GormWebhookEvent, error)
+	// DispatchWebhookEvents creates the deliveries of the events with the
+	// given ids and marks those events dispatched. A delivery of an event
+	// to a webhook that already has one is left out.
+	DispatchWebhookEvents(ctx context.Context, eventIDs []uint, deliveries []models.GormWebhookDelivery) error
+
+	// ClaimWebhookDeliveries returns up to limit pending deliveries to
+	// active webhooks that are due by now, earliest first, with their
+	// webhook and event. Their next attempt is moved to until, so nobody
+	// else sends them meanwhile, and they are sent again then if the
+	// claimer never records how the attempt went.
+	ClaimWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.GormWebhookDelivery, error)
+	// UpdateWebhookDelivery records the status, attempts, next attempt and
+	// outcome of the last attempt of a delivery.
+	UpdateWebhookDelivery(ctx context.Context, delivery models.GormWebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, id uint) (*models.GormWebhookDelivery, error)
+	// WebhookDeliveries returns one page of a webhook's deliveries, newest
+	// first, and how many there are in all. A non-empty status leaves out
+	// deliveries in any other.
+	WebhookDeliveries(ctx context.Context, webhookID uint, status string, page Page) ([]models.GormWebhookDelivery, int64, error)
+}
@@ -1,55 +1,125 @@
 package router
 
 import (
-	"github.com/bellaananda/go-postgresql-blog-http.git/database"
+	"github.com/bellaananda/go-postgresql-blog-http.git/app"
 	"github.com/bellaananda/go-postgresql-blog-http.git/handler"
-	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
-	"github.com/bellaananda/go-postgresql-blog-http.git/service"
+	"github.com/bellaananda/go-postgresql-blog-http.git/telemetry"
 	"github.com/gorilla/mux"
 )
 
-func Router() *mux.Router {
-
-	db, errDb := database.RunDatabase()
-	if errDb != nil {
-		panic("Failed to connect to database")
-	}
-
+// NewRouter registers every route against the services held by application.
+func NewRouter(application *app.App) *mux.Router {
 	router := mux.NewRouter()
+	router.Use(telemetry.Middleware)
+	router.Use(handler.Authenticate)
 
-	userRepository := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepository, db)
-
-	postRepository := repository.NewPostRepository(db)
-	postService := service.NewPostService(postRepository, db)
-
-	commentRepository := repository.NewCommentRepository(db)
-	commentService := service.NewCommentService(commentRepository, db)
+	userService := application.UserService
+	postService := application.PostService
+	commentService := application.CommentService
+	trashService := application.TrashService
+	reactionService := application.ReactionService
+	bookmarkService := application.BookmarkService
+	followService := application.FollowService
+	syndicationService := application.SyndicationService
+	sitemapService := application.SitemapService
+	viewService := application.ViewService
+	notificationService := application.NotificationService
+	webhookService := application.WebhookService
+	streamService := application.CommentStreamService
 
 	router.HandleFunc("/api/nicetry", handler.FirstHandler).Methods("GET")
 	router.HandleFunc("/api/migrate", handler.MigrateHandler).Methods("GET")
 	router.HandleFunc("/api/connect", handler.ConnectHandler).Methods("GET")
 
 	// User routes
-	router.HandleFunc("/api/users", handler.CreateUserHandler(*userService)).Methods("POST")                     // create
-	router.HandleFunc("/api/users", handler.GetAllUsersHandler(*userService)).Methods("GET")                     // read
-	router.HandleFunc("/api/users/{id:[0-9]+}", handler.GetUserHandler(*userService)).Methods("GET")             // read 1
-	router.HandleFunc("/api/users/{id:[0-9]+}", handler.UpdateUserHandler(*userService)).Methods("PUT", "PATCH") // update
-	router.HandleFunc("/api/users/{id:[0-9]+}", handler.DeleteUserHandler(*userService)).Methods("DELETE")       // delete
+	router.HandleFunc("/api/users", handler.CreateUserHandler(userService)).Methods("POST")                          // create
+	router.HandleFunc("/api/users", handler.GetAllUsersHandler(userService)).Methods("GET")                          // read
+	router.HandleFunc("/api/users/{id:[0-9]+}", handler.GetUserHandler(userService)).Methods("GET")                  // read 1
+	router.HandleFunc("/api/users/{id:[0-9]+}", handler.UpdateUserHandler(userService)).Methods("PUT")               // replace
+	router.HandleFunc("/api/users/{id:[0-9]+}", handler.PatchUserHandler(userService)).Methods("PATCH")              // partial update
+	router.HandleFunc("/api/users/{id:[0-9]+}", handler.DeleteUserHandler(userService)).Methods("DELETE")            // delete
+	router.HandleFunc("/api/users/{id:[0-9]+}/follow", handler.FollowHandler(followService)).Methods("PUT")          // follow
+	router.HandleFunc("/api/users/{id:[0-9]+}/follow", handler.UnfollowHandler(followService)).Methods("DELETE")     // unfollow
+	router.HandleFunc("/api/users/{id:[0-9]+}/followers", handler.GetFollowersHandler(followService)).Methods("GET") // followers
+	router.HandleFunc("/api/users/{id:[0-9]+}/following", handler.GetFollowingHandler(followService)).Methods("GET") // following
+
+	// Password reset routes
+	router.HandleFunc("/api/password-resets", handler.RequestPasswordResetHandler(userService)).Methods("POST")  // mail reset link
+	router.HandleFunc("/api/password-resets/confirm", handler.ResetPasswordHandler(userService)).Methods("POST") // set new password
 
 	// Post routes
-	router.HandleFunc("/api/posts", handler.CreatePostHandler(*postService, *userService)).Methods("POST")                     // create
-	router.HandleFunc("/api/posts", handler.GetAllPostsHandler(*postService)).Methods("GET")                                   // read
-	router.HandleFunc("/api/posts/{id:[0-9]+}", handler.GetPostHandler(*postService)).Methods("GET")                           // read 1
-	router.HandleFunc("/api/posts/{id:[0-9]+}", handler.UpdatePostHandler(*postService, *userService)).Methods("PUT", "PATCH") // update
-	router.HandleFunc("/api/posts/{id:[0-9]+}", handler.DeletePostHandler(*postService)).Methods("DELETE")                     // delete
+	router.HandleFunc("/api/posts", handler.CreatePostHandler(postService)).Methods("POST")                                            // create
+	router.HandleFunc("/api/posts", handler.GetAllPostsHandler(postService)).Methods("GET")                                            // read
+	router.HandleFunc("/api/posts/{id:[0-9]+}", handler.GetPostHandler(postService, viewService)).Methods("GET")                       // read 1
+	router.HandleFunc("/api/posts/{id:[0-9]+}", handler.UpdatePostHandler(postService)).Methods("PUT")                                 // replace
+	router.HandleFunc("/api/posts/{id:[0-9]+}", handler.PatchPostHandler(postService)).Methods("PATCH")                                // partial update
+	router.HandleFunc("/api/posts/{id:[0-9]+}", handler.DeletePostHandler(postService)).Methods("DELETE")                              // delete
+	router.HandleFunc("/api/posts/{id:[0-9]+}/thumbnail", handler.UploadThumbnailHandler(postService)).Methods("POST")                 // upload thumbnail
+	router.HandleFunc("/api/posts/{id:[0-9]+}/thumbnail", handler.GetThumbnailHandler(postService)).Methods("GET", "HEAD")             // read thumbnail
+	router.HandleFunc("/api/posts/{id:[0-9]+}/reactions/{type}", handler.AddPostReactionHandler(reactionService)).Methods("PUT")       // react
+	router.HandleFunc("/api/posts/{id:[0-9]+}/reactions/{type}", handler.RemovePostReactionHandler(reactionService)).Methods("DELETE") // unreact
+	router.HandleFunc("/api/posts/{id:[0-9]+}/bookmark", handler.AddBookmarkHandler(bookmarkService)).Methods("PUT")                   // bookmark
+	router.HandleFunc("/api/posts/{id:[0-9]+}/bookmark", handler.RemoveBookmarkHandler(bookmarkService)).Methods("DELETE")             // unbookmark
+	router.HandleFunc("/api/posts/{id:[0-9]+}/comments/stream", handler.StreamCommentsHandler(streamService)).Methods("GET")           // follow comments
 
 	// Comment routes
-	router.HandleFunc("/api/comments", handler.CreateCommentHandler(*commentService, *postService, *userService)).Methods("POST")                     // create
-	router.HandleFunc("/api/comments", handler.GetAllCommentsHandler(*commentService)).Methods("GET")                                                 // read
-	router.HandleFunc("/api/comments/{id:[0-9]+}", handler.GetCommentHandler(*commentService)).Methods("GET")                                         // read 1
-	router.HandleFunc("/api/comments/{id:[0-9]+}", handler.UpdateCommentHandler(*commentService, *postService, *userService)).Methods("PUT", "PATCH") // update
-	router.HandleFunc("/api/comments/{id:[0-9]+}", handler.DeleteCommentHandler(*commentService)).Methods("DELETE")                                   // delete
+	router.HandleFunc("/api/comments", handler.CreateCommentHandler(commentService)).Methods("POST")                                         // create
+	router.HandleFunc("/api/comments", handler.GetAllCommentsHandler(commentService)).Methods("GET")                                         // read
+	router.HandleFunc("/api/comments/{id:[0-9]+}", handler.GetCommentHandler(commentService)).Methods("GET")                                 // read 1
+	router.HandleFunc("/api/comments/{id:[0-9]+}", handler.UpdateCommentHandler(commentService)).Methods("PUT")                              // replace
+	router.HandleFunc("/api/comments/{id:[0-9]+}", handler.PatchCommentHandler(commentService)).Methods("PATCH")                             // partial update
+	router.HandleFunc("/api/comments/{id:[0-9]+}", handler.DeleteCommentHandler(commentService)).Methods("DELETE")                           // delete
+	router.HandleFunc("/api/comments/{id:[0-9]+}/reactions/{type}", handler.AddCommentReactionHandler(reactionService)).Methods("PUT")       // react
+	router.HandleFunc("/api/comments/{id:[0-9]+}/reactions/{type}", handler.RemoveCommentReactionHandler(reactionService)).Methods("DELETE") // unreact
+
+	// Feed routes
+	router.HandleFunc("/api/feed", handler.GetFeedHandler(postService)).Methods("GET") // read
+
+	// Bookmark routes
+	router.HandleFunc("/api/bookmarks", handler.GetBookmarksHandler(bookmarkService)).Methods("GET")           // read
+	router.HandleFunc("/api/bookmarks/lists", handler.GetBookmarkListsHandler(bookmarkService)).Methods("GET") // reading lists
+
+	// Notification routes
+	router.HandleFunc("/api/notifications", handler.GetNotificationsHandler(notificationService)).Methods("GET")                          // read
+	router.HandleFunc("/api/notifications/unread", handler.GetUnreadCountHandler(notificationService)).Methods("GET")                     // unread count
+	router.HandleFunc("/api/notifications/read", handler.MarkNotificationsReadHandler(notificationService)).Methods("POST")               // mark read
+	router.HandleFunc("/api/notifications/preferences", handler.GetNotificationPreferencesHandler(notificationService)).Methods("GET")    // read preferences
+	router.HandleFunc("/api/notifications/preferences", handler.UpdateNotificationPreferencesHandler(notificationService)).Methods("PUT") // update preferences
+
+	// Trash routes
+	router.HandleFunc("/api/trash/users", handler.RequireAdmin(handler.GetDeletedUsersHandler(trashService))).Methods("GET")                        // read
+	router.HandleFunc("/api/trash/users/{id:[0-9]+}/restore", handler.RequireAdmin(handler.RestoreUserHandler(trashService))).Methods("POST")       // restore
+	router.HandleFunc("/api/trash/users/{id:[0-9]+}", handler.RequireAdmin(handler.PurgeUserHandler(trashService))).Methods("DELETE")               // purge
+	router.HandleFunc("/api/trash/posts", handler.RequireAdmin(handler.GetDeletedPostsHandler(trashService))).Methods("GET")                        // read
+	router.HandleFunc("/api/trash/posts/{id:[0-9]+}/restore", handler.RequireAdmin(handler.RestorePostHandler(trashService))).Methods("POST")       // restore
+	router.HandleFunc("/api/trash/posts/{id:[0-9]+}", handler.RequireAdmin(handler.PurgePostHandler(trashService))).Methods("DELETE")               // purge
+	router.HandleFunc("/api/trash/comments", handler.RequireAdmin(handler.GetDeletedCommentsHandler(trashService))).Methods("GET")                  // read
+	router.HandleFunc("/api/trash/comments/{id:[0-9]+}/restore", handler.RequireAdmin(handler.RestoreCommentHandler(trashService))).Methods("POST") // restore
+	router.HandleFunc("/api/trash/comments/{id:[0-9]+}", handler.RequireAdmin(handler.PurgeCommentHandler(trashService))).Methods("DELETE")         // purge
+
+	// Webhook routes
+	router.HandleFunc("/api/webhooks", handler.RequireAdmin(handler.CreateWebhookHandler(webhookService))).Methods("POST")                                                       // create
+	router.HandleFunc("/api/webhooks", handler.RequireAdmin(handler.GetWebhooksHandler(webhookService))).Methods("GET")                                                          // read
+	router.HandleFunc("/api/webhooks/{id:[0-9]+}", handler.RequireAdmin(handler.GetWebhookHandler(webhookService))).Methods("GET")                                               // read 1
+	router.HandleFunc("/api/webhooks/{id:[0-9]+}", handler.RequireAdmin(handler.UpdateWebhookHandler(webhookService))).Methods("PUT")                                            // replace
+	router.HandleFunc("/api/webhooks/{id:[0-9]+}", handler.RequireAdmin(handler.DeleteWebhookHandler(webhookService))).Methods("DELETE")                                         // delete
+	router.HandleFunc("/api/webhooks/{id:[0-9]+}/deliveries", handler.RequireAdmin(handler.GetWebhookDeliveriesHandler(webhookService))).Methods("GET")                          // delivery log
+	router.HandleFunc("/api/webhooks/{id:[0-9]+}/deliveries/{delivery:[0-9]+}/redeliver", handler.RequireAdmin(handler.RedeliverWebhookHandler(webhookService))).Methods("POST") // send again
+
+	// Syndication routes
+	router.HandleFunc("/feed.{format:rss|atom}", handler.GetSiteFeedHandler(syndicationService)).Methods("GET", "HEAD")                       // site
+	router.HandleFunc("/authors/{id:[0-9]+}/feed.{format:rss|atom}", handler.GetAuthorFeedHandler(syndicationService)).Methods("GET", "HEAD") // author
+	router.HandleFunc("/tags/{tag}/feed.{format:rss|atom}", handler.GetTagFeedHandler(syndicationService)).Methods("GET", "HEAD")             // tag
+
+	// Analytics routes
+	router.HandleFunc("/api/analytics/views", handler.GetViewsOverTimeHandler(viewService)).Methods("GET") // views over time
+	router.HandleFunc("/api/analytics/posts", handler.GetTopPostsHandler(viewService)).Methods("GET")      // top posts
+	router.HandleFunc("/api/analytics/authors", handler.GetTopAuthorsHandler(viewService)).Methods("GET")  // top authors
+
+	// Crawler routes
+	router.HandleFunc("/sitemap.xml", handler.GetSitemapHandler(sitemapService)).Methods("GET")                          // sitemap or index
+	router.HandleFunc("/sitemaps/posts-{part:[0-9]+}.xml", handler.GetSitemapPartHandler(sitemapService)).Methods("GET") // part
+	router.HandleFunc("/robots.txt", handler.GetRobotsHandler(sitemapService)).Methods("GET")                            // robots
 
 	return router
 }
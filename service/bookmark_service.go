@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// MaxListNameLength is the longest reading list name, in characters.
+const MaxListNameLength = 100
+
+type BookmarkSvc struct {
+	BookmarkRepo repository.BookmarkRepository
+	PostRepo     repository.PostRepository
+	UserRepo     repository.UserRepository
+	Media        MediaService
+}
+
+func NewBookmarkService(bookmarkRepo repository.BookmarkRepository, postRepo repository.PostRepository, userRepo repository.UserRepository, media MediaService) BookmarkService {
+	return &BookmarkSvc{
+		BookmarkRepo: bookmarkRepo,
+		PostRepo:     postRepo,
+		UserRepo:     userRepo,
+		Media:        media,
+	}
+}
+
+// listName trims a reading list name and checks its length.
+func listName(list string) (string, error) {
+	list = strings.TrimSpace(list)
+	if utf8.RuneCountInString(list) > MaxListNameLength {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalidList, MaxListNameLength)
+	}
+	return list, nil
+}
+
+func (bookmarkService *BookmarkSvc) AddBookmark(ctx context.Context, userID uint, postID uint, list string) (_ *models.GormBookmark, _ bool, err error) {
+	ctx, span := tracer.Start(ctx, "BookmarkService.AddBookmark")
+	defer endSpan(span, &err)
+
+	list, err = listName(list)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var bookmark *models.GormBookmark
+	var created bool
+	err = bookmarkService.BookmarkRepo.WithTx(ctx, func(ctx context.Context) error {
+		_, err := bookmarkService.UserRepo.GetUserByID(ctx, userID)
+		if errors.Is(err, repository.ErrNotExist) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// trashed posts cannot be saved
+		if _, err := bookmarkService.PostRepo.GetPostByID(ctx, postID); err != nil {
+			return err
+		}
+
+		bookmark, created, err = bookmarkService.BookmarkRepo.CreateBookmark(ctx, models.GormBookmark{UserID: userID, PostID: postID, List: list})
+		return err
+	})
+	if err != nil {
+		log.Printf("Error bookmarking post with ID %d for user ID %d: %v", postID, userID, err)
+		return nil, false, err
+	}
+
+	return bookmark, created, nil
+}
+
+func (bookmarkService *BookmarkSvc) RemoveBookmark(ctx context.Context, userID uint, postID uint, list string) (err error) {
+	ctx, span := tracer.Start(ctx, "BookmarkService.RemoveBookmark")
+	defer endSpan(span, &err)
+
+	list, err = listName(list)
+	if err != nil {
+		return err
+	}
+
+	// removing a bookmark that is not there leaves nothing to do
+	if _, err := bookmarkService.BookmarkRepo.DeleteBookmark(ctx, userID, postID, list); err != nil {
+		log.Printf("Error removing bookmark of post with ID %d for user ID %d: %v", postID, userID, err)
+		return err
+	}
+	return nil
+}
+
+func (bookmarkService *BookmarkSvc) GetBookmarks(ctx context.Context, userID uint, list *string, page Page) (_ []models.GormBookmark, _ int64, err error) {
+	ctx, span := tracer.Start(ctx, "BookmarkService.GetBookmarks")
+	defer endSpan(span, &err)
+
+	if list != nil {
+		name, err := listName(*list)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = &name
+	}
+
+	bookmarks, total, err := bookmarkService.BookmarkRepo.Bookmarks(ctx, userID, list, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the posts of a page get their thumbnail URLs together
+	posts := make([]models.GormPost, len(bookmarks))
+	for i := range bookmarks {
+		posts[i] = *bookmarks[i].Post
+	}
+	if err := bookmarkService.Media.AttachURLs(ctx, posts); err != nil {
+		return nil, 0, err
+	}
+	bookmarked := true
+	for i := range bookmarks {
+		posts[i].Bookmarked = &bookmarked
+		bookmarks[i].Post = &posts[i]
+	}
+
+	return bookmarks, total, nil
+}
+
+func (bookmarkService *BookmarkSvc) GetBookmarkLists(ctx context.Context, userID uint) (_ []models.BookmarkList, err error) {
+	ctx, span := tracer.Start(ctx, "BookmarkService.GetBookmarkLists")
+	defer endSpan(span, &err)
+
+	return bookmarkService.BookmarkRepo.BookmarkLists(ctx, userID)
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// BookmarkService lets readers save posts for later, optionally sorted
+// into named reading lists. The empty list name is a reader's unsorted
+// bookmarks.
+type BookmarkService interface {
+	// AddBookmark reports false when the post was already saved to list.
+	AddBookmark(ctx context.Context, userID uint, postID uint, list string) (*models.GormBookmark, bool, error)
+	RemoveBookmark(ctx context.Context, userID uint, postID uint, list string) error
+	// GetBookmarks reads one page of a reader's bookmarks, newest first, and
+	// how many there are in all. A nil list reads every list.
+	GetBookmarks(ctx context.Context, userID uint, list *string, page Page) ([]models.GormBookmark, int64, error)
+	GetBookmarkLists(ctx context.Context, userID uint) ([]models.BookmarkList, error)
+}
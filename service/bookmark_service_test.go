@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+)
+
+func TestBookmarks(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	blobs, err := storage.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore: %v", err)
+	}
+	reader, err := repo.CreateUser(ctx, models.GormUser{Email: "bo@example.com", Username: "bo"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	var posts []*models.GormPost
+	for _, title := range []string{"First", "Second", "Trashed"} {
+		post, err := repo.CreatePost(ctx, models.GormPost{UserID: reader.ID, Title: title})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		posts = append(posts, post)
+	}
+	if err := repo.DeletePost(ctx, posts[2].ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	bookmarkService := NewBookmarkService(repo, repo, repo, NewMediaService(repo, repo, blobs, []int{100}))
+
+	for _, add := range []struct {
+		postID  uint
+		list    string
+		created bool
+	}{
+		{posts[0].ID, "", true},
+		{posts[0].ID, "", false},
+		// names are trimmed
+		{posts[0].ID, "  later ", true},
+		{posts[1].ID, "later", true},
+		{posts[1].ID, " later", false},
+	} {
+		bookmark, created, err := bookmarkService.AddBookmark(ctx, reader.ID, add.postID, add.list)
+		if err != nil {
+			t.Fatalf("AddBookmark(%d, %q): %v", add.postID, add.list, err)
+		}
+		if created != add.created || bookmark.List != strings.TrimSpace(add.list) || bookmark.PostID != add.postID {
+			t.Errorf("AddBookmark(%d, %q) = %+v, %t; want created %t", add.postID, add.list, bookmark, created, add.created)
+		}
+	}
+
+	lists, err := bookmarkService.GetBookmarkLists(ctx, reader.ID)
+	if err != nil {
+		t.Fatalf("GetBookmarkLists: %v", err)
+	}
+	if want := []models.BookmarkList{{Name: "", Count: 1}, {Name: "later", Count: 2}}; !reflect.DeepEqual(lists, want) {
+		t.Errorf("GetBookmarkLists = %+v, want %+v", lists, want)
+	}
+
+	later := "later"
+	bookmarks, total, err := bookmarkService.GetBookmarks(ctx, reader.ID, &later, Page{Number: 1, Size: 10})
+	if err != nil {
+		t.Fatalf("GetBookmarks: %v", err)
+	}
+	if total != 2 || len(bookmarks) != 2 || bookmarks[0].PostID != posts[1].ID {
+		t.Fatalf("GetBookmarks of later = %+v, %d, want the two posts, newest first", bookmarks, total)
+	}
+	for _, bookmark := range bookmarks {
+		if bookmark.Post == nil || bookmark.Post.Bookmarked == nil || !*bookmark.Post.Bookmarked {
+			t.Errorf("bookmark %+v does not carry its post marked as bookmarked", bookmark)
+		}
+	}
+	if _, total, err := bookmarkService.GetBookmarks(ctx, reader.ID, nil, Page{Number: 1, Size: 1}); err != nil || total != 3 {
+		t.Errorf("GetBookmarks of every list = %d, %v, want 3", total, err)
+	}
+
+	// removing twice is fine
+	for i := 0; i < 2; i++ {
+		if err := bookmarkService.RemoveBookmark(ctx, reader.ID, posts[0].ID, " later "); err != nil {
+			t.Fatalf("RemoveBookmark: %v", err)
+		}
+	}
+	if _, total, err := bookmarkService.GetBookmarks(ctx, reader.ID, &later, Page{Number: 1, Size: 10}); err != nil || total != 1 {
+		t.Errorf("GetBookmarks of later after removing = %d, %v, want 1", total, err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		userID uint
+		postID uint
+		list   string
+		want   error
+	}{
+		{"trashed post", reader.ID, posts[2].ID, "", ErrNotFound},
+		{"unknown post", reader.ID, 99, "", ErrNotFound},
+		{"unknown user", reader.ID + 10, posts[0].ID, "", ErrUserNotFound},
+		{"long list name", reader.ID, posts[0].ID, strings.Repeat("ł", MaxListNameLength+1), ErrInvalidList},
+	} {
+		if _, _, err := bookmarkService.AddBookmark(ctx, tt.userID, tt.postID, tt.list); !errors.Is(err, tt.want) {
+			t.Errorf("AddBookmark of %s err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, _, err := bookmarkService.AddBookmark(ctx, reader.ID, posts[0].ID, strings.Repeat("ł", MaxListNameLength)); err != nil {
+		t.Errorf("AddBookmark with the longest list name: %v", err)
+	}
+}
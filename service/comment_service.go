@@ -3,51 +3,181 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 
 	// "fmt"
 	// "log"
+	"github.com/bellaananda/go-postgresql-blog-http.git/content"
 	"github.com/bellaananda/go-postgresql-blog-http.git/models"
 	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
-
-	"gorm.io/gorm"
 )
 
-type CommentService struct {
-	CommentRepo repository.CommentRepository
-	db          *gorm.DB
+type CommentSvc struct {
+	CommentRepo   repository.CommentRepository
+	PostRepo      repository.PostRepository
+	UserRepo      repository.UserRepository
+	Notifications NotificationService
+	Webhooks      WebhookService
+	Stream        CommentStreamService
+	HTML          *content.Cache
+}
+
+func NewCommentService(commentRepo repository.CommentRepository, postRepo repository.PostRepository, userRepo repository.UserRepository, notifications NotificationService, webhooks WebhookService, stream CommentStreamService, html *content.Cache) CommentService {
+	return &CommentSvc{
+		CommentRepo:   commentRepo,
+		PostRepo:      postRepo,
+		UserRepo:      userRepo,
+		Notifications: notifications,
+		Webhooks:      webhooks,
+		Stream:        stream,
+		HTML:          html,
+	}
 }
 
-func NewCommentService(commentRepo repository.CommentRepository, db *gorm.DB) *CommentService {
-	return &CommentService{
-		CommentRepo: commentRepo,
-		db:          db,
+// present renders the content of a single comment to HTML.
+func (commentService *CommentSvc) present(comment *models.GormComment) (*models.GormComment, error) {
+	comments := []models.GormComment{*comment}
+	if err := commentService.presentAll(comments); err != nil {
+		return nil, err
+	}
+	return &comments[0], nil
+}
+
+// presentRead renders the content of comments to HTML when the fieldset
+// returns it.
+func (commentService *CommentSvc) presentRead(comments []models.GormComment, fieldset Fieldset) error {
+	if !fieldset.Returns("comment", "content") {
+		return nil
+	}
+	return commentService.presentAll(comments)
+}
+
+// presentAll renders the content of comments to HTML.
+func (commentService *CommentSvc) presentAll(comments []models.GormComment) error {
+	for i := range comments {
+		html, err := commentService.HTML.Render(commentHTMLKey(comments[i].ID), comments[i].Version, comments[i].ContentFormat, comments[i].Content)
+		if err != nil {
+			return err
+		}
+		comments[i].ContentHTML = html
+	}
+	return nil
+}
+
+// userAndPostExist maps a missing user or post onto ErrUserNotFound and
+// ErrPostNotFound.
+func (commentService *CommentSvc) userAndPostExist(ctx context.Context, userID uint, postID uint) error {
+	_, err := commentService.UserRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotExist) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = commentService.PostRepo.GetPostByID(ctx, postID)
+	if errors.Is(err, repository.ErrNotExist) {
+		return ErrPostNotFound
+	}
+	return err
+}
+
+// notifyComment tells the author of the post commented on, and everyone who
+// commented on it before, about a new comment.
+func (commentService *CommentSvc) notifyComment(ctx context.Context, comment *models.GormComment) error {
+	post, err := commentService.PostRepo.GetPostByID(ctx, comment.PostID)
+	if err != nil {
+		return err
+	}
+	earlier, err := commentService.CommentRepo.GetCommentByPostID(ctx, comment.PostID)
+	if err != nil {
+		return err
+	}
+
+	postID, commentID := comment.PostID, comment.ID
+	notifications := []models.GormNotification{
+		{UserID: post.UserID, ActorID: comment.UserID, Type: NotificationComment, PostID: &postID, CommentID: &commentID},
 	}
+	for _, other := range earlier {
+		// the author already hears about every comment
+		if other.ID != comment.ID && other.UserID != post.UserID {
+			notifications = append(notifications, models.GormNotification{
+				UserID: other.UserID, ActorID: comment.UserID, Type: NotificationReply, PostID: &postID, CommentID: &commentID,
+			})
+		}
+	}
+
+	return commentService.Notifications.Notify(ctx, notifications...)
 }
 
-func (commentService *CommentService) CreateComment(ctx context.Context, comment models.GormComment) (*models.GormComment, error) {
-	_, err := commentService.CommentRepo.GetCommentByUserIDPostID(ctx, comment.UserID, comment.PostID)
-	if err == nil || !errors.Is(err, repository.ErrNotExist) {
-		return nil, errors.New("a comment with the post already exists")
+func (commentService *CommentSvc) CreateComment(ctx context.Context, comment models.GormComment) (_ *models.GormComment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.CreateComment")
+	defer endSpan(span, &err)
+
+	format, err := contentFormat(comment.ContentFormat)
+	if err != nil {
+		return nil, err
 	}
+	comment.ContentFormat = format
 
-	return commentService.CommentRepo.CreateComment(ctx, comment)
+	var createdComment *models.GormComment
+	err = commentService.CommentRepo.WithTx(ctx, func(ctx context.Context) error {
+		if err := commentService.userAndPostExist(ctx, comment.UserID, comment.PostID); err != nil {
+			return err
+		}
+
+		// idx_comments_user_post still turns away a comment created since the check
+		_, err := commentService.CommentRepo.GetCommentByUserIDPostID(ctx, comment.UserID, comment.PostID)
+		if err == nil {
+			return fmt.Errorf("a comment with the post already exists: %w", ErrConflict)
+		}
+		if !errors.Is(err, repository.ErrNotExist) {
+			return err
+		}
+
+		createdComment, err = commentService.CommentRepo.CreateComment(ctx, comment)
+		if err != nil {
+			return err
+		}
+		if err := commentService.Webhooks.Enqueue(ctx, WebhookCommentCreated, commentEvent(createdComment)); err != nil {
+			return err
+		}
+		if err := commentService.Stream.Record(ctx, CommentEventCreated, createdComment); err != nil {
+			return err
+		}
+		return commentService.notifyComment(ctx, createdComment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commentService.present(createdComment)
 }
 
-func (commentService *CommentService) GetAllComments(ctx context.Context) ([]models.GormComment, error) {
-	post, err := commentService.CommentRepo.AllComments(ctx)
+func (commentService *CommentSvc) GetAllComments(ctx context.Context, fieldset Fieldset) (_ []models.GormComment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetAllComments")
+	defer endSpan(span, &err)
+
+	post, err := commentService.CommentRepo.AllComments(ctx, fieldset.query())
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
 			return nil, err
 		}
 		return nil, err
 	}
+	if err := commentService.presentRead(post, fieldset); err != nil {
+		return nil, err
+	}
 
 	return post, nil
 }
 
-func (commentService *CommentService) GetCommentByID(ctx context.Context, id uint) (*models.GormComment, error) {
-	comment, err := commentService.CommentRepo.GetCommentByID(ctx, id)
+func (commentService *CommentSvc) GetCommentByID(ctx context.Context, id uint, fieldset Fieldset) (_ *models.GormComment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetCommentByID")
+	defer endSpan(span, &err)
+
+	comment, err := commentService.CommentRepo.FindComment(ctx, id, fieldset.query())
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
 			return nil, err
@@ -55,10 +185,17 @@ func (commentService *CommentService) GetCommentByID(ctx context.Context, id uin
 		return nil, err
 	}
 
-	return comment, nil
+	comments := []models.GormComment{*comment}
+	if err := commentService.presentRead(comments, fieldset); err != nil {
+		return nil, err
+	}
+	return &comments[0], nil
 }
 
-func (commentService *CommentService) GetCommentByUserID(ctx context.Context, userid uint) ([]models.GormComment, error) {
+func (commentService *CommentSvc) GetCommentByUserID(ctx context.Context, userid uint) (_ []models.GormComment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetCommentByUserID")
+	defer endSpan(span, &err)
+
 	comment, err := commentService.CommentRepo.GetCommentByUserID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
@@ -66,10 +203,16 @@ func (commentService *CommentService) GetCommentByUserID(ctx context.Context, us
 		}
 		return nil, err
 	}
+	if err := commentService.presentAll(comment); err != nil {
+		return nil, err
+	}
 	return comment, nil
 }
 
-func (commentService *CommentService) GetCommentByPostID(ctx context.Context, postid uint) ([]models.GormComment, error) {
+func (commentService *CommentSvc) GetCommentByPostID(ctx context.Context, postid uint) (_ []models.GormComment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetCommentByPostID")
+	defer endSpan(span, &err)
+
 	comment, err := commentService.CommentRepo.GetCommentByPostID(ctx, postid)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
@@ -77,11 +220,17 @@ func (commentService *CommentService) GetCommentByPostID(ctx context.Context, po
 		}
 		return nil, err
 	}
+	if err := commentService.presentAll(comment); err != nil {
+		return nil, err
+	}
 
 	return comment, nil
 }
 
-func (commentService *CommentService) GetCommentByUserIDPostID(ctx context.Context, userid uint, postid uint) (*models.GormComment, error) {
+func (commentService *CommentSvc) GetCommentByUserIDPostID(ctx context.Context, userid uint, postid uint) (_ *models.GormComment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetCommentByUserIDPostID")
+	defer endSpan(span, &err)
+
 	comment, err := commentService.CommentRepo.GetCommentByUserIDPostID(ctx, userid, postid)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
@@ -92,31 +241,142 @@ func (commentService *CommentService) GetCommentByUserIDPostID(ctx context.Conte
 		return nil, err
 	}
 	log.Printf("Post found by user id '%d' and post id '%d': %+v\n", userid, postid, comment)
-	return comment, nil
+	return commentService.present(comment)
 }
 
-func (commentService *CommentService) UpdateCommentByID(ctx context.Context, commentID uint, comment models.GormComment) (*models.GormComment, error) {
+func (commentService *CommentSvc) UpdateCommentByID(ctx context.Context, commentID uint, comment models.GormComment) (_ *models.GormComment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.UpdateCommentByID")
+	defer endSpan(span, &err)
+
 	// Check if the comment ID in the URL matches the ID in the comment object
 	if commentID != comment.ID {
 		return nil, errors.New("mismatched comment ID in URL and request body")
 	}
 
-	existingComment, err := commentService.CommentRepo.GetCommentByID(ctx, comment.ID)
+	format, err := contentFormat(comment.ContentFormat)
 	if err != nil {
 		return nil, err
 	}
+	comment.ContentFormat = format
+
+	var updatedComment *models.GormComment
+	err = commentService.CommentRepo.WithTx(ctx, func(ctx context.Context) error {
+		if err := commentService.userAndPostExist(ctx, comment.UserID, comment.PostID); err != nil {
+			return err
+		}
+
+		existingComment, err := commentService.CommentRepo.GetCommentByID(ctx, comment.ID)
+		if err != nil {
+			return err
+		}
 
-	if _, err := commentService.CommentRepo.UpdateComment(ctx, commentID, comment); err != nil {
+		// without an If-Match precondition, write over whatever is stored
+		if comment.Version == 0 {
+			comment.Version = existingComment.Version
+		}
+
+		// keep what PUT does not carry
+		comment.CreatedAt = existingComment.CreatedAt
+		comment.PublishedAt = existingComment.PublishedAt
+
+		updatedComment, err = commentService.CommentRepo.UpdateComment(ctx, commentID, comment)
+		if err != nil {
+			return err
+		}
+		if err := commentService.Webhooks.Enqueue(ctx, WebhookCommentUpdated, commentEvent(updatedComment)); err != nil {
+			return err
+		}
+		return recordUpdate(ctx, commentService.Stream, existingComment, updatedComment)
+	})
+	if err != nil {
 		log.Printf("Error updating comment with ID %d: %v", commentID, err)
 		return nil, err
 	}
-	return existingComment, nil
+	commentService.HTML.Invalidate(commentHTMLKey(commentID))
+	return commentService.present(updatedComment)
 }
 
-func (commentService *CommentService) DeleteCommentByID(ctx context.Context, id uint) error {
-	if err := commentService.CommentRepo.DeleteComment(ctx, id); err != nil {
+func (commentService *CommentSvc) PatchCommentByID(ctx context.Context, commentID uint, version uint, patchType string, patch []byte) (_ *models.GormComment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.PatchCommentByID")
+	defer endSpan(span, &err)
+
+	var patchedComment *models.GormComment
+	err = commentService.CommentRepo.WithTx(ctx, func(ctx context.Context) error {
+		existingComment, err := commentService.CommentRepo.GetCommentByID(ctx, commentID)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			version = existingComment.Version
+		}
+
+		current := commentFields{
+			UserID:        existingComment.UserID,
+			PostID:        existingComment.PostID,
+			Content:       existingComment.Content,
+			ContentFormat: existingComment.ContentFormat,
+		}
+		patched, columns, err := applyPatch(current, patchType, patch)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := columns["content_format"]; ok {
+			if err := content.ValidFormat(patched.ContentFormat); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+			}
+		}
+
+		_, userChanged := columns["user_id"]
+		_, postChanged := columns["post_id"]
+		if userChanged || postChanged {
+			if err := commentService.userAndPostExist(ctx, patched.UserID, patched.PostID); err != nil {
+				return err
+			}
+		}
+
+		if len(columns) == 0 {
+			if version != existingComment.Version {
+				return ErrVersionConflict
+			}
+			patchedComment = existingComment
+			return nil
+		}
+
+		patchedComment, err = commentService.CommentRepo.PatchComment(ctx, commentID, version, columns)
+		if err != nil {
+			return err
+		}
+		if err := commentService.Webhooks.Enqueue(ctx, WebhookCommentUpdated, commentEvent(patchedComment)); err != nil {
+			return err
+		}
+		return recordUpdate(ctx, commentService.Stream, existingComment, patchedComment)
+	})
+	if err != nil {
+		log.Printf("Error patching comment with ID %d: %v", commentID, err)
+		return nil, err
+	}
+	commentService.HTML.Invalidate(commentHTMLKey(commentID))
+
+	return commentService.present(patchedComment)
+}
+
+func (commentService *CommentSvc) DeleteCommentByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.DeleteCommentByID")
+	defer endSpan(span, &err)
+
+	err = commentService.CommentRepo.WithTx(ctx, func(ctx context.Context) error {
+		deleter := &deleter{
+			commentRepo: commentService.CommentRepo,
+			webhooks:    commentService.Webhooks,
+			stream:      commentService.Stream,
+		}
+		return deleter.deleteComment(ctx, id, false)
+	})
+	if err != nil {
 		log.Printf("Error deleting post with ID %d: %v", id, err)
 		return err
 	}
+	commentService.HTML.Invalidate(commentHTMLKey(id))
 	return nil
 }
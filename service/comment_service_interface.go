@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// CommentService holds the comment use cases the handlers depend on.
+type CommentService interface {
+	CreateComment(ctx context.Context, comment models.GormComment) (*models.GormComment, error)
+	GetAllComments(ctx context.Context, fieldset Fieldset) ([]models.GormComment, error)
+	GetCommentByID(ctx context.Context, id uint, fieldset Fieldset) (*models.GormComment, error)
+	GetCommentByUserID(ctx context.Context, userid uint) ([]models.GormComment, error)
+	GetCommentByPostID(ctx context.Context, postid uint) ([]models.GormComment, error)
+	GetCommentByUserIDPostID(ctx context.Context, userid uint, postid uint) (*models.GormComment, error)
+	UpdateCommentByID(ctx context.Context, commentID uint, comment models.GormComment) (*models.GormComment, error)
+	PatchCommentByID(ctx context.Context, commentID uint, version uint, patchType string, patch []byte) (*models.GormComment, error)
+	DeleteCommentByID(ctx context.Context, id uint) error
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// The types of comment event sent to the subscribers of a post.
+const (
+	CommentEventCreated = "created"
+	CommentEventUpdated = "updated"
+	// CommentEventDeleted is sent for comments deleted on their own, along
+	// with their post or author, and for those moved to another post.
+	CommentEventDeleted = "deleted"
+)
+
+// CommentEventRetention is how long events stay in the log, and so how long
+// a subscriber can be away and still resume where it left off.
+const CommentEventRetention = 24 * time.Hour
+
+// CommentStreamBatchSize is how many events are read from the log at once.
+const CommentStreamBatchSize = 100
+
+// commentStreamPoll is how often subscribers read the log without being
+// woken, which catches events whose notification was lost while the
+// listener reconnected.
+const commentStreamPoll = 30 * time.Second
+
+// commentListenRetry is how long Run waits before listening again after
+// losing its connection.
+const commentListenRetry = 5 * time.Second
+
+type CommentStreamSvc struct {
+	EventRepo repository.CommentEventRepository
+	PostRepo  repository.PostRepository
+
+	// subscribers holds the wake channel of every subscription by post
+	mu          sync.Mutex
+	subscribers map[uint]map[chan struct{}]struct{}
+}
+
+func NewCommentStreamService(eventRepo repository.CommentEventRepository, postRepo repository.PostRepository) CommentStreamService {
+	return &CommentStreamSvc{
+		EventRepo:   eventRepo,
+		PostRepo:    postRepo,
+		subscribers: make(map[uint]map[chan struct{}]struct{}),
+	}
+}
+
+func (streamService *CommentStreamSvc) Record(ctx context.Context, eventType string, comment *models.GormComment) (err error) {
+	ctx, span := tracer.Start(ctx, "CommentStreamService.Record")
+	defer endSpan(span, &err)
+
+	encoded, err := json.Marshal(commentEvent(comment))
+	if err != nil {
+		return err
+	}
+
+	err = streamService.EventRepo.CreateCommentEvent(ctx, models.GormCommentEvent{
+		PostID:    comment.PostID,
+		CommentID: comment.ID,
+		Type:      eventType,
+		Data:      string(encoded),
+	})
+	if err != nil {
+		log.Printf("Error storing %s event of comment with ID %d: %v", eventType, comment.ID, err)
+		return err
+	}
+	return nil
+}
+
+// recordUpdate records an update of a comment, or when it moved to another
+// post, its deletion from the old post and creation on the new one.
+func recordUpdate(ctx context.Context, stream CommentStreamService, before *models.GormComment, after *models.GormComment) error {
+	if before.PostID == after.PostID {
+		return stream.Record(ctx, CommentEventUpdated, after)
+	}
+	if err := stream.Record(ctx, CommentEventDeleted, before); err != nil {
+		return err
+	}
+	return stream.Record(ctx, CommentEventCreated, after)
+}
+
+func (streamService *CommentStreamSvc) Subscribe(ctx context.Context, postID uint, lastEventID *uint) (_ <-chan models.GormCommentEvent, err error) {
+	ctx, span := tracer.Start(ctx, "CommentStreamService.Subscribe")
+	defer endSpan(span, &err)
+
+	if _, err := streamService.PostRepo.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	// subscribe before reading where to start, so nothing committed in
+	// between goes unnoticed
+	wake := streamService.subscribe(postID)
+
+	var cursor uint
+	if lastEventID != nil {
+		cursor = *lastEventID
+	} else {
+		var err error
+		cursor, err = streamService.EventRepo.LastCommentEventID(ctx, postID)
+		if err != nil {
+			streamService.unsubscribe(postID, wake)
+			return nil, err
+		}
+	}
+
+	events := make(chan models.GormCommentEvent)
+	go func() {
+		defer close(events)
+		defer streamService.unsubscribe(postID, wake)
+
+		poll := time.NewTicker(commentStreamPoll)
+		defer poll.Stop()
+
+		for {
+			batch, err := streamService.EventRepo.CommentEventsAfter(ctx, postID, cursor, CommentStreamBatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Error reading comment events of post with ID %d: %v", postID, err)
+				}
+				return
+			}
+			for _, event := range batch {
+				select {
+				case events <- event:
+					cursor = event.ID
+				case <-ctx.Done():
+					return
+				}
+			}
+			// a full batch may not be all there is
+			if len(batch) == CommentStreamBatchSize {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			case <-poll.C:
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (streamService *CommentStreamSvc) subscribe(postID uint) chan struct{} {
+	streamService.mu.Lock()
+	defer streamService.mu.Unlock()
+
+	wake := make(chan struct{}, 1)
+	if streamService.subscribers[postID] == nil {
+		streamService.subscribers[postID] = make(map[chan struct{}]struct{})
+	}
+	streamService.subscribers[postID][wake] = struct{}{}
+	return wake
+}
+
+func (streamService *CommentStreamSvc) unsubscribe(postID uint, wake chan struct{}) {
+	streamService.mu.Lock()
+	defer streamService.mu.Unlock()
+
+	delete(streamService.subscribers[postID], wake)
+	if len(streamService.subscribers[postID]) == 0 {
+		delete(streamService.subscribers, postID)
+	}
+}
+
+// wake tells the subscribers of a post there are events to read.
+func (streamService *CommentStreamSvc) wake(postID uint) {
+	streamService.mu.Lock()
+	defer streamService.mu.Unlock()
+
+	for wake := range streamService.subscribers[postID] {
+		// the subscriber is already told if the channel is full
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (streamService *CommentStreamSvc) Run(ctx context.Context, retention time.Duration) {
+	go streamService.purge(ctx, retention, time.Hour)
+
+	for {
+		err := streamService.EventRepo.ListenCommentEvents(ctx, streamService.wake)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Error listening for comment events: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(commentListenRetry):
+		}
+	}
+}
+
+func (streamService *CommentStreamSvc) purge(ctx context.Context, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := streamService.EventRepo.PurgeCommentEventsBefore(ctx, time.Now().Add(-retention))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error purging comment events: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d comment events", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// CommentStreamService follows the comments of posts as they change. Each
+// change is appended to the event log of its post in the transaction that
+// makes it, and subscribers on every replica are woken once it commits.
+type CommentStreamService interface {
+	// Record appends an event of eventType about comment to the log of
+	// its post, from within the transaction of the write it describes.
+	Record(ctx context.Context, eventType string, comment *models.GormComment) error
+	// Subscribe sends the events of a post on the returned channel, oldest
+	// first, until ctx is done or reading the log fails, and then closes
+	// it. With a lastEventID it starts with the events after that one,
+	// otherwise with the next event recorded.
+	Subscribe(ctx context.Context, postID uint, lastEventID *uint) (<-chan models.GormCommentEvent, error)
+	// Run listens for events committed by any replica and wakes the
+	// subscribers of their posts, and purges events older than retention
+	// once an hour, until ctx is done.
+	Run(ctx context.Context, retention time.Duration)
+}
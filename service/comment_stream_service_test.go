@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"gorm.io/gorm"
+)
+
+// subscriberCount is how many subscriptions a post has.
+func (streamService *CommentStreamSvc) subscriberCount(postID uint) int {
+	streamService.mu.Lock()
+	defer streamService.mu.Unlock()
+	return len(streamService.subscribers[postID])
+}
+
+func TestSubscribeReleasesOnCancel(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	author, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Followed"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	streamService := NewCommentStreamService(repo, repo).(*CommentStreamSvc)
+	comment := &models.GormComment{Model: gorm.Model{ID: 1}, UserID: author.ID, PostID: post.ID, Content: "Hi"}
+	if err := streamService.Record(ctx, CommentEventCreated, comment); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	subscribeCtx, cancel := context.WithCancel(ctx)
+	var lastEventID uint
+	events, err := streamService.Subscribe(subscribeCtx, post.ID, &lastEventID)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if event := <-events; event.ID != 1 || event.Type != CommentEventCreated {
+		t.Errorf("event = %+v, want the creation replayed", event)
+	}
+	if count := streamService.subscriberCount(post.ID); count != 1 {
+		t.Errorf("%d subscribers, want 1", count)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("an event came after the subscription was cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the events were not closed after the subscription was cancelled")
+	}
+	if count := streamService.subscriberCount(post.ID); count != 0 {
+		t.Errorf("%d subscribers after cancelling, want 0", count)
+	}
+
+	if _, err := streamService.Subscribe(ctx, post.ID+1, nil); err == nil {
+		t.Error("Subscribe to a missing post succeeded")
+	}
+	if count := streamService.subscriberCount(post.ID + 1); count != 0 {
+		t.Errorf("%d subscribers to the missing post, want 0", count)
+	}
+}
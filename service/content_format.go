@@ -0,0 +1,25 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/content"
+)
+
+// contentFormat defaults an empty format to content.DefaultFormat and
+// rejects formats content cannot render.
+func contentFormat(format string) (string, error) {
+	if format == "" {
+		return content.DefaultFormat, nil
+	}
+	return format, content.ValidFormat(format)
+}
+
+// Keys of the rendered HTML in the content cache.
+func postHTMLKey(id uint) string {
+	return fmt.Sprintf("post:%d", id)
+}
+
+func commentHTMLKey(id uint) string {
+	return fmt.Sprintf("comment:%d", id)
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+)
+
+// DeletePolicy says what happens to dependent rows when the row they belong
+// to is deleted.
+type DeletePolicy string
+
+const (
+	// DeleteCascade deletes the dependent rows along with their parent.
+	DeleteCascade DeletePolicy = "cascade"
+	// DeleteAnonymize hands the dependent rows to the "deleted user"
+	// placeholder. It only applies to rows owned by a user.
+	DeleteAnonymize DeletePolicy = "anonymize"
+	// DeleteBlock refuses to delete a parent that still has dependent rows.
+	DeleteBlock DeletePolicy = "block"
+)
+
+// Identity of the placeholder user that anonymized posts and comments are
+// handed to. The .invalid domain can never receive mail.
+const (
+	DeletedUserName     = "Deleted user"
+	DeletedUserEmail    = "deleted-user@deleted.invalid"
+	DeletedUserUsername = "deleted-user"
+)
+
+// DeletePolicies holds one policy per relation.
+type DeletePolicies struct {
+	UserPosts    DeletePolicy
+	UserComments DeletePolicy
+	PostComments DeletePolicy
+}
+
+// DefaultDeletePolicies removes a user's posts, keeps their comments on
+// other people's posts under the placeholder, and removes a post's comments
+// with the post.
+func DefaultDeletePolicies() DeletePolicies {
+	return DeletePolicies{
+		UserPosts:    DeleteCascade,
+		UserComments: DeleteAnonymize,
+		PostComments: DeleteCascade,
+	}
+}
+
+// DeletePoliciesFromEnv starts from DefaultDeletePolicies and overrides each
+// relation from DELETE_POLICY_USER_POSTS, DELETE_POLICY_USER_COMMENTS and
+// DELETE_POLICY_POST_COMMENTS.
+func DeletePoliciesFromEnv() (DeletePolicies, error) {
+	policies := DefaultDeletePolicies()
+
+	for name, policy := range map[string]*DeletePolicy{
+		"DELETE_POLICY_USER_POSTS":    &policies.UserPosts,
+		"DELETE_POLICY_USER_COMMENTS": &policies.UserComments,
+		"DELETE_POLICY_POST_COMMENTS": &policies.PostComments,
+	} {
+		if value := os.Getenv(name); value != "" {
+			*policy = DeletePolicy(value)
+		}
+	}
+
+	if err := policies.Validate(); err != nil {
+		return DeletePolicies{}, err
+	}
+	return policies, nil
+}
+
+// Validate rejects unknown policies, and anonymizing the comments of a post
+// since those have no user to be taken away from.
+func (policies DeletePolicies) Validate() error {
+	for relation, policy := range map[string]DeletePolicy{
+		"user posts":    policies.UserPosts,
+		"user comments": policies.UserComments,
+		"post comments": policies.PostComments,
+	} {
+		switch policy {
+		case DeleteCascade, DeleteBlock:
+		case DeleteAnonymize:
+			if relation == "post comments" {
+				return fmt.Errorf("delete policy %q does not apply to %s", policy, relation)
+			}
+		default:
+			return fmt.Errorf("unknown delete policy %q for %s", policy, relation)
+		}
+	}
+	return nil
+}
+
+// deleter removes users, posts and comments while applying the delete
+// policies to whatever depends on them. A soft delete moves the dependents to
+// the trash too; a hard delete also takes out the ones already in the trash,
+// so that no foreign key is left dangling. Callers run it inside a
+// transaction so a blocked relation rolls back everything, and call
+// removeFiles once that transaction has committed.
+type deleter struct {
+	userRepo     repository.UserRepository
+	postRepo     repository.PostRepository
+	commentRepo  repository.CommentRepository
+	mediaRepo    repository.MediaRepository
+	reactionRepo repository.ReactionRepository
+	blobs        storage.BlobStore
+	policies     DeletePolicies
+	webhooks     WebhookService
+	stream       CommentStreamService
+
+	// thumbnails and image variants of the posts purged so far
+	files []string
+}
+
+func (d *deleter) deleteUser(ctx context.Context, id uint, hard bool) error {
+	placeholder, err := d.userRepo.GetUserByEmail(ctx, DeletedUserEmail)
+	if err == nil && placeholder.ID == id {
+		return fmt.Errorf("%w: the deleted user placeholder cannot be deleted", ErrDeleteBlocked)
+	}
+
+	// reactions are not anonymized: they go, and stop being counted
+	if hard {
+		reactions, err := d.reactionRepo.DeleteReactionsByUserID(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, reaction := range reactions {
+			if err := d.reactionRepo.AdjustReactionCount(ctx, reaction, -1); err != nil {
+				return err
+			}
+		}
+	}
+
+	postIDs, err := d.postRepo.PostIDsByUserID(ctx, id, hard)
+	if err != nil {
+		return err
+	}
+	if len(postIDs) > 0 {
+		switch d.policies.UserPosts {
+		case DeleteBlock:
+			return fmt.Errorf("%w: user %d still has %d posts", ErrDeleteBlocked, id, len(postIDs))
+		case DeleteAnonymize:
+			placeholder, err := d.placeholderUser(ctx)
+			if err != nil {
+				return err
+			}
+			if err := d.postRepo.ReassignPosts(ctx, id, placeholder.ID); err != nil {
+				return err
+			}
+		default:
+			for _, postID := range postIDs {
+				if err := d.deletePost(ctx, postID, hard); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// read after the posts, whose comments may already be gone with them
+	commentIDs, err := d.commentRepo.CommentIDsByUserID(ctx, id, hard)
+	if err != nil {
+		return err
+	}
+	if len(commentIDs) > 0 {
+		switch d.policies.UserComments {
+		case DeleteBlock:
+			return fmt.Errorf("%w: user %d still has %d comments", ErrDeleteBlocked, id, len(commentIDs))
+		case DeleteAnonymize:
+			placeholder, err := d.placeholderUser(ctx)
+			if err != nil {
+				return err
+			}
+			if err := d.commentRepo.ReassignComments(ctx, id, placeholder.ID); err != nil {
+				return err
+			}
+		default:
+			for _, commentID := range commentIDs {
+				if err := d.deleteComment(ctx, commentID, hard); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if hard {
+		return d.userRepo.PurgeUser(ctx, id)
+	}
+	return d.userRepo.DeleteUser(ctx, id)
+}
+
+func (d *deleter) deletePost(ctx context.Context, id uint, hard bool) error {
+	commentIDs, err := d.commentRepo.CommentIDsByPostID(ctx, id, hard)
+	if err != nil {
+		return err
+	}
+	if len(commentIDs) > 0 {
+		if d.policies.PostComments == DeleteBlock {
+			return fmt.Errorf("%w: post %d still has %d comments", ErrDeleteBlocked, id, len(commentIDs))
+		}
+		for _, commentID := range commentIDs {
+			if err := d.deleteComment(ctx, commentID, hard); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !hard {
+		return d.postRepo.DeletePost(ctx, id)
+	}
+
+	if err := moveToTrash(ctx, id, d.postRepo.DeletePost); err != nil {
+		return err
+	}
+	media, err := d.mediaRepo.DeleteMediaByPostID(ctx, id)
+	if err != nil {
+		return err
+	}
+	post, err := d.postRepo.PurgePost(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if post.Thumbnail != "" {
+		d.files = append(d.files, post.Thumbnail)
+	}
+	for _, variant := range media {
+		d.files = append(d.files, variant.Key)
+	}
+	return nil
+}
+
+// deleteComment tells webhooks and the comment stream about comments
+// leaving for the trash, not about the ones already there.
+func (d *deleter) deleteComment(ctx context.Context, id uint, hard bool) error {
+	comment, err := d.commentRepo.GetCommentByID(ctx, id)
+	if err != nil && !errors.Is(err, repository.ErrNotExist) {
+		return err
+	}
+
+	if !hard {
+		err = d.commentRepo.DeleteComment(ctx, id)
+	} else if err = moveToTrash(ctx, id, d.commentRepo.DeleteComment); err == nil {
+		err = d.commentRepo.PurgeComment(ctx, id)
+	}
+	if err != nil || comment == nil {
+		return err
+	}
+	if err := d.webhooks.Enqueue(ctx, WebhookCommentDeleted, commentEvent(comment)); err != nil {
+		return err
+	}
+	return d.stream.Record(ctx, CommentEventDeleted, comment)
+}
+
+// moveToTrash soft deletes a dependent row that is about to be purged, since
+// the Purge methods only take rows from the trash. Rows already there are
+// left as they are.
+func moveToTrash(ctx context.Context, id uint, softDelete func(context.Context, uint) error) error {
+	if err := softDelete(ctx, id); err != nil && !errors.Is(err, repository.ErrDeleteFailed) {
+		return err
+	}
+	return nil
+}
+
+// removeFiles deletes the files of the purged posts. Failures are only
+// logged: the rows are gone either way.
+func (d *deleter) removeFiles(ctx context.Context) {
+	for _, key := range d.files {
+		if err := d.blobs.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrBlobNotFound) {
+			log.Printf("Error removing thumbnail %s: %v", key, err)
+		}
+	}
+	d.files = nil
+}
+
+// placeholderUser returns the "deleted user" placeholder, creating it the
+// first time it is needed.
+func (d *deleter) placeholderUser(ctx context.Context) (*models.GormUser, error) {
+	placeholder, err := d.userRepo.GetUserByEmail(ctx, DeletedUserEmail)
+	if !errors.Is(err, repository.ErrNotExist) {
+		return placeholder, err
+	}
+
+	// nobody can sign in as the placeholder
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+
+	return d.userRepo.CreateUser(ctx, models.GormUser{
+		Name:     DeletedUserName,
+		Email:    DeletedUserEmail,
+		Username: DeletedUserUsername,
+		Password: hex.EncodeToString(password),
+	})
+}
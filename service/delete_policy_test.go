@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// deleteFixture is Ann, who is about to be deleted, and Bo: each wrote a
+// post, and commented on the other's.
+type deleteFixture struct {
+	repo           *repository.InMemoryRepository
+	ann, bo        *models.GormUser
+	annPost        *models.GormPost
+	boPost         *models.GormPost
+	annComment     *models.GormComment // on Bo's post
+	boComment      *models.GormComment // on Ann's post
+	webhooks       WebhookService
+	stream         CommentStreamService
+	deletePolicies DeletePolicies
+}
+
+func newDeleteFixture(t *testing.T, policies DeletePolicies) *deleteFixture {
+	t.Helper()
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	fixture := &deleteFixture{
+		repo:           repo,
+		webhooks:       NewWebhookService(repo, nil),
+		stream:         NewCommentStreamService(repo, repo),
+		deletePolicies: policies,
+	}
+
+	var err error
+	if fixture.ann, err = repo.CreateUser(ctx, models.GormUser{Name: "Ann", Email: "ann@example.com", Username: "ann"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if fixture.bo, err = repo.CreateUser(ctx, models.GormUser{Name: "Bo", Email: "bo@example.com", Username: "bo"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if fixture.annPost, err = repo.CreatePost(ctx, models.GormPost{UserID: fixture.ann.ID, Title: "Ann's"}); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if fixture.boPost, err = repo.CreatePost(ctx, models.GormPost{UserID: fixture.bo.ID, Title: "Bo's"}); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if fixture.annComment, err = repo.CreateComment(ctx, models.GormComment{UserID: fixture.ann.ID, PostID: fixture.boPost.ID, Content: "By Ann"}); err != nil {
+		t.Fatalf("CreateComment: %v", err)
+	}
+	if fixture.boComment, err = repo.CreateComment(ctx, models.GormComment{UserID: fixture.bo.ID, PostID: fixture.annPost.ID, Content: "By Bo"}); err != nil {
+		t.Fatalf("CreateComment: %v", err)
+	}
+	return fixture
+}
+
+func (fixture *deleteFixture) userService() UserService {
+	return NewUserService(fixture.repo, fixture.repo, fixture.repo, fixture.deletePolicies, nil, fixture.webhooks, fixture.stream)
+}
+
+func (fixture *deleteFixture) trashService() TrashService {
+	return NewTrashService(fixture.repo, fixture.repo, fixture.repo, fixture.repo, fixture.repo, nil, fixture.deletePolicies, fixture.webhooks, fixture.stream)
+}
+
+// postOwner is who a live post belongs to, or 0 once it is in the trash.
+func (fixture *deleteFixture) postOwner(t *testing.T, id uint) uint {
+	t.Helper()
+	post, err := fixture.repo.GetPostByID(context.Background(), id)
+	if errors.Is(err, repository.ErrNotExist) {
+		return 0
+	}
+	if err != nil {
+		t.Fatalf("GetPostByID: %v", err)
+	}
+	return post.UserID
+}
+
+// commentOwner is who a live comment belongs to, or 0 once it is in the
+// trash.
+func (fixture *deleteFixture) commentOwner(t *testing.T, id uint) uint {
+	t.Helper()
+	comment, err := fixture.repo.GetCommentByID(context.Background(), id)
+	if errors.Is(err, repository.ErrNotExist) {
+		return 0
+	}
+	if err != nil {
+		t.Fatalf("GetCommentByID: %v", err)
+	}
+	return comment.UserID
+}
+
+// placeholderID is the ID of the "deleted user" placeholder, or 0 when
+// there is none.
+func (fixture *deleteFixture) placeholderID(t *testing.T) uint {
+	t.Helper()
+	placeholder, err := fixture.repo.GetUserByEmail(context.Background(), DeletedUserEmail)
+	if errors.Is(err, repository.ErrNotExist) {
+		return 0
+	}
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+	return placeholder.ID
+}
+
+func TestDeleteUserPolicies(t *testing.T) {
+	ctx := context.Background()
+	const trashed, ann, placeholder = "trashed", "ann", "placeholder"
+
+	for _, tt := range []struct {
+		name     string
+		policies DeletePolicies
+		// who owns Ann's post, her comment and Bo's comment afterwards,
+		// when the delete goes through
+		annPost, annComment, boComment string
+		blocked                        bool
+	}{
+		{
+			name:     "cascade",
+			policies: DeletePolicies{UserPosts: DeleteCascade, UserComments: DeleteCascade, PostComments: DeleteCascade},
+			annPost:  trashed, annComment: trashed, boComment: trashed,
+		},
+		{
+			name:     "default",
+			policies: DefaultDeletePolicies(),
+			annPost:  trashed, annComment: placeholder, boComment: trashed,
+		},
+		{
+			name:     "anonymize",
+			policies: DeletePolicies{UserPosts: DeleteAnonymize, UserComments: DeleteAnonymize, PostComments: DeleteCascade},
+			annPost:  placeholder, annComment: placeholder, boComment: "bo",
+		},
+		{
+			name:     "block posts",
+			policies: DeletePolicies{UserPosts: DeleteBlock, UserComments: DeleteCascade, PostComments: DeleteCascade},
+			blocked:  true,
+		},
+		{
+			// the post goes first, and comes back when the comments block
+			name:     "block comments",
+			policies: DeletePolicies{UserPosts: DeleteCascade, UserComments: DeleteBlock, PostComments: DeleteCascade},
+			blocked:  true,
+		},
+		{
+			name:     "block the comments of posts",
+			policies: DeletePolicies{UserPosts: DeleteCascade, UserComments: DeleteCascade, PostComments: DeleteBlock},
+			blocked:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture := newDeleteFixture(t, tt.policies)
+			err := fixture.userService().DeleteUserByID(ctx, fixture.ann.ID)
+
+			if tt.blocked {
+				if !errors.Is(err, ErrDeleteBlocked) {
+					t.Fatalf("DeleteUserByID err = %v, want ErrDeleteBlocked", err)
+				}
+				// nothing moved
+				if _, err := fixture.repo.GetUserByID(ctx, fixture.ann.ID); err != nil {
+					t.Errorf("GetUserByID of Ann: %v", err)
+				}
+				tt.annPost, tt.annComment, tt.boComment = ann, ann, "bo"
+			} else if err != nil {
+				t.Fatalf("DeleteUserByID: %v", err)
+			} else if _, err := fixture.repo.GetUserByID(ctx, fixture.ann.ID); !errors.Is(err, repository.ErrNotExist) {
+				t.Errorf("GetUserByID of Ann err = %v, want ErrNotExist", err)
+			}
+
+			owners := map[string]uint{trashed: 0, ann: fixture.ann.ID, "bo": fixture.bo.ID, placeholder: fixture.placeholderID(t)}
+			if (owners[placeholder] != 0) != (tt.annPost == placeholder || tt.annComment == placeholder) {
+				t.Errorf("placeholder ID = %d, want one only when something is anonymized", owners[placeholder])
+			}
+			if got := fixture.postOwner(t, fixture.annPost.ID); got != owners[tt.annPost] {
+				t.Errorf("Ann's post belongs to %d, want %s", got, tt.annPost)
+			}
+			if got := fixture.commentOwner(t, fixture.annComment.ID); got != owners[tt.annComment] {
+				t.Errorf("Ann's comment belongs to %d, want %s", got, tt.annComment)
+			}
+			if got := fixture.commentOwner(t, fixture.boComment.ID); got != owners[tt.boComment] {
+				t.Errorf("Bo's comment belongs to %d, want %s", got, tt.boComment)
+			}
+			if got := fixture.postOwner(t, fixture.boPost.ID); got != fixture.bo.ID {
+				t.Errorf("Bo's post belongs to %d, want Bo", got)
+			}
+		})
+	}
+}
+
+func TestDeletePlaceholderIsBlocked(t *testing.T) {
+	ctx := context.Background()
+	fixture := newDeleteFixture(t, DefaultDeletePolicies())
+	userService := fixture.userService()
+
+	// Ann's comment is handed to the placeholder, which stays
+	if err := userService.DeleteUserByID(ctx, fixture.ann.ID); err != nil {
+		t.Fatalf("DeleteUserByID: %v", err)
+	}
+	placeholderID := fixture.placeholderID(t)
+	if err := userService.DeleteUserByID(ctx, placeholderID); !errors.Is(err, ErrDeleteBlocked) {
+		t.Errorf("DeleteUserByID of the placeholder err = %v, want ErrDeleteBlocked", err)
+	}
+}
+
+func TestPurgeUserTakesBackReactions(t *testing.T) {
+	ctx := context.Background()
+	fixture := newDeleteFixture(t, DefaultDeletePolicies())
+	repo := fixture.repo
+
+	react := func(reaction models.GormReaction) {
+		t.Helper()
+		if _, err := repo.CreateReaction(ctx, reaction); err != nil {
+			t.Fatalf("CreateReaction: %v", err)
+		}
+		if err := repo.AdjustReactionCount(ctx, reaction, 1); err != nil {
+			t.Fatalf("AdjustReactionCount: %v", err)
+		}
+	}
+	react(models.GormReaction{UserID: fixture.ann.ID, PostID: &fixture.boPost.ID, Type: "like"})
+	react(models.GormReaction{UserID: fixture.ann.ID, PostID: &fixture.boPost.ID, Type: "love"})
+	react(models.GormReaction{UserID: fixture.bo.ID, PostID: &fixture.boPost.ID, Type: "like"})
+	// her own comment outlives her, handed to the placeholder
+	react(models.GormReaction{UserID: fixture.ann.ID, CommentID: &fixture.annComment.ID, Type: "wow"})
+
+	if err := fixture.userService().DeleteUserByID(ctx, fixture.ann.ID); err != nil {
+		t.Fatalf("DeleteUserByID: %v", err)
+	}
+
+	// a soft delete keeps the reactions, so a restore loses nothing
+	post, err := repo.GetPostByID(ctx, fixture.boPost.ID)
+	if err != nil {
+		t.Fatalf("GetPostByID: %v", err)
+	}
+	if post.ReactionCounts["like"] != 2 || post.ReactionCounts["love"] != 1 {
+		t.Errorf("counts after the soft delete = %v, want like 2 and love 1", post.ReactionCounts)
+	}
+
+	if err := fixture.trashService().PurgeUserByID(ctx, fixture.ann.ID); err != nil {
+		t.Fatalf("PurgeUserByID: %v", err)
+	}
+	if post, err = repo.GetPostByID(ctx, fixture.boPost.ID); err != nil {
+		t.Fatalf("GetPostByID: %v", err)
+	}
+	if post.ReactionCounts["like"] != 1 || post.ReactionCounts["love"] != 0 {
+		t.Errorf("counts after the purge = %v, want Bo's like alone", post.ReactionCounts)
+	}
+	if _, ok := post.ReactionCounts["love"]; ok {
+		t.Errorf("counts after the purge = %v, want love gone rather than 0", post.ReactionCounts)
+	}
+	comment, err := repo.GetCommentByID(ctx, fixture.annComment.ID)
+	if err != nil {
+		t.Fatalf("GetCommentByID: %v", err)
+	}
+	if len(comment.ReactionCounts) != 0 {
+		t.Errorf("comment counts after the purge = %v, want none", comment.ReactionCounts)
+	}
+	if _, err := repo.GetUserByID(ctx, fixture.ann.ID); !errors.Is(err, repository.ErrNotExist) {
+		t.Errorf("GetUserByID of Ann err = %v, want ErrNotExist", err)
+	}
+}
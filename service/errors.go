@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/content"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+var (
+	ErrUserNotFound        = errors.New("user with the specified ID does not exist")
+	ErrPostNotFound        = errors.New("post with the specified ID does not exist")
+	ErrNotFound            = repository.ErrNotExist
+	ErrVersionConflict     = repository.ErrVersionConflict
+	ErrConflict            = repository.ErrDuplicate
+	ErrDeleteBlocked       = errors.New("delete blocked by dependent rows")
+	ErrUnsupportedType     = errors.New("unsupported image type")
+	ErrTooLarge            = errors.New("upload is too large")
+	ErrUnknownFormat       = content.ErrUnknownFormat
+	ErrUnknownReaction     = errors.New("unknown reaction type")
+	ErrInvalidList         = errors.New("invalid reading list name")
+	ErrSelfFollow          = errors.New("users cannot follow themselves")
+	ErrInvalidTags         = errors.New("invalid tags")
+	ErrInvalidAnalytics    = errors.New("invalid analytics query")
+	ErrUnknownNotification = errors.New("unknown notification type")
+	ErrInvalidResetToken   = errors.New("password reset token is invalid or expired")
+	ErrInvalidWebhook      = errors.New("invalid webhook")
+	ErrDeliveryPending     = errors.New("webhook delivery is still pending")
+)
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// FanOutLimit is how many followers an author can have and still have
+// their posts written to every follower's timeline when published.
+var FanOutLimit int64 = 10000
+
+// GetFeed reads up to limit published posts by the authors userID follows,
+// newest first, after the cursor; a nil cursor starts at the newest. Most
+// posts come from the user's timeline, the rest from the fallback query
+// over authors with too many followers to fan out to, and the two are
+// merged into one page.
+func (postService *PostSvc) GetFeed(ctx context.Context, userID uint, after *Cursor, limit int) (_ []models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetFeed")
+	defer endSpan(span, &err)
+
+	timeline, err := postService.FollowRepo.Timeline(ctx, userID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	pulled, err := postService.FollowRepo.PulledPosts(ctx, userID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := mergeFeed(timeline, pulled, limit)
+	if err := postService.presentRead(ctx, posts, false); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// mergeFeed merges two pages read newest first into one of up to limit
+// posts. A post republished after its author got too many followers can be
+// in both, and is only kept once.
+func mergeFeed(timeline []models.GormPost, pulled []models.GormPost, limit int) []models.GormPost {
+	posts := append(timeline, pulled...)
+	sort.SliceStable(posts, func(i, j int) bool {
+		if !posts[i].PublishedAt.Equal(posts[j].PublishedAt) {
+			return posts[i].PublishedAt.After(posts[j].PublishedAt)
+		}
+		return posts[i].ID > posts[j].ID
+	})
+
+	seen := map[uint]bool{}
+	merged := []models.GormPost{}
+	for _, post := range posts {
+		if len(merged) == limit {
+			break
+		}
+		if !seen[post.ID] {
+			seen[post.ID] = true
+			merged = append(merged, post)
+		}
+	}
+	return merged
+}
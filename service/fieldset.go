@@ -0,0 +1,332 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+var ErrInvalidFieldset = errors.New("invalid fields or include")
+
+// readableField is a field clients may ask for by name: the columns it is
+// read from and the keys it is written under in a response.
+type readableField struct {
+	columns []string
+	keys    []string
+}
+
+// readableResource is the allowlist of one resource: the fields clients may
+// ask for, the relations they may include and the columns every read needs
+// anyway, to load relations by and for the ETag.
+type readableResource struct {
+	table     string
+	fields    map[string]readableField
+	relations map[string]readableRelation
+	required  []string
+}
+
+type readableRelation struct {
+	key      string
+	resource string
+}
+
+// column is a field stored in a column of the same name.
+func column(name string, key string) readableField {
+	return readableField{columns: []string{name}, keys: []string{key}}
+}
+
+// Every resource is also written with its ID. Passwords are never readable.
+var readable = map[string]readableResource{
+	"user": {
+		table: "users",
+		fields: map[string]readableField{
+			"name":       column("name", "Name"),
+			"email":      column("email", "Email"),
+			"username":   column("username", "Username"),
+			"version":    column("version", "Version"),
+			"created_at": column("created_at", "CreatedAt"),
+			"updated_at": column("updated_at", "UpdatedAt"),
+		},
+		relations: map[string]readableRelation{
+			"posts":    {"Posts", "post"},
+			"comments": {"Comments", "comment"},
+		},
+		required: []string{"id", "version"},
+	},
+	"post": {
+		table: "posts",
+		fields: map[string]readableField{
+			"user_id": column("user_id", "UserID"),
+			"title":   column("title", "Title"),
+			"content": {
+				columns: []string{"content", "content_format"},
+				keys:    []string{"Content", "ContentHTML"},
+			},
+			"content_format":  column("content_format", "ContentFormat"),
+			"excerpt":         column("excerpt", "Excerpt"),
+			"custom_excerpt":  column("custom_excerpt", "CustomExcerpt"),
+			"word_count":      column("word_count", "WordCount"),
+			"reading_minutes": column("reading_minutes", "ReadingMinutes"),
+			"tags":            column("tags", "Tags"),
+			"thumbnail": {
+				columns: []string{"thumbnail"},
+				keys:    []string{"Thumbnail", "ThumbnailURL", "ThumbnailSrcset"},
+			},
+			"is_published":    column("is_published", "IsPublished"),
+			"reaction_counts": column("reaction_counts", "ReactionCounts"),
+			"published_at":    column("published_at", "PublishedAt"),
+			"version":         column("version", "Version"),
+			"created_at":      column("created_at", "CreatedAt"),
+			"updated_at":      column("updated_at", "UpdatedAt"),
+			// worked out per reader rather than read from a column
+			"bookmarked": {keys: []string{"Bookmarked"}},
+		},
+		relations: map[string]readableRelation{
+			"user":     {"User", "user"},
+			"comments": {"Comments", "comment"},
+		},
+		required: []string{"id", "version", "user_id"},
+	},
+	"comment": {
+		table: "comments",
+		fields: map[string]readableField{
+			"user_id": column("user_id", "UserID"),
+			"post_id": column("post_id", "PostID"),
+			"content": {
+				columns: []string{"content", "content_format"},
+				keys:    []string{"Content", "ContentHTML"},
+			},
+			"content_format":  column("content_format", "ContentFormat"),
+			"reaction_counts": column("reaction_counts", "ReactionCounts"),
+			"published_at":    column("published_at", "PublishedAt"),
+			"version":         column("version", "Version"),
+			"created_at":      column("created_at", "CreatedAt"),
+			"updated_at":      column("updated_at", "UpdatedAt"),
+		},
+		relations: map[string]readableRelation{
+			"user": {"User", "user"},
+			"post": {"Post", "post"},
+		},
+		required: []string{"id", "version", "user_id", "post_id"},
+	},
+}
+
+// Fieldset picks what a read returns: some of the fields of each resource,
+// as in ?fields[post]=title,published_at, and the relations included with
+// the resource read, as in ?include=user,comments. The zero Fieldset returns
+// every field and the relations a read loads by default.
+type Fieldset struct {
+	resource string
+	fields   map[string][]string
+	include  []string
+}
+
+// NewFieldset validates fields, keyed by resource, and include against the
+// allowlist of resource. A nil include keeps the default relations, unless
+// fields narrows resource itself, in which case none are loaded.
+func NewFieldset(resource string, fields map[string][]string, include []string) (Fieldset, error) {
+	top, ok := readable[resource]
+	if !ok {
+		return Fieldset{}, fmt.Errorf("%w: unknown resource %q", ErrInvalidFieldset, resource)
+	}
+
+	for name, names := range fields {
+		allowed, ok := readable[name]
+		if !ok {
+			return Fieldset{}, fmt.Errorf("%w: unknown resource %q in fields", ErrInvalidFieldset, name)
+		}
+		for _, field := range names {
+			if _, ok := allowed.fields[field]; !ok {
+				return Fieldset{}, fmt.Errorf("%w: %s has no readable field %q, want one of %s",
+					ErrInvalidFieldset, name, field, strings.Join(readableFields(name), ", "))
+			}
+		}
+	}
+	for _, name := range include {
+		if _, ok := top.relations[name]; !ok {
+			return Fieldset{}, fmt.Errorf("%w: %s has no relation %q", ErrInvalidFieldset, resource, name)
+		}
+	}
+
+	if _, ok := fields[resource]; ok && include == nil {
+		include = []string{}
+	}
+
+	return Fieldset{resource: resource, fields: fields, include: include}, nil
+}
+
+// String writes the fieldset in a normal form, as in
+// "fields[post]=id,title;include=user": the same for every query string
+// asking for the same fields and relations, in whatever order.
+func (fieldset Fieldset) String() string {
+	resources := make([]string, 0, len(fieldset.fields))
+	for resource := range fieldset.fields {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	parts := []string{}
+	for _, resource := range resources {
+		parts = append(parts, "fields["+resource+"]="+strings.Join(sortedSet(fieldset.fields[resource]), ","))
+	}
+	// nil keeps the default relations, which differs from including none
+	if fieldset.include != nil {
+		parts = append(parts, "include="+strings.Join(sortedSet(fieldset.include), ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// sortedSet returns names sorted, without repeats.
+func sortedSet(names []string) []string {
+	set := []string{}
+	for _, name := range names {
+		if !contains(set, name) {
+			set = append(set, name)
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+// Returns reports whether the read returns field of resource.
+func (fieldset Fieldset) Returns(resource string, field string) bool {
+	names, ok := fieldset.fields[resource]
+	if !ok {
+		return true
+	}
+	for _, name := range names {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// narrows reports whether the fieldset names the fields of resource.
+func (fieldset Fieldset) narrows(resource string) bool {
+	_, ok := fieldset.fields[resource]
+	return ok
+}
+
+// query turns the fieldset into the columns and relations to read.
+func (fieldset Fieldset) query() repository.Query {
+	query := repository.Query{Include: fieldset.include}
+	if len(fieldset.fields) == 0 {
+		return query
+	}
+
+	query.Fields = make(map[string][]string, len(fieldset.fields))
+	for resource, names := range fieldset.fields {
+		allowed := readable[resource]
+
+		seen := map[string]bool{}
+		columns := []string{}
+		add := func(names ...string) {
+			for _, name := range names {
+				if !seen[name] {
+					seen[name] = true
+					columns = append(columns, name)
+				}
+			}
+		}
+
+		add(allowed.required...)
+		for _, name := range names {
+			add(allowed.fields[name].columns...)
+		}
+		query.Fields[allowed.table] = columns
+	}
+
+	return query
+}
+
+// Project leaves out of v, one or a slice of the models of the fieldset's
+// resource, everything the fieldset does not return, and the password of
+// every user in it. The result is meant to be encoded as JSON.
+func (fieldset Fieldset) Project(v interface{}) (interface{}, error) {
+	if len(fieldset.fields) == 0 && fieldset.include == nil && fieldset.resource != "user" {
+		return v, nil
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return fieldset.project(decoded, fieldset.resource, true), nil
+}
+
+// project narrows a decoded model of resource, or a slice of them. Only the
+// top level resource has relations of its own to include or leave out.
+func (fieldset Fieldset) project(value interface{}, resource string, top bool) interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		for i := range value {
+			value[i] = fieldset.project(value[i], resource, top)
+		}
+	case map[string]interface{}:
+		allowed := readable[resource]
+		if resource == "user" {
+			delete(value, "Password")
+		}
+
+		keep := map[string]bool{"ID": true}
+		for _, name := range fieldset.fields[resource] {
+			for _, key := range allowed.fields[name].keys {
+				keep[key] = true
+			}
+		}
+
+		if top {
+			for name, relation := range allowed.relations {
+				if fieldset.include != nil && !contains(fieldset.include, name) {
+					delete(value, relation.key)
+					continue
+				}
+				if related := value[relation.key]; related != nil {
+					value[relation.key] = fieldset.project(related, relation.resource, false)
+					keep[relation.key] = true
+				}
+			}
+		}
+
+		if fieldset.narrows(resource) {
+			for key := range value {
+				if !keep[key] {
+					delete(value, key)
+				}
+			}
+		}
+	}
+	return value
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readableFields lists the fields of resource clients may ask for.
+func readableFields(resource string) []string {
+	names := []string{}
+	for name := range readable[resource].fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
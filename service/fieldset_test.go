@@ -0,0 +1,52 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+func TestProjectLeavesOutPasswords(t *testing.T) {
+	author := &models.GormUser{Name: "Ann", Password: "secret"}
+	post := models.GormPost{Title: "Hello", User: author}
+	user := models.GormUser{Name: "Ann", Password: "secret", Posts: []*models.GormPost{{Title: "Hello"}}}
+
+	tests := []struct {
+		name     string
+		resource string
+		fields   map[string][]string
+		include  []string
+		v        interface{}
+	}{
+		{"post including the user", "post", nil, []string{"user"}, post},
+		{"post with some fields including the user", "post", map[string][]string{"post": {"title"}}, []string{"user"}, []models.GormPost{post}},
+		{"post with some fields of the user", "post", map[string][]string{"user": {"name"}}, []string{"user"}, post},
+		{"user", "user", nil, nil, user},
+		{"users including posts", "user", nil, []string{"posts"}, []models.GormUser{user}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fieldset, err := NewFieldset(test.resource, test.fields, test.include)
+			if err != nil {
+				t.Fatalf("NewFieldset: %v", err)
+			}
+			projected, err := fieldset.Project(test.v)
+			if err != nil {
+				t.Fatalf("Project: %v", err)
+			}
+			encoded, err := json.Marshal(projected)
+			if err != nil {
+				t.Fatalf("encoding: %v", err)
+			}
+			if strings.Contains(string(encoded), "Password") || strings.Contains(string(encoded), "secret") {
+				t.Errorf("Project wrote a password: %s", encoded)
+			}
+			if !strings.Contains(string(encoded), "Ann") {
+				t.Errorf("Project left out the user: %s", encoded)
+			}
+		})
+	}
+}
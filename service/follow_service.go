@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+type FollowSvc struct {
+	FollowRepo    repository.FollowRepository
+	UserRepo      repository.UserRepository
+	Notifications NotificationService
+}
+
+func NewFollowService(followRepo repository.FollowRepository, userRepo repository.UserRepository, notifications NotificationService) FollowService {
+	return &FollowSvc{
+		FollowRepo:    followRepo,
+		UserRepo:      userRepo,
+		Notifications: notifications,
+	}
+}
+
+func (followService *FollowSvc) Follow(ctx context.Context, followerID uint, followeeID uint) (_ *models.GormFollow, _ bool, err error) {
+	ctx, span := tracer.Start(ctx, "FollowService.Follow")
+	defer endSpan(span, &err)
+
+	if followerID == followeeID {
+		return nil, false, ErrSelfFollow
+	}
+
+	var follow *models.GormFollow
+	var created bool
+	err = followService.FollowRepo.WithTx(ctx, func(ctx context.Context) error {
+		_, err := followService.UserRepo.GetUserByID(ctx, followerID)
+		if errors.Is(err, repository.ErrNotExist) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// trashed users cannot be followed
+		if _, err := followService.UserRepo.GetUserByID(ctx, followeeID); err != nil {
+			return err
+		}
+
+		follow, created, err = followService.FollowRepo.CreateFollow(ctx, followerID, followeeID)
+		if err != nil || !created {
+			return err
+		}
+
+		// what the author published so far shows up on the feed right away
+		if err := followService.FollowRepo.Backfill(ctx, followerID, followeeID); err != nil {
+			return err
+		}
+
+		return followService.Notifications.Notify(ctx, models.GormNotification{UserID: followeeID, ActorID: followerID, Type: NotificationFollow})
+	})
+	if err != nil {
+		log.Printf("Error following user with ID %d by user ID %d: %v", followeeID, followerID, err)
+		return nil, false, err
+	}
+
+	return follow, created, nil
+}
+
+func (followService *FollowSvc) Unfollow(ctx context.Context, followerID uint, followeeID uint) (err error) {
+	ctx, span := tracer.Start(ctx, "FollowService.Unfollow")
+	defer endSpan(span, &err)
+
+	// unfollowing someone not followed leaves nothing to do
+	err = followService.FollowRepo.WithTx(ctx, func(ctx context.Context) error {
+		deleted, err := followService.FollowRepo.DeleteFollow(ctx, followerID, followeeID)
+		if err != nil || !deleted {
+			return err
+		}
+		return followService.FollowRepo.ClearTimeline(ctx, followerID, followeeID)
+	})
+	if err != nil {
+		log.Printf("Error unfollowing user with ID %d by user ID %d: %v", followeeID, followerID, err)
+		return err
+	}
+	return nil
+}
+
+func (followService *FollowSvc) GetFollowers(ctx context.Context, userID uint, page Page) (_ []models.GormUser, _ int64, err error) {
+	ctx, span := tracer.Start(ctx, "FollowService.GetFollowers")
+	defer endSpan(span, &err)
+
+	if _, err := followService.UserRepo.GetUserByID(ctx, userID); err != nil {
+		return nil, 0, err
+	}
+	return followService.FollowRepo.Followers(ctx, userID, page)
+}
+
+func (followService *FollowSvc) GetFollowing(ctx context.Context, userID uint, page Page) (_ []models.GormUser, _ int64, err error) {
+	ctx, span := tracer.Start(ctx, "FollowService.GetFollowing")
+	defer endSpan(span, &err)
+
+	if _, err := followService.UserRepo.GetUserByID(ctx, userID); err != nil {
+		return nil, 0, err
+	}
+	return followService.FollowRepo.Following(ctx, userID, page)
+}
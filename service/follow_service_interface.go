@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// FollowService lets users follow each other, which puts the posts of the
+// authors they follow on their feed.
+type FollowService interface {
+	// Follow reports false when followerID already followed followeeID.
+	Follow(ctx context.Context, followerID uint, followeeID uint) (*models.GormFollow, bool, error)
+	Unfollow(ctx context.Context, followerID uint, followeeID uint) error
+	// GetFollowers and GetFollowing read one page of the users following
+	// or followed by a user, and how many there are in all.
+	GetFollowers(ctx context.Context, userID uint, page Page) ([]models.GormUser, int64, error)
+	GetFollowing(ctx context.Context, userID uint, page Page) ([]models.GormUser, int64, error)
+}
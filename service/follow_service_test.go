@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+func TestFollows(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	var users []*models.GormUser
+	for _, username := range []string{"ann", "bo", "cy"} {
+		user, err := repo.CreateUser(ctx, models.GormUser{Email: username + "@example.com", Username: username})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		users = append(users, user)
+	}
+	ann, bo, cy := users[0], users[1], users[2]
+	// fanned out, as the posts of authors with few followers are
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: ann.ID, Title: "Published", IsPublished: true, PublishedAt: time.Now(), FannedOut: true})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if _, err := repo.CreatePost(ctx, models.GormPost{UserID: ann.ID, Title: "Draft"}); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	notifications := newNotificationService(repo)
+	followService := NewFollowService(repo, repo, notifications)
+
+	// timeline is the IDs of the posts on a user's timeline
+	timeline := func(userID uint) []uint {
+		t.Helper()
+		posts, err := repo.Timeline(ctx, userID, nil, 10)
+		if err != nil {
+			t.Fatalf("Timeline: %v", err)
+		}
+		ids := []uint{}
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		return ids
+	}
+
+	for _, follow := range []struct {
+		follower, followee *models.GormUser
+		created            bool
+	}{
+		{bo, ann, true},
+		{bo, ann, false},
+		{cy, ann, true},
+		{ann, bo, true},
+	} {
+		got, created, err := followService.Follow(ctx, follow.follower.ID, follow.followee.ID)
+		if err != nil {
+			t.Fatalf("Follow(%s, %s): %v", follow.follower.Username, follow.followee.Username, err)
+		}
+		if created != follow.created || got.FollowerID != follow.follower.ID || got.FolloweeID != follow.followee.ID {
+			t.Errorf("Follow(%s, %s) = %+v, %t; want created %t", follow.follower.Username, follow.followee.Username, got, created, follow.created)
+		}
+	}
+
+	// what Ann published so far is on Bo's timeline, and the draft is not
+	if ids := timeline(bo.ID); len(ids) != 1 || ids[0] != post.ID {
+		t.Errorf("Bo's timeline = %v, want Ann's published post", ids)
+	}
+
+	// Ann heard about each follower once
+	received, total, err := notifications.GetNotifications(ctx, ann.ID, false, Page{Number: 1, Size: 10})
+	if err != nil || total != 2 {
+		t.Fatalf("GetNotifications of Ann = %+v, %d, %v, want 2", received, total, err)
+	}
+	for _, notification := range received {
+		if notification.Type != NotificationFollow || (notification.ActorID != bo.ID && notification.ActorID != cy.ID) {
+			t.Errorf("notification = %+v, want a follow by Bo or Cy", notification)
+		}
+	}
+
+	followers, total, err := followService.GetFollowers(ctx, ann.ID, Page{Number: 1, Size: 1})
+	if err != nil || total != 2 || len(followers) != 1 {
+		t.Errorf("GetFollowers of Ann = %d on the page, %d, %v; want 1 of 2", len(followers), total, err)
+	}
+	following, total, err := followService.GetFollowing(ctx, bo.ID, Page{Number: 1, Size: 10})
+	if err != nil || total != 1 || following[0].ID != ann.ID {
+		t.Errorf("GetFollowing of Bo = %+v, %d, %v; want Ann", following, total, err)
+	}
+
+	// unfollowing twice is fine, and takes Ann's posts off the timeline
+	for i := 0; i < 2; i++ {
+		if err := followService.Unfollow(ctx, bo.ID, ann.ID); err != nil {
+			t.Fatalf("Unfollow: %v", err)
+		}
+	}
+	if ids := timeline(bo.ID); len(ids) != 0 {
+		t.Errorf("Bo's timeline after unfollowing = %v, want it empty", ids)
+	}
+	if _, total, err := followService.GetFollowers(ctx, ann.ID, Page{Number: 1, Size: 10}); err != nil || total != 1 {
+		t.Errorf("GetFollowers of Ann after Bo unfollowed = %d, %v, want 1", total, err)
+	}
+	if ids := timeline(cy.ID); len(ids) != 1 {
+		t.Errorf("Cy's timeline = %v, want it left alone", ids)
+	}
+}
+
+func TestFollowErrors(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	ann, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	trashed, err := repo.CreateUser(ctx, models.GormUser{Email: "bo@example.com", Username: "bo"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := repo.DeleteUser(ctx, trashed.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	followService := NewFollowService(repo, repo, newNotificationService(repo))
+
+	for _, tt := range []struct {
+		name                   string
+		followerID, followeeID uint
+		want                   error
+	}{
+		{"self", ann.ID, ann.ID, ErrSelfFollow},
+		{"unknown follower", ann.ID + 10, ann.ID, ErrUserNotFound},
+		{"trashed follower", trashed.ID, ann.ID, ErrUserNotFound},
+		{"trashed followee", ann.ID, trashed.ID, ErrNotFound},
+		{"unknown followee", ann.ID, ann.ID + 10, ErrNotFound},
+	} {
+		if _, _, err := followService.Follow(ctx, tt.followerID, tt.followeeID); !errors.Is(err, tt.want) {
+			t.Errorf("Follow of %s err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, _, err := followService.GetFollowers(ctx, trashed.ID, Page{Number: 1, Size: 10}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetFollowers of a trashed user err = %v, want ErrNotFound", err)
+	}
+}
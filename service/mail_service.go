@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	netmail "net/mail"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/content"
+	"github.com/bellaananda/go-postgresql-blog-http.git/mail"
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// MailQueueSize is how many messages can wait to be sent before more are
+// dropped. The queue is kept in memory only, so mail still waiting, or
+// waiting to be retried, is also lost when the process stops. Every drop is
+// logged and counted in Dropped.
+const MailQueueSize = 1000
+
+// MailAttempts is how many times a message is tried before it is given up
+// on. The wait after each failed attempt doubles from MailRetryDelay.
+const MailAttempts = 5
+
+var MailRetryDelay = 30 * time.Second
+
+// MailedNotifications are the types of notification also sent by mail.
+// Reactions and follows are left to the in-app list.
+var MailedNotifications = []string{NotificationComment, NotificationReply}
+
+// mailJob is one message waiting to be sent. compose puts it together when
+// it is sent, so it reflects what is stored by then.
+type mailJob struct {
+	name    string
+	compose func(ctx context.Context) (mail.Message, error)
+	attempt int
+}
+
+type MailSvc struct {
+	Mailer      mail.Mailer
+	Templates   *mail.Templates
+	UserRepo    repository.UserRepository
+	PostRepo    repository.PostRepository
+	CommentRepo repository.CommentRepository
+	Site        Site
+	jobs        chan *mailJob
+	dropped     atomic.Uint64
+}
+
+func NewMailService(mailer mail.Mailer, templates *mail.Templates, userRepo repository.UserRepository, postRepo repository.PostRepository, commentRepo repository.CommentRepository, site Site) MailService {
+	return &MailSvc{
+		Mailer:      mailer,
+		Templates:   templates,
+		UserRepo:    userRepo,
+		PostRepo:    postRepo,
+		CommentRepo: commentRepo,
+		Site:        site,
+		jobs:        make(chan *mailJob, MailQueueSize),
+	}
+}
+
+// welcomeMail, commentMail and passwordResetMail are what the templates of
+// the same names are rendered with.
+type welcomeMail struct {
+	Name      string
+	Username  string
+	SiteTitle string
+	SiteURL   string
+}
+
+type commentMail struct {
+	Name      string
+	Actor     string
+	Reply     bool
+	PostTitle string
+	PostURL   string
+	Comment   string
+}
+
+type passwordResetMail struct {
+	Name      string
+	SiteTitle string
+	ResetURL  string
+	ValidFor  string
+}
+
+func (mailService *MailSvc) Welcome(userID uint) {
+	mailService.enqueue(&mailJob{
+		name: fmt.Sprintf("welcome mail to user ID %d", userID),
+		compose: func(ctx context.Context) (mail.Message, error) {
+			user, err := mailService.UserRepo.GetUserByID(ctx, userID)
+			if err != nil {
+				return mail.Message{}, err
+			}
+			return mailService.render(user, "welcome", welcomeMail{
+				Name:      displayName(user),
+				Username:  user.Username,
+				SiteTitle: mailService.Site.Title,
+				SiteURL:   mailService.Site.link("/"),
+			})
+		},
+	})
+}
+
+func (mailService *MailSvc) Notification(notification models.GormNotification) {
+	if !mailedNotification(notification.Type) || notification.PostID == nil || notification.CommentID == nil {
+		return
+	}
+
+	mailService.enqueue(&mailJob{
+		name: fmt.Sprintf("%s mail to user ID %d", notification.Type, notification.UserID),
+		compose: func(ctx context.Context) (mail.Message, error) {
+			user, err := mailService.UserRepo.GetUserByID(ctx, notification.UserID)
+			if err != nil {
+				return mail.Message{}, err
+			}
+			actor, err := mailService.UserRepo.GetUserByID(ctx, notification.ActorID)
+			if err != nil {
+				return mail.Message{}, err
+			}
+			post, err := mailService.PostRepo.GetPostByID(ctx, *notification.PostID)
+			if err != nil {
+				return mail.Message{}, err
+			}
+			// a comment deleted in the meantime is not mailed about
+			comment, err := mailService.CommentRepo.GetCommentByID(ctx, *notification.CommentID)
+			if err != nil {
+				return mail.Message{}, err
+			}
+			summary, err := content.Summarize(comment.ContentFormat, comment.Content)
+			if err != nil {
+				return mail.Message{}, err
+			}
+
+			return mailService.render(user, "comment", commentMail{
+				Name:      displayName(user),
+				Actor:     displayName(actor),
+				Reply:     notification.Type == NotificationReply,
+				PostTitle: post.Title,
+				PostURL:   mailService.Site.postLink(post.ID),
+				Comment:   summary.Excerpt,
+			})
+		},
+	})
+}
+
+func (mailService *MailSvc) PasswordReset(userID uint, token string, validFor time.Duration) {
+	mailService.enqueue(&mailJob{
+		name: fmt.Sprintf("password reset mail to user ID %d", userID),
+		compose: func(ctx context.Context) (mail.Message, error) {
+			user, err := mailService.UserRepo.GetUserByID(ctx, userID)
+			if err != nil {
+				return mail.Message{}, err
+			}
+			return mailService.render(user, "password_reset", passwordResetMail{
+				Name:      displayName(user),
+				SiteTitle: mailService.Site.Title,
+				ResetURL:  mailService.Site.link("/reset-password?token=" + url.QueryEscape(token)),
+				ValidFor:  validFor.String(),
+			})
+		},
+	})
+}
+
+// render fills in the named templates and addresses the mail to user.
+func (mailService *MailSvc) render(user *models.GormUser, name string, data interface{}) (mail.Message, error) {
+	message, err := mailService.Templates.Render(name, data)
+	if err != nil {
+		return mail.Message{}, err
+	}
+	message.To = []string{(&netmail.Address{Name: displayName(user), Address: user.Email}).String()}
+	return message, nil
+}
+
+// enqueue never blocks: with the queue full the message is dropped.
+func (mailService *MailSvc) enqueue(job *mailJob) {
+	select {
+	case mailService.jobs <- job:
+	default:
+		dropped := mailService.dropped.Add(1)
+		log.Printf("Mail queue of %d is full, dropping %s (%d dropped so far)", MailQueueSize, job.name, dropped)
+	}
+}
+
+func (mailService *MailSvc) Dropped() uint64 {
+	return mailService.dropped.Load()
+}
+
+func (mailService *MailSvc) Run(ctx context.Context, workers int) {
+	done := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-mailService.jobs:
+					mailService.send(ctx, job)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < workers; i++ {
+		<-done
+	}
+}
+
+// send makes one attempt at a job and schedules the next one when it
+// fails. Mail to or about something that no longer exists, or that can
+// never be sent, is dropped.
+func (mailService *MailSvc) send(ctx context.Context, job *mailJob) {
+	ctx, span := tracer.Start(ctx, "MailService.Send")
+	defer span.End()
+
+	job.attempt++
+	message, err := job.compose(ctx)
+	if err == nil {
+		err = mailService.Mailer.Send(ctx, message)
+	}
+	if err == nil {
+		return
+	}
+	recordError(span, err)
+
+	if errors.Is(err, repository.ErrNotExist) || errors.Is(err, mail.ErrInvalidMessage) {
+		log.Printf("Dropping %s: %v", job.name, err)
+		return
+	}
+	if job.attempt >= MailAttempts || ctx.Err() != nil {
+		log.Printf("Error sending %s, giving up after %d attempts: %v", job.name, job.attempt, err)
+		return
+	}
+
+	delay := MailRetryDelay << (job.attempt - 1)
+	log.Printf("Error sending %s, retrying in %s: %v", job.name, delay, err)
+	time.AfterFunc(delay, func() { mailService.enqueue(job) })
+}
+
+// displayName is what a user is called in mail: their name, or their
+// username when they gave none.
+func displayName(user *models.GormUser) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	return user.Username
+}
+
+func mailedNotification(notificationType string) bool {
+	for _, mailed := range MailedNotifications {
+		if notificationType == mailed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// MailService queues mail and delivers it in the background, retrying what
+// fails, so requests never wait on a mail server. Queueing returns at once;
+// messages are only put together when they are sent.
+type MailService interface {
+	Welcome(userID uint)
+	// Notification mails the notifications of types in MailedNotifications
+	// and ignores the rest.
+	Notification(notification models.GormNotification)
+	PasswordReset(userID uint, token string, validFor time.Duration)
+	// Run sends queued mail on the given number of workers until ctx is
+	// done.
+	Run(ctx context.Context, workers int)
+	// Dropped is how many messages were dropped because the queue was
+	// full since the service started.
+	Dropped() uint64
+}
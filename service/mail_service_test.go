@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/mail"
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+func TestMailQueueCountsDrops(t *testing.T) {
+	// nothing runs the queue, so it fills up and the rest is dropped
+	mailService := NewMailService(nil, nil, nil, nil, nil, Site{})
+	for i := 0; i < MailQueueSize+2; i++ {
+		mailService.Welcome(uint(i + 1))
+	}
+
+	if dropped := mailService.Dropped(); dropped != 2 {
+		t.Errorf("Dropped() = %d, want 2", dropped)
+	}
+}
+
+// fakeMailer hands every message it is asked to send to the test, and fails
+// with the errors in fail until it runs out of them.
+type fakeMailer struct {
+	sent chan mail.Message
+	fail chan error
+}
+
+func newFakeMailer(fail ...error) *fakeMailer {
+	mailer := &fakeMailer{sent: make(chan mail.Message, 100), fail: make(chan error, len(fail))}
+	for _, err := range fail {
+		mailer.fail <- err
+	}
+	return mailer
+}
+
+func (mailer *fakeMailer) Send(ctx context.Context, message mail.Message) error {
+	mailer.sent <- message
+	select {
+	case err := <-mailer.fail:
+		return err
+	default:
+		return nil
+	}
+}
+
+// waitForMail returns the next message sent, or fails the test.
+func (mailer *fakeMailer) waitForMail(t *testing.T) mail.Message {
+	t.Helper()
+	select {
+	case message := <-mailer.sent:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail was sent")
+		return mail.Message{}
+	}
+}
+
+// checkNoMail fails the test if anything else is sent, waiting long enough
+// for any retry to come due.
+func (mailer *fakeMailer) checkNoMail(t *testing.T) {
+	t.Helper()
+	select {
+	case message := <-mailer.sent:
+		t.Errorf("unexpected mail %q", message.Subject)
+	case <-time.After(MailRetryDelay << MailAttempts):
+	}
+}
+
+// runMailService runs a mail service sending through mailer until the test
+// ends, with retries that come due at once.
+func runMailService(t *testing.T, mailer mail.Mailer, repo *repository.InMemoryRepository) MailService {
+	t.Helper()
+	templates, err := mail.DefaultTemplates()
+	if err != nil {
+		t.Fatalf("DefaultTemplates: %v", err)
+	}
+
+	retryDelay := MailRetryDelay
+	MailRetryDelay = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		MailRetryDelay = retryDelay
+	})
+
+	mailService := NewMailService(mailer, templates, repo, repo, repo, Site{URL: "https://blog.example.com", Title: "Blog"})
+	go func() {
+		mailService.Run(ctx, 1)
+		close(done)
+	}()
+	return mailService
+}
+
+func TestMailQueueRetries(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	user, err := repo.CreateUser(context.Background(), models.GormUser{Name: "Ann", Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	failure := errors.New("connection refused")
+
+	t.Run("until sent", func(t *testing.T) {
+		mailer := newFakeMailer(failure, failure)
+		mailService := runMailService(t, mailer, repo)
+
+		mailService.Welcome(user.ID)
+		for attempt := 1; attempt <= 3; attempt++ {
+			message := mailer.waitForMail(t)
+			if len(message.To) != 1 || !strings.Contains(message.To[0], "ann@example.com") {
+				t.Errorf("attempt %d sent to %v, want Ann", attempt, message.To)
+			}
+		}
+		mailer.checkNoMail(t)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		fail := make([]error, MailAttempts+1)
+		for i := range fail {
+			fail[i] = failure
+		}
+		mailer := newFakeMailer(fail...)
+		mailService := runMailService(t, mailer, repo)
+
+		mailService.Welcome(user.ID)
+		for attempt := 1; attempt <= MailAttempts; attempt++ {
+			mailer.waitForMail(t)
+		}
+		mailer.checkNoMail(t)
+	})
+
+	t.Run("drops invalid messages", func(t *testing.T) {
+		mailer := newFakeMailer(mail.ErrInvalidMessage)
+		mailService := runMailService(t, mailer, repo)
+
+		mailService.Welcome(user.ID)
+		mailer.waitForMail(t)
+		mailer.checkNoMail(t)
+	})
+
+	t.Run("drops mail to nobody", func(t *testing.T) {
+		mailer := newFakeMailer()
+		mailService := runMailService(t, mailer, repo)
+
+		mailService.Welcome(user.ID + 100)
+		mailer.checkNoMail(t)
+	})
+}
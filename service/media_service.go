@@ -0,0 +1,300 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/imaging"
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+)
+
+// mediaQueueSize bounds how many uploads can wait for processing.
+const mediaQueueSize = 64
+
+// DefaultThumbnailWidths are the variant widths made when
+// THUMBNAIL_WIDTHS is not set.
+var DefaultThumbnailWidths = []int{320, 768, 1280}
+
+// ThumbnailWidthsFromEnv reads a comma separated list of widths from
+// THUMBNAIL_WIDTHS.
+func ThumbnailWidthsFromEnv() ([]int, error) {
+	value := os.Getenv("THUMBNAIL_WIDTHS")
+	if value == "" {
+		return DefaultThumbnailWidths, nil
+	}
+
+	widths := []int{}
+	for _, field := range strings.Split(value, ",") {
+		width, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || width < 1 {
+			return nil, fmt.Errorf("invalid THUMBNAIL_WIDTHS entry %q", field)
+		}
+		widths = append(widths, width)
+	}
+	return widths, nil
+}
+
+type mediaJob struct {
+	postID    uint
+	sourceKey string
+}
+
+type MediaSvc struct {
+	MediaRepo repository.MediaRepository
+	PostRepo  repository.PostRepository
+	Blobs     storage.BlobStore
+	Widths    []int
+
+	jobs chan mediaJob
+}
+
+func NewMediaService(mediaRepo repository.MediaRepository, postRepo repository.PostRepository, blobs storage.BlobStore, widths []int) MediaService {
+	return &MediaSvc{
+		MediaRepo: mediaRepo,
+		PostRepo:  postRepo,
+		Blobs:     blobs,
+		Widths:    widths,
+		jobs:      make(chan mediaJob, mediaQueueSize),
+	}
+}
+
+// Enqueue schedules variants for an upload without waiting for them. When
+// the queue is full the stored copy keeps being served at full size.
+func (mediaService *MediaSvc) Enqueue(postID uint, sourceKey string) {
+	select {
+	case mediaService.jobs <- mediaJob{postID: postID, sourceKey: sourceKey}:
+	default:
+		log.Printf("Media queue is full, skipping variants of %s", sourceKey)
+	}
+}
+
+// Run processes queued uploads on the given number of workers until ctx is
+// done.
+func (mediaService *MediaSvc) Run(ctx context.Context, workers int) {
+	done := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-mediaService.jobs:
+					if err := mediaService.GenerateVariants(ctx, job.postID, job.sourceKey); err != nil {
+						log.Printf("Error generating variants of %s: %v", job.sourceKey, err)
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < workers; i++ {
+		<-done
+	}
+}
+
+// GenerateVariants makes and records every variant of an upload and bumps
+// the version of the post. If the post has moved on to another thumbnail,
+// or is gone, by the time they are ready, the files are thrown away again.
+func (mediaService *MediaSvc) GenerateVariants(ctx context.Context, postID uint, sourceKey string) (err error) {
+	ctx, span := tracer.Start(ctx, "MediaService.GenerateVariants")
+	defer endSpan(span, &err)
+
+	blob, err := mediaService.Blobs.Get(ctx, sourceKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return err
+	}
+
+	variants, err := imaging.Variants(data, mediaService.Widths)
+	if err != nil {
+		return err
+	}
+
+	// Upload the files before touching the database
+	base := strings.TrimSuffix(sourceKey, path.Ext(sourceKey))
+	media := make([]models.GormPostMedia, 0, len(variants))
+	for _, variant := range variants {
+		key := fmt.Sprintf("%s-%dw%s", base, variant.Width, extensionOf(variant.ContentType))
+		if err := mediaService.Blobs.Put(ctx, key, variant.ContentType, bytes.NewReader(variant.Data)); err != nil {
+			mediaService.removeFiles(ctx, media)
+			return err
+		}
+		media = append(media, models.GormPostMedia{
+			PostID:      postID,
+			SourceKey:   sourceKey,
+			Width:       variant.Width,
+			Height:      variant.Height,
+			Key:         key,
+			ContentType: variant.ContentType,
+		})
+	}
+
+	err = mediaService.MediaRepo.WithTx(ctx, func(ctx context.Context) error {
+		post, err := mediaService.PostRepo.GetPostByID(ctx, postID)
+		if err != nil {
+			return err
+		}
+		if post.Thumbnail != sourceKey {
+			return errStaleThumbnail
+		}
+
+		for _, variant := range media {
+			if _, err := mediaService.MediaRepo.CreateMedia(ctx, variant); err != nil {
+				return err
+			}
+		}
+
+		// The srcset is part of the post, so it moves to a new version and
+		// copies validated against the old one are fetched again. A write
+		// that got in since the read fails this with ErrVersionConflict
+		// and the stored copy keeps being served as is.
+		_, err = mediaService.PostRepo.PatchPost(ctx, postID, post.Version, map[string]interface{}{})
+		return err
+	})
+	if err != nil {
+		mediaService.removeFiles(ctx, media)
+		if errors.Is(err, errStaleThumbnail) || errors.Is(err, repository.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// errStaleThumbnail rolls back variants of a thumbnail that was replaced
+// while they were being made.
+var errStaleThumbnail = errors.New("thumbnail was replaced")
+
+// RemoveVariants deletes the variants made from an upload, rows and files.
+func (mediaService *MediaSvc) RemoveVariants(ctx context.Context, sourceKey string) (err error) {
+	ctx, span := tracer.Start(ctx, "MediaService.RemoveVariants")
+	defer endSpan(span, &err)
+
+	media, err := mediaService.MediaRepo.DeleteMediaBySourceKey(ctx, sourceKey)
+	if err != nil {
+		return err
+	}
+
+	mediaService.removeFiles(ctx, media)
+	return nil
+}
+
+func (mediaService *MediaSvc) removeFiles(ctx context.Context, media []models.GormPostMedia) {
+	for _, variant := range media {
+		if err := mediaService.Blobs.Delete(ctx, variant.Key); err != nil && !errors.Is(err, storage.ErrBlobNotFound) {
+			log.Printf("Error removing blob %s: %v", variant.Key, err)
+		}
+	}
+}
+
+// AttachURLs fills ThumbnailURL and ThumbnailSrcset on each post from its
+// current variants, loading them for the whole slice in one query.
+func (mediaService *MediaSvc) AttachURLs(ctx context.Context, posts []models.GormPost) error {
+	ids := []uint{}
+	for _, post := range posts {
+		if post.Thumbnail != "" {
+			ids = append(ids, post.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	media, err := mediaService.MediaRepo.MediaByPostIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byPost := make(map[uint][]models.GormPostMedia)
+	for _, variant := range media {
+		byPost[variant.PostID] = append(byPost[variant.PostID], variant)
+	}
+
+	for i := range posts {
+		post := &posts[i]
+		if post.Thumbnail == "" {
+			continue
+		}
+
+		post.ThumbnailURL = thumbnailURL(post.ID, 0)
+		candidates := []string{}
+		for _, variant := range byPost[post.ID] {
+			if variant.SourceKey == post.Thumbnail {
+				candidates = append(candidates, fmt.Sprintf("%s %dw", thumbnailURL(post.ID, variant.Width), variant.Width))
+			}
+		}
+		post.ThumbnailSrcset = strings.Join(candidates, ", ")
+	}
+
+	return nil
+}
+
+// OpenVariant opens the variant of the post's thumbnail that is at least
+// width wide, or the widest one when width is 0 or larger than all of them.
+// Until the variants are ready the copy SetThumbnail stored is served, which
+// already has none of the upload's metadata.
+func (mediaService *MediaSvc) OpenVariant(ctx context.Context, post *models.GormPost, width int) (_ *storage.Blob, err error) {
+	ctx, span := tracer.Start(ctx, "MediaService.OpenVariant")
+	defer endSpan(span, &err)
+
+	media, err := mediaService.MediaRepo.MediaByPostIDs(ctx, []uint{post.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	current := []models.GormPostMedia{}
+	for _, variant := range media {
+		if variant.SourceKey == post.Thumbnail {
+			current = append(current, variant)
+		}
+	}
+	if len(current) == 0 {
+		return mediaService.Blobs.Get(ctx, post.Thumbnail)
+	}
+
+	sort.Slice(current, func(i, j int) bool { return current[i].Width < current[j].Width })
+	chosen := current[len(current)-1]
+	if width > 0 {
+		for _, variant := range current {
+			if variant.Width >= width {
+				chosen = variant
+				break
+			}
+		}
+	}
+
+	return mediaService.Blobs.Get(ctx, chosen.Key)
+}
+
+// thumbnailURL is where a post's thumbnail is served; width 0 asks for the
+// widest variant.
+func thumbnailURL(postID uint, width int) string {
+	url := fmt.Sprintf("/api/posts/%d/thumbnail", postID)
+	if width > 0 {
+		url += "?w=" + strconv.Itoa(width)
+	}
+	return url
+}
+
+func extensionOf(contentType string) string {
+	if extension, ok := thumbnailTypes[contentType]; ok {
+		return extension
+	}
+	return ""
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+)
+
+// MediaService turns uploaded thumbnails into resized variants in the
+// background and exposes them to readers of a post.
+type MediaService interface {
+	Enqueue(postID uint, sourceKey string)
+	Run(ctx context.Context, workers int)
+	GenerateVariants(ctx context.Context, postID uint, sourceKey string) error
+	RemoveVariants(ctx context.Context, sourceKey string) error
+	AttachURLs(ctx context.Context, posts []models.GormPost) error
+	OpenVariant(ctx context.Context, post *models.GormPost, width int) (*storage.Blob, error)
+}
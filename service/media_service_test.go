@@ -0,0 +1,57 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+)
+
+func TestGenerateVariantsBumpsVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	blobs, err := storage.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore: %v", err)
+	}
+
+	var upload bytes.Buffer
+	if err := png.Encode(&upload, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatalf("encoding the upload: %v", err)
+	}
+	const key = "thumbnails/1/upload.png"
+	if err := blobs.Put(ctx, key, "image/png", &upload); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	author, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Pictured", Thumbnail: key})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	mediaService := NewMediaService(repo, repo, blobs, []int{100})
+	if err := mediaService.GenerateVariants(ctx, post.ID, key); err != nil {
+		t.Fatalf("GenerateVariants: %v", err)
+	}
+
+	media, err := repo.MediaByPostIDs(ctx, []uint{post.ID})
+	if err != nil || len(media) == 0 {
+		t.Fatalf("MediaByPostIDs = %v, %v, want the variants", media, err)
+	}
+	got, err := repo.GetPostByID(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("GetPostByID: %v", err)
+	}
+	if got.Version != post.Version+1 {
+		t.Errorf("version = %d after the variants were stored, want %d", got.Version, post.Version+1)
+	}
+}
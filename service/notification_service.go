@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// The types of notification users are sent.
+const (
+	// NotificationComment tells an author their post was commented on.
+	NotificationComment = "comment"
+	// NotificationReply tells a commenter someone else commented on the
+	// same post after them.
+	NotificationReply = "reply"
+	// NotificationReaction tells an author their post or comment was
+	// reacted to.
+	NotificationReaction = "reaction"
+	// NotificationFollow tells a user someone started following them.
+	NotificationFollow = "follow"
+)
+
+// NotificationTypes are the types of notification users can turn on or off.
+var NotificationTypes = []string{NotificationComment, NotificationReply, NotificationReaction, NotificationFollow}
+
+type NotificationSvc struct {
+	NotificationRepo repository.NotificationRepository
+	UserRepo         repository.UserRepository
+	Mail             MailService
+}
+
+func NewNotificationService(notificationRepo repository.NotificationRepository, userRepo repository.UserRepository, mail MailService) NotificationService {
+	return &NotificationSvc{
+		NotificationRepo: notificationRepo,
+		UserRepo:         userRepo,
+		Mail:             mail,
+	}
+}
+
+func (notificationService *NotificationSvc) Notify(ctx context.Context, notifications ...models.GormNotification) (err error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.Notify")
+	defer endSpan(span, &err)
+
+	// ask once per type who turned it off
+	recipients := map[string][]uint{}
+	for _, notification := range notifications {
+		if notification.UserID != notification.ActorID {
+			recipients[notification.Type] = append(recipients[notification.Type], notification.UserID)
+		}
+	}
+	muted := make(map[string]map[uint]bool, len(recipients))
+	for notificationType, userIDs := range recipients {
+		mutedIDs, err := notificationService.NotificationRepo.MutedUserIDs(ctx, notificationType, userIDs)
+		if err != nil {
+			return err
+		}
+		muted[notificationType] = mutedIDs
+	}
+
+	kept := []models.GormNotification{}
+	for _, notification := range notifications {
+		if notification.UserID == notification.ActorID || muted[notification.Type][notification.UserID] {
+			continue
+		}
+		kept = append(kept, notification)
+	}
+
+	if err := notificationService.NotificationRepo.CreateNotifications(ctx, kept); err != nil {
+		log.Printf("Error storing %d notifications: %v", len(kept), err)
+		return err
+	}
+
+	// nothing is mailed about a write that is rolled back
+	repository.AfterCommit(ctx, func() {
+		for _, notification := range kept {
+			notificationService.Mail.Notification(notification)
+		}
+	})
+	return nil
+}
+
+func (notificationService *NotificationSvc) GetNotifications(ctx context.Context, userID uint, unread bool, page Page) (_ []models.GormNotification, _ int64, err error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.GetNotifications")
+	defer endSpan(span, &err)
+
+	return notificationService.NotificationRepo.Notifications(ctx, userID, unread, page)
+}
+
+func (notificationService *NotificationSvc) UnreadCount(ctx context.Context, userID uint) (_ int64, err error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.UnreadCount")
+	defer endSpan(span, &err)
+
+	return notificationService.NotificationRepo.UnreadCount(ctx, userID)
+}
+
+func (notificationService *NotificationSvc) MarkRead(ctx context.Context, userID uint, ids []uint) (_ int64, err error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.MarkRead")
+	defer endSpan(span, &err)
+
+	var unread int64
+	err = notificationService.NotificationRepo.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := notificationService.NotificationRepo.MarkRead(ctx, userID, ids); err != nil {
+			return err
+		}
+
+		var err error
+		unread, err = notificationService.NotificationRepo.UnreadCount(ctx, userID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error marking notifications read for user ID %d: %v", userID, err)
+		return 0, err
+	}
+
+	return unread, nil
+}
+
+func (notificationService *NotificationSvc) GetPreferences(ctx context.Context, userID uint) (_ map[string]bool, err error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.GetPreferences")
+	defer endSpan(span, &err)
+
+	stored, err := notificationService.NotificationRepo.NotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return withDefaultPreferences(stored), nil
+}
+
+func (notificationService *NotificationSvc) SetPreferences(ctx context.Context, userID uint, preferences map[string]bool) (_ map[string]bool, err error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.SetPreferences")
+	defer endSpan(span, &err)
+
+	for notificationType := range preferences {
+		if !validNotificationType(notificationType) {
+			return nil, fmt.Errorf("%w %q, want one of %s", ErrUnknownNotification, notificationType, strings.Join(NotificationTypes, ", "))
+		}
+	}
+
+	var stored map[string]bool
+	err = notificationService.NotificationRepo.WithTx(ctx, func(ctx context.Context) error {
+		_, err := notificationService.UserRepo.GetUserByID(ctx, userID)
+		if errors.Is(err, repository.ErrNotExist) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := notificationService.NotificationRepo.SetNotificationPreferences(ctx, userID, preferences); err != nil {
+			return err
+		}
+
+		stored, err = notificationService.NotificationRepo.NotificationPreferences(ctx, userID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error setting notification preferences for user ID %d: %v", userID, err)
+		return nil, err
+	}
+
+	return withDefaultPreferences(stored), nil
+}
+
+// withDefaultPreferences fills in the types a user never set, which are on.
+func withDefaultPreferences(stored map[string]bool) map[string]bool {
+	preferences := make(map[string]bool, len(NotificationTypes))
+	for _, notificationType := range NotificationTypes {
+		enabled, ok := stored[notificationType]
+		preferences[notificationType] = enabled || !ok
+	}
+	return preferences
+}
+
+func validNotificationType(notificationType string) bool {
+	for _, known := range NotificationTypes {
+		if notificationType == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// NotificationService tells users when others comment on, reply under or
+// react to what they wrote, or follow them, and lets them choose which of
+// those they hear about.
+type NotificationService interface {
+	// Notify stores notifications from within the transaction of the write
+	// that caused them, leaving out any addressed to their own actor or to
+	// a user who turned their type off. The ones kept are mailed once the
+	// transaction commits.
+	Notify(ctx context.Context, notifications ...models.GormNotification) error
+	// GetNotifications reads one page of a user's notifications, newest
+	// first, and how many there are in all. unread leaves out the ones
+	// already read.
+	GetNotifications(ctx context.Context, userID uint, unread bool, page Page) ([]models.GormNotification, int64, error)
+	UnreadCount(ctx context.Context, userID uint) (int64, error)
+	// MarkRead marks the user's notifications among ids read, or all of
+	// them when ids is nil, and returns how many are left unread.
+	MarkRead(ctx context.Context, userID uint, ids []uint) (int64, error)
+	// GetPreferences tells, for every type in NotificationTypes, whether
+	// the user hears about it.
+	GetPreferences(ctx context.Context, userID uint) (map[string]bool, error)
+	// SetPreferences turns the types named in preferences on or off,
+	// leaves the others as they are, and returns every type's setting.
+	SetPreferences(ctx context.Context, userID uint, preferences map[string]bool) (map[string]bool, error)
+}
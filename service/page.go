@@ -0,0 +1,15 @@
+package service
+
+import "github.com/bellaananda/go-postgresql-blog-http.git/repository"
+
+// Page sizes of listings read page by page.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Page is one page of a listing, numbered from 1.
+type Page = repository.Page
+
+// Cursor is a position in a feed read newest first.
+type Cursor = repository.Cursor
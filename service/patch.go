@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Media types accepted by the Patch*ByID methods.
+const (
+	MergePatchType = "application/merge-patch+json" // RFC 7396
+	JSONPatchType  = "application/json-patch+json"  // RFC 6902
+)
+
+var (
+	ErrUnsupportedPatch = errors.New("unsupported patch media type")
+	ErrInvalidPatch     = errors.New("invalid patch document")
+)
+
+// userFields, postFields and commentFields are the writable parts of each
+// resource as a patch document sees them. The JSON names double as column
+// names. The password is write-only and never part of the document; see
+// takePassword.
+type userFields struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+// passwordPath is where a user patch may write the password.
+const passwordPath = "/password"
+
+type postFields struct {
+	UserID        uint     `json:"user_id"`
+	Title         string   `json:"title"`
+	Content       string   `json:"content"`
+	ContentFormat string   `json:"content_format"`
+	Excerpt       string   `json:"excerpt"`
+	IsPublished   bool     `json:"is_published"`
+	Tags          []string `json:"tags"`
+}
+
+type commentFields struct {
+	UserID        uint   `json:"user_id"`
+	PostID        uint   `json:"post_id"`
+	Content       string `json:"content"`
+	ContentFormat string `json:"content_format"`
+}
+
+// applyPatch applies a merge patch or JSON patch to current and returns the
+// result together with the columns whose value changed.
+func applyPatch[T any](current T, patchType string, patch []byte) (T, map[string]interface{}, error) {
+	var patched T
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		return patched, nil, err
+	}
+
+	var result []byte
+	switch patchType {
+	case MergePatchType:
+		result, err = jsonpatch.MergePatch(original, patch)
+	case JSONPatchType:
+		var decoded jsonpatch.Patch
+		decoded, err = jsonpatch.DecodePatch(patch)
+		if err == nil {
+			result, err = decoded.Apply(original)
+		}
+	default:
+		return patched, nil, ErrUnsupportedPatch
+	}
+	if err != nil {
+		return patched, nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	// a patch may only touch known fields, with values of the right type
+	decoder := json.NewDecoder(bytes.NewReader(result))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return patched, nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return patched, changedColumns(current, patched), nil
+}
+
+// changedColumns lists, by JSON name, the fields that differ between before
+// and after.
+func changedColumns[T any](before, after T) map[string]interface{} {
+	columns := make(map[string]interface{})
+
+	beforeValue := reflect.ValueOf(before)
+	afterValue := reflect.ValueOf(after)
+	for i := 0; i < beforeValue.NumField(); i++ {
+		if reflect.DeepEqual(beforeValue.Field(i).Interface(), afterValue.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(beforeValue.Type().Field(i).Tag.Get("json"), ",")
+		columns[name] = afterValue.Field(i).Interface()
+	}
+
+	return columns
+}
+
+// takePassword pulls the password out of a user patch, so the rest can be
+// applied to a document that never holds the stored one. A password may only
+// be set, through a merge value or a JSON patch add or replace; nothing may
+// read, move, test or remove it. The password is nil when the patch leaves it
+// alone.
+func takePassword(patchType string, patch []byte) ([]byte, *string, error) {
+	switch patchType {
+	case MergePatchType:
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(patch, &members); err != nil {
+			// not an object, so it has no password in it
+			return patch, nil, nil
+		}
+		value, ok := members["password"]
+		if !ok {
+			return patch, nil, nil
+		}
+		password, err := decodePassword(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		delete(members, "password")
+		rest, err := json.Marshal(members)
+		return rest, password, err
+
+	case JSONPatchType:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+
+		var password *string
+		rest := make(jsonpatch.Patch, 0, len(operations))
+		for _, operation := range operations {
+			path, _ := operation.Path()
+			from, _ := operation.From()
+			touchesPassword := path == passwordPath || strings.HasPrefix(path, passwordPath+"/")
+			readsPassword := from == passwordPath || strings.HasPrefix(from, passwordPath+"/")
+
+			switch {
+			case readsPassword:
+				return nil, nil, fmt.Errorf("%w: the password cannot be read", ErrInvalidPatch)
+			case !touchesPassword:
+				rest = append(rest, operation)
+			case path != passwordPath:
+				return nil, nil, fmt.Errorf("%w: the password is a string", ErrInvalidPatch)
+			case operation.Kind() == "add" || operation.Kind() == "replace":
+				value, ok := operation["value"]
+				if !ok || value == nil {
+					return nil, nil, fmt.Errorf("%w: %s of the password has no value", ErrInvalidPatch, operation.Kind())
+				}
+				if password, err = decodePassword(*value); err != nil {
+					return nil, nil, err
+				}
+			default:
+				return nil, nil, fmt.Errorf("%w: the password can only be added or replaced, not %s", ErrInvalidPatch, operation.Kind())
+			}
+		}
+		restPatch, err := json.Marshal(rest)
+		return restPatch, password, err
+	}
+
+	// applyPatch turns the patch away
+	return patch, nil, nil
+}
+
+// decodePassword reads a password value out of a patch. Null, which would
+// blank the password, and the empty string are turned away.
+func decodePassword(value json.RawMessage) (*string, error) {
+	var password *string
+	if err := json.Unmarshal(value, &password); err != nil {
+		return nil, fmt.Errorf("%w: the password is a string", ErrInvalidPatch)
+	}
+	if password == nil || *password == "" {
+		return nil, fmt.Errorf("%w: the password cannot be removed", ErrInvalidPatch)
+	}
+	return password, nil
+}
@@ -3,48 +3,228 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	// "fmt"
 	// "log"
+	"github.com/bellaananda/go-postgresql-blog-http.git/content"
 	"github.com/bellaananda/go-postgresql-blog-http.git/models"
 	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
-
-	"gorm.io/gorm"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
 )
 
-type PostService struct {
-	PostRepo repository.PostRepository
-	db       *gorm.DB
+type PostSvc struct {
+	PostRepo     repository.PostRepository
+	UserRepo     repository.UserRepository
+	CommentRepo  repository.CommentRepository
+	BookmarkRepo repository.BookmarkRepository
+	FollowRepo   repository.FollowRepository
+	Media        MediaService
+	Blobs        storage.BlobStore
+	Policies     DeletePolicies
+	Webhooks     WebhookService
+	Stream       CommentStreamService
+	HTML         *content.Cache
 }
 
-func NewPostService(postRepo repository.PostRepository, db *gorm.DB) *PostService {
-	return &PostService{
-		PostRepo: postRepo,
-		db:       db,
+func NewPostService(postRepo repository.PostRepository, userRepo repository.UserRepository, commentRepo repository.CommentRepository, bookmarkRepo repository.BookmarkRepository, followRepo repository.FollowRepository, media MediaService, blobs storage.BlobStore, policies DeletePolicies, webhooks WebhookService, stream CommentStreamService, html *content.Cache) PostService {
+	return &PostSvc{
+		PostRepo:     postRepo,
+		UserRepo:     userRepo,
+		CommentRepo:  commentRepo,
+		BookmarkRepo: bookmarkRepo,
+		FollowRepo:   followRepo,
+		Media:        media,
+		Blobs:        blobs,
+		Policies:     policies,
+		Webhooks:     webhooks,
+		Stream:       stream,
+		HTML:         html,
 	}
 }
 
-func (postService *PostService) CreatePost(ctx context.Context, post models.GormPost) (*models.GormPost, error) {
-	_, err := postService.PostRepo.GetPostByTitle(ctx, post.Title)
-	if err == nil || !errors.Is(err, repository.ErrNotExist) {
-		return nil, errors.New("a post with this title already exists")
+// userExists maps a missing author onto ErrUserNotFound.
+func (postService *PostSvc) userExists(ctx context.Context, userID uint) error {
+	_, err := postService.UserRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotExist) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// present fills in what a single post carries besides its columns.
+func (postService *PostSvc) present(ctx context.Context, post *models.GormPost) (*models.GormPost, error) {
+	posts := []models.GormPost{*post}
+	if err := postService.presentAll(ctx, posts); err != nil {
+		return nil, err
+	}
+	return &posts[0], nil
+}
+
+// presentAll renders the content of posts to HTML and fills their thumbnail
+// URLs and, for an authenticated reader, whether they bookmarked them.
+func (postService *PostSvc) presentAll(ctx context.Context, posts []models.GormPost) error {
+	if err := postService.renderHTML(posts); err != nil {
+		return err
 	}
+	if err := postService.markBookmarked(ctx, posts); err != nil {
+		return err
+	}
+	return postService.Media.AttachURLs(ctx, posts)
+}
 
-	return postService.PostRepo.CreatePost(ctx, post)
+// presentRead is presentAll for posts read through a fieldset, which only
+// have their content rendered when they were read with it.
+func (postService *PostSvc) presentRead(ctx context.Context, posts []models.GormPost, withContent bool) error {
+	if withContent {
+		if err := postService.renderHTML(posts); err != nil {
+			return err
+		}
+	}
+	if err := postService.markBookmarked(ctx, posts); err != nil {
+		return err
+	}
+	return postService.Media.AttachURLs(ctx, posts)
 }
 
-func (postService *PostService) GetAllPosts(ctx context.Context) ([]models.GormPost, error) {
-	posts, err := postService.PostRepo.AllPosts(ctx)
+// markBookmarked sets whether the reader of ctx bookmarked each of posts,
+// asking about all of them at once. Anonymous reads leave it unset.
+func (postService *PostSvc) markBookmarked(ctx context.Context, posts []models.GormPost) error {
+	userID, ok := ReaderFrom(ctx)
+	if !ok || len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	bookmarked, err := postService.BookmarkRepo.BookmarkedPostIDs(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		flag := bookmarked[posts[i].ID]
+		posts[i].Bookmarked = &flag
+	}
+	return nil
+}
+
+func (postService *PostSvc) renderHTML(posts []models.GormPost) error {
+	for i := range posts {
+		html, err := postService.HTML.Render(postHTMLKey(posts[i].ID), posts[i].Version, posts[i].ContentFormat, posts[i].Content)
+		if err != nil {
+			return err
+		}
+		posts[i].ContentHTML = html
+	}
+	return nil
+}
+
+// summarize stores the word count and reading time of the content of post,
+// and takes its excerpt from the content unless the author wrote one.
+func summarize(post *models.GormPost) error {
+	summary, err := content.Summarize(post.ContentFormat, post.Content)
+	if err != nil {
+		return err
+	}
+
+	post.WordCount = summary.WordCount
+	post.ReadingMinutes = summary.ReadingMinutes
+	if !post.CustomExcerpt {
+		post.Excerpt = summary.Excerpt
+	}
+	return nil
+}
+
+// authorExcerpt marks an excerpt the author wrote as such.
+func authorExcerpt(post *models.GormPost) {
+	post.Excerpt = strings.TrimSpace(post.Excerpt)
+	post.CustomExcerpt = post.Excerpt != ""
+}
+
+func (postService *PostSvc) CreatePost(ctx context.Context, post models.GormPost) (_ *models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.CreatePost")
+	defer endSpan(span, &err)
+
+	format, err := contentFormat(post.ContentFormat)
+	if err != nil {
+		return nil, err
+	}
+	post.ContentFormat = format
+
+	post.Tags, err = postTags(post.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	authorExcerpt(&post)
+	if err := summarize(&post); err != nil {
+		return nil, err
+	}
+
+	var createdPost *models.GormPost
+	err = postService.PostRepo.WithTx(ctx, func(ctx context.Context) error {
+		if err := postService.userExists(ctx, post.UserID); err != nil {
+			return err
+		}
+
+		// idx_posts_title still turns away a post created since the check
+		_, err := postService.PostRepo.GetPostByTitle(ctx, post.Title)
+		if err == nil {
+			return fmt.Errorf("a post with this title already exists: %w", ErrConflict)
+		}
+		if !errors.Is(err, repository.ErrNotExist) {
+			return err
+		}
+
+		createdPost, err = postService.PostRepo.CreatePost(ctx, post)
+		if err != nil || !createdPost.IsPublished {
+			return err
+		}
+		return postService.Webhooks.Enqueue(ctx, WebhookPostPublished, postEvent(createdPost))
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	return postService.present(ctx, createdPost)
+}
+
+func (postService *PostSvc) GetAllPosts(ctx context.Context, includeContent bool, fieldset Fieldset) (_ []models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetAllPosts")
+	defer endSpan(span, &err)
+
+	posts, err := postService.PostRepo.AllPosts(ctx, fieldset.query())
+	if err != nil {
+		return nil, err
+	}
+
+	// the excerpt stands in for the content unless it was asked for, either
+	// with includeContent or by naming it among the fields
+	withContent := fieldset.Returns("post", "content") && (includeContent || fieldset.narrows("post"))
+	if !withContent {
+		for i := range posts {
+			posts[i].Content = ""
+		}
+	}
+
+	if err := postService.presentRead(ctx, posts, withContent); err != nil {
+		return nil, err
+	}
+
 	return posts, nil
 }
 
-func (postService *PostService) GetPostByID(ctx context.Context, id uint) (*models.GormPost, error) {
-	post, err := postService.PostRepo.GetPostByID(ctx, id)
+func (postService *PostSvc) GetPostByID(ctx context.Context, id uint, fieldset Fieldset) (_ *models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetPostByID")
+	defer endSpan(span, &err)
+
+	post, err := postService.PostRepo.FindPost(ctx, id, fieldset.query())
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
 			return nil, err
@@ -52,10 +232,17 @@ func (postService *PostService) GetPostByID(ctx context.Context, id uint) (*mode
 		return nil, err
 	}
 
-	return post, nil
+	posts := []models.GormPost{*post}
+	if err := postService.presentRead(ctx, posts, fieldset.Returns("post", "content")); err != nil {
+		return nil, err
+	}
+	return &posts[0], nil
 }
 
-func (postService *PostService) GetPostByTitle(ctx context.Context, title string) (*models.GormPost, error) {
+func (postService *PostSvc) GetPostByTitle(ctx context.Context, title string) (_ *models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetPostByTitle")
+	defer endSpan(span, &err)
+
 	post, err := postService.PostRepo.GetPostByTitle(ctx, title)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
@@ -64,10 +251,13 @@ func (postService *PostService) GetPostByTitle(ctx context.Context, title string
 		return nil, err
 	}
 
-	return post, nil
+	return postService.present(ctx, post)
 }
 
-func (postService *PostService) GetPostByUserID(ctx context.Context, userid uint) ([]models.GormPost, error) {
+func (postService *PostSvc) GetPostByUserID(ctx context.Context, userid uint) (_ []models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetPostByUserID")
+	defer endSpan(span, &err)
+
 	post, err := postService.PostRepo.GetPostByUserID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
@@ -75,33 +265,221 @@ func (postService *PostService) GetPostByUserID(ctx context.Context, userid uint
 		}
 		return nil, err
 	}
+	if err := postService.presentAll(ctx, post); err != nil {
+		return nil, err
+	}
 	return post, nil
 }
 
-func (postService *PostService) UpdatePostByID(ctx context.Context, postID uint, post models.GormPost) (*models.GormPost, error) {
+func (postService *PostSvc) UpdatePostByID(ctx context.Context, postID uint, post models.GormPost) (_ *models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.UpdatePostByID")
+	defer endSpan(span, &err)
+
 	// Check if the post ID in the URL matches the ID in the post object
 	if postID != post.ID {
 		return nil, errors.New("mismatched post ID in URL and request body")
 	}
 
-	existingPost, err := postService.PostRepo.GetPostByID(ctx, postID)
+	format, err := contentFormat(post.ContentFormat)
+	if err != nil {
+		return nil, err
+	}
+	post.ContentFormat = format
+
+	post.Tags, err = postTags(post.Tags)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = postService.PostRepo.UpdatePost(ctx, postID, post)
+	authorExcerpt(&post)
+	if err := summarize(&post); err != nil {
+		return nil, err
+	}
+
+	var updatedPost *models.GormPost
+	err = postService.PostRepo.WithTx(ctx, func(ctx context.Context) error {
+		if err := postService.userExists(ctx, post.UserID); err != nil {
+			return err
+		}
+
+		existingPost, err := postService.PostRepo.GetPostByID(ctx, postID)
+		if err != nil {
+			return err
+		}
+
+		// without an If-Match precondition, write over whatever is stored
+		if post.Version == 0 {
+			post.Version = existingPost.Version
+		}
+
+		// keep what PUT does not carry
+		post.CreatedAt = existingPost.CreatedAt
+		post.Thumbnail = existingPost.Thumbnail
+		post.PublishedAt = publishedAt(existingPost, post.IsPublished)
+
+		updatedPost, err = postService.PostRepo.UpdatePost(ctx, postID, post)
+		if err != nil {
+			return err
+		}
+		return postService.publish(ctx, existingPost, updatedPost)
+	})
 	if err != nil {
 		log.Printf("Error updating post with ID %d: %v", postID, err)
 		return nil, err
 	}
+	postService.HTML.Invalidate(postHTMLKey(postID))
+
+	return postService.present(ctx, updatedPost)
+}
+
+func (postService *PostSvc) PatchPostByID(ctx context.Context, postID uint, version uint, patchType string, patch []byte) (_ *models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.PatchPostByID")
+	defer endSpan(span, &err)
+
+	var patchedPost *models.GormPost
+	err = postService.PostRepo.WithTx(ctx, func(ctx context.Context) error {
+		existingPost, err := postService.PostRepo.GetPostByID(ctx, postID)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			version = existingPost.Version
+		}
+
+		current := postFields{
+			UserID:        existingPost.UserID,
+			Title:         existingPost.Title,
+			Content:       existingPost.Content,
+			ContentFormat: existingPost.ContentFormat,
+			Excerpt:       existingPost.Excerpt,
+			IsPublished:   existingPost.IsPublished,
+			Tags:          existingPost.Tags,
+		}
+		patched, columns, err := applyPatch(current, patchType, patch)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := columns["content_format"]; ok {
+			if err := content.ValidFormat(patched.ContentFormat); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+			}
+		}
+		if _, ok := columns["tags"]; ok {
+			tags, err := postTags(patched.Tags)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+			}
+			columns["tags"] = tags
+		}
+
+		_, contentChanged := columns["content"]
+		_, formatChanged := columns["content_format"]
+		_, excerptChanged := columns["excerpt"]
+		if contentChanged || formatChanged || excerptChanged {
+			summarized := models.GormPost{
+				Content:       patched.Content,
+				ContentFormat: patched.ContentFormat,
+				Excerpt:       patched.Excerpt,
+				CustomExcerpt: existingPost.CustomExcerpt,
+			}
+			if excerptChanged {
+				authorExcerpt(&summarized)
+			}
+			if err := summarize(&summarized); err != nil {
+				return err
+			}
+			columns["excerpt"] = summarized.Excerpt
+			columns["custom_excerpt"] = summarized.CustomExcerpt
+			columns["word_count"] = summarized.WordCount
+			columns["reading_minutes"] = summarized.ReadingMinutes
+		}
+		if _, ok := columns["user_id"]; ok {
+			if err := postService.userExists(ctx, patched.UserID); err != nil {
+				return err
+			}
+		}
+		if _, ok := columns["is_published"]; ok {
+			columns["published_at"] = publishedAt(existingPost, patched.IsPublished)
+		}
+
+		if len(columns) == 0 {
+			if version != existingPost.Version {
+				return ErrVersionConflict
+			}
+			patchedPost = existingPost
+			return nil
+		}
+
+		patchedPost, err = postService.PostRepo.PatchPost(ctx, postID, version, columns)
+		if err != nil {
+			return err
+		}
+		return postService.publish(ctx, existingPost, patchedPost)
+	})
+	if err != nil {
+		log.Printf("Error patching post with ID %d: %v", postID, err)
+		return nil, err
+	}
+	postService.HTML.Invalidate(postHTMLKey(postID))
 
-	return existingPost, nil
+	return postService.present(ctx, patchedPost)
 }
 
-func (postService *PostService) DeletePostByID(ctx context.Context, id uint) error {
-	if err := postService.PostRepo.DeletePost(ctx, id); err != nil {
+// publish puts a post that was just published, or handed to another
+// author while published, on the feeds of the author's followers. Posts of
+// authors with more than FanOutLimit followers are left for feeds to pull
+// in when they are read, rather than written to that many timelines.
+// Webhooks only hear about the first of the two.
+func (postService *PostSvc) publish(ctx context.Context, before *models.GormPost, after *models.GormPost) error {
+	if !after.IsPublished || before.IsPublished && before.UserID == after.UserID {
+		return nil
+	}
+	if !before.IsPublished {
+		if err := postService.Webhooks.Enqueue(ctx, WebhookPostPublished, postEvent(after)); err != nil {
+			return err
+		}
+	}
+
+	followers, err := postService.FollowRepo.CountFollowers(ctx, after.UserID)
+	if err != nil {
+		return err
+	}
+	if followers > FanOutLimit {
+		return postService.FollowRepo.SkipFanOut(ctx, after.ID)
+	}
+	return postService.FollowRepo.FanOut(ctx, *after)
+}
+
+// publishedAt stamps a post the first time it is published and otherwise
+// keeps the stored time.
+func publishedAt(existingPost *models.GormPost, isPublished bool) time.Time {
+	if isPublished && existingPost.PublishedAt.IsZero() {
+		return time.Now()
+	}
+	return existingPost.PublishedAt
+}
+
+func (postService *PostSvc) DeletePostByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracer.Start(ctx, "PostService.DeletePostByID")
+	defer endSpan(span, &err)
+
+	// the post's comments follow the configured delete policy
+	err = postService.PostRepo.WithTx(ctx, func(ctx context.Context) error {
+		deleter := &deleter{
+			userRepo:    postService.UserRepo,
+			postRepo:    postService.PostRepo,
+			commentRepo: postService.CommentRepo,
+			policies:    postService.Policies,
+			webhooks:    postService.Webhooks,
+			stream:      postService.Stream,
+		}
+		return deleter.deletePost(ctx, id, false)
+	})
+	if err != nil {
 		log.Printf("Error deleting post with ID %d: %v", id, err)
 		return err
 	}
+	postService.HTML.Invalidate(postHTMLKey(id))
 	return nil
 }
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+)
+
+// PostService holds the post use cases the handlers depend on.
+type PostService interface {
+	CreatePost(ctx context.Context, post models.GormPost) (*models.GormPost, error)
+	GetAllPosts(ctx context.Context, includeContent bool, fieldset Fieldset) ([]models.GormPost, error)
+	GetPostByID(ctx context.Context, id uint, fieldset Fieldset) (*models.GormPost, error)
+	GetPostByTitle(ctx context.Context, title string) (*models.GormPost, error)
+	GetPostByUserID(ctx context.Context, userid uint) ([]models.GormPost, error)
+	UpdatePostByID(ctx context.Context, postID uint, post models.GormPost) (*models.GormPost, error)
+	PatchPostByID(ctx context.Context, postID uint, version uint, patchType string, patch []byte) (*models.GormPost, error)
+	DeletePostByID(ctx context.Context, id uint) error
+	SetThumbnail(ctx context.Context, postID uint, version uint, image io.Reader) (*models.GormPost, error)
+	GetThumbnail(ctx context.Context, postID uint, width int) (*storage.Blob, error)
+	GetFeed(ctx context.Context, userID uint, after *Cursor, limit int) ([]models.GormPost, error)
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// ReactionTypes are the reactions readers can leave.
+var ReactionTypes = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+type ReactionSvc struct {
+	ReactionRepo  repository.ReactionRepository
+	PostRepo      repository.PostRepository
+	CommentRepo   repository.CommentRepository
+	UserRepo      repository.UserRepository
+	Notifications NotificationService
+}
+
+func NewReactionService(reactionRepo repository.ReactionRepository, postRepo repository.PostRepository, commentRepo repository.CommentRepository, userRepo repository.UserRepository, notifications NotificationService) ReactionService {
+	return &ReactionSvc{
+		ReactionRepo:  reactionRepo,
+		PostRepo:      postRepo,
+		CommentRepo:   commentRepo,
+		UserRepo:      userRepo,
+		Notifications: notifications,
+	}
+}
+
+func (reactionService *ReactionSvc) AddPostReaction(ctx context.Context, postID uint, userID uint, reactionType string) (_ models.ReactionCounts, err error) {
+	ctx, span := tracer.Start(ctx, "ReactionService.AddPostReaction")
+	defer endSpan(span, &err)
+
+	return reactionService.react(ctx, models.GormReaction{UserID: userID, PostID: &postID, Type: reactionType}, 1)
+}
+
+func (reactionService *ReactionSvc) RemovePostReaction(ctx context.Context, postID uint, userID uint, reactionType string) (_ models.ReactionCounts, err error) {
+	ctx, span := tracer.Start(ctx, "ReactionService.RemovePostReaction")
+	defer endSpan(span, &err)
+
+	return reactionService.react(ctx, models.GormReaction{UserID: userID, PostID: &postID, Type: reactionType}, -1)
+}
+
+func (reactionService *ReactionSvc) AddCommentReaction(ctx context.Context, commentID uint, userID uint, reactionType string) (_ models.ReactionCounts, err error) {
+	ctx, span := tracer.Start(ctx, "ReactionService.AddCommentReaction")
+	defer endSpan(span, &err)
+
+	return reactionService.react(ctx, models.GormReaction{UserID: userID, CommentID: &commentID, Type: reactionType}, 1)
+}
+
+func (reactionService *ReactionSvc) RemoveCommentReaction(ctx context.Context, commentID uint, userID uint, reactionType string) (_ models.ReactionCounts, err error) {
+	ctx, span := tracer.Start(ctx, "ReactionService.RemoveCommentReaction")
+	defer endSpan(span, &err)
+
+	return reactionService.react(ctx, models.GormReaction{UserID: userID, CommentID: &commentID, Type: reactionType}, -1)
+}
+
+// react adds the reaction when delta is 1 and removes it when delta is -1,
+// moving the counter of its target in the same transaction. Adding a
+// reaction that is already there, or removing one that is not, changes
+// nothing, so both can be retried safely.
+func (reactionService *ReactionSvc) react(ctx context.Context, reaction models.GormReaction, delta int) (models.ReactionCounts, error) {
+	if !validReaction(reaction.Type) {
+		return nil, fmt.Errorf("%w %q, want one of %s", ErrUnknownReaction, reaction.Type, strings.Join(ReactionTypes, ", "))
+	}
+
+	var counts models.ReactionCounts
+	err := reactionService.ReactionRepo.WithTx(ctx, func(ctx context.Context) error {
+		_, err := reactionService.UserRepo.GetUserByID(ctx, reaction.UserID)
+		if errors.Is(err, repository.ErrNotExist) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// a missing or trashed post or comment is not found
+		if _, err := reactionService.reactionCounts(ctx, reaction); err != nil {
+			return err
+		}
+
+		var changed bool
+		if delta > 0 {
+			changed, err = reactionService.ReactionRepo.CreateReaction(ctx, reaction)
+		} else {
+			changed, err = reactionService.ReactionRepo.DeleteReaction(ctx, reaction)
+		}
+		if err != nil {
+			return err
+		}
+		if changed {
+			if err := reactionService.ReactionRepo.AdjustReactionCount(ctx, reaction, delta); err != nil {
+				return err
+			}
+		}
+		if changed && delta > 0 {
+			if err := reactionService.notifyReaction(ctx, reaction); err != nil {
+				return err
+			}
+		}
+
+		counts, err = reactionService.reactionCounts(ctx, reaction)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error reacting with %q by user ID %d: %v", reaction.Type, reaction.UserID, err)
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// notifyReaction tells the author of the post or comment reacted to.
+func (reactionService *ReactionSvc) notifyReaction(ctx context.Context, reaction models.GormReaction) error {
+	notification := models.GormNotification{ActorID: reaction.UserID, Type: NotificationReaction, Reaction: reaction.Type}
+	if reaction.PostID != nil {
+		post, err := reactionService.PostRepo.GetPostByID(ctx, *reaction.PostID)
+		if err != nil {
+			return err
+		}
+		notification.UserID, notification.PostID = post.UserID, &post.ID
+	} else {
+		comment, err := reactionService.CommentRepo.GetCommentByID(ctx, *reaction.CommentID)
+		if err != nil {
+			return err
+		}
+		notification.UserID, notification.PostID, notification.CommentID = comment.UserID, &comment.PostID, &comment.ID
+	}
+
+	return reactionService.Notifications.Notify(ctx, notification)
+}
+
+// reactionCounts reads the counters of the post or comment reacted to.
+func (reactionService *ReactionSvc) reactionCounts(ctx context.Context, reaction models.GormReaction) (models.ReactionCounts, error) {
+	if reaction.PostID != nil {
+		post, err := reactionService.PostRepo.GetPostByID(ctx, *reaction.PostID)
+		if err != nil {
+			return nil, err
+		}
+		return post.ReactionCounts, nil
+	}
+
+	comment, err := reactionService.CommentRepo.GetCommentByID(ctx, *reaction.CommentID)
+	if err != nil {
+		return nil, err
+	}
+	return comment.ReactionCounts, nil
+}
+
+func validReaction(reactionType string) bool {
+	for _, known := range ReactionTypes {
+		if reactionType == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// ReactionService lets readers react to posts and comments. Every method
+// returns the counts of the post or comment reacted to, as they stand
+// after the change.
+type ReactionService interface {
+	AddPostReaction(ctx context.Context, postID uint, userID uint, reactionType string) (models.ReactionCounts, error)
+	RemovePostReaction(ctx context.Context, postID uint, userID uint, reactionType string) (models.ReactionCounts, error)
+	AddCommentReaction(ctx context.Context, commentID uint, userID uint, reactionType string) (models.ReactionCounts, error)
+	RemoveCommentReaction(ctx context.Context, commentID uint, userID uint, reactionType string) (models.ReactionCounts, error)
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// newNotificationService stores notifications in repo and mails nothing,
+// since nothing runs the mail queue.
+func newNotificationService(repo *repository.InMemoryRepository) NotificationService {
+	return NewNotificationService(repo, repo, NewMailService(nil, nil, repo, repo, repo, Site{}))
+}
+
+func TestReactions(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	author, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	reader, err := repo.CreateUser(ctx, models.GormUser{Email: "bo@example.com", Username: "bo"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Liked"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	comment, err := repo.CreateComment(ctx, models.GormComment{UserID: reader.ID, PostID: post.ID, Content: "Nice"})
+	if err != nil {
+		t.Fatalf("CreateComment: %v", err)
+	}
+
+	notifications := newNotificationService(repo)
+	reactionService := NewReactionService(repo, repo, repo, repo, notifications)
+
+	for _, step := range []struct {
+		name string
+		call func() (models.ReactionCounts, error)
+		want models.ReactionCounts
+	}{
+		{"like", func() (models.ReactionCounts, error) {
+			return reactionService.AddPostReaction(ctx, post.ID, reader.ID, "like")
+		}, models.ReactionCounts{"like": 1}},
+		// adding it again changes nothing
+		{"like again", func() (models.ReactionCounts, error) {
+			return reactionService.AddPostReaction(ctx, post.ID, reader.ID, "like")
+		}, models.ReactionCounts{"like": 1}},
+		{"love too", func() (models.ReactionCounts, error) {
+			return reactionService.AddPostReaction(ctx, post.ID, reader.ID, "love")
+		}, models.ReactionCounts{"like": 1, "love": 1}},
+		{"own post", func() (models.ReactionCounts, error) {
+			return reactionService.AddPostReaction(ctx, post.ID, author.ID, "like")
+		}, models.ReactionCounts{"like": 2, "love": 1}},
+		{"unlike", func() (models.ReactionCounts, error) {
+			return reactionService.RemovePostReaction(ctx, post.ID, reader.ID, "like")
+		}, models.ReactionCounts{"like": 1, "love": 1}},
+		// removing it again changes nothing
+		{"unlike again", func() (models.ReactionCounts, error) {
+			return reactionService.RemovePostReaction(ctx, post.ID, reader.ID, "like")
+		}, models.ReactionCounts{"like": 1, "love": 1}},
+		{"unlove", func() (models.ReactionCounts, error) {
+			return reactionService.RemovePostReaction(ctx, post.ID, reader.ID, "love")
+		}, models.ReactionCounts{"like": 1}},
+		{"comment", func() (models.ReactionCounts, error) {
+			return reactionService.AddCommentReaction(ctx, comment.ID, author.ID, "laugh")
+		}, models.ReactionCounts{"laugh": 1}},
+		{"comment removed", func() (models.ReactionCounts, error) {
+			return reactionService.RemoveCommentReaction(ctx, comment.ID, author.ID, "laugh")
+		}, models.ReactionCounts{}},
+	} {
+		counts, err := step.call()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if len(counts) != 0 || len(step.want) != 0 {
+			if !reflect.DeepEqual(counts, step.want) {
+				t.Errorf("%s: counts = %v, want %v", step.name, counts, step.want)
+			}
+		}
+	}
+
+	stored, err := repo.GetPostByID(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("GetPostByID: %v", err)
+	}
+	if !reflect.DeepEqual(stored.ReactionCounts, models.ReactionCounts{"like": 1}) {
+		t.Errorf("stored counts = %v, want the author's like", stored.ReactionCounts)
+	}
+
+	// the author heard about the like and the love, once each, but not
+	// about their own; the reader heard about the laugh
+	received, total, err := notifications.GetNotifications(ctx, author.ID, false, Page{Number: 1, Size: 10})
+	if err != nil || total != 2 {
+		t.Fatalf("GetNotifications of the author = %v, %d, %v, want 2", received, total, err)
+	}
+	for _, notification := range received {
+		if notification.Type != NotificationReaction || notification.ActorID != reader.ID || *notification.PostID != post.ID {
+			t.Errorf("notification = %+v, want the reader's reaction to the post", notification)
+		}
+	}
+	received, total, err = notifications.GetNotifications(ctx, reader.ID, false, Page{Number: 1, Size: 10})
+	if err != nil || total != 1 || received[0].CommentID == nil || *received[0].CommentID != comment.ID || received[0].Reaction != "laugh" {
+		t.Errorf("GetNotifications of the reader = %+v, %d, %v, want the laugh at the comment", received, total, err)
+	}
+}
+
+func TestReactionErrors(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	author, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Trashed"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if err := repo.DeletePost(ctx, post.ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	reactionService := NewReactionService(repo, repo, repo, repo, newNotificationService(repo))
+
+	for _, tt := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"unknown type", func() error {
+			_, err := reactionService.AddPostReaction(ctx, post.ID, author.ID, "meh")
+			return err
+		}(), ErrUnknownReaction},
+		{"unknown user", func() error {
+			_, err := reactionService.AddPostReaction(ctx, post.ID, author.ID+10, "like")
+			return err
+		}(), ErrUserNotFound},
+		{"trashed post", func() error {
+			_, err := reactionService.AddPostReaction(ctx, post.ID, author.ID, "like")
+			return err
+		}(), ErrNotFound},
+		{"unknown comment", func() error {
+			_, err := reactionService.AddCommentReaction(ctx, 99, author.ID, "like")
+			return err
+		}(), ErrNotFound},
+	} {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+}
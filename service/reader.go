@@ -0,0 +1,18 @@
+package service
+
+import "context"
+
+type readerKey struct{}
+
+// WithReader marks ctx as belonging to a request by the authenticated user
+// userID, so reads can answer for that reader, such as whether they
+// bookmarked a post.
+func WithReader(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, readerKey{}, userID)
+}
+
+// ReaderFrom returns the authenticated user of ctx, if there is one.
+func ReaderFrom(ctx context.Context) (uint, bool) {
+	userID, ok := ctx.Value(readerKey{}).(uint)
+	return userID, ok
+}
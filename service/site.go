@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Site describes the blog to the outside world: where it is served from
+// and what it is called. Links in feeds are built from URL.
+type Site struct {
+	URL         string
+	Title       string
+	Description string
+}
+
+// DefaultSite is what SiteFromEnv falls back to for unset variables.
+var DefaultSite = Site{
+	URL:         "http://localhost:8080",
+	Title:       "Blog",
+	Description: "The latest posts",
+}
+
+// SiteFromEnv reads the site from SITE_URL, SITE_TITLE and
+// SITE_DESCRIPTION. SITE_URL must be absolute.
+func SiteFromEnv() (Site, error) {
+	site := DefaultSite
+	if value := os.Getenv("SITE_URL"); value != "" {
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return Site{}, fmt.Errorf("invalid SITE_URL %q", value)
+		}
+		site.URL = value
+	}
+	if value := os.Getenv("SITE_TITLE"); value != "" {
+		site.Title = value
+	}
+	if value := os.Getenv("SITE_DESCRIPTION"); value != "" {
+		site.Description = value
+	}
+
+	site.URL = strings.TrimRight(site.URL, "/")
+	return site, nil
+}
+
+// link makes an absolute URL on the site from a path.
+func (site Site) link(path string) string {
+	return site.URL + path
+}
+
+// postLink and authorLink are where a post and an author are read.
+func (site Site) postLink(postID uint) string {
+	return site.link(fmt.Sprintf("/api/posts/%d", postID))
+}
+
+func (site Site) authorLink(userID uint) string {
+	return site.link(fmt.Sprintf("/api/users/%d", userID))
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/sitemap"
+)
+
+// SitemapSize is how many posts one sitemap lists before the sitemap is
+// split into parts under an index.
+var SitemapSize = sitemap.MaxURLs
+
+// Robots is what robots.txt asks of crawlers.
+type Robots struct {
+	// Disallow lists the path prefixes crawlers should stay out of.
+	Disallow []string
+}
+
+// DefaultRobots keeps crawlers out of the parts of the API that are per
+// reader or only for moderators.
+var DefaultRobots = Robots{
+	Disallow: []string{"/api/trash/", "/api/bookmarks", "/api/feed", "/api/notifications", "/api/analytics/", "/api/webhooks"},
+}
+
+// RobotsFromEnv reads a comma separated list of path prefixes from
+// ROBOTS_DISALLOW. "/" keeps crawlers out of the whole site, and "none"
+// lets them in everywhere.
+func RobotsFromEnv() (Robots, error) {
+	value, ok := os.LookupEnv("ROBOTS_DISALLOW")
+	if !ok || value == "" {
+		return DefaultRobots, nil
+	}
+	if value == "none" {
+		return Robots{}, nil
+	}
+
+	robots := Robots{}
+	for _, field := range strings.Split(value, ",") {
+		path := strings.TrimSpace(field)
+		if !strings.HasPrefix(path, "/") {
+			return Robots{}, fmt.Errorf("invalid ROBOTS_DISALLOW entry %q", field)
+		}
+		robots.Disallow = append(robots.Disallow, path)
+	}
+	return robots, nil
+}
+
+type SitemapSvc struct {
+	PostRepo repository.PostRepository
+	Site     Site
+	Robots   Robots
+}
+
+func NewSitemapService(postRepo repository.PostRepository, site Site, robots Robots) SitemapService {
+	return &SitemapSvc{
+		PostRepo: postRepo,
+		Site:     site,
+		Robots:   robots,
+	}
+}
+
+func (sitemapService *SitemapSvc) WriteSitemap(ctx context.Context, w io.Writer) (err error) {
+	ctx, span := tracer.Start(ctx, "SitemapService.WriteSitemap")
+	defer endSpan(span, &err)
+
+	parts, err := sitemapService.PostRepo.SitemapParts(ctx, SitemapSize)
+	if err != nil {
+		log.Printf("Error splitting the sitemap: %v", err)
+		return err
+	}
+
+	// a single part is the sitemap itself
+	if len(parts) <= 1 {
+		return sitemapService.writeURLs(ctx, 1, w)
+	}
+
+	sitemaps := make([]sitemap.Sitemap, 0, len(parts))
+	for _, part := range parts {
+		sitemaps = append(sitemaps, sitemap.Sitemap{
+			Loc:     sitemapService.Site.link(fmt.Sprintf("/sitemaps/posts-%d.xml", part.Number)),
+			LastMod: part.LastModified,
+		})
+	}
+	return sitemap.WriteIndex(w, sitemaps)
+}
+
+func (sitemapService *SitemapSvc) WriteSitemapPart(ctx context.Context, part int, w io.Writer) (err error) {
+	ctx, span := tracer.Start(ctx, "SitemapService.WriteSitemapPart")
+	defer endSpan(span, &err)
+
+	if part < 1 {
+		return ErrNotFound
+	}
+	return sitemapService.writeURLs(ctx, part, w)
+}
+
+// writeURLs streams one part of the sitemap from the posts cursor. A part
+// past the last one is not found, which is only known once the cursor
+// comes back empty, before anything was written.
+func (sitemapService *SitemapSvc) writeURLs(ctx context.Context, part int, w io.Writer) error {
+	urls := sitemap.NewURLSet(w)
+	err := sitemapService.PostRepo.ScanPublishedPosts(ctx, (part-1)*SitemapSize, SitemapSize, func(post models.GormPost) error {
+		return urls.Add(sitemap.URL{Loc: sitemapService.Site.postLink(post.ID), LastMod: post.UpdatedAt})
+	})
+	if err != nil {
+		log.Printf("Error writing part %d of the sitemap: %v", part, err)
+		return err
+	}
+	if urls.Count() == 0 && part > 1 {
+		return ErrNotFound
+	}
+
+	return urls.Close()
+}
+
+func (sitemapService *SitemapSvc) RobotsTxt(ctx context.Context) string {
+	var robots strings.Builder
+	robots.WriteString("User-agent: *\n")
+	if len(sitemapService.Robots.Disallow) == 0 {
+		// an empty Disallow allows everything
+		robots.WriteString("Disallow:\n")
+	}
+	for _, path := range sitemapService.Robots.Disallow {
+		fmt.Fprintf(&robots, "Disallow: %s\n", path)
+	}
+	fmt.Fprintf(&robots, "\nSitemap: %s\n", sitemapService.Site.link("/sitemap.xml"))
+
+	return robots.String()
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+	"io"
+)
+
+// SitemapService tells crawlers what there is to index: sitemaps of the
+// published posts and the robots.txt pointing at them.
+type SitemapService interface {
+	// WriteSitemap writes the sitemap of every published post, or, once
+	// there are more than SitemapSize, an index of the parts written by
+	// WriteSitemapPart.
+	WriteSitemap(ctx context.Context, w io.Writer) error
+	// WriteSitemapPart writes one part, numbered from 1, of the sitemap.
+	WriteSitemapPart(ctx context.Context, part int, w io.Writer) error
+	// RobotsTxt is the robots.txt of the site.
+	RobotsTxt(ctx context.Context) string
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/content"
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/syndication"
+)
+
+// FeedLength is how many of the latest posts a feed carries.
+var FeedLength = 50
+
+type SyndicationSvc struct {
+	PostRepo repository.PostRepository
+	UserRepo repository.UserRepository
+	Site     Site
+	HTML     *content.Cache
+}
+
+func NewSyndicationService(postRepo repository.PostRepository, userRepo repository.UserRepository, site Site, html *content.Cache) SyndicationService {
+	return &SyndicationSvc{
+		PostRepo: postRepo,
+		UserRepo: userRepo,
+		Site:     site,
+		HTML:     html,
+	}
+}
+
+func (syndicationService *SyndicationSvc) SiteFeed(ctx context.Context, options FeedOptions) (_ *syndication.Feed, err error) {
+	ctx, span := tracer.Start(ctx, "SyndicationService.SiteFeed")
+	defer endSpan(span, &err)
+
+	site := syndicationService.Site
+	feed := syndication.Feed{
+		Title:       site.Title,
+		Description: site.Description,
+		Link:        site.link("/"),
+	}
+	return syndicationService.build(ctx, feed, "/feed", 0, "", options)
+}
+
+func (syndicationService *SyndicationSvc) AuthorFeed(ctx context.Context, userID uint, options FeedOptions) (_ *syndication.Feed, err error) {
+	ctx, span := tracer.Start(ctx, "SyndicationService.AuthorFeed")
+	defer endSpan(span, &err)
+
+	user, err := syndicationService.UserRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	site := syndicationService.Site
+	feed := syndication.Feed{
+		Title:       fmt.Sprintf("%s: posts by %s", site.Title, user.Name),
+		Description: fmt.Sprintf("The latest posts by %s on %s", user.Name, site.Title),
+		Link:        site.authorLink(userID),
+	}
+	return syndicationService.build(ctx, feed, fmt.Sprintf("/authors/%d/feed", userID), userID, "", options)
+}
+
+func (syndicationService *SyndicationSvc) TagFeed(ctx context.Context, tag string, options FeedOptions) (_ *syndication.Feed, err error) {
+	ctx, span := tracer.Start(ctx, "SyndicationService.TagFeed")
+	defer endSpan(span, &err)
+
+	// no post can carry a tag that does not normalize to itself
+	tag = strings.ToLower(tag)
+	if err := validTag(tag); err != nil {
+		return nil, ErrNotFound
+	}
+
+	site := syndicationService.Site
+	feed := syndication.Feed{
+		Title:       fmt.Sprintf("%s: posts tagged %s", site.Title, tag),
+		Description: fmt.Sprintf("The latest posts tagged %s on %s", tag, site.Title),
+		Link:        site.link("/"),
+	}
+	return syndicationService.build(ctx, feed, "/tags/"+url.PathEscape(tag)+"/feed", 0, tag, options)
+}
+
+// build fills feed with the latest posts matching userID and tag. The feed
+// is identified by the URL of its Atom version, whichever format it is
+// read in, and was last updated when the latest of its posts was.
+func (syndicationService *SyndicationSvc) build(ctx context.Context, feed syndication.Feed, path string, userID uint, tag string, options FeedOptions) (*syndication.Feed, error) {
+	site := syndicationService.Site
+	feed.ID = site.link(path + ".atom")
+	feed.Self = site.link(path + "." + options.Format)
+	// an empty feed never changed
+	feed.Updated = time.Unix(0, 0)
+
+	posts, err := syndicationService.PostRepo.PublishedPosts(ctx, userID, tag, FeedLength)
+	if err != nil {
+		log.Printf("Error reading the posts of feed %s: %v", path, err)
+		return nil, err
+	}
+
+	feed.Items = make([]syndication.Item, 0, len(posts))
+	for _, post := range posts {
+		item, err := syndicationService.item(post, options.Full)
+		if err != nil {
+			log.Printf("Error adding post with ID %d to feed %s: %v", post.ID, path, err)
+			return nil, err
+		}
+		feed.Items = append(feed.Items, item)
+
+		if post.UpdatedAt.After(feed.Updated) {
+			feed.Updated = post.UpdatedAt
+		}
+	}
+
+	return &feed, nil
+}
+
+// item turns a post into a feed item. Its id is a tag URI minted from the
+// day the post was created, which no edit, retitling or move changes.
+func (syndicationService *SyndicationSvc) item(post models.GormPost, full bool) (syndication.Item, error) {
+	site := syndicationService.Site
+	id, err := syndication.TagURI(site.URL, post.CreatedAt, fmt.Sprintf("post:%d", post.ID))
+	if err != nil {
+		return syndication.Item{}, err
+	}
+
+	// the author may have been trashed since
+	author := syndication.Author{Name: "Unknown author"}
+	if post.User != nil {
+		author = syndication.Author{Name: post.User.Name, URI: site.authorLink(post.UserID)}
+	}
+
+	item := syndication.Item{
+		ID:         id,
+		Title:      post.Title,
+		Link:       site.postLink(post.ID),
+		Author:     author,
+		Published:  post.PublishedAt,
+		Updated:    post.UpdatedAt,
+		Summary:    post.Excerpt,
+		Categories: post.Tags,
+	}
+	if full {
+		item.Content, err = syndicationService.HTML.Render(postHTMLKey(post.ID), post.Version, post.ContentFormat, post.Content)
+		if err != nil {
+			return syndication.Item{}, err
+		}
+	}
+
+	return item, nil
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/syndication"
+)
+
+// FeedOptions pick how a feed is written.
+type FeedOptions struct {
+	// Format is "rss" or "atom", the format the feed links to itself in.
+	Format string
+	// Full puts the whole post, as HTML, in every item rather than only
+	// its excerpt.
+	Full bool
+}
+
+// SyndicationService builds the feeds of the latest published posts, of the
+// whole site, of one author or of one tag, ready to be encoded.
+type SyndicationService interface {
+	SiteFeed(ctx context.Context, options FeedOptions) (*syndication.Feed, error)
+	AuthorFeed(ctx context.Context, userID uint, options FeedOptions) (*syndication.Feed, error)
+	TagFeed(ctx context.Context, tag string, options FeedOptions) (*syndication.Feed, error)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// Limits on the tags of one post.
+const (
+	MaxTags      = 10
+	MaxTagLength = 50
+)
+
+// postTags normalizes the tags an author gave a post: trimmed, lowercase,
+// runs of spaces turned into a hyphen and duplicates dropped, in the order
+// given. Tags end up in feed URLs, so only letters, digits and hyphens are
+// allowed.
+func postTags(tags []string) (models.Tags, error) {
+	normalized := models.Tags{}
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+		if tag == "" || normalized.Has(tag) {
+			continue
+		}
+		if err := validTag(tag); err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("%w: more than %d tags", ErrInvalidTags, MaxTags)
+	}
+	return normalized, nil
+}
+
+// validTag checks a tag that is already normalized.
+func validTag(tag string) error {
+	if utf8.RuneCountInString(tag) > MaxTagLength {
+		return fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTags, tag, MaxTagLength)
+	}
+	for _, r := range tag {
+		if r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return fmt.Errorf("%w: %q may only have letters, digits and hyphens", ErrInvalidTags, tag)
+		}
+	}
+	return nil
+}
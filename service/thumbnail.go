@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/imaging"
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+)
+
+// MaxThumbnailSize caps an uploaded thumbnail at 5 MiB.
+const MaxThumbnailSize = 5 << 20
+
+// thumbnailTypes is the allowlist of sniffed image types, with the file
+// extension each is stored under.
+var thumbnailTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// limitedReader fails with ErrTooLarge instead of silently stopping once
+// more than n bytes have been read.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// SetThumbnail stores image as the post's thumbnail and removes the one it
+// replaces. The type is sniffed from the content, never taken from the
+// client, and must be on the allowlist. The upload itself is never stored:
+// what is kept is a copy encoded afresh, without the EXIF metadata (GPS
+// position, device) of the original, so it is safe to serve before the
+// variants are made or if they never are.
+func (postService *PostSvc) SetThumbnail(ctx context.Context, postID uint, version uint, image io.Reader) (_ *models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.SetThumbnail")
+	defer endSpan(span, &err)
+
+	data, err := io.ReadAll(&limitedReader{r: image, n: MaxThumbnailSize})
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty upload", ErrUnsupportedType)
+	}
+
+	// Sniff the type from the first bytes
+	contentType := http.DetectContentType(data)
+	if _, ok := thumbnailTypes[contentType]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	// Fail early rather than upload a file for a post that is not there
+	if _, err := postService.PostRepo.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	clean, err := imaging.Clean(data)
+	if errors.Is(err, imaging.ErrTooManyPixels) {
+		return nil, fmt.Errorf("%w: %v", ErrTooLarge, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("thumbnails/%d/%s%s", postID, hex.EncodeToString(suffix), extensionOf(clean.ContentType))
+
+	if err := postService.Blobs.Put(ctx, key, clean.ContentType, bytes.NewReader(clean.Data)); err != nil {
+		// a partial object may have been left behind
+		postService.removeBlob(ctx, key)
+		return nil, err
+	}
+
+	var previous string
+	var updatedPost *models.GormPost
+	err = postService.PostRepo.WithTx(ctx, func(ctx context.Context) error {
+		existingPost, err := postService.PostRepo.GetPostByID(ctx, postID)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			version = existingPost.Version
+		}
+		previous = existingPost.Thumbnail
+
+		updatedPost, err = postService.PostRepo.PatchPost(ctx, postID, version, map[string]interface{}{"thumbnail": key})
+		return err
+	})
+	if err != nil {
+		log.Printf("Error setting thumbnail of post with ID %d: %v", postID, err)
+		postService.removeBlob(ctx, key)
+		return nil, err
+	}
+
+	// Variants are made in the background; until then the clean copy is served
+	postService.Media.Enqueue(postID, key)
+	if previous != "" {
+		if err := postService.Media.RemoveVariants(ctx, previous); err != nil {
+			log.Printf("Error removing variants of %s: %v", previous, err)
+		}
+		postService.removeBlob(ctx, previous)
+	}
+
+	return postService.present(ctx, updatedPost)
+}
+
+// GetThumbnail opens the post's thumbnail at the given width, or at full
+// size when width is 0. It returns ErrNotFound when the post has none.
+func (postService *PostSvc) GetThumbnail(ctx context.Context, postID uint, width int) (_ *storage.Blob, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetThumbnail")
+	defer endSpan(span, &err)
+
+	post, err := postService.PostRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if post.Thumbnail == "" {
+		return nil, ErrNotFound
+	}
+
+	blob, err := postService.Media.OpenVariant(ctx, post, width)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, ErrNotFound
+	}
+	return blob, err
+}
+
+// removeBlob deletes a file the post no longer points at, logging failures.
+func (postService *PostSvc) removeBlob(ctx context.Context, key string) {
+	if err := postService.Blobs.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrBlobNotFound) {
+		log.Printf("Error removing blob %s: %v", key, err)
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/content"
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+)
+
+// newThumbnailService sets up a post service on the in-memory repositories
+// and a local blob store, with one post to upload thumbnails to. Nothing
+// runs the media queue.
+func newThumbnailService(t *testing.T) (*PostSvc, storage.BlobStore, *models.GormPost) {
+	t.Helper()
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	blobs, err := storage.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore: %v", err)
+	}
+
+	author, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Pictured"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	media := NewMediaService(repo, repo, blobs, []int{100})
+	postService := NewPostService(repo, repo, repo, repo, repo, media, blobs, DefaultDeletePolicies(), nil, nil, content.NewCache(8))
+	return postService.(*PostSvc), blobs, post
+}
+
+// jpegWithExif encodes a JPEG and puts an EXIF segment carrying marker right
+// after its start, the way a camera stores the GPS position.
+func jpegWithExif(t *testing.T, marker string) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil); err != nil {
+		t.Fatalf("encoding the upload: %v", err)
+	}
+
+	// a big-endian TIFF header with an empty first IFD, then the marker
+	exif := append([]byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x00"), marker...)
+	segment := append([]byte{0xFF, 0xE1, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)}, exif...)
+
+	data := encoded.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestSetThumbnailStripsMetadata(t *testing.T) {
+	ctx := context.Background()
+	postService, blobs, post := newThumbnailService(t)
+	const marker = "GPS 51.5074N 0.1278W"
+
+	updated, err := postService.SetThumbnail(ctx, post.ID, 0, bytes.NewReader(jpegWithExif(t, marker)))
+	if err != nil {
+		t.Fatalf("SetThumbnail: %v", err)
+	}
+
+	// no variants were made, so the stored copy is what is served
+	for _, open := range []func() (*storage.Blob, error){
+		func() (*storage.Blob, error) { return blobs.Get(ctx, updated.Thumbnail) },
+		func() (*storage.Blob, error) { return postService.GetThumbnail(ctx, post.ID, 0) },
+	} {
+		blob, err := open()
+		if err != nil {
+			t.Fatalf("opening the thumbnail: %v", err)
+		}
+		stored, err := io.ReadAll(blob)
+		blob.Close()
+		if err != nil {
+			t.Fatalf("reading the thumbnail: %v", err)
+		}
+		if bytes.Contains(stored, []byte("Exif")) || bytes.Contains(stored, []byte(marker)) {
+			t.Errorf("the served thumbnail kept the EXIF segment")
+		}
+		if _, format, err := image.DecodeConfig(bytes.NewReader(stored)); err != nil || format != "jpeg" {
+			t.Errorf("the served thumbnail is %q, %v, want a JPEG", format, err)
+		}
+	}
+}
+
+// encodeImage encodes a small image with encode.
+func encodeImage(t *testing.T, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatalf("encoding the upload: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSetThumbnailSniffsTheType(t *testing.T) {
+	ctx := context.Background()
+	postService, blobs, post := newThumbnailService(t)
+
+	for _, tt := range []struct {
+		name        string
+		upload      []byte
+		contentType string
+	}{
+		{"jpeg", encodeImage(t, func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) }), "image/jpeg"},
+		{"png", encodeImage(t, png.Encode), "image/png"},
+		{"gif", encodeImage(t, func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) }), "image/png"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := postService.SetThumbnail(ctx, post.ID, 0, bytes.NewReader(tt.upload))
+			if err != nil {
+				t.Fatalf("SetThumbnail: %v", err)
+			}
+			blob, err := blobs.Get(ctx, updated.Thumbnail)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			blob.Close()
+			if blob.ContentType != tt.contentType {
+				t.Errorf("stored as %s, want %s", blob.ContentType, tt.contentType)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name   string
+		upload []byte
+	}{
+		{"empty", nil},
+		{"text", []byte("just some text")},
+		{"html", []byte("<html><script>alert(1)</script></html>")},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)},
+		{"bmp", append([]byte("BM"), make([]byte, 64)...)},
+		{"pdf", []byte("%PDF-1.7\n")},
+		{"broken png", append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), make([]byte, 64)...)},
+	} {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			if _, err := postService.SetThumbnail(ctx, post.ID, 0, bytes.NewReader(tt.upload)); !errors.Is(err, ErrUnsupportedType) {
+				t.Errorf("SetThumbnail err = %v, want ErrUnsupportedType", err)
+			}
+		})
+	}
+}
+
+func TestSetThumbnailLimitsTheSize(t *testing.T) {
+	postService, _, post := newThumbnailService(t)
+
+	// a real PNG header, padded past the limit
+	upload := append(encodeImage(t, png.Encode), make([]byte, MaxThumbnailSize)...)
+	if _, err := postService.SetThumbnail(context.Background(), post.ID, 0, bytes.NewReader(upload)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("SetThumbnail err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestSetThumbnailKeysAreRandom(t *testing.T) {
+	ctx := context.Background()
+	postService, blobs, post := newThumbnailService(t)
+	upload := encodeImage(t, png.Encode)
+
+	pattern := regexp.MustCompile(fmt.Sprintf(`^thumbnails/%d/[0-9a-f]{16}\.png$`, post.ID))
+	first, err := postService.SetThumbnail(ctx, post.ID, 0, bytes.NewReader(upload))
+	if err != nil {
+		t.Fatalf("SetThumbnail: %v", err)
+	}
+	firstKey := first.Thumbnail
+	second, err := postService.SetThumbnail(ctx, post.ID, 0, bytes.NewReader(upload))
+	if err != nil {
+		t.Fatalf("SetThumbnail: %v", err)
+	}
+
+	for _, key := range []string{firstKey, second.Thumbnail} {
+		if !pattern.MatchString(key) {
+			t.Errorf("key %q is not a random name under the post", key)
+		}
+	}
+	if firstKey == second.Thumbnail {
+		t.Errorf("the same upload got the same key %q twice", firstKey)
+	}
+
+	// the replaced upload is removed
+	if _, err := blobs.Get(ctx, firstKey); !errors.Is(err, storage.ErrBlobNotFound) {
+		t.Errorf("Get of the replaced thumbnail err = %v, want ErrBlobNotFound", err)
+	}
+}
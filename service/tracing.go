@@ -0,0 +1,26 @@
+package service
+
+import (
+	"github.com/bellaananda/go-postgresql-blog-http.git/telemetry"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer(telemetry.ServiceName + "/service")
+
+// endSpan ends the span of a service method, marking it failed when the
+// method returns an error. Methods defer it with their named error result.
+func endSpan(span trace.Span, err *error) {
+	if *err != nil {
+		recordError(span, *err)
+	}
+	span.End()
+}
+
+// recordError marks span failed with err.
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpansRecordErrors(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	user, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	bookmarkService := NewBookmarkService(repo, repo, repo, nil)
+
+	if err := bookmarkService.RemoveBookmark(ctx, user.ID, 99, ""); err != nil {
+		t.Fatalf("RemoveBookmark: %v", err)
+	}
+	if _, _, err := bookmarkService.AddBookmark(ctx, user.ID, 99, ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("AddBookmark err = %v, want ErrNotFound", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans ended, want 2", len(spans))
+	}
+	if status := spans[0].Status(); status.Code != codes.Unset || len(spans[0].Events()) != 0 {
+		t.Errorf("span of a success = %v with %d events, want it unset and without events", status, len(spans[0].Events()))
+	}
+	failed := spans[1]
+	if status := failed.Status(); status.Code != codes.Error || status.Description != ErrNotFound.Error() {
+		t.Errorf("span of a failure = %v, want an error of %q", status, ErrNotFound)
+	}
+	if events := failed.Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("span of a failure has events %v, want the error recorded", events)
+	}
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/storage"
+)
+
+// TrashPurgeBatchSize is how many expired rows of a table PurgeExpired reads
+// at a time.
+const TrashPurgeBatchSize = 100
+
+type TrashSvc struct {
+	UserRepo     repository.UserRepository
+	PostRepo     repository.PostRepository
+	CommentRepo  repository.CommentRepository
+	MediaRepo    repository.MediaRepository
+	ReactionRepo repository.ReactionRepository
+	Blobs        storage.BlobStore
+	Policies     DeletePolicies
+	Webhooks     WebhookService
+	Stream       CommentStreamService
+}
+
+func NewTrashService(userRepo repository.UserRepository, postRepo repository.PostRepository, commentRepo repository.CommentRepository, mediaRepo repository.MediaRepository, reactionRepo repository.ReactionRepository, blobs storage.BlobStore, policies DeletePolicies, webhooks WebhookService, stream CommentStreamService) TrashService {
+	return &TrashSvc{
+		UserRepo:     userRepo,
+		PostRepo:     postRepo,
+		CommentRepo:  commentRepo,
+		MediaRepo:    mediaRepo,
+		ReactionRepo: reactionRepo,
+		Blobs:        blobs,
+		Policies:     policies,
+		Webhooks:     webhooks,
+		Stream:       stream,
+	}
+}
+
+func (trashService *TrashSvc) deleter() *deleter {
+	return &deleter{
+		userRepo:     trashService.UserRepo,
+		postRepo:     trashService.PostRepo,
+		commentRepo:  trashService.CommentRepo,
+		mediaRepo:    trashService.MediaRepo,
+		reactionRepo: trashService.ReactionRepo,
+		blobs:        trashService.Blobs,
+		policies:     trashService.Policies,
+		webhooks:     trashService.Webhooks,
+		stream:       trashService.Stream,
+	}
+}
+
+func (trashService *TrashSvc) GetDeletedUsers(ctx context.Context, page Page) (_ []models.GormUser, _ int64, err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.GetDeletedUsers")
+	defer endSpan(span, &err)
+
+	return trashService.UserRepo.DeletedUsers(ctx, page)
+}
+
+func (trashService *TrashSvc) GetDeletedPosts(ctx context.Context, page Page) (_ []models.GormPost, _ int64, err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.GetDeletedPosts")
+	defer endSpan(span, &err)
+
+	return trashService.PostRepo.DeletedPosts(ctx, page)
+}
+
+func (trashService *TrashSvc) GetDeletedComments(ctx context.Context, page Page) (_ []models.GormComment, _ int64, err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.GetDeletedComments")
+	defer endSpan(span, &err)
+
+	return trashService.CommentRepo.DeletedComments(ctx, page)
+}
+
+func (trashService *TrashSvc) RestoreUserByID(ctx context.Context, id uint) (_ *models.GormUser, err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.RestoreUserByID")
+	defer endSpan(span, &err)
+
+	user, err := trashService.UserRepo.RestoreUser(ctx, id)
+	if err != nil {
+		log.Printf("Error restoring user with ID %d: %v", id, err)
+		return nil, err
+	}
+	return user, nil
+}
+
+func (trashService *TrashSvc) RestorePostByID(ctx context.Context, id uint) (_ *models.GormPost, err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.RestorePostByID")
+	defer endSpan(span, &err)
+
+	var post *models.GormPost
+	err = trashService.PostRepo.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		post, err = trashService.PostRepo.RestorePost(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// a post cannot come back while its author is still in the trash
+		if _, err := trashService.UserRepo.GetUserByID(ctx, post.UserID); errors.Is(err, repository.ErrNotExist) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error restoring post with ID %d: %v", id, err)
+		return nil, err
+	}
+	return post, nil
+}
+
+func (trashService *TrashSvc) RestoreCommentByID(ctx context.Context, id uint) (_ *models.GormComment, err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.RestoreCommentByID")
+	defer endSpan(span, &err)
+
+	var comment *models.GormComment
+	err = trashService.CommentRepo.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		comment, err = trashService.CommentRepo.RestoreComment(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// nor a comment while its author or post is
+		if _, err := trashService.UserRepo.GetUserByID(ctx, comment.UserID); errors.Is(err, repository.ErrNotExist) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+		if _, err := trashService.PostRepo.GetPostByID(ctx, comment.PostID); errors.Is(err, repository.ErrNotExist) {
+			return ErrPostNotFound
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error restoring comment with ID %d: %v", id, err)
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (trashService *TrashSvc) PurgeUserByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.PurgeUserByID")
+	defer endSpan(span, &err)
+
+	// the user's posts and comments follow the configured delete policies
+	deleter := trashService.deleter()
+	err = trashService.UserRepo.WithTx(ctx, func(ctx context.Context) error {
+		if err := requireTrashed(ctx, "user", id, trashService.UserRepo.GetUserByID); err != nil {
+			return err
+		}
+		return deleter.deleteUser(ctx, id, true)
+	})
+	if err != nil {
+		log.Printf("Error purging user with ID %d: %v", id, err)
+		return err
+	}
+
+	deleter.removeFiles(ctx)
+	return nil
+}
+
+func (trashService *TrashSvc) PurgePostByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.PurgePostByID")
+	defer endSpan(span, &err)
+
+	// the post's comments follow the configured delete policy
+	deleter := trashService.deleter()
+	err = trashService.PostRepo.WithTx(ctx, func(ctx context.Context) error {
+		if err := requireTrashed(ctx, "post", id, trashService.PostRepo.GetPostByID); err != nil {
+			return err
+		}
+		return deleter.deletePost(ctx, id, true)
+	})
+	if err != nil {
+		log.Printf("Error purging post with ID %d: %v", id, err)
+		return err
+	}
+
+	deleter.removeFiles(ctx)
+	return nil
+}
+
+func (trashService *TrashSvc) PurgeCommentByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.PurgeCommentByID")
+	defer endSpan(span, &err)
+
+	// the comment's reactions and notifications go with it
+	deleter := trashService.deleter()
+	err = trashService.CommentRepo.WithTx(ctx, func(ctx context.Context) error {
+		if err := requireTrashed(ctx, "comment", id, trashService.CommentRepo.GetCommentByID); err != nil {
+			return err
+		}
+		return deleter.deleteComment(ctx, id, true)
+	})
+	if err != nil {
+		log.Printf("Error purging comment with ID %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// requireTrashed answers ErrNotFound for a row that is still live, so that a
+// purge never takes out what was not moved to the trash first. getLive reads
+// live rows only.
+func requireTrashed[T any](ctx context.Context, what string, id uint, getLive func(context.Context, uint) (T, error)) error {
+	_, err := getLive(ctx, id)
+	if err == nil {
+		return fmt.Errorf("%s %d is not in the trash: %w", what, id, ErrNotFound)
+	}
+	if errors.Is(err, repository.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// PurgeExpired permanently removes everything that has been in the trash for
+// longer than retention. Comments, then posts, then users go one at a time
+// the way the Purge methods take them, each in its own transaction, so one
+// that is blocked stays in the trash without holding up the rest. Only the
+// ids of expired rows are read, TrashPurgeBatchSize at a time.
+func (trashService *TrashSvc) PurgeExpired(ctx context.Context, retention time.Duration) (_ int64, err error) {
+	ctx, span := tracer.Start(ctx, "TrashService.PurgeExpired")
+	defer endSpan(span, &err)
+
+	cutoff := time.Now().Add(-retention)
+	var purged int64
+
+	for _, table := range []struct {
+		expired func(ctx context.Context, before time.Time, afterID uint, limit int) ([]uint, error)
+		purge   func(ctx context.Context, id uint) error
+	}{
+		{trashService.CommentRepo.ExpiredCommentIDs, trashService.PurgeCommentByID},
+		{trashService.PostRepo.ExpiredPostIDs, trashService.PurgePostByID},
+		{trashService.UserRepo.ExpiredUserIDs, trashService.PurgeUserByID},
+	} {
+		// blocked rows stay behind, so each batch starts after the last id
+		var afterID uint
+		for {
+			ids, err := table.expired(ctx, cutoff, afterID, TrashPurgeBatchSize)
+			if err != nil {
+				return purged, err
+			}
+			for _, id := range ids {
+				if err := table.purge(ctx, id); err != nil {
+					if errors.Is(err, ErrDeleteBlocked) {
+						continue
+					}
+					return purged, err
+				}
+				purged++
+			}
+			if len(ids) < TrashPurgeBatchSize {
+				break
+			}
+			afterID = ids[len(ids)-1]
+		}
+	}
+
+	return purged, nil
+}
+
+// RunTrashRetention calls PurgeExpired every interval until ctx is done.
+func RunTrashRetention(ctx context.Context, trashService TrashService, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := trashService.PurgeExpired(ctx, retention)
+		if err != nil {
+			log.Printf("Error purging expired trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d rows from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
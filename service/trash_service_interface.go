@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// TrashService lists, restores and permanently removes soft-deleted rows.
+type TrashService interface {
+	GetDeletedUsers(ctx context.Context, page Page) ([]models.GormUser, int64, error)
+	GetDeletedPosts(ctx context.Context, page Page) ([]models.GormPost, int64, error)
+	GetDeletedComments(ctx context.Context, page Page) ([]models.GormComment, int64, error)
+	RestoreUserByID(ctx context.Context, id uint) (*models.GormUser, error)
+	RestorePostByID(ctx context.Context, id uint) (*models.GormPost, error)
+	RestoreCommentByID(ctx context.Context, id uint) (*models.GormComment, error)
+	PurgeUserByID(ctx context.Context, id uint) error
+	PurgePostByID(ctx context.Context, id uint) error
+	PurgeCommentByID(ctx context.Context, id uint) error
+	PurgeExpired(ctx context.Context, retention time.Duration) (int64, error)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+func TestPurgeExpiredComments(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+
+	author, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	reader, err := repo.CreateUser(ctx, models.GormUser{Email: "bo@example.com", Username: "bo"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Commented"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	trashed, err := repo.CreateComment(ctx, models.GormComment{UserID: author.ID, PostID: post.ID, Content: "Gone"})
+	if err != nil {
+		t.Fatalf("CreateComment: %v", err)
+	}
+	live, err := repo.CreateComment(ctx, models.GormComment{UserID: reader.ID, PostID: post.ID, Content: "Here"})
+	if err != nil {
+		t.Fatalf("CreateComment: %v", err)
+	}
+	if err := repo.DeleteComment(ctx, trashed.ID); err != nil {
+		t.Fatalf("DeleteComment: %v", err)
+	}
+
+	// comments already in the trash were announced when they went there,
+	// so purging them needs neither webhooks nor the stream
+	trashService := NewTrashService(repo, repo, repo, repo, repo, nil, DeletePolicies{}, nil, nil)
+	if purged, err := trashService.PurgeExpired(ctx, time.Hour); err != nil || purged != 0 {
+		t.Errorf("PurgeExpired within the retention = %d, %v, want 0", purged, err)
+	}
+	purged, err := trashService.PurgeExpired(ctx, -time.Minute)
+	if err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("PurgeExpired purged %d rows, want 1", purged)
+	}
+
+	deleted, _, err := repo.DeletedComments(ctx, repository.Page{Number: 1, Size: 10})
+	if err != nil || len(deleted) != 0 {
+		t.Errorf("DeletedComments = %v, %v, want none", deleted, err)
+	}
+	if _, err := repo.GetCommentByID(ctx, live.ID); err != nil {
+		t.Errorf("GetCommentByID of the live comment: %v", err)
+	}
+	if err := trashService.PurgeCommentByID(ctx, trashed.ID); !errors.Is(err, repository.ErrNotExist) {
+		t.Errorf("PurgeCommentByID of a purged comment err = %v, want ErrNotExist", err)
+	}
+}
+
+func TestPurgeOnlyTakesFromTheTrash(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+
+	author, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Live"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	comment, err := repo.CreateComment(ctx, models.GormComment{UserID: author.ID, PostID: post.ID, Content: "Live"})
+	if err != nil {
+		t.Fatalf("CreateComment: %v", err)
+	}
+
+	trashService := NewTrashService(repo, repo, repo, repo, repo, nil, DefaultDeletePolicies(), nil, nil)
+	for _, purge := range []struct {
+		name string
+		call func() error
+	}{
+		{"PurgeUserByID", func() error { return trashService.PurgeUserByID(ctx, author.ID) }},
+		{"PurgePostByID", func() error { return trashService.PurgePostByID(ctx, post.ID) }},
+		{"PurgeCommentByID", func() error { return trashService.PurgeCommentByID(ctx, comment.ID) }},
+		{"PurgePostByID of an unknown post", func() error { return trashService.PurgePostByID(ctx, post.ID+1) }},
+	} {
+		if err := purge.call(); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s err = %v, want ErrNotFound", purge.name, err)
+		}
+	}
+
+	if _, err := repo.GetUserByID(ctx, author.ID); err != nil {
+		t.Errorf("GetUserByID: %v", err)
+	}
+	if _, err := repo.GetPostByID(ctx, post.ID); err != nil {
+		t.Errorf("GetPostByID: %v", err)
+	}
+	if _, err := repo.GetCommentByID(ctx, comment.ID); err != nil {
+		t.Errorf("GetCommentByID: %v", err)
+	}
+}
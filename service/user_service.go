@@ -2,49 +2,84 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	// "fmt"
 	// "log"
 	"github.com/bellaananda/go-postgresql-blog-http.git/models"
 	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
-
-	"gorm.io/gorm"
 )
 
-type UserService struct {
-	UserRepo repository.UserRepository
-	db       *gorm.DB
+// PasswordResetTTL is how long a mailed password reset link works.
+const PasswordResetTTL = time.Hour
+
+type UserSvc struct {
+	UserRepo    repository.UserRepository
+	PostRepo    repository.PostRepository
+	CommentRepo repository.CommentRepository
+	Policies    DeletePolicies
+	Mail        MailService
+	Webhooks    WebhookService
+	Stream      CommentStreamService
 }
 
-func NewUserService(userRepo repository.UserRepository, db *gorm.DB) *UserService {
-	return &UserService{
-		UserRepo: userRepo,
-		db:       db,
+func NewUserService(userRepo repository.UserRepository, postRepo repository.PostRepository, commentRepo repository.CommentRepository, policies DeletePolicies, mail MailService, webhooks WebhookService, stream CommentStreamService) UserService {
+	return &UserSvc{
+		UserRepo:    userRepo,
+		PostRepo:    postRepo,
+		CommentRepo: commentRepo,
+		Policies:    policies,
+		Mail:        mail,
+		Webhooks:    webhooks,
+		Stream:      stream,
 	}
 }
 
-func (userService *UserService) CreateUser(ctx context.Context, user models.GormUser) (*models.GormUser, error) {
-	_, err := userService.UserRepo.GetUserByEmail(ctx, user.Email)
-	if err == nil || !errors.Is(err, repository.ErrNotExist) {
-		return nil, errors.New("user with this email already exists")
+func (userService *UserSvc) CreateUser(ctx context.Context, user models.GormUser) (_ *models.GormUser, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.CreateUser")
+	defer endSpan(span, &err)
+
+	var createdUser *models.GormUser
+	err = userService.UserRepo.WithTx(ctx, func(ctx context.Context) error {
+		_, err := userService.UserRepo.GetUserByEmail(ctx, user.Email)
+		if err == nil || !errors.Is(err, repository.ErrNotExist) {
+			return errors.New("user with this email already exists")
+		}
+
+		createdUser, err = userService.UserRepo.CreateUser(ctx, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return userService.UserRepo.CreateUser(ctx, user)
+	userService.Mail.Welcome(createdUser.ID)
+	return createdUser, nil
 }
 
-func (userService *UserService) GetAllUsers(ctx context.Context) ([]models.GormUser, error) {
-	var allUsers []models.GormUser
-	if err := userService.db.WithContext(ctx).Find(&allUsers).Error; err != nil {
+func (userService *UserSvc) GetAllUsers(ctx context.Context, fieldset Fieldset) (_ []models.GormUser, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetAllUsers")
+	defer endSpan(span, &err)
+
+	users, err := userService.UserRepo.AllUsers(ctx, fieldset.query())
+	if err != nil {
 		return nil, err
 	}
 
-	return allUsers, nil
+	return users, nil
 }
 
-func (userService *UserService) GetUserByID(ctx context.Context, id uint) (*models.GormUser, error) {
-	user, err := userService.UserRepo.GetUserByID(ctx, id)
+func (userService *UserSvc) GetUserByID(ctx context.Context, id uint, fieldset Fieldset) (_ *models.GormUser, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserByID")
+	defer endSpan(span, &err)
+
+	user, err := userService.UserRepo.FindUser(ctx, id, fieldset.query())
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
 			return nil, err
@@ -56,7 +91,10 @@ func (userService *UserService) GetUserByID(ctx context.Context, id uint) (*mode
 	return user, nil
 }
 
-func (userService *UserService) GetUserByEmail(ctx context.Context, email string) (*models.GormUser, error) {
+func (userService *UserSvc) GetUserByEmail(ctx context.Context, email string) (_ *models.GormUser, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserByEmail")
+	defer endSpan(span, &err)
+
 	user, err := userService.UserRepo.GetUserByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
@@ -68,7 +106,10 @@ func (userService *UserService) GetUserByEmail(ctx context.Context, email string
 	return user, nil
 }
 
-func (userService *UserService) GetUserByUsernameAndPassword(ctx context.Context, username, password string) (*models.GormUser, error) {
+func (userService *UserSvc) GetUserByUsernameAndPassword(ctx context.Context, username, password string) (_ *models.GormUser, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserByUsernameAndPassword")
+	defer endSpan(span, &err)
+
 	user, err := userService.UserRepo.GetUserByUsernameAndPassword(ctx, username, password)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotExist) {
@@ -80,30 +121,184 @@ func (userService *UserService) GetUserByUsernameAndPassword(ctx context.Context
 	return user, nil
 }
 
-func (userService *UserService) UpdateUserByID(ctx context.Context, userID uint, user models.GormUser) (*models.GormUser, error) {
+func (userService *UserSvc) UpdateUserByID(ctx context.Context, userID uint, user models.GormUser) (_ *models.GormUser, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateUserByID")
+	defer endSpan(span, &err)
+
 	// Check if the user ID in the URL matches the ID in the user object
 	if userID != user.ID {
 		return nil, errors.New("mismatched user ID in URL and request body")
 	}
 
-	existingUser, err := userService.UserRepo.GetUserByID(ctx, userID)
+	var updatedUser *models.GormUser
+	err = userService.UserRepo.WithTx(ctx, func(ctx context.Context) error {
+		existingUser, err := userService.UserRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		// without an If-Match precondition, write over whatever is stored
+		if user.Version == 0 {
+			user.Version = existingUser.Version
+		}
+		user.CreatedAt = existingUser.CreatedAt
+
+		updatedUser, err = userService.UserRepo.UpdateUser(ctx, userID, user)
+		return err
+	})
 	if err != nil {
+		log.Printf("Error updating user with ID %d: %v", userID, err)
 		return nil, err
 	}
 
-	_, err = userService.UserRepo.UpdateUser(ctx, userID, user)
+	return updatedUser, nil
+}
+
+func (userService *UserSvc) PatchUserByID(ctx context.Context, userID uint, version uint, patchType string, patch []byte) (_ *models.GormUser, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.PatchUserByID")
+	defer endSpan(span, &err)
+
+	var patchedUser *models.GormUser
+	err = userService.UserRepo.WithTx(ctx, func(ctx context.Context) error {
+		existingUser, err := userService.UserRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			version = existingUser.Version
+		}
+
+		// the password is set on its own and never shown to the patch
+		patch, password, err := takePassword(patchType, patch)
+		if err != nil {
+			return err
+		}
+
+		current := userFields{
+			Name:     existingUser.Name,
+			Email:    existingUser.Email,
+			Username: existingUser.Username,
+		}
+		patched, columns, err := applyPatch(current, patchType, patch)
+		if err != nil {
+			return err
+		}
+		if patched.Email == "" || patched.Username == "" {
+			return fmt.Errorf("%w: email and username are required", ErrInvalidPatch)
+		}
+		if password != nil && *password != existingUser.Password {
+			columns["password"] = *password
+		}
+
+		if len(columns) == 0 {
+			if version != existingUser.Version {
+				return ErrVersionConflict
+			}
+			patchedUser = existingUser
+			return nil
+		}
+
+		patchedUser, err = userService.UserRepo.PatchUser(ctx, userID, version, columns)
+		return err
+	})
 	if err != nil {
-		log.Printf("Error updating user with ID %d: %v", userID, err)
+		log.Printf("Error patching user with ID %d: %v", userID, err)
 		return nil, err
 	}
 
-	return existingUser, nil
+	return patchedUser, nil
 }
 
-func (userService *UserService) DeleteUserByID(ctx context.Context, id uint) error {
-	if err := userService.UserRepo.DeleteUser(ctx, id); err != nil {
+func (userService *UserSvc) DeleteUserByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.DeleteUserByID")
+	defer endSpan(span, &err)
+
+	// the user's posts and comments follow the configured delete policies
+	err = userService.UserRepo.WithTx(ctx, func(ctx context.Context) error {
+		deleter := &deleter{
+			userRepo:    userService.UserRepo,
+			postRepo:    userService.PostRepo,
+			commentRepo: userService.CommentRepo,
+			policies:    userService.Policies,
+			webhooks:    userService.Webhooks,
+			stream:      userService.Stream,
+		}
+		return deleter.deleteUser(ctx, id, false)
+	})
+	if err != nil {
 		log.Printf("Error deleting user with ID %d: %v", id, err)
 		return err
 	}
 	return nil
 }
+
+func (userService *UserSvc) RequestPasswordReset(ctx context.Context, email string) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.RequestPasswordReset")
+	defer endSpan(span, &err)
+
+	user, err := userService.UserRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// the token is mailed and only its hash is stored
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(secret)
+
+	err = userService.UserRepo.CreatePasswordReset(ctx, models.GormPasswordReset{
+		TokenHash: resetTokenHash(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(PasswordResetTTL),
+	})
+	if err != nil {
+		log.Printf("Error creating password reset for user ID %d: %v", user.ID, err)
+		return err
+	}
+
+	userService.Mail.PasswordReset(user.ID, token, PasswordResetTTL)
+	return nil
+}
+
+func (userService *UserSvc) ResetPassword(ctx context.Context, token string, password string) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ResetPassword")
+	defer endSpan(span, &err)
+
+	err = userService.UserRepo.WithTx(ctx, func(ctx context.Context) error {
+		reset, err := userService.UserRepo.TakePasswordReset(ctx, resetTokenHash(token), time.Now())
+		if errors.Is(err, repository.ErrNotExist) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		// a user trashed since the reset was asked for keeps their password
+		user, err := userService.UserRepo.GetUserByID(ctx, reset.UserID)
+		if errors.Is(err, repository.ErrNotExist) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = userService.UserRepo.PatchUser(ctx, user.ID, user.Version, map[string]interface{}{"password": password})
+		return err
+	})
+	if err != nil {
+		log.Printf("Error resetting password: %v", err)
+		return err
+	}
+	return nil
+}
+
+// resetTokenHash is what a password reset is stored under.
+func resetTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
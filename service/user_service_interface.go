@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// UserService holds the user use cases the handlers depend on.
+type UserService interface {
+	CreateUser(ctx context.Context, user models.GormUser) (*models.GormUser, error)
+	GetAllUsers(ctx context.Context, fieldset Fieldset) ([]models.GormUser, error)
+	GetUserByID(ctx context.Context, id uint, fieldset Fieldset) (*models.GormUser, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.GormUser, error)
+	GetUserByUsernameAndPassword(ctx context.Context, username string, password string) (*models.GormUser, error)
+	UpdateUserByID(ctx context.Context, userID uint, user models.GormUser) (*models.GormUser, error)
+	PatchUserByID(ctx context.Context, userID uint, version uint, patchType string, patch []byte) (*models.GormUser, error)
+	DeleteUserByID(ctx context.Context, id uint) error
+	// RequestPasswordReset mails a reset link to the user with email, if
+	// there is one. Whether there is is never told, so addresses cannot be
+	// probed.
+	RequestPasswordReset(ctx context.Context, email string) error
+	// ResetPassword sets the password of the user a reset token was mailed
+	// to. Each token works once, and not after PasswordResetTTL.
+	ResetPassword(ctx context.Context, token string, password string) error
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+func TestPatchUserPassword(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	user, err := repo.CreateUser(ctx, models.GormUser{Name: "Ann", Email: "ann@example.com", Username: "ann", Password: "secret"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	userService := NewUserService(repo, repo, repo, DeletePolicies{}, nil, nil, nil)
+
+	for _, tt := range []struct {
+		name      string
+		patchType string
+		patch     string
+	}{
+		{"copy to a shown field", JSONPatchType, `[{"op":"copy","from":"/password","path":"/name"}]`},
+		{"move to a shown field", JSONPatchType, `[{"op":"move","from":"/password","path":"/name"}]`},
+		{"test a guess", JSONPatchType, `[{"op":"test","path":"/password","value":"secret"}]`},
+		{"remove", JSONPatchType, `[{"op":"remove","path":"/password"}]`},
+		{"copy over it", JSONPatchType, `[{"op":"copy","from":"/email","path":"/password"}]`},
+		{"replace with null", JSONPatchType, `[{"op":"replace","path":"/password","value":null}]`},
+		{"merge null", MergePatchType, `{"password":null}`},
+		{"merge empty", MergePatchType, `{"password":""}`},
+		{"merge a number", MergePatchType, `{"password":7}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := userService.PatchUserByID(ctx, user.ID, 0, tt.patchType, []byte(tt.patch))
+			if !errors.Is(err, ErrInvalidPatch) {
+				t.Errorf("PatchUserByID err = %v, want ErrInvalidPatch", err)
+			}
+			if _, err := repo.GetUserByUsernameAndPassword(ctx, "ann", "secret"); err != nil {
+				t.Errorf("the password changed: %v", err)
+			}
+		})
+	}
+
+	patched, err := userService.PatchUserByID(ctx, user.ID, 0, JSONPatchType, []byte(`[{"op":"replace","path":"/password","value":"better"},{"op":"replace","path":"/name","value":"Annie"}]`))
+	if err != nil {
+		t.Fatalf("PatchUserByID replace: %v", err)
+	}
+	if patched.Name != "Annie" || patched.Version != user.Version+1 {
+		t.Errorf("patched = %+v, want the new name at version %d", patched, user.Version+1)
+	}
+	if _, err := repo.GetUserByUsernameAndPassword(ctx, "ann", "better"); err != nil {
+		t.Errorf("GetUserByUsernameAndPassword after replace: %v", err)
+	}
+
+	if _, err := userService.PatchUserByID(ctx, user.ID, 0, MergePatchType, []byte(`{"password":"best"}`)); err != nil {
+		t.Fatalf("PatchUserByID merge: %v", err)
+	}
+	if _, err := repo.GetUserByUsernameAndPassword(ctx, "ann", "best"); err != nil {
+		t.Errorf("GetUserByUsernameAndPassword after merge: %v", err)
+	}
+}
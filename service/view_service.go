@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// DedupeWindow is how long views of a post by the same visitor count once.
+var DedupeWindow = 30 * time.Minute
+
+// ViewBufferSize is how many views may wait in memory before a flush is
+// started early.
+var ViewBufferSize int64 = 10000
+
+// Defaults and limits of analytics queries.
+var (
+	DefaultAnalyticsPeriod = 30 * 24 * time.Hour
+	MaxAnalyticsPeriod     = 5 * 366 * 24 * time.Hour
+	DefaultAnalyticsLimit  = 10
+)
+
+// AnalyticsIntervals are the bucket sizes ViewsOverTime accepts.
+var AnalyticsIntervals = []string{"day", "week", "month"}
+
+// botMarkers are found, lowercased, in the user agents of crawlers, link
+// previewers, monitors and HTTP libraries, none of which are readers.
+var botMarkers = []string{
+	"bot", "crawl", "spider", "slurp", "preview", "facebookexternalhit",
+	"headless", "monitor", "curl/", "wget/", "python-requests", "go-http-client",
+	"okhttp", "java/", "libwww", "httpclient",
+}
+
+// isBot reports whether a user agent belongs to a bot. Browsers always
+// send a user agent, so a missing one counts as a bot.
+func isBot(userAgent string) bool {
+	userAgent = strings.ToLower(strings.TrimSpace(userAgent))
+	if userAgent == "" {
+		return true
+	}
+	for _, marker := range botMarkers {
+		if strings.Contains(userAgent, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// viewSlot is a post and the hour, as Unix seconds, its views are counted
+// in.
+type viewSlot struct {
+	postID uint
+	hour   int64
+}
+
+type ViewSvc struct {
+	ViewRepo repository.ViewRepository
+
+	mu       sync.Mutex
+	pending  map[viewSlot]int64
+	buffered int64
+	// when each visitor, per post, last had a view counted
+	seen  map[string]time.Time
+	flush chan struct{}
+}
+
+func NewViewService(viewRepo repository.ViewRepository) ViewService {
+	return &ViewSvc{
+		ViewRepo: viewRepo,
+		pending:  make(map[viewSlot]int64),
+		seen:     make(map[string]time.Time),
+		flush:    make(chan struct{}, 1),
+	}
+}
+
+func (viewService *ViewSvc) RecordView(visit Visit) {
+	if isBot(visit.UserAgent) {
+		return
+	}
+
+	now := time.Now()
+	key := fmt.Sprintf("%d:%s", visit.PostID, visit.Visitor)
+
+	viewService.mu.Lock()
+	defer viewService.mu.Unlock()
+
+	if last, ok := viewService.seen[key]; ok && now.Sub(last) < DedupeWindow {
+		return
+	}
+	viewService.seen[key] = now
+
+	viewService.pending[viewSlot{visit.PostID, now.UTC().Truncate(time.Hour).Unix()}]++
+	viewService.buffered++
+	if viewService.buffered >= ViewBufferSize {
+		// Run is already told if the channel is full
+		select {
+		case viewService.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (viewService *ViewSvc) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx is done, so the last flush gets a context of its own
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			viewService.Flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+		case <-viewService.flush:
+		}
+
+		viewService.Flush(ctx)
+	}
+}
+
+func (viewService *ViewSvc) Flush(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "ViewService.Flush")
+	defer endSpan(span, &err)
+
+	now := time.Now()
+	viewService.mu.Lock()
+	pending := viewService.pending
+	viewService.pending = make(map[viewSlot]int64)
+	viewService.buffered = 0
+	// forget visitors whose window is over
+	for key, last := range viewService.seen {
+		if now.Sub(last) >= DedupeWindow {
+			delete(viewService.seen, key)
+		}
+	}
+	viewService.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	views := make([]models.GormPostView, 0, len(pending))
+	for slot, count := range pending {
+		views = append(views, models.GormPostView{PostID: slot.postID, Hour: time.Unix(slot.hour, 0).UTC(), Views: count})
+	}
+
+	if err := viewService.ViewRepo.AddViews(ctx, views); err != nil {
+		log.Printf("Error flushing %d post view counts: %v", len(views), err)
+
+		// keep the counts for the next flush
+		viewService.mu.Lock()
+		for slot, count := range pending {
+			viewService.pending[slot] += count
+			viewService.buffered += count
+		}
+		viewService.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// viewFilter checks query and fills in its defaults: the last
+// DefaultAnalyticsPeriod, daily buckets and DefaultAnalyticsLimit entries.
+func viewFilter(query *AnalyticsQuery) (repository.ViewFilter, error) {
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	if query.From.IsZero() {
+		query.From = query.To.Add(-DefaultAnalyticsPeriod)
+	}
+	if !query.From.Before(query.To) {
+		return repository.ViewFilter{}, fmt.Errorf("%w: the period ends before it starts", ErrInvalidAnalytics)
+	}
+	if query.To.Sub(query.From) > MaxAnalyticsPeriod {
+		return repository.ViewFilter{}, fmt.Errorf("%w: the period is longer than %d days", ErrInvalidAnalytics, MaxAnalyticsPeriod/(24*time.Hour))
+	}
+
+	if query.Interval == "" {
+		query.Interval = AnalyticsIntervals[0]
+	}
+	known := false
+	for _, interval := range AnalyticsIntervals {
+		known = known || query.Interval == interval
+	}
+	if !known {
+		return repository.ViewFilter{}, fmt.Errorf("%w: interval %q is not one of %s", ErrInvalidAnalytics, query.Interval, strings.Join(AnalyticsIntervals, ", "))
+	}
+
+	if query.Limit < 1 {
+		query.Limit = DefaultAnalyticsLimit
+	}
+	if query.Limit > MaxPageSize {
+		query.Limit = MaxPageSize
+	}
+
+	return repository.ViewFilter{Since: query.From, Until: query.To, PostID: query.PostID, UserID: query.UserID}, nil
+}
+
+func (viewService *ViewSvc) ViewsOverTime(ctx context.Context, query AnalyticsQuery) (_ []models.ViewBucket, err error) {
+	ctx, span := tracer.Start(ctx, "ViewService.ViewsOverTime")
+	defer endSpan(span, &err)
+
+	filter, err := viewFilter(&query)
+	if err != nil {
+		return nil, err
+	}
+	return viewService.ViewRepo.ViewBuckets(ctx, filter, query.Interval)
+}
+
+func (viewService *ViewSvc) TopPosts(ctx context.Context, query AnalyticsQuery) (_ []models.PostViews, err error) {
+	ctx, span := tracer.Start(ctx, "ViewService.TopPosts")
+	defer endSpan(span, &err)
+
+	filter, err := viewFilter(&query)
+	if err != nil {
+		return nil, err
+	}
+	return viewService.ViewRepo.TopPosts(ctx, filter, query.Limit)
+}
+
+func (viewService *ViewSvc) TopAuthors(ctx context.Context, query AnalyticsQuery) (_ []models.AuthorViews, err error) {
+	ctx, span := tracer.Start(ctx, "ViewService.TopAuthors")
+	defer endSpan(span, &err)
+
+	filter, err := viewFilter(&query)
+	if err != nil {
+		return nil, err
+	}
+	return viewService.ViewRepo.TopAuthors(ctx, filter, query.Limit)
+}
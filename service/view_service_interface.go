@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// Visit is one read of a post as the request came in.
+type Visit struct {
+	PostID uint
+	// Visitor tells readers apart without saying who they are, so that
+	// one reader reloading a post counts once.
+	Visitor   string
+	UserAgent string
+}
+
+// AnalyticsQuery picks the views a report covers: those from From up to,
+// not including, To, of one post or the posts of one author when PostID or
+// UserID is set. Interval is the bucket size of ViewsOverTime and Limit
+// the length of the top lists.
+type AnalyticsQuery struct {
+	From     time.Time
+	To       time.Time
+	Interval string
+	PostID   uint
+	UserID   uint
+	Limit    int
+}
+
+// ViewService counts how often posts are read and reports on it.
+type ViewService interface {
+	// RecordView counts a visit in memory, unless it comes from a bot or
+	// the visitor already viewed the post within DedupeWindow. Counts
+	// reach the database when they are flushed.
+	RecordView(visit Visit)
+	// Run flushes the counts every interval, and sooner once
+	// ViewBufferSize views are waiting, until ctx is done; then it
+	// flushes one last time.
+	Run(ctx context.Context, interval time.Duration)
+	// Flush writes the counts recorded so far in one batch. Counts that
+	// fail to be written are kept for the next flush.
+	Flush(ctx context.Context) error
+	// ViewsOverTime sums the views per day, week or month, oldest first,
+	// including the ones without any.
+	ViewsOverTime(ctx context.Context, query AnalyticsQuery) ([]models.ViewBucket, error)
+	// TopPosts and TopAuthors list the most viewed posts and authors.
+	TopPosts(ctx context.Context, query AnalyticsQuery) ([]models.PostViews, error)
+	TopAuthors(ctx context.Context, query AnalyticsQuery) ([]models.AuthorViews, error)
+}
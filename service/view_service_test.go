@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+)
+
+// failingViewRepository fails AddViews while err is set.
+type failingViewRepository struct {
+	repository.ViewRepository
+	err error
+}
+
+func (repo *failingViewRepository) AddViews(ctx context.Context, views []models.GormPostView) error {
+	if repo.err != nil {
+		return repo.err
+	}
+	return repo.ViewRepository.AddViews(ctx, views)
+}
+
+// newViewService sets up a view service over the in-memory repositories
+// with one published post, and returns a function reading the post's views.
+func newViewService(t *testing.T) (ViewService, *failingViewRepository, uint, func() int64) {
+	t.Helper()
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	author, err := repo.CreateUser(ctx, models.GormUser{Email: "ann@example.com", Username: "ann"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	post, err := repo.CreatePost(ctx, models.GormPost{UserID: author.ID, Title: "Read", IsPublished: true})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	views := &failingViewRepository{ViewRepository: repo}
+	viewService := NewViewService(views)
+	count := func() int64 {
+		t.Helper()
+		top, err := viewService.TopPosts(ctx, AnalyticsQuery{From: time.Now().Add(-2 * time.Hour), To: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("TopPosts: %v", err)
+		}
+		if len(top) == 0 {
+			return 0
+		}
+		return top[0].Views
+	}
+	return viewService, views, post.ID, count
+}
+
+const browser = "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0"
+
+func TestRecordViewSkipsBots(t *testing.T) {
+	viewService, _, postID, count := newViewService(t)
+
+	for _, userAgent := range []string{
+		"",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"facebookexternalhit/1.1",
+		"curl/8.4.0",
+		"Go-http-client/1.1",
+		"Mozilla/5.0 HeadlessChrome/120.0",
+	} {
+		viewService.RecordView(Visit{PostID: postID, Visitor: userAgent, UserAgent: userAgent})
+	}
+	viewService.RecordView(Visit{PostID: postID, Visitor: "reader", UserAgent: browser})
+
+	if err := viewService.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if views := count(); views != 1 {
+		t.Errorf("views = %d, want only the browser's", views)
+	}
+}
+
+func TestRecordViewDedupes(t *testing.T) {
+	defer func(window time.Duration) { DedupeWindow = window }(DedupeWindow)
+	DedupeWindow = time.Hour
+	viewService, _, postID, count := newViewService(t)
+
+	viewService.RecordView(Visit{PostID: postID, Visitor: "ann", UserAgent: browser})
+	viewService.RecordView(Visit{PostID: postID, Visitor: "ann", UserAgent: browser})
+	viewService.RecordView(Visit{PostID: postID, Visitor: "bo", UserAgent: browser})
+	if err := viewService.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if views := count(); views != 2 {
+		t.Errorf("views = %d, want one per visitor", views)
+	}
+
+	// the window outlasts a flush
+	viewService.RecordView(Visit{PostID: postID, Visitor: "ann", UserAgent: browser})
+	if err := viewService.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if views := count(); views != 2 {
+		t.Errorf("views = %d after a repeat visit, want 2", views)
+	}
+
+	// and once it is over the visitor counts again
+	DedupeWindow = 0
+	viewService.RecordView(Visit{PostID: postID, Visitor: "ann", UserAgent: browser})
+	if err := viewService.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if views := count(); views != 3 {
+		t.Errorf("views = %d after the window, want 3", views)
+	}
+}
+
+func TestFlushKeepsCountsThatFail(t *testing.T) {
+	viewService, views, postID, count := newViewService(t)
+	ctx := context.Background()
+
+	viewService.RecordView(Visit{PostID: postID, Visitor: "ann", UserAgent: browser})
+	views.err = errors.New("database is down")
+	if err := viewService.Flush(ctx); !errors.Is(err, views.err) {
+		t.Fatalf("Flush err = %v, want the repository's", err)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("views = %d after a failed flush, want 0", n)
+	}
+
+	viewService.RecordView(Visit{PostID: postID, Visitor: "bo", UserAgent: browser})
+	views.err = nil
+	if err := viewService.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("views = %d, want the failed view and the new one", n)
+	}
+
+	// nothing is written twice
+	if err := viewService.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("views = %d after an empty flush, want 2", n)
+	}
+}
@@ -0,0 +1,430 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/webhook"
+)
+
+// The types of event webhooks can subscribe to.
+const (
+	// WebhookPostPublished is sent when a post is published for the first
+	// time, whether it was created published or published later.
+	WebhookPostPublished  = "post.published"
+	WebhookCommentCreated = "comment.created"
+	WebhookCommentUpdated = "comment.updated"
+	// WebhookCommentDeleted is sent for comments deleted on their own and
+	// for those deleted along with their post or author.
+	WebhookCommentDeleted = "comment.deleted"
+)
+
+// WebhookEvents are the types of event webhooks can subscribe to.
+var WebhookEvents = []string{WebhookPostPublished, WebhookCommentCreated, WebhookCommentUpdated, WebhookCommentDeleted}
+
+// WebhookAttempts is how many times a delivery is tried before it is dead.
+// The wait after each failed attempt doubles from WebhookRetryDelay, which
+// spreads the attempts over about an hour.
+const WebhookAttempts = 8
+
+var WebhookRetryDelay = 30 * time.Second
+
+// WebhookTimeout is how long a webhook has to answer.
+const WebhookTimeout = 10 * time.Second
+
+// WebhookBatchSize is how many events are dispatched, and how many
+// deliveries sent at the same time, in one go.
+const WebhookBatchSize = 20
+
+// webhookLease is how long a claimed delivery is left alone before it is
+// sent again, in case whoever claimed it died sending it. It must outlast
+// WebhookTimeout.
+const webhookLease = time.Minute
+
+type WebhookSvc struct {
+	WebhookRepo repository.WebhookRepository
+	Client      *webhook.Client
+	wake        chan struct{}
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository, client *webhook.Client) WebhookService {
+	return &WebhookSvc{
+		WebhookRepo: webhookRepo,
+		Client:      client,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// webhookBody is the JSON every webhook request carries. ID is the event's,
+// the same for every webhook and every attempt, so receivers can tell
+// repeats apart.
+type webhookBody struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (webhookService *WebhookSvc) CreateWebhook(ctx context.Context, hook models.GormWebhook) (_ *models.GormWebhook, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.CreateWebhook")
+	defer endSpan(span, &err)
+
+	if err := validWebhook(hook); err != nil {
+		return nil, err
+	}
+	if hook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		hook.Secret = hex.EncodeToString(secret)
+	}
+
+	createdWebhook, err := webhookService.WebhookRepo.CreateWebhook(ctx, hook)
+	if err != nil {
+		log.Printf("Error creating webhook for %s: %v", hook.URL, err)
+		return nil, err
+	}
+	return createdWebhook, nil
+}
+
+func (webhookService *WebhookSvc) GetWebhooks(ctx context.Context) (_ []models.GormWebhook, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetWebhooks")
+	defer endSpan(span, &err)
+
+	return webhookService.WebhookRepo.Webhooks(ctx)
+}
+
+func (webhookService *WebhookSvc) GetWebhookByID(ctx context.Context, id uint) (_ *models.GormWebhook, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetWebhookByID")
+	defer endSpan(span, &err)
+
+	return webhookService.WebhookRepo.GetWebhookByID(ctx, id)
+}
+
+func (webhookService *WebhookSvc) UpdateWebhook(ctx context.Context, id uint, hook models.GormWebhook) (_ *models.GormWebhook, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.UpdateWebhook")
+	defer endSpan(span, &err)
+
+	if err := validWebhook(hook); err != nil {
+		return nil, err
+	}
+
+	var updatedWebhook *models.GormWebhook
+	err = webhookService.WebhookRepo.WithTx(ctx, func(ctx context.Context) error {
+		existingWebhook, err := webhookService.WebhookRepo.GetWebhookByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// without a new secret, keep signing with the old one
+		hook.ID = id
+		if hook.Secret == "" {
+			hook.Secret = existingWebhook.Secret
+		}
+
+		updatedWebhook, err = webhookService.WebhookRepo.UpdateWebhook(ctx, hook)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error updating webhook with ID %d: %v", id, err)
+		return nil, err
+	}
+
+	// turned back on, its waiting deliveries may go out right away
+	webhookService.nudge()
+	return updatedWebhook, nil
+}
+
+func (webhookService *WebhookSvc) DeleteWebhook(ctx context.Context, id uint) (err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.DeleteWebhook")
+	defer endSpan(span, &err)
+
+	if err := webhookService.WebhookRepo.DeleteWebhook(ctx, id); err != nil {
+		log.Printf("Error deleting webhook with ID %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+func (webhookService *WebhookSvc) GetDeliveries(ctx context.Context, webhookID uint, status string, page Page) (_ []models.GormWebhookDelivery, _ int64, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetDeliveries")
+	defer endSpan(span, &err)
+
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown delivery status %q, want %s, %s or %s", ErrInvalidWebhook, status, models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead)
+	}
+
+	if _, err := webhookService.WebhookRepo.GetWebhookByID(ctx, webhookID); err != nil {
+		return nil, 0, err
+	}
+	return webhookService.WebhookRepo.WebhookDeliveries(ctx, webhookID, status, page)
+}
+
+func (webhookService *WebhookSvc) Redeliver(ctx context.Context, webhookID uint, deliveryID uint) (_ *models.GormWebhookDelivery, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.Redeliver")
+	defer endSpan(span, &err)
+
+	var delivery *models.GormWebhookDelivery
+	err = webhookService.WebhookRepo.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		delivery, err = webhookService.WebhookRepo.GetWebhookDelivery(ctx, deliveryID)
+		if err != nil {
+			return err
+		}
+		if delivery.WebhookID != webhookID {
+			return repository.ErrNotExist
+		}
+		if delivery.Status == models.DeliveryPending {
+			return ErrDeliveryPending
+		}
+
+		delivery.Status = models.DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now()
+		return webhookService.WebhookRepo.UpdateWebhookDelivery(ctx, *delivery)
+	})
+	if err != nil {
+		log.Printf("Error redelivering webhook delivery with ID %d: %v", deliveryID, err)
+		return nil, err
+	}
+
+	webhookService.nudge()
+	return delivery, nil
+}
+
+func (webhookService *WebhookSvc) Enqueue(ctx context.Context, eventType string, data interface{}) (err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.Enqueue")
+	defer endSpan(span, &err)
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	err = webhookService.WebhookRepo.CreateWebhookEvent(ctx, models.GormWebhookEvent{Type: eventType, Data: string(encoded)})
+	if err != nil {
+		log.Printf("Error storing %s webhook event: %v", eventType, err)
+		return err
+	}
+
+	// the event only exists for the dispatcher once it is committed
+	repository.AfterCommit(ctx, webhookService.nudge)
+	return nil
+}
+
+// nudge tells Run there is work to do without waiting for it.
+func (webhookService *WebhookSvc) nudge() {
+	// Run is already told if the channel is full
+	select {
+	case webhookService.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (webhookService *WebhookSvc) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := webhookService.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error dispatching webhook events: %v", err)
+		}
+		if err := webhookService.Deliver(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error delivering webhooks: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-webhookService.wake:
+		}
+	}
+}
+
+func (webhookService *WebhookSvc) Dispatch(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.Dispatch")
+	defer endSpan(span, &err)
+
+	for {
+		var dispatched int
+		err := webhookService.WebhookRepo.WithTx(ctx, func(ctx context.Context) error {
+			events, err := webhookService.WebhookRepo.PendingWebhookEvents(ctx, WebhookBatchSize)
+			if err != nil || len(events) == 0 {
+				return err
+			}
+			webhooks, err := webhookService.WebhookRepo.Webhooks(ctx)
+			if err != nil {
+				return err
+			}
+
+			// inactive webhooks get their deliveries too, to send once they
+			// are turned back on
+			eventIDs := make([]uint, len(events))
+			deliveries := []models.GormWebhookDelivery{}
+			now := time.Now()
+			for i, event := range events {
+				eventIDs[i] = event.ID
+				for _, hook := range webhooks {
+					if hook.Events.Has(event.Type) {
+						deliveries = append(deliveries, models.GormWebhookDelivery{
+							WebhookID:     hook.ID,
+							EventID:       event.ID,
+							EventType:     event.Type,
+							Status:        models.DeliveryPending,
+							NextAttemptAt: now,
+						})
+					}
+				}
+			}
+
+			dispatched = len(events)
+			return webhookService.WebhookRepo.DispatchWebhookEvents(ctx, eventIDs, deliveries)
+		})
+		if err != nil {
+			return err
+		}
+		if dispatched < WebhookBatchSize {
+			return nil
+		}
+	}
+}
+
+func (webhookService *WebhookSvc) Deliver(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.Deliver")
+	defer endSpan(span, &err)
+
+	for {
+		now := time.Now()
+		deliveries, err := webhookService.WebhookRepo.ClaimWebhookDeliveries(ctx, now, now.Add(webhookLease), WebhookBatchSize)
+		if err != nil {
+			return err
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery models.GormWebhookDelivery) {
+				defer wg.Done()
+				webhookService.attempt(ctx, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) < WebhookBatchSize || ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// attempt sends a claimed delivery once and records how it went: delivered,
+// due again after a backoff, or dead once it is out of attempts.
+func (webhookService *WebhookSvc) attempt(ctx context.Context, delivery models.GormWebhookDelivery) {
+	ctx, span := tracer.Start(ctx, "WebhookService.Attempt")
+	defer span.End()
+
+	body, err := json.Marshal(webhookBody{
+		ID:        delivery.Event.ID,
+		Type:      delivery.Event.Type,
+		CreatedAt: delivery.Event.CreatedAt,
+		Data:      json.RawMessage(delivery.Event.Data),
+	})
+	if err != nil {
+		recordError(span, err)
+		log.Printf("Error encoding webhook delivery with ID %d: %v", delivery.ID, err)
+		return
+	}
+
+	status, err := webhookService.Client.Send(ctx, webhook.Request{
+		URL:        delivery.Webhook.URL,
+		Secret:     delivery.Webhook.Secret,
+		Event:      delivery.EventType,
+		DeliveryID: strconv.FormatUint(uint64(delivery.ID), 10),
+		Body:       body,
+	})
+	if err != nil {
+		recordError(span, err)
+	}
+	// cut short by shutting down: the lease runs out and it is sent again
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	delivery.Error = ""
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+	case delivery.Attempts >= WebhookAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.Error = err.Error()
+		log.Printf("Error delivering %s to webhook ID %d, giving up after %d attempts: %v", delivery.EventType, delivery.WebhookID, delivery.Attempts, err)
+	default:
+		delay := WebhookRetryDelay << (delivery.Attempts - 1)
+		delivery.NextAttemptAt = now.Add(delay)
+		delivery.Error = err.Error()
+		log.Printf("Error delivering %s to webhook ID %d, retrying in %s: %v", delivery.EventType, delivery.WebhookID, delay, err)
+	}
+
+	if err := webhookService.WebhookRepo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		recordError(span, err)
+		log.Printf("Error recording webhook delivery with ID %d: %v", delivery.ID, err)
+	}
+}
+
+// validWebhook checks the URL and that every event type is known.
+func validWebhook(hook models.GormWebhook) error {
+	if err := webhook.ValidURL(hook.URL); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	if len(hook.Events) == 0 {
+		return fmt.Errorf("%w: subscribe to at least one of %s", ErrInvalidWebhook, strings.Join(WebhookEvents, ", "))
+	}
+	for _, eventType := range hook.Events {
+		if !validWebhookEvent(eventType) {
+			return fmt.Errorf("%w: unknown event %q, want one of %s", ErrInvalidWebhook, eventType, strings.Join(WebhookEvents, ", "))
+		}
+	}
+	return nil
+}
+
+func validWebhookEvent(eventType string) bool {
+	for _, known := range WebhookEvents {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// postEvent and commentEvent are what events about posts and comments
+// carry: the row as stored, without the author or other rows attached.
+func postEvent(post *models.GormPost) models.GormPost {
+	event := *post
+	event.User, event.Comments = nil, nil
+	event.ContentHTML, event.ThumbnailURL, event.ThumbnailSrcset, event.Bookmarked = "", "", "", nil
+	return event
+}
+
+func commentEvent(comment *models.GormComment) models.GormComment {
+	event := *comment
+	event.User, event.Post = nil, nil
+	event.ContentHTML = ""
+	return event
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+)
+
+// WebhookService lets other systems subscribe to what happens to posts and
+// comments. Events are written to an outbox in the transaction of the
+// change they describe, then delivered in the background, signed, and
+// retried until they succeed or run out of attempts.
+type WebhookService interface {
+	// CreateWebhook stores a subscription and returns it with its secret,
+	// which is generated when none is given. It is the only time the
+	// secret is shown.
+	CreateWebhook(ctx context.Context, webhook models.GormWebhook) (*models.GormWebhook, error)
+	GetWebhooks(ctx context.Context) ([]models.GormWebhook, error)
+	GetWebhookByID(ctx context.Context, id uint) (*models.GormWebhook, error)
+	// UpdateWebhook replaces the URL, events and whether the webhook is
+	// active, and the secret when a new one is given.
+	UpdateWebhook(ctx context.Context, id uint, webhook models.GormWebhook) (*models.GormWebhook, error)
+	DeleteWebhook(ctx context.Context, id uint) error
+	// GetDeliveries reads one page of the delivery log of a webhook, newest
+	// first, and how many deliveries there are in all. A non-empty status
+	// leaves out deliveries in any other.
+	GetDeliveries(ctx context.Context, webhookID uint, status string, page Page) ([]models.GormWebhookDelivery, int64, error)
+	// Redeliver sends a delivery that was delivered or is dead once more,
+	// with a fresh set of attempts.
+	Redeliver(ctx context.Context, webhookID uint, deliveryID uint) (*models.GormWebhookDelivery, error)
+
+	// Enqueue adds an event of eventType carrying data to the outbox, from
+	// within the transaction of the write it describes. Once that commits
+	// the event is sent to every active webhook subscribed to eventType.
+	Enqueue(ctx context.Context, eventType string, data interface{}) error
+	// Run dispatches and delivers events every interval, and as soon as an
+	// event is enqueued, until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+	// Dispatch turns the events in the outbox into one delivery per
+	// subscribed webhook.
+	Dispatch(ctx context.Context) error
+	// Deliver makes one attempt at every delivery that is due.
+	Deliver(ctx context.Context) error
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/models"
+	"github.com/bellaananda/go-postgresql-blog-http.git/repository"
+	"github.com/bellaananda/go-postgresql-blog-http.git/webhook"
+)
+
+// roundTripFunc lets a test answer the requests of a webhook.Client.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// respond answers every request with status.
+func respond(status int) roundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(strings.NewReader(http.StatusText(status))),
+			Header:     http.Header{},
+			Request:    r,
+		}, nil
+	}
+}
+
+// claimDelivery sets up a webhook with one delivery due, and claims it the
+// way Deliver does.
+func claimDelivery(t *testing.T, repo *repository.InMemoryRepository, webhookService *WebhookSvc) models.GormWebhookDelivery {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := webhookService.CreateWebhook(ctx, models.GormWebhook{
+		URL:    "https://hooks.example.com/blog",
+		Events: models.EventTypes{WebhookCommentCreated},
+		Active: true,
+	}); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	if err := webhookService.Enqueue(ctx, WebhookCommentCreated, map[string]string{"content": "Hi"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := webhookService.Dispatch(ctx); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	now := time.Now()
+	deliveries, err := repo.ClaimWebhookDeliveries(ctx, now, now.Add(webhookLease), WebhookBatchSize)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("ClaimWebhookDeliveries = %v, %v, want one delivery", deliveries, err)
+	}
+	return deliveries[0]
+}
+
+func TestWebhookAttempt(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delivered", func(t *testing.T) {
+		repo := repository.NewInMemoryRepository()
+		var received http.Header
+		client := webhook.NewClient(time.Second)
+		client.HTTP.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			received = r.Header
+			return respond(http.StatusNoContent)(r)
+		})
+		webhookService := NewWebhookService(repo, client).(*WebhookSvc)
+		delivery := claimDelivery(t, repo, webhookService)
+
+		webhookService.attempt(ctx, delivery)
+
+		stored, err := repo.GetWebhookDelivery(ctx, delivery.ID)
+		if err != nil {
+			t.Fatalf("GetWebhookDelivery: %v", err)
+		}
+		if stored.Status != models.DeliveryDelivered || stored.Attempts != 1 || stored.ResponseStatus != http.StatusNoContent || stored.Error != "" {
+			t.Errorf("delivery = %+v, want delivered on the first attempt", stored)
+		}
+		if received.Get(webhook.SignatureHeader) == "" || received.Get(webhook.DeliveryHeader) != "1" {
+			t.Errorf("request headers = %v, want it signed with the delivery ID", received)
+		}
+	})
+
+	t.Run("backs off", func(t *testing.T) {
+		repo := repository.NewInMemoryRepository()
+		client := webhook.NewClient(time.Second)
+		client.HTTP.Transport = respond(http.StatusInternalServerError)
+		webhookService := NewWebhookService(repo, client).(*WebhookSvc)
+		delivery := claimDelivery(t, repo, webhookService)
+
+		for attempts := 1; attempts < WebhookAttempts; attempts++ {
+			before := time.Now()
+			webhookService.attempt(ctx, delivery)
+			after := time.Now()
+
+			stored, err := repo.GetWebhookDelivery(ctx, delivery.ID)
+			if err != nil {
+				t.Fatalf("GetWebhookDelivery: %v", err)
+			}
+			if stored.Status != models.DeliveryPending || stored.Attempts != attempts {
+				t.Fatalf("after attempt %d delivery = %+v, want it pending", attempts, stored)
+			}
+			if stored.ResponseStatus != http.StatusInternalServerError || !strings.Contains(stored.Error, "500") {
+				t.Errorf("after attempt %d status %d, error %q, want the 500 recorded", attempts, stored.ResponseStatus, stored.Error)
+			}
+			delay := WebhookRetryDelay << (attempts - 1)
+			if stored.NextAttemptAt.Before(before.Add(delay)) || stored.NextAttemptAt.After(after.Add(delay)) {
+				t.Errorf("after attempt %d next attempt in %s, want %s", attempts, stored.NextAttemptAt.Sub(before), delay)
+			}
+			delivery.Attempts = stored.Attempts
+		}
+
+		// the last attempt fails for good
+		webhookService.attempt(ctx, delivery)
+		stored, err := repo.GetWebhookDelivery(ctx, delivery.ID)
+		if err != nil {
+			t.Fatalf("GetWebhookDelivery: %v", err)
+		}
+		if stored.Status != models.DeliveryDead || stored.Attempts != WebhookAttempts || stored.Error == "" {
+			t.Errorf("delivery = %+v, want it dead after %d attempts", stored, WebhookAttempts)
+		}
+		if claimed, err := repo.ClaimWebhookDeliveries(ctx, time.Now().Add(24*time.Hour), time.Now(), WebhookBatchSize); err != nil || len(claimed) != 0 {
+			t.Errorf("ClaimWebhookDeliveries = %v, %v, want a dead delivery left alone", claimed, err)
+		}
+	})
+
+	t.Run("no response", func(t *testing.T) {
+		repo := repository.NewInMemoryRepository()
+		client := webhook.NewClient(time.Second)
+		client.HTTP.Transport = roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return nil, io.ErrUnexpectedEOF
+		})
+		webhookService := NewWebhookService(repo, client).(*WebhookSvc)
+		delivery := claimDelivery(t, repo, webhookService)
+
+		webhookService.attempt(ctx, delivery)
+
+		stored, err := repo.GetWebhookDelivery(ctx, delivery.ID)
+		if err != nil {
+			t.Fatalf("GetWebhookDelivery: %v", err)
+		}
+		if stored.Status != models.DeliveryPending || stored.ResponseStatus != 0 || stored.Error == "" {
+			t.Errorf("delivery = %+v, want it pending with the error and no status", stored)
+		}
+	})
+}
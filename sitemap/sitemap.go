@@ -0,0 +1,129 @@
+// Package sitemap writes sitemaps and sitemap indexes following the
+// sitemaps.org protocol. URL sets are written as they are added, so a
+// sitemap of any length streams straight from the rows it is read from.
+package sitemap
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// ContentType is the media type of sitemaps and sitemap indexes.
+const ContentType = "application/xml; charset=utf-8"
+
+// MaxURLs is the most URLs the protocol allows in one sitemap, and the
+// most sitemaps in one index.
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is one page in a sitemap: where it is and when it last changed.
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+// Sitemap is one sitemap listed in an index.
+type Sitemap struct {
+	Loc     string
+	LastMod time.Time
+}
+
+// entry is how both a URL and a Sitemap are written.
+type entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// lastMod writes a time in the W3C datetime format the protocol asks for;
+// an unknown time is left out.
+func lastMod(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// URLSet writes a urlset one URL at a time. Nothing is written before the
+// first Add or Close, so a caller can still answer with an error up to
+// then.
+type URLSet struct {
+	buf     *bufio.Writer
+	encoder *xml.Encoder
+	started bool
+	count   int
+}
+
+func NewURLSet(w io.Writer) *URLSet {
+	buf := bufio.NewWriter(w)
+	return &URLSet{buf: buf, encoder: xml.NewEncoder(buf)}
+}
+
+// start writes the declaration and the opening urlset tag.
+func (set *URLSet) start() error {
+	if set.started {
+		return nil
+	}
+	set.started = true
+
+	if _, err := set.buf.WriteString(xml.Header); err != nil {
+		return err
+	}
+	return set.encoder.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "urlset"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: namespace}},
+	})
+}
+
+// Add writes one URL.
+func (set *URLSet) Add(url URL) error {
+	if err := set.start(); err != nil {
+		return err
+	}
+	set.count++
+	return set.encoder.EncodeElement(entry{Loc: url.Loc, LastMod: lastMod(url.LastMod)}, xml.StartElement{Name: xml.Name{Local: "url"}})
+}
+
+// Count is how many URLs were added so far.
+func (set *URLSet) Count() int {
+	return set.count
+}
+
+// Close ends the urlset, which may be empty, and flushes it.
+func (set *URLSet) Close() error {
+	if err := set.start(); err != nil {
+		return err
+	}
+	if err := set.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "urlset"}}); err != nil {
+		return err
+	}
+	if err := set.encoder.Flush(); err != nil {
+		return err
+	}
+	if _, err := set.buf.WriteString("\n"); err != nil {
+		return err
+	}
+	return set.buf.Flush()
+}
+
+// WriteIndex writes a sitemap index listing sitemaps.
+func WriteIndex(w io.Writer, sitemaps []Sitemap) error {
+	index := struct {
+		XMLName  xml.Name `xml:"sitemapindex"`
+		XMLNS    string   `xml:"xmlns,attr"`
+		Sitemaps []entry  `xml:"sitemap"`
+	}{XMLNS: namespace}
+	for _, sitemap := range sitemaps {
+		index.Sitemaps = append(index.Sitemaps, entry{Loc: sitemap.Loc, LastMod: lastMod(sitemap.LastMod)})
+	}
+
+	buf := bufio.NewWriter(w)
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(buf).Encode(index); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+	return buf.Flush()
+}
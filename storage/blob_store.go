@@ -0,0 +1,51 @@
+// Package storage keeps uploaded files such as post thumbnails outside the
+// database, behind a BlobStore that can live on local disk or in an
+// S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var ErrBlobNotFound = errors.New("blob does not exist")
+
+// Blob is an open stored file. It is seekable so it can be served with
+// http.ServeContent, which handles ranges and conditional requests.
+type Blob struct {
+	io.ReadSeekCloser
+	Key         string
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// BlobStore saves, opens and removes files by key. Keys are slash separated
+// paths such as "thumbnails/12/9f86d081.png".
+type BlobStore interface {
+	Put(ctx context.Context, key string, contentType string, content io.Reader) error
+	Get(ctx context.Context, key string) (*Blob, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStoreFromEnv picks the store named by BLOB_STORE: "local" (the
+// default) writes under BLOB_DIR, and "s3" talks to the bucket described by
+// the S3_* variables.
+func NewBlobStoreFromEnv() (BlobStore, error) {
+	switch backend := os.Getenv("BLOB_STORE"); backend {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocalBlobStore(dir)
+	case "s3":
+		return NewS3BlobStore(S3ConfigFromEnv())
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", backend)
+	}
+}
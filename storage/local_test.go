@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store, err := NewLocalBlobStore(root)
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore: %v", err)
+	}
+
+	const key = "thumbnails/7/9f86d081.png"
+	if err := store.Put(ctx, key, "image/png", strings.NewReader("first")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(ctx, key, "image/png", strings.NewReader("second")); err != nil {
+		t.Fatalf("Put over a blob: %v", err)
+	}
+
+	blob, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil || string(data) != "second" {
+		t.Errorf("Get read %q, %v, want the second write", data, err)
+	}
+	if blob.Key != key || blob.ContentType != "image/png" || blob.Size != int64(len("second")) || blob.ModTime.IsZero() {
+		t.Errorf("Get = %+v", blob)
+	}
+
+	// the temporary files of the writes are gone
+	entries, err := os.ReadDir(filepath.Join(root, "thumbnails", "7"))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory holds %v, %v, want the blob alone", entries, err)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Get after Delete err = %v, want ErrBlobNotFound", err)
+	}
+	if err := store.Delete(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("second Delete err = %v, want ErrBlobNotFound", err)
+	}
+}
+
+func TestLocalBlobStoreKeysStayUnderRoot(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	root := filepath.Join(parent, "blobs")
+	store, err := NewLocalBlobStore(root)
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore: %v", err)
+	}
+
+	if err := store.Put(ctx, "../../escaped.txt", "text/plain", strings.NewReader("x")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escaped.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a key climbed out of the root: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped.txt")); err != nil {
+		t.Errorf("the blob is not under the root: %v", err)
+	}
+
+	for _, key := range []string{"", "/", "..", `thumbnails\7\x.png`} {
+		if err := store.Put(ctx, key, "text/plain", strings.NewReader("x")); !errors.Is(err, ErrBlobNotFound) {
+			t.Errorf("Put %q err = %v, want ErrBlobNotFound", key, err)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+			t.Errorf("Get %q err = %v, want ErrBlobNotFound", key, err)
+		}
+	}
+}
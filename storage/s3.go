@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config describes a bucket on any S3-compatible service, AWS or a local
+// MinIO alike.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// S3ConfigFromEnv reads S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY,
+// S3_SECRET_KEY and S3_USE_SSL. TLS is on unless S3_USE_SSL is "false".
+func S3ConfigFromEnv() S3Config {
+	return S3Config{
+		Endpoint:  os.Getenv("S3_ENDPOINT"),
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    os.Getenv("S3_BUCKET"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+		UseSSL:    os.Getenv("S3_USE_SSL") != "false",
+	}
+}
+
+type S3BlobStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3BlobStore(config S3Config) (BlobStore, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3BlobStore{client: client, bucket: config.Bucket}, nil
+}
+
+// notFound maps the service's missing-object answer onto ErrBlobNotFound.
+func notFound(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+func (store *S3BlobStore) Put(ctx context.Context, key string, contentType string, content io.Reader) error {
+	_, err := store.client.PutObject(ctx, store.bucket, key, content, -1, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (store *S3BlobStore) Get(ctx context.Context, key string) (*Blob, error) {
+	object, err := store.client.GetObject(ctx, store.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	// GetObject is lazy; Stat is the first call that reaches the service
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, notFound(err)
+	}
+
+	return &Blob{
+		ReadSeekCloser: object,
+		Key:            key,
+		ContentType:    info.ContentType,
+		Size:           info.Size,
+		ModTime:        info.LastModified,
+	}, nil
+}
+
+// Delete removes the object. S3 answers a delete of a missing key with
+// success, so unlike the local store it never returns ErrBlobNotFound.
+func (store *S3BlobStore) Delete(ctx context.Context, key string) error {
+	return store.client.RemoveObject(ctx, store.bucket, key, minio.RemoveObjectOptions{})
+}
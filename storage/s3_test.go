@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestNotFound(t *testing.T) {
+	if err := notFound(minio.ErrorResponse{Code: "NoSuchKey"}); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("notFound(NoSuchKey) = %v, want ErrBlobNotFound", err)
+	}
+
+	denied := minio.ErrorResponse{Code: "AccessDenied"}
+	if err := notFound(denied); errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("notFound(AccessDenied) = %v, want it passed through", err)
+	}
+}
@@ -0,0 +1,118 @@
+package syndication
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+// generator names the software in both formats.
+const generator = "go-postgresql-blog-http"
+
+// AtomNamespace is the namespace of every Atom element.
+const AtomNamespace = "http://www.w3.org/2005/Atom"
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string      `xml:"id"`
+	Title     atomText    `xml:"title"`
+	Subtitle  *atomText   `xml:"subtitle,omitempty"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Author     atomPerson     `xml:"author"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// atomDate is the RFC 3339 date Atom asks for.
+func atomDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Atom encodes feed as an Atom feed document. Every entry carries its
+// author, so the feed itself does not need one.
+func Atom(feed Feed) ([]byte, error) {
+	document := atomFeed{
+		ID:      feed.ID,
+		Title:   atomText{Type: "text", Value: feed.Title},
+		Updated: atomDate(feed.Updated),
+		Links: []atomLink{
+			{Href: feed.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: feed.Link, Rel: "alternate", Type: "text/html"},
+		},
+		Generator: generator,
+		Entries:   make([]atomEntry, 0, len(feed.Items)),
+	}
+	if feed.Description != "" {
+		document.Subtitle = &atomText{Type: "text", Value: feed.Description}
+	}
+
+	for _, item := range feed.Items {
+		entry := atomEntry{
+			ID:        item.ID,
+			Title:     atomText{Type: "text", Value: item.Title},
+			Updated:   atomDate(item.Updated),
+			Published: atomDate(item.Published),
+			Author:    atomPerson{Name: item.Author.Name, URI: item.Author.URI},
+			Links:     []atomLink{{Href: item.Link, Rel: "alternate", Type: "text/html"}},
+		}
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Value: item.Summary}
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: item.Content}
+		}
+		document.Entries = append(document.Entries, entry)
+	}
+
+	return encode(document)
+}
+
+// encode writes document as an indented XML document with its declaration.
+func encode(document interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+// Package syndication writes feeds of posts as RSS 2.0 and Atom (RFC 4287).
+// Feeds are built once as a Feed and then encoded in either format.
+package syndication
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Media types of the encoded feeds.
+const (
+	RSSType  = "application/rss+xml; charset=utf-8"
+	AtomType = "application/atom+xml; charset=utf-8"
+)
+
+// Feed is a feed independent of the format it is written in.
+type Feed struct {
+	// ID identifies the feed for good; Atom needs it, RSS has no use for
+	// it.
+	ID          string
+	Title       string
+	Description string
+	// Link is the page the feed is the feed of, Self the URL the feed is
+	// read from.
+	Link string
+	Self string
+	// Updated is when any item last changed.
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is one post in a feed.
+type Item struct {
+	// ID stays the same for as long as the post exists, whatever else
+	// about it changes, so readers never show a post twice.
+	ID        string
+	Title     string
+	Link      string
+	Author    Author
+	Published time.Time
+	Updated   time.Time
+	// Summary is plain text. Content is HTML and left empty for feeds of
+	// excerpts only.
+	Summary    string
+	Content    string
+	Categories []string
+}
+
+// Author is who wrote an item, with an optional link to their page.
+type Author struct {
+	Name string
+	URI  string
+}
+
+// TagURI makes a tag URI (RFC 4151) for an item or feed: an id that no
+// other site mints, that is stable as long as the site keeps its domain,
+// and that unlike a URL does not change when pages move. date should be
+// when the thing named came to be, so it never changes.
+func TagURI(siteURL string, date time.Time, specific string) (string, error) {
+	parsed, err := url.Parse(siteURL)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("site URL %q has no host", siteURL)
+	}
+
+	// a tagging authority is a bare domain name, without a port
+	host := parsed.Host
+	if withoutPort, _, err := net.SplitHostPort(host); err == nil {
+		host = withoutPort
+	}
+	return fmt.Sprintf("tag:%s,%s:%s", host, date.UTC().Format(time.DateOnly), specific), nil
+}
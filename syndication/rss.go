@@ -0,0 +1,92 @@
+package syndication
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// The RSS 2.0 document, with the namespaces for the extensions used: atom
+// for the self link, dc for author names (RSS's own author element holds
+// an email address) and content for full HTML.
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	DCNS      string     `xml:"xmlns:dc,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          rssLink   `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Generator     string    `xml:"generator"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+	Content     string   `xml:"content:encoded,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rssDate is the RFC 822 date RSS 2.0 asks for, with a four digit year.
+func rssDate(t time.Time) string {
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+// RSS encodes feed as an RSS 2.0 document. The item ids go out as guids
+// that are not permalinks, so readers do not mistake them for URLs.
+func RSS(feed Feed) ([]byte, error) {
+	channel := rssChannel{
+		Title:       feed.Title,
+		Link:        feed.Link,
+		Description: feed.Description,
+		Self:        rssLink{Href: feed.Self, Rel: "self", Type: "application/rss+xml"},
+		Generator:   generator,
+		Items:       make([]rssItem, 0, len(feed.Items)),
+	}
+	if !feed.Updated.IsZero() {
+		channel.LastBuildDate = rssDate(feed.Updated)
+	}
+
+	for _, item := range feed.Items {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{IsPermaLink: false, Value: item.ID},
+			PubDate:     rssDate(item.Published),
+			Creator:     item.Author.Name,
+			Categories:  item.Categories,
+			Description: item.Summary,
+			Content:     item.Content,
+		})
+	}
+
+	return encode(rssDocument{
+		Version:   "2.0",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		Channel:   channel,
+	})
+}
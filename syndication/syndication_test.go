@@ -0,0 +1,111 @@
+package syndication_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bellaananda/go-postgresql-blog-http.git/syndication"
+	"github.com/bellaananda/go-postgresql-blog-http.git/syndication/syndicationtest"
+)
+
+// TestEncoders runs syndication.RSS and syndication.Atom over feeds that
+// exercise the corners of both formats and checks the output against the
+// specifications.
+func TestEncoders(t *testing.T) {
+	published := time.Date(2024, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	updated := published.Add(2 * time.Hour)
+
+	item := func(id string) syndication.Item {
+		return syndication.Item{
+			ID:         id,
+			Title:      "Ampersands & <angle brackets>",
+			Link:       "https://blog.example.com/posts/1",
+			Author:     syndication.Author{Name: "Ann", URI: "https://blog.example.com/authors/1"},
+			Published:  published,
+			Updated:    updated,
+			Summary:    "An excerpt with \"quotes\" & more",
+			Categories: []string{"go", "feeds"},
+		}
+	}
+	full := item("tag:blog.example.com,2024-03-04:post:1")
+	full.Content = "<p>Some <em>HTML</em> with a ]]> in it</p>"
+
+	feeds := map[string]syndication.Feed{
+		"Empty": {
+			ID:          "tag:blog.example.com,2024-01-01:feed",
+			Title:       "Empty",
+			Description: "Nothing yet",
+			Link:        "https://blog.example.com/",
+			Self:        "https://blog.example.com/feed.atom",
+			Updated:     time.Unix(0, 0),
+		},
+		"Excerpts": {
+			ID:          "tag:blog.example.com,2024-01-01:feed",
+			Title:       "Excerpts",
+			Description: "Posts",
+			Link:        "https://blog.example.com/",
+			Self:        "https://blog.example.com/feed.atom",
+			Updated:     updated,
+			Items:       []syndication.Item{item("tag:blog.example.com,2024-03-04:post:1"), item("tag:blog.example.com,2024-03-04:post:2")},
+		},
+		"FullContent": {
+			ID:          "tag:blog.example.com,2024-01-01:feed",
+			Title:       "Full",
+			Description: "Posts",
+			Link:        "https://blog.example.com/",
+			Self:        "https://blog.example.com/feed.atom",
+			Updated:     updated,
+			Items:       []syndication.Item{full},
+		},
+	}
+
+	for name, feed := range feeds {
+		feed := feed
+		t.Run(name, func(t *testing.T) {
+			rss, err := syndication.RSS(feed)
+			if err != nil {
+				t.Fatalf("RSS: %v", err)
+			}
+			syndicationtest.CheckRSS(t, rss)
+
+			atom, err := syndication.Atom(feed)
+			if err != nil {
+				t.Fatalf("Atom: %v", err)
+			}
+			syndicationtest.CheckAtom(t, atom)
+
+			for _, item := range feed.Items {
+				if !bytes.Contains(rss, []byte(item.ID)) || !bytes.Contains(atom, []byte(item.ID)) {
+					t.Errorf("item id %q missing from the output", item.ID)
+				}
+			}
+		})
+	}
+
+	t.Run("Dates", func(t *testing.T) {
+		feed := feeds["Excerpts"]
+		rss, _ := syndication.RSS(feed)
+		if want := "<pubDate>Mon, 04 Mar 2024 04:06:07 +0000</pubDate>"; !bytes.Contains(rss, []byte(want)) {
+			t.Errorf("RSS does not carry the publication time in UTC as %s", want)
+		}
+		atom, _ := syndication.Atom(feed)
+		if want := "<published>2024-03-04T04:06:07Z</published>"; !bytes.Contains(atom, []byte(want)) {
+			t.Errorf("Atom does not carry the publication time in UTC as %s", want)
+		}
+	})
+
+	t.Run("TagURI", func(t *testing.T) {
+		id, err := syndication.TagURI("https://blog.example.com:8443/base/", published, "post:7")
+		if err != nil {
+			t.Fatalf("TagURI: %v", err)
+		}
+		if want := "tag:blog.example.com,2024-03-04:post:7"; id != want {
+			t.Errorf("TagURI = %q, want %q", id, want)
+		}
+		if _, err := syndication.TagURI("/relative", published, "post:7"); err == nil || !strings.Contains(err.Error(), "no host") {
+			t.Errorf("TagURI of a relative URL err = %v, want an error", err)
+		}
+	})
+}
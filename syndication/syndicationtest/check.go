@@ -0,0 +1,326 @@
+// Package syndicationtest checks encoded feeds against what the RSS 2.0
+// specification and RFC 4287 (Atom) require of them. The checkers take the
+// bytes as served, so they work as well on a handler's response as on the
+// encoders directly:
+//
+//	body, _ := syndication.Atom(feed)
+//	syndicationtest.CheckAtom(t, body)
+package syndicationtest
+
+import (
+	"encoding/xml"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type rssDocument struct {
+	XMLName  xml.Name     `xml:"rss"`
+	Version  string       `xml:"version,attr"`
+	Channels []rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Description   string    `xml:"description"`
+	Links         []anyLink `xml:"link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+// anyLink is an RSS link, which is text, or an atom:link, which is
+// attributes; XMLName tells them apart.
+type anyLink struct {
+	XMLName xml.Name
+	Href    string `xml:"href,attr"`
+	Rel     string `xml:"rel,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string    `xml:"title"`
+	Description string    `xml:"description"`
+	Links       []anyLink `xml:"link"`
+	GUIDs       []rssGUID `xml:"guid"`
+	PubDate     string    `xml:"pubDate"`
+	Creators    []string  `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Encoded     []string  `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// CheckRSS reports where body breaks the RSS 2.0 specification, or the
+// conventions of the extensions it uses, as test errors.
+func CheckRSS(t testing.TB, body []byte) {
+	t.Helper()
+
+	var document rssDocument
+	if err := xml.Unmarshal(body, &document); err != nil {
+		t.Errorf("RSS is not well-formed XML: %v", err)
+		return
+	}
+	if document.Version != "2.0" {
+		t.Errorf("rss version = %q, want 2.0", document.Version)
+	}
+	if len(document.Channels) != 1 {
+		t.Errorf("rss has %d channels, want exactly 1", len(document.Channels))
+		return
+	}
+
+	channel := document.Channels[0]
+	if channel.Title == "" {
+		t.Error("channel has no title")
+	}
+	if channel.Description == "" {
+		t.Error("channel has no description")
+	}
+
+	var link string
+	selfLinks := 0
+	for _, each := range channel.Links {
+		switch each.XMLName.Space {
+		case atomNamespace:
+			if each.Rel == "self" {
+				selfLinks++
+				checkURL(t, "channel atom:link self", each.Href)
+			}
+		case "":
+			link = each.Value
+		}
+	}
+	checkURL(t, "channel link", link)
+	if selfLinks != 1 {
+		t.Errorf("channel has %d atom:link rel=self, want 1", selfLinks)
+	}
+	if channel.LastBuildDate != "" {
+		checkRFC822(t, "channel lastBuildDate", channel.LastBuildDate)
+	}
+
+	guids := map[string]bool{}
+	for i, item := range channel.Items {
+		if item.Title == "" && item.Description == "" {
+			t.Errorf("item %d has neither a title nor a description", i)
+		}
+		for _, each := range item.Links {
+			if each.XMLName.Space == "" {
+				checkURL(t, "item link", each.Value)
+			}
+		}
+
+		if len(item.GUIDs) != 1 || item.GUIDs[0].Value == "" {
+			t.Errorf("item %d has %d guids, want one that is not empty", i, len(item.GUIDs))
+		} else {
+			guid := item.GUIDs[0]
+			if guids[guid.Value] {
+				t.Errorf("item %d repeats guid %q", i, guid.Value)
+			}
+			guids[guid.Value] = true
+			// a guid is a permalink unless it says otherwise
+			if guid.IsPermaLink != "false" {
+				checkURL(t, "permalink guid", guid.Value)
+			}
+		}
+
+		checkRFC822(t, "item pubDate", item.PubDate)
+		if len(item.Creators) > 1 {
+			t.Errorf("item %d has %d dc:creator elements, want at most 1", i, len(item.Creators))
+		}
+		if len(item.Encoded) > 1 {
+			t.Errorf("item %d has %d content:encoded elements, want at most 1", i, len(item.Encoded))
+		}
+	}
+}
+
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	IDs     []string     `xml:"http://www.w3.org/2005/Atom id"`
+	Titles  []atomText   `xml:"http://www.w3.org/2005/Atom title"`
+	Updated []string     `xml:"http://www.w3.org/2005/Atom updated"`
+	Authors []atomPerson `xml:"http://www.w3.org/2005/Atom author"`
+	Links   []atomLink   `xml:"http://www.w3.org/2005/Atom link"`
+	Entries []atomEntry  `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type atomEntry struct {
+	IDs       []string     `xml:"http://www.w3.org/2005/Atom id"`
+	Titles    []atomText   `xml:"http://www.w3.org/2005/Atom title"`
+	Updated   []string     `xml:"http://www.w3.org/2005/Atom updated"`
+	Published []string     `xml:"http://www.w3.org/2005/Atom published"`
+	Authors   []atomPerson `xml:"http://www.w3.org/2005/Atom author"`
+	Links     []atomLink   `xml:"http://www.w3.org/2005/Atom link"`
+	Summaries []atomText   `xml:"http://www.w3.org/2005/Atom summary"`
+	Contents  []atomText   `xml:"http://www.w3.org/2005/Atom content"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Src   string `xml:"src,attr"`
+	Value string `xml:",innerxml"`
+}
+
+type atomPerson struct {
+	Names []string `xml:"http://www.w3.org/2005/Atom name"`
+	URI   string   `xml:"http://www.w3.org/2005/Atom uri"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// CheckAtom reports where body breaks RFC 4287 as test errors.
+func CheckAtom(t testing.TB, body []byte) {
+	t.Helper()
+
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		t.Errorf("Atom is not a well-formed feed document: %v", err)
+		return
+	}
+
+	checkOne(t, "feed id", feed.IDs, func(id string) { checkURL(t, "feed id", id) })
+	checkText(t, "feed title", feed.Titles)
+	checkOne(t, "feed updated", feed.Updated, func(updated string) { checkRFC3339(t, "feed updated", updated) })
+	checkPeople(t, "feed", feed.Authors)
+	checkLinks(t, "feed", feed.Links)
+	if !hasRel(feed.Links, "self") {
+		t.Error("feed has no link rel=self")
+	}
+
+	ids := map[string]bool{}
+	for i, entry := range feed.Entries {
+		checkOne(t, "entry id", entry.IDs, func(id string) {
+			checkURL(t, "entry id", id)
+			if ids[id] {
+				t.Errorf("entry %d repeats id %q", i, id)
+			}
+			ids[id] = true
+		})
+		checkText(t, "entry title", entry.Titles)
+		checkOne(t, "entry updated", entry.Updated, func(updated string) { checkRFC3339(t, "entry updated", updated) })
+		if len(entry.Published) > 1 {
+			t.Errorf("entry %d has %d published elements, want at most 1", i, len(entry.Published))
+		}
+		for _, published := range entry.Published {
+			checkRFC3339(t, "entry published", published)
+		}
+
+		// the feed's authors stand in for an entry's missing ones
+		if len(feed.Authors) == 0 && len(entry.Authors) == 0 {
+			t.Errorf("entry %d has no author, and neither does the feed", i)
+		}
+		checkPeople(t, "entry", entry.Authors)
+		checkLinks(t, "entry", entry.Links)
+
+		if len(entry.Contents) > 1 || len(entry.Summaries) > 1 {
+			t.Errorf("entry %d has %d contents and %d summaries, want at most 1 of each", i, len(entry.Contents), len(entry.Summaries))
+		}
+		if len(entry.Contents) == 0 && !hasRel(entry.Links, "alternate") {
+			t.Errorf("entry %d has neither content nor a link rel=alternate", i)
+		}
+		for _, content := range entry.Contents {
+			if content.Src != "" && len(entry.Summaries) == 0 {
+				t.Errorf("entry %d has out-of-line content but no summary", i)
+			}
+		}
+	}
+}
+
+// checkOne requires exactly one value and checks it.
+func checkOne(t testing.TB, what string, values []string, check func(string)) {
+	t.Helper()
+	if len(values) != 1 {
+		t.Errorf("%s appears %d times, want exactly once", what, len(values))
+		return
+	}
+	check(values[0])
+}
+
+// checkText requires exactly one text construct of a type Atom defines.
+func checkText(t testing.TB, what string, texts []atomText) {
+	t.Helper()
+	if len(texts) != 1 {
+		t.Errorf("%s appears %d times, want exactly once", what, len(texts))
+		return
+	}
+	switch texts[0].Type {
+	case "", "text", "html", "xhtml":
+	default:
+		t.Errorf("%s has type %q, want text, html or xhtml", what, texts[0].Type)
+	}
+}
+
+func checkPeople(t testing.TB, what string, people []atomPerson) {
+	t.Helper()
+	for _, person := range people {
+		if len(person.Names) != 1 || person.Names[0] == "" {
+			t.Errorf("%s author has %d names, want one that is not empty", what, len(person.Names))
+		}
+		if person.URI != "" {
+			checkURL(t, what+" author uri", person.URI)
+		}
+	}
+}
+
+// checkLinks requires an href on every link and at most one alternate link
+// per media type.
+func checkLinks(t testing.TB, what string, links []atomLink) {
+	t.Helper()
+	alternates := map[string]bool{}
+	for _, link := range links {
+		if link.Href == "" {
+			t.Errorf("%s has a link without href", what)
+		}
+		if link.Rel == "" || link.Rel == "alternate" {
+			if alternates[link.Type] {
+				t.Errorf("%s has more than one link rel=alternate of type %q", what, link.Type)
+			}
+			alternates[link.Type] = true
+		}
+	}
+}
+
+// hasRel reports whether a link with rel is among links; a link without
+// rel is an alternate one.
+func hasRel(links []atomLink, rel string) bool {
+	for _, link := range links {
+		if link.Rel == rel || link.Rel == "" && rel == "alternate" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkURL requires an absolute URI.
+func checkURL(t testing.TB, what string, value string) {
+	t.Helper()
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" {
+		t.Errorf("%s %q is not an absolute URI", what, value)
+	}
+}
+
+// checkRFC822 requires the date format of RSS 2.0, which allows a named or
+// numeric zone.
+func checkRFC822(t testing.TB, what string, value string) {
+	t.Helper()
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123} {
+		if _, err := time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	t.Errorf("%s %q is not an RFC 822 date", what, value)
+}
+
+func checkRFC3339(t testing.TB, what string, value string) {
+	t.Helper()
+	if _, err := time.Parse(time.RFC3339, value); err != nil {
+		t.Errorf("%s %q is not an RFC 3339 date", what, value)
+	}
+}
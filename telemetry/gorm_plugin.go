@@ -0,0 +1,114 @@
+package telemetry
+
+import (
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	gormSpanKey        = "telemetry:span"
+	gormCallbackBefore = "telemetry:before"
+	gormCallbackAfter  = "telemetry:after"
+)
+
+// GormPlugin emits a client span for every statement GORM runs. The span is
+// a child of whatever span is on the context passed to db.WithContext, and
+// carries the rendered SQL in db.statement.
+type GormPlugin struct {
+	tracer trace.Tracer
+}
+
+func NewGormPlugin() *GormPlugin {
+	return &GormPlugin{
+		tracer: otel.Tracer(ServiceName + "/gorm"),
+	}
+}
+
+func (p *GormPlugin) Name() string {
+	return "telemetry"
+}
+
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registrations := []func() error{
+		func() error {
+			return cb.Create().Before("gorm:create").Register(gormCallbackBefore, p.before("create"))
+		},
+		func() error { return cb.Create().After("gorm:create").Register(gormCallbackAfter, p.after) },
+		func() error { return cb.Query().Before("gorm:query").Register(gormCallbackBefore, p.before("select")) },
+		func() error { return cb.Query().After("gorm:query").Register(gormCallbackAfter, p.after) },
+		func() error {
+			return cb.Update().Before("gorm:update").Register(gormCallbackBefore, p.before("update"))
+		},
+		func() error { return cb.Update().After("gorm:update").Register(gormCallbackAfter, p.after) },
+		func() error {
+			return cb.Delete().Before("gorm:delete").Register(gormCallbackBefore, p.before("delete"))
+		},
+		func() error { return cb.Delete().After("gorm:delete").Register(gormCallbackAfter, p.after) },
+		func() error { return cb.Row().Before("gorm:row").Register(gormCallbackBefore, p.before("row")) },
+		func() error { return cb.Row().After("gorm:row").Register(gormCallbackAfter, p.after) },
+		func() error { return cb.Raw().Before("gorm:raw").Register(gormCallbackBefore, p.before("raw")) },
+		func() error { return cb.Raw().After("gorm:raw").Register(gormCallbackAfter, p.after) },
+	}
+
+	for _, register := range registrations {
+		if err := register(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+
+		name := "gorm." + operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+
+		ctx, span := p.tracer.Start(db.Statement.Context, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperation(strings.ToUpper(operation)),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func (p *GormPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	if db.Statement.Table != "" {
+		span.SetAttributes(semconv.DBSQLTable(db.Statement.Table))
+	}
+	if sql := db.Statement.SQL.String(); sql != "" {
+		span.SetAttributes(semconv.DBStatement(sql))
+	}
+
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,24 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Middleware wraps every request in a server span. Incoming traceparent
+// headers are extracted so the span joins the caller's trace, and the span
+// is named after the matched mux route template rather than the raw path.
+func Middleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.server",
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			if route := mux.CurrentRoute(r); route != nil {
+				if tpl, err := route.GetPathTemplate(); err == nil {
+					return r.Method + " " + tpl
+				}
+			}
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+}
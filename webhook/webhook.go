@@ -0,0 +1,152 @@
+// Package webhook signs and sends webhook requests, and checks the
+// signature of the ones a receiver gets.
+//
+// Every request is a POST of a JSON body carrying these headers:
+//
+//	X-Webhook-Event: comment.created
+//	X-Webhook-Delivery: 42
+//	X-Webhook-Timestamp: 1700000000
+//	X-Webhook-Signature: sha256=<hex HMAC-SHA256 of "1700000000." + body>
+//
+// The HMAC is keyed with the webhook's secret. Signing the timestamp along
+// with the body lets receivers turn away requests replayed later.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+var (
+	ErrInvalidURL       = errors.New("webhook URL must be an absolute http or https URL")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Sign returns the signature header of body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature in header against body, and that the request
+// was signed no longer than tolerance before or after now.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	seconds, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp", ErrInvalidSignature)
+	}
+	timestamp := time.Unix(seconds, 0)
+	if timestamp.Before(now.Add(-tolerance)) || timestamp.After(now.Add(tolerance)) {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ValidURL accepts the absolute http and https URLs webhooks can be sent to.
+func ValidURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// Request is one signed POST to a webhook.
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID string
+	Body       []byte
+}
+
+// StatusError is what Send returns for a response outside 2xx. Body holds
+// the start of the response, to tell what went wrong.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (err *StatusError) Error() string {
+	if err.Body == "" {
+		return fmt.Sprintf("webhook responded %d", err.StatusCode)
+	}
+	return fmt.Sprintf("webhook responded %d: %s", err.StatusCode, err.Body)
+}
+
+// maxErrorBody is how much of a failed response StatusError keeps.
+const maxErrorBody = 512
+
+// Client sends webhook requests. Redirects are not followed: a webhook
+// that moved is a failed delivery until its URL is updated.
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string
+}
+
+func NewClient(timeout time.Duration) *Client {
+	return &Client{
+		HTTP: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		UserAgent: "blog-webhooks/1.0",
+	}
+}
+
+// Send signs and posts request, and returns the status it was answered
+// with, or 0 when no response came back.
+func (client *Client) Send(ctx context.Context, request Request) (int, error) {
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, request.URL, bytes.NewReader(request.Body))
+	if err != nil {
+		return 0, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("User-Agent", client.UserAgent)
+	httpRequest.Header.Set(EventHeader, request.Event)
+	httpRequest.Header.Set(DeliveryHeader, request.DeliveryID)
+	now := time.Now()
+	httpRequest.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	httpRequest.Header.Set(SignatureHeader, Sign(request.Secret, now, request.Body))
+
+	response, err := client.HTTP.Do(httpRequest)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+		return response.StatusCode, &StatusError{StatusCode: response.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	// drain what is left so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	return response.StatusCode, nil
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// signedHeader is what a request signed at timestamp carries.
+func signedHeader(secret string, timestamp time.Time, body []byte) http.Header {
+	header := http.Header{}
+	header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(SignatureHeader, Sign(secret, timestamp, body))
+	return header
+}
+
+func TestVerify(t *testing.T) {
+	const secret = "s3cret"
+	const tolerance = 5 * time.Minute
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1,"type":"comment.created"}`)
+
+	for _, tt := range []struct {
+		name    string
+		header  http.Header
+		body    []byte
+		wantErr bool
+	}{
+		{"round trip", signedHeader(secret, now, body), body, false},
+		{"signed a little earlier", signedHeader(secret, now.Add(-tolerance), body), body, false},
+		{"clock a little ahead", signedHeader(secret, now.Add(tolerance), body), body, false},
+		{"tampered body", signedHeader(secret, now, body), []byte(`{"id":2,"type":"comment.created"}`), true},
+		{"other secret", signedHeader("other", now, body), body, true},
+		{"stale timestamp", signedHeader(secret, now.Add(-tolerance-time.Second), body), body, true},
+		{"future timestamp", signedHeader(secret, now.Add(tolerance+time.Second), body), body, true},
+		{"timestamp moved", func() http.Header {
+			// a fresh timestamp on an old signature
+			header := signedHeader(secret, now.Add(-time.Hour), body)
+			header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+			return header
+		}(), body, true},
+		{"no timestamp", http.Header{SignatureHeader: {Sign(secret, now, body)}}, body, true},
+		{"no signature", http.Header{TimestampHeader: {strconv.FormatInt(now.Unix(), 10)}}, body, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(secret, tt.header, tt.body, tolerance, now)
+			if tt.wantErr && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify err = %v, want ErrInvalidSignature", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestSendSignsRequests(t *testing.T) {
+	const secret = "s3cret"
+	body := []byte(`{"id":7}`)
+
+	var received http.Header
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	status, err := NewClient(time.Second).Send(context.Background(), Request{
+		URL:        server.URL,
+		Secret:     secret,
+		Event:      "comment.created",
+		DeliveryID: "42",
+		Body:       body,
+	})
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v; want 204", status, err)
+	}
+
+	if got := received.Get(EventHeader); got != "comment.created" {
+		t.Errorf("%s = %q, want comment.created", EventHeader, got)
+	}
+	if got := received.Get(DeliveryHeader); got != "42" {
+		t.Errorf("%s = %q, want 42", DeliveryHeader, got)
+	}
+	if err := Verify(secret, received, receivedBody, time.Minute, time.Now()); err != nil {
+		t.Errorf("Verify of the request sent: %v", err)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		http.Error(w, "  out of order  ", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(time.Second)
+	for _, tt := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", http.StatusServiceUnavailable, "out of order"},
+		// redirects are not followed
+		{"/moved", http.StatusFound, ""},
+	} {
+		status, err := client.Send(context.Background(), Request{URL: server.URL + tt.path, Body: []byte("{}")})
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || status != tt.status || statusErr.StatusCode != tt.status {
+			t.Errorf("Send %s = %d, %v; want a %d StatusError", tt.path, status, err, tt.status)
+			continue
+		}
+		if tt.body != "" && statusErr.Body != tt.body {
+			t.Errorf("Send %s error body = %q, want %q", tt.path, statusErr.Body, tt.body)
+		}
+	}
+}